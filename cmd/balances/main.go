@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+
+	"github.com/citizenwallet/engine/internal/config"
+	"github.com/citizenwallet/engine/internal/db"
+	"github.com/citizenwallet/engine/internal/ethrequest"
+	"github.com/citizenwallet/engine/internal/keys"
+	"github.com/citizenwallet/engine/pkg/common"
+)
+
+// usage: go run ./cmd/balances -contract 0x... [rebuild|check]
+func main() {
+	env := flag.String("env", ".env", "path to .env file")
+	contractAddress := flag.String("contract", "", "contract address")
+	flag.Parse()
+
+	cmd := flag.Arg(0)
+	if cmd != "rebuild" && cmd != "check" {
+		log.Fatal("command should be rebuild or check")
+	}
+
+	contract, err := common.NormalizeAddress(*contractAddress)
+	if err != nil {
+		log.Fatal("a valid contract address is required")
+	}
+
+	ctx := context.Background()
+	conf, err := config.New(ctx, *env)
+	if err != nil {
+		log.Fatalf("Error loading configuration: %v", err)
+	}
+
+	evm, err := ethrequest.NewEthService(ctx, conf.RPCURL)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	chid, err := evm.ChainID()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	kc, err := keys.NewCipher(ctx, keys.CipherType(conf.KeyCipher), conf.DBSecret, conf.KMSKeyID)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	d, err := db.NewDB(chid, kc, conf.DBUser, conf.DBPassword, conf.DBName, conf.DBPort, conf.DBHost, conf.DBReaderHost, 0, db.DefaultQueryExecMode, db.DefaultStatementCacheCapacity) // recomputing the balances reads whole tables
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer d.Close()
+
+	switch cmd {
+	case "rebuild":
+		err = d.BalanceDB.Rebuild(contract)
+		if err != nil {
+			log.Fatalf("Error rebuilding balances: %v", err)
+		}
+
+		log.Println("balances rebuilt for", contract)
+	case "check":
+		mismatches, err := d.BalanceDB.Check(contract)
+		if err != nil {
+			log.Fatalf("Error checking balances: %v", err)
+		}
+
+		for _, m := range mismatches {
+			log.Printf("%s: materialized %s, computed %s\n", m.Account, m.Materialized, m.Computed)
+		}
+
+		if len(mismatches) > 0 {
+			log.Fatalf("%d balances do not match, run rebuild to fix them", len(mismatches))
+		}
+
+		log.Println("balances are consistent for", contract)
+	}
+}
@@ -7,12 +7,19 @@ import (
 
 	"github.com/citizenwallet/engine/internal/api"
 	"github.com/citizenwallet/engine/internal/bucket"
+	"github.com/citizenwallet/engine/internal/chain"
 	"github.com/citizenwallet/engine/internal/config"
 	"github.com/citizenwallet/engine/internal/db"
 	"github.com/citizenwallet/engine/internal/ethrequest"
 	"github.com/citizenwallet/engine/internal/indexer"
+	"github.com/citizenwallet/engine/internal/keys"
+	"github.com/citizenwallet/engine/internal/logs"
 	"github.com/citizenwallet/engine/internal/queue"
+	"github.com/citizenwallet/engine/internal/sponsors"
+	"github.com/citizenwallet/engine/internal/tracing"
+	"github.com/citizenwallet/engine/internal/webhook"
 	"github.com/citizenwallet/engine/internal/ws"
+	"github.com/citizenwallet/engine/pkg/engine"
 )
 
 func main() {
@@ -30,6 +37,8 @@ func main() {
 
 	useropqbf := flag.Int("buffer", 1000, "userop queue buffer size (default: 1000)")
 
+	pprof := flag.Bool("pprof", false, "serve runtime profiles on /debug/pprof, requires the admin api key")
+
 	flag.Parse()
 	////////////////////
 
@@ -41,6 +50,33 @@ func main() {
 	if err != nil {
 		log.Fatal(err)
 	}
+
+	entryPoints, err := conf.EntryPoints()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	sponsorThreshold, err := conf.SponsorBalanceThresholdWei()
+	if err != nil {
+		log.Fatal(err)
+	}
+	////////////////////
+
+	////////////////////
+	// tracing
+	shutdownTracing, err := tracing.Setup(ctx, conf.OTLPEndpoint != "", "engine")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer shutdownTracing(ctx)
+	////////////////////
+
+	////////////////////
+	// webhook
+	var w engine.WebhookMessager
+	if conf.WebhookURL != "" {
+		w = webhook.NewMessager(conf.WebhookURL, conf.ChainName)
+	}
 	////////////////////
 
 	////////////////////
@@ -58,6 +94,28 @@ func main() {
 		log.Fatal(err)
 	}
 
+	evm.SetFeeSettings(conf.FeeSettings())
+
+	bs := ethrequest.DefaultBreakerSettings
+	if conf.RPCBreakerThreshold > 0 {
+		bs.Threshold = conf.RPCBreakerThreshold
+	}
+	if conf.RPCBreakerCooldown > 0 {
+		bs.Cooldown = conf.RPCBreakerCooldown
+	}
+	evm.SetBreakerSettings(bs)
+
+	ms := ethrequest.DefaultMonitorSettings
+	if conf.RPCMonitorInterval > 0 {
+		ms.Interval = conf.RPCMonitorInterval
+	}
+	if conf.RPCStaleAfter > 0 {
+		ms.StaleAfter = conf.RPCStaleAfter
+	}
+	if conf.RPCMaxLatency > 0 {
+		ms.MaxLatency = conf.RPCMaxLatency
+	}
+
 	chid, err := evm.ChainID()
 	if err != nil {
 		log.Fatal(err)
@@ -70,11 +128,53 @@ func main() {
 	// db
 	log.Default().Println("starting internal db service...")
 
-	d, err := db.NewDB(chid, conf.DBSecret, conf.DBUser, conf.DBPassword, conf.DBName, conf.DBPort, conf.DBHost, conf.DBReaderHost)
+	kc, err := keys.NewCipher(ctx, keys.CipherType(conf.KeyCipher), conf.DBSecret, conf.KMSKeyID)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	statementTimeout, statsTimeout := db.DefaultStatementTimeout, db.DefaultStatsTimeout
+	if conf.DBStatementTimeout > 0 {
+		statementTimeout = conf.DBStatementTimeout
+	}
+	if conf.DBStatsTimeout > 0 {
+		statsTimeout = conf.DBStatsTimeout
+	}
+
+	execMode, cacheCapacity := db.DefaultQueryExecMode, db.DefaultStatementCacheCapacity
+	if conf.DBQueryExecMode != "" {
+		execMode = conf.DBQueryExecMode
+	}
+	if conf.DBStatementCache > 0 {
+		cacheCapacity = conf.DBStatementCache
+	}
+
+	d, err := db.NewDB(chid, kc, conf.DBUser, conf.DBPassword, conf.DBName, conf.DBPort, conf.DBHost, conf.DBReaderHost, statementTimeout, execMode, cacheCapacity)
 	if err != nil {
 		log.Fatal(err)
 	}
 	defer d.Close()
+
+	d.LogDB.SetStatsTimeout(statsTimeout)
+	////////////////////
+
+	////////////////////
+	// events manifest
+	if conf.EventsManifest != "" {
+		evs, err := config.LoadEventManifest(conf.EventsManifest)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		for _, ev := range evs {
+			err = d.EventDB.AddEvent(ctx, ev)
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
+
+		log.Default().Printf("added %d events from manifest %s\n", len(evs), conf.EventsManifest)
+	}
 	////////////////////
 
 	////////////////////
@@ -86,6 +186,21 @@ func main() {
 	////////////////////
 	// pools
 	pools := ws.NewConnectionPools()
+	pools.SetCompression(conf.WSCompression)
+
+	err = pools.SetOutbox(d.OutboxDB)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	outboxRetention := ws.DefaultOutboxRetention
+	if conf.WSOutboxRetention > 0 {
+		outboxRetention = conf.WSOutboxRetention
+	}
+
+	go func() {
+		quitAck <- pools.PruneOutbox(ctx, outboxRetention)
+	}()
 	////////////////////
 
 	////////////////////
@@ -111,13 +226,46 @@ func main() {
 
 	////////////////////
 	// indexer
+	var idx *indexer.Indexer
 	if !*noindex {
 		log.Default().Println("starting indexer service...")
 
-		idx := indexer.NewIndexer(ctx, d, evm, pools)
+		idx = indexer.NewIndexer(ctx, d, evm, pools, w)
+		if conf.IndexerConfirmationDepth > 0 {
+			idx.SetConfirmationDepth(conf.IndexerConfirmationDepth)
+		}
+
+		if conf.IndexerFinality > 0 {
+			idx.SetFinality(conf.IndexerFinality)
+		}
+
+		maxFailures := webhook.DefaultMaxFailures
+		if conf.LogWebhookMaxFailures > 0 {
+			maxFailures = conf.LogWebhookMaxFailures
+		}
+
+		dispatcher := webhook.NewDispatcher(ctx, d.LogWebhookDB, maxFailures)
+		idx.SetDispatcher(dispatcher)
+
+		go func() {
+			quitAck <- dispatcher.Start()
+		}()
+
 		go func() {
 			quitAck <- idx.Start()
 		}()
+
+		reconcileWindow, reconcileInterval := uint64(indexer.DefaultReconcileWindow), indexer.DefaultReconcileInterval
+		if conf.IndexerReconcileWindow > 0 {
+			reconcileWindow = conf.IndexerReconcileWindow
+		}
+		if conf.IndexerReconcileInterval > 0 {
+			reconcileInterval = conf.IndexerReconcileInterval
+		}
+
+		go func() {
+			quitAck <- idx.Reconcile(reconcileWindow, reconcileInterval)
+		}()
 	}
 	////////////////////
 
@@ -125,11 +273,27 @@ func main() {
 	// userop queue
 	log.Default().Println("starting userop queue service...")
 
-	op := queue.NewUserOpService(d, evm, pushqueue, pools)
+	op := queue.NewUserOpService(d, evm, pushqueue, pools, entryPoints, conf.SponsorStrategy)
+	if conf.UserOpMaxBatchGas > 0 {
+		op.SetMaxBatchGas(conf.UserOpMaxBatchGas)
+	}
+
+	if conf.UserOpMaxInFlight > 0 {
+		op.SetMaxInFlightPerSender(conf.UserOpMaxInFlight)
+	}
 
 	useropq, qerr := queue.NewService("userop", 3, *useropqbf, ctx)
 	defer useropq.Close()
 
+	minWait, maxWait := queue.DefaultBatchMinWait, queue.DefaultBatchMaxWait
+	if conf.UserOpBatchMinWait > 0 {
+		minWait = conf.UserOpBatchMinWait
+	}
+	if conf.UserOpBatchMaxWait > 0 {
+		maxWait = conf.UserOpBatchMaxWait
+	}
+	useropq.SetBatchWindow(minWait, maxWait)
+
 	go func() {
 		for err := range qerr {
 			// TODO: handle errors coming from the queue
@@ -140,11 +304,95 @@ func main() {
 	go func() {
 		quitAck <- useropq.Start(op)
 	}()
+
+	inProgressTTL := queue.DefaultInProgressTTL
+	if conf.UserOpInProgressTTL > 0 {
+		inProgressTTL = conf.UserOpInProgressTTL
+	}
+
+	go func() {
+		quitAck <- op.Janitor(ctx, inProgressTTL)
+	}()
+	////////////////////
+
+	////////////////////
+	// rpc monitor
+	log.Default().Println("starting rpc monitor...")
+
+	go func() {
+		quitAck <- evm.Monitor(ms)
+	}()
+	////////////////////
+
+	////////////////////
+	// sponsor monitor
+	log.Default().Println("starting sponsor monitor...")
+
+	sm := sponsors.NewMonitor(ctx, d, evm, w, sponsorThreshold, conf.SponsorCheckInterval)
+	go func() {
+		quitAck <- sm.Start()
+	}()
 	////////////////////
 
 	////////////////////
 	// api
-	s := api.NewServer(chid, d, evm, useropq, pools)
+	rc := chain.NewCache(conf.RPCCacheTTLs)
+
+	sp := api.SignaturePolicy{
+		MaxValidity:  conf.SignatureMaxValidity,
+		RequireNonce: conf.SignatureRequireNonce,
+	}
+
+	cp := api.DefaultCORSPolicy
+	if len(conf.CORSAllowedOrigins) > 0 {
+		cp.AllowedOrigins = conf.CORSAllowedOrigins
+	}
+	cp.AllowedMethods = conf.CORSAllowedMethods
+	cp.AllowedHeaders = conf.CORSAllowedHeaders
+	cp.AllowCredentials = conf.CORSAllowCredentials
+	cp.MaxAge = conf.CORSMaxAge
+
+	tp := api.DefaultTimeoutPolicy
+	if conf.RequestTimeout > 0 {
+		tp.Default = conf.RequestTimeout
+	}
+	tp.Routes = conf.RequestTimeouts
+
+	pollTimeout := logs.DefaultPollTimeout
+	if conf.PollTimeout > 0 {
+		pollTimeout = conf.PollTimeout
+	}
+
+	cmp := api.DefaultCompressionPolicy
+	if conf.CompressionLevel > 0 {
+		cmp.Level = conf.CompressionLevel
+	}
+	if conf.CompressionMinSize > 0 {
+		cmp.MinSize = conf.CompressionMinSize
+	}
+
+	cnp := api.DefaultConnectionPolicy
+	if conf.HTTPReadHeaderTimeout > 0 {
+		cnp.ReadHeaderTimeout = conf.HTTPReadHeaderTimeout
+	}
+	if conf.HTTPReadTimeout > 0 {
+		cnp.ReadTimeout = conf.HTTPReadTimeout
+	}
+	if conf.HTTPWriteTimeout > 0 {
+		cnp.WriteTimeout = conf.HTTPWriteTimeout
+	}
+	if conf.HTTPIdleTimeout > 0 {
+		cnp.IdleTimeout = conf.HTTPIdleTimeout
+	}
+	if conf.HTTPMaxHeaderBytes > 0 {
+		cnp.MaxHeaderBytes = conf.HTTPMaxHeaderBytes
+	}
+	cnp.TLSCertFile = conf.TLSCertFile
+	cnp.TLSKeyFile = conf.TLSKeyFile
+	cnp.AutocertDomains = conf.AutocertDomains
+	cnp.AutocertCacheDir = conf.AutocertCacheDir
+
+	s := api.NewServer(chid, d, evm, useropq, op, entryPoints, pools, rc, sp, cp, tp, pollTimeout, cmp, cnp, w, sm, idx, *pprof, conf.AdminAPIKey)
 
 	bu := bucket.NewBucket(conf.PinataBaseURL, conf.PinataAPIKey, conf.PinataAPISecret)
 
@@ -14,26 +14,32 @@ import (
 	"github.com/citizenwallet/engine/internal/config"
 	"github.com/citizenwallet/engine/internal/db"
 	"github.com/citizenwallet/engine/internal/ethrequest"
+	"github.com/citizenwallet/engine/internal/keys"
 	"github.com/citizenwallet/engine/pkg/engine"
 	_ "github.com/mattn/go-sqlite3"
 )
 
 const (
-	transferTopic   = "0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"
-	batchSize       = 1000
-	migrationSuffix = "migration"
+	transferTopic    = "0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"
+	defaultBatchSize = 1000
+	migrationSuffix  = "migration"
 )
 
 func main() {
 	// Parse command-line flags
 	env := flag.String("env", ".env", "path to .env file")
 	contractAddress := flag.String("contract", "", "contract address")
+	batchSize := flag.Int("batch", defaultBatchSize, "transfers migrated per batch, larger is faster and uses more memory")
 	flag.Parse()
 
 	if *contractAddress == "" {
 		log.Fatal("contract address is required")
 	}
 
+	if *batchSize <= 0 {
+		log.Fatal("batch size must be positive")
+	}
+
 	// Load configuration from .env file
 	ctx := context.Background()
 	conf, err := config.New(ctx, *env)
@@ -59,26 +65,47 @@ func main() {
 		log.Fatal(err)
 	}
 
-	d, err := db.NewDB(chid, conf.DBSecret, conf.DBUser, conf.DBPassword, conf.DBName, conf.DBPort,
-		"0.0.0.0", "0.0.0.0")
+	kc, err := keys.NewCipher(ctx, keys.CipherType(conf.KeyCipher), conf.DBSecret, conf.KMSKeyID)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	d, err := db.NewDB(chid, kc, conf.DBUser, conf.DBPassword, conf.DBName, conf.DBPort,
+		"0.0.0.0", "0.0.0.0", 0, db.DefaultQueryExecMode, db.DefaultStatementCacheCapacity)
 	if err != nil {
 		log.Fatal(err)
 	}
 	defer d.Close()
 
 	// Perform migration
-	err = migrateData(sqliteDB, d.LogDB, chid, *contractAddress)
+	err = migrateData(ctx, sqliteDB, d.LogDB, chid, *contractAddress, *batchSize)
 	if err != nil {
 		log.Fatalf("Error during migration: %v", err)
 	}
 
+	// migrated logs are not added to the balances, recompute them
+	err = d.BalanceDB.Rebuild(*contractAddress)
+	if err != nil {
+		log.Fatalf("Error rebuilding balances: %v", err)
+	}
+
 	log.Println("Migration completed successfully")
 }
 
-func migrateData(sqliteDB *sql.DB, logDB *db.LogDB, chid *big.Int, contractAddress string) error {
+func migrateData(ctx context.Context, sqliteDB *sql.DB, logDB *db.LogDB, chid *big.Int, contractAddress string, batchSize int) error {
+	suffix := fmt.Sprintf("%s_%s", chid.String(), contractAddress)
+
+	total, err := countTransfers(sqliteDB, suffix)
+	if err != nil {
+		return fmt.Errorf("error counting transfers: %v", err)
+	}
+
+	log.Printf("Migrating %d transfers in batches of %d", total, batchSize)
+
+	start := time.Now()
 	offset := 0
 	for {
-		transfers, err := getTransfers(sqliteDB, offset, batchSize, fmt.Sprintf("%s_%s", chid.String(), contractAddress))
+		transfers, err := getTransfers(sqliteDB, offset, batchSize, suffix)
 		if err != nil {
 			return fmt.Errorf("error getting transfers: %v", err)
 		}
@@ -87,20 +114,44 @@ func migrateData(sqliteDB *sql.DB, logDB *db.LogDB, chid *big.Int, contractAddre
 			break
 		}
 
-		logs := convertTransfersToLogs(transfers, contractAddress)
+		logs, err := convertTransfersToLogs(transfers, contractAddress)
+		if err != nil {
+			return fmt.Errorf("error converting transfers: %v", err)
+		}
 
-		err = logDB.AddLogs(logs)
+		err = logDB.AddLogs(ctx, logs)
 		if err != nil {
 			return fmt.Errorf("error adding logs: %v", err)
 		}
 
 		offset += len(transfers)
-		log.Printf("Migrated %d transfers", offset)
+		log.Println(progress(offset, total, time.Since(start)))
 	}
 
 	return nil
 }
 
+// progress describes how far the migration is, its rate and how long the rest should take at that rate
+func progress(done, total int, elapsed time.Duration) string {
+	rate := float64(done) / elapsed.Seconds()
+
+	if total <= 0 || done >= total || rate <= 0 {
+		return fmt.Sprintf("Migrated %d transfers (%.0f/s)", done, rate)
+	}
+
+	eta := time.Duration(float64(total-done) / rate * float64(time.Second))
+
+	return fmt.Sprintf("Migrated %d/%d transfers, %.1f%% (%.0f/s, eta %s)", done, total, float64(done)*100/float64(total), rate, eta.Round(time.Second))
+}
+
+// countTransfers returns the number of transfers to migrate, it is only used to report progress
+func countTransfers(db *sql.DB, suffix string) (int, error) {
+	var count int
+	err := db.QueryRow(fmt.Sprintf(`SELECT COUNT(*) FROM t_transfers_%s`, suffix)).Scan(&count)
+
+	return count, err
+}
+
 func getTransfers(db *sql.DB, offset, limit int, suffix string) ([]*mtransfer.Transfer, error) {
 	query := fmt.Sprintf(`
 		SELECT hash, tx_hash, token_id, created_at, from_addr, to_addr, nonce, value, data, status
@@ -123,15 +174,19 @@ func getTransfers(db *sql.DB, offset, limit int, suffix string) ([]*mtransfer.Tr
 		if err != nil {
 			return nil, err
 		}
-		t.Value = new(big.Int)
-		t.Value.SetString(valueStr, 10)
+		value, ok := new(big.Int).SetString(valueStr, 10)
+		if !ok {
+			return nil, fmt.Errorf("invalid value %q of transfer %s", valueStr, t.Hash)
+		}
+		t.Value = value
 		transfers = append(transfers, &t)
 	}
 
 	return transfers, nil
 }
 
-func convertTransfersToLogs(transfers []*mtransfer.Transfer, contractAddress string) []*engine.Log {
+// convertTransfersToLogs converts transfers to logs, a transfer that doesn't convert to valid transfer data is an error
+func convertTransfersToLogs(transfers []*mtransfer.Transfer, contractAddress string) ([]*engine.Log, error) {
 	var logs []*engine.Log
 	for _, t := range transfers {
 		data := map[string]interface{}{
@@ -166,7 +221,13 @@ func convertTransfersToLogs(transfers []*mtransfer.Transfer, contractAddress str
 			ExtraData: &extraDataRaw,
 			Status:    engine.LogStatusSuccess,
 		}
+
+		_, err = log.ParseTransferData()
+		if err != nil {
+			return nil, fmt.Errorf("transfer %s: %w", t.Hash, err)
+		}
+
 		logs = append(logs, log)
 	}
-	return logs
+	return logs, nil
 }
@@ -7,8 +7,6 @@ import (
 	"github.com/citizenwallet/engine/internal/db"
 	com "github.com/citizenwallet/engine/pkg/common"
 	"github.com/citizenwallet/engine/pkg/engine"
-
-	"github.com/ethereum/go-ethereum/common"
 	"github.com/go-chi/chi/v5"
 )
 
@@ -29,23 +27,27 @@ func NewService(evm engine.EVMRequester, db *db.DB) *Service {
 func (s *Service) Exists(w http.ResponseWriter, r *http.Request) {
 	accaddr := chi.URLParam(r, "acc_addr")
 
-	acc := common.HexToAddress(accaddr)
+	acc, err := com.ParseAddress(accaddr)
+	if err != nil {
+		com.WriteError(w, http.StatusBadRequest, "invalid account address")
+		return
+	}
 
 	// Get the contract's bytecode
 	bytecode, err := s.evm.CodeAt(context.Background(), acc, nil)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		com.WriteError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
 	// Check if the account contract is already deployed
 	if len(bytecode) == 0 {
-		http.Error(w, "account contract does not exist", http.StatusNotFound)
+		com.WriteError(w, http.StatusNotFound, "account contract does not exist")
 		return
 	}
 
 	err = com.Body(w, nil, nil)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		com.WriteError(w, http.StatusInternalServerError, "error writing response")
 	}
 }
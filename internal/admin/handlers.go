@@ -0,0 +1,478 @@
+package admin
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/citizenwallet/engine/internal/db"
+	"github.com/citizenwallet/engine/internal/indexer"
+	"github.com/citizenwallet/engine/internal/queue"
+	"github.com/citizenwallet/engine/internal/sponsors"
+	"github.com/citizenwallet/engine/internal/ws"
+	com "github.com/citizenwallet/engine/pkg/common"
+	"github.com/citizenwallet/engine/pkg/engine"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+)
+
+type Service struct {
+	db       *db.DB
+	pools    *ws.ConnectionPools
+	sponsors *sponsors.Monitor
+	userOps  *queue.UserOpService
+	indexer  *indexer.Indexer // nil when the engine doesn't index
+}
+
+func NewService(db *db.DB, pools *ws.ConnectionPools, sponsors *sponsors.Monitor, userOps *queue.UserOpService, indexer *indexer.Indexer) *Service {
+	return &Service{
+		db:       db,
+		pools:    pools,
+		sponsors: sponsors,
+		userOps:  userOps,
+		indexer:  indexer,
+	}
+}
+
+type addSponsorRequest struct {
+	Paymaster  string `json:"paymaster"`
+	PrivateKey string `json:"private_key"` // optional, a new key is generated when empty
+}
+
+type sponsorResponse struct {
+	Paymaster string `json:"paymaster"`
+	Address   string `json:"address"` // the account that needs to be funded
+}
+
+// WSStats returns the connection counts and send buffer occupancy of the websocket pools
+func (s *Service) WSStats(w http.ResponseWriter, r *http.Request) {
+	err := com.Body(w, s.pools.Stats(), nil)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// SponsorBalances returns the last checked balances of the sponsor accounts
+func (s *Service) SponsorBalances(w http.ResponseWriter, r *http.Request) {
+	err := com.BodyMultiple(w, s.sponsors.Balances(), nil)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// InProgress returns the number of sent transactions waiting to be mined per entrypoint
+func (s *Service) InProgress(w http.ResponseWriter, r *http.Request) {
+	err := com.Body(w, s.userOps.InProgress(), nil)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// AddSponsor stores the sponsor key of a paymaster and returns the address of the sponsor account to fund
+func (s *Service) AddSponsor(w http.ResponseWriter, r *http.Request) {
+	var req addSponsorRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	paymaster, err := com.NormalizeAddress(req.Paymaster)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	pk := strings.TrimPrefix(req.PrivateKey, "0x")
+	if pk == "" {
+		pk, _, err = engine.GenerateHexPrivateKey()
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+
+	key, err := com.HexToPrivateKey(pk)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	// a paymaster has a single main sponsor, it has to be removed before it can be replaced
+	_, err = s.db.SponsorDB.GetSponsor(paymaster)
+	if err == nil {
+		w.WriteHeader(http.StatusConflict)
+		return
+	}
+
+	if !errors.Is(err, pgx.ErrNoRows) {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now().UTC()
+	err = s.db.SponsorDB.AddSponsor(&engine.Sponsor{
+		Contract:   paymaster,
+		PrivateKey: pk,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	})
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	err = com.Body(w, &sponsorResponse{
+		Paymaster: paymaster,
+		Address:   crypto.PubkeyToAddress(key.PublicKey).Hex(),
+	}, nil)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// RemoveSponsor removes the sponsor keys of a paymaster
+func (s *Service) RemoveSponsor(w http.ResponseWriter, r *http.Request) {
+	paymaster, err := com.NormalizeAddress(chi.URLParam(r, "paymaster"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	removed, err := s.db.SponsorDB.RemoveSponsor(paymaster)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if !removed {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// ContractMetadata returns how a contract is presented in notifications
+func (s *Service) ContractMetadata(w http.ResponseWriter, r *http.Request) {
+	contract, err := com.NormalizeAddress(chi.URLParam(r, "contract_address"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	meta, err := s.db.EventDB.GetContractMetadata(r.Context(), contract)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			http.Error(w, "contract is not indexed", http.StatusNotFound)
+			return
+		}
+
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	err = com.Body(w, meta, nil)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+type setContractMetadataRequest struct {
+	Community string `json:"community"`
+	Icon      string `json:"icon"` // optional
+}
+
+// SetContractMetadata sets the community and icon that brand the notifications of a contract, the name, symbol and
+// decimals are the ones of its events
+func (s *Service) SetContractMetadata(w http.ResponseWriter, r *http.Request) {
+	contract, err := com.NormalizeAddress(chi.URLParam(r, "contract_address"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	var req setContractMetadataRequest
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	community := strings.TrimSpace(req.Community)
+	if community == "" {
+		http.Error(w, "community is required", http.StatusBadRequest)
+		return
+	}
+
+	icon := ""
+	if req.Icon != "" {
+		u, err := url.Parse(req.Icon)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "ipfs") || u.Host == "" {
+			http.Error(w, "icon must be an https or ipfs url", http.StatusBadRequest)
+			return
+		}
+
+		icon = u.String()
+	}
+
+	err = s.db.EventDB.SetContractDisplay(r.Context(), contract, community, icon)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			http.Error(w, "contract is not indexed", http.StatusNotFound)
+			return
+		}
+
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// Reindex removes the logs of a contract from the block of the fromBlock query param onward and indexes them again,
+// the job runs in the background and its progress is returned by ReindexProgress
+func (s *Service) Reindex(w http.ResponseWriter, r *http.Request) {
+	if s.indexer == nil {
+		http.Error(w, "indexing is disabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	contract, err := com.NormalizeAddress(chi.URLParam(r, "contract"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	fromBlock, err := strconv.ParseInt(r.URL.Query().Get("fromBlock"), 10, 64)
+	if err != nil {
+		http.Error(w, "fromBlock must be a block number", http.StatusBadRequest)
+		return
+	}
+
+	job, err := s.indexer.Reindex(contract, fromBlock)
+	if err != nil {
+		switch {
+		case errors.Is(err, indexer.ErrReindexFromBlock):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, indexer.ErrReindexNotIndexed):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case errors.Is(err, indexer.ErrReindexRunning):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+
+	err = com.Body(w, job, nil)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// ReindexProgress returns the progress of the last re-indexing of a contract
+func (s *Service) ReindexProgress(w http.ResponseWriter, r *http.Request) {
+	if s.indexer == nil {
+		http.Error(w, "indexing is disabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	contract, err := com.NormalizeAddress(chi.URLParam(r, "contract"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	job := s.indexer.ReindexProgress(contract)
+	if job == nil {
+		http.Error(w, "contract was not re-indexed", http.StatusNotFound)
+		return
+	}
+
+	err = com.Body(w, job, nil)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+type addLogWebhookRequest struct {
+	URL      string `json:"url"`
+	Contract string `json:"contract"`
+	Topic    string `json:"topic"`
+	Address  string `json:"address"` // optional
+}
+
+// AddLogWebhook subscribes a callback url to the indexed logs of a contract and topic, the response carries the secret
+// that signs the deliveries, it is not returned again
+func (s *Service) AddLogWebhook(w http.ResponseWriter, r *http.Request) {
+	var req addLogWebhookRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	u, err := url.Parse(req.URL)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		http.Error(w, "url must be https", http.StatusBadRequest)
+		return
+	}
+
+	contract, err := com.NormalizeAddress(req.Contract)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	topic := common.HexToHash(req.Topic)
+	if !strings.EqualFold(topic.Hex(), req.Topic) {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	address := ""
+	if req.Address != "" {
+		address, err = com.NormalizeAddress(req.Address)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+
+	exists, err := s.db.EventDB.EventExists(r.Context(), contract)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if !exists {
+		http.Error(w, "contract is not indexed", http.StatusNotFound)
+		return
+	}
+
+	id, err := randomHex(16)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	secret, err := randomHex(32)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now().UTC()
+	hook := &engine.LogWebhook{
+		ID:        id,
+		URL:       u.String(),
+		Secret:    secret,
+		Contract:  contract,
+		Topic:     topic.Hex(),
+		Address:   address,
+		Status:    engine.LogWebhookStatusActive,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	err = s.db.LogWebhookDB.AddLogWebhook(hook)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	err = com.Body(w, hook, nil)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// LogWebhooks returns the log webhooks with the status of their deliveries
+func (s *Service) LogWebhooks(w http.ResponseWriter, r *http.Request) {
+	hooks, err := s.db.LogWebhookDB.GetLogWebhooks()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	err = com.BodyMultiple(w, hooks, nil)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// RemoveLogWebhook removes a log webhook
+func (s *Service) RemoveLogWebhook(w http.ResponseWriter, r *http.Request) {
+	removed, err := s.db.LogWebhookDB.RemoveLogWebhook(chi.URLParam(r, "id"))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if !removed {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+type logDataResponse struct {
+	Contract       string             `json:"contract"`
+	EventSignature string             `json:"event_signature"`
+	Schema         []engine.DataField `json:"schema"`
+}
+
+// ValidateLogData checks the data of a user operation against the indexed events, the data of an operation is only
+// tracked as a log when one of them accepts it, the response says which one or why the data was rejected
+func (s *Service) ValidateLogData(w http.ResponseWriter, r *http.Request) {
+	var data map[string]any
+	err := json.NewDecoder(r.Body).Decode(&data)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	events, err := s.db.EventDB.GetEvents(r.Context())
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	ev, err := engine.MatchEventData(events, data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	err = com.Body(w, logDataResponse{
+		Contract:       ev.Contract,
+		EventSignature: ev.EventSignature,
+		Schema:         ev.DataSchema(),
+	}, nil)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
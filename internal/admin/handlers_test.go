@@ -0,0 +1,98 @@
+package admin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/citizenwallet/engine/internal/indexer"
+	"github.com/go-chi/chi/v5"
+)
+
+func TestAddSponsor_InvalidRequests(t *testing.T) {
+	s := NewService(nil, nil, nil, nil, nil)
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"not json", "paymaster"},
+		{"missing paymaster", `{}`},
+		{"invalid paymaster", `{"paymaster": "0x123"}`},
+		{"invalid private key", `{"paymaster": "0x7079253c0358eF9Fd87E16488299Ef6e06F403B6", "private_key": "0xnothex"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			s.AddSponsor(w, httptest.NewRequest(http.MethodPost, "/admin/sponsors", strings.NewReader(tt.body)))
+
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("expected %d, got %d", http.StatusBadRequest, w.Code)
+			}
+		})
+	}
+}
+
+func TestSetContractMetadata_InvalidRequests(t *testing.T) {
+	s := NewService(nil, nil, nil, nil, nil)
+
+	r := chi.NewRouter()
+	r.Put("/admin/contracts/{contract_address}/metadata", s.SetContractMetadata)
+
+	tests := []struct {
+		name     string
+		contract string
+		body     string
+	}{
+		{"invalid contract", "0x123", `{"community": "Bread"}`},
+		{"not json", "0x5815E61eF72c9E6107b5c5A05FD121F334f7a7f1", "Bread"},
+		{"missing community", "0x5815E61eF72c9E6107b5c5A05FD121F334f7a7f1", `{"icon": "https://example.com/bread.png"}`},
+		{"http icon", "0x5815E61eF72c9E6107b5c5A05FD121F334f7a7f1", `{"community": "Bread", "icon": "http://example.com/bread.png"}`},
+		{"relative icon", "0x5815E61eF72c9E6107b5c5A05FD121F334f7a7f1", `{"community": "Bread", "icon": "bread.png"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/admin/contracts/"+tt.contract+"/metadata", strings.NewReader(tt.body)))
+
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("expected %d, got %d", http.StatusBadRequest, w.Code)
+			}
+		})
+	}
+}
+
+func TestReindex_InvalidRequests(t *testing.T) {
+	s := NewService(nil, nil, nil, nil, nil)
+
+	r := chi.NewRouter()
+	r.Post("/admin/events/{contract}/reindex", s.Reindex)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/admin/events/0x5815E61eF72c9E6107b5c5A05FD121F334f7a7f1/reindex?fromBlock=1", nil))
+
+	// the engine doesn't index without an indexer
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected %d, got %d", http.StatusServiceUnavailable, w.Code)
+	}
+
+	s = NewService(nil, nil, nil, nil, &indexer.Indexer{})
+	r = chi.NewRouter()
+	r.Post("/admin/events/{contract}/reindex", s.Reindex)
+
+	for _, path := range []string{
+		"/admin/events/0x123/reindex?fromBlock=1",
+		"/admin/events/0x5815E61eF72c9E6107b5c5A05FD121F334f7a7f1/reindex",
+		"/admin/events/0x5815E61eF72c9E6107b5c5A05FD121F334f7a7f1/reindex?fromBlock=latest",
+	} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, path, nil))
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected %d, got %d", path, http.StatusBadRequest, w.Code)
+		}
+	}
+}
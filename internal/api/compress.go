@@ -0,0 +1,237 @@
+package api
+
+import (
+	"compress/gzip"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultCompressionLevel compresses json about as well as the maximum level for a fraction of the cpu
+const DefaultCompressionLevel = 5
+
+// CompressionPolicy controls how responses are gzip compressed
+type CompressionPolicy struct {
+	// Level is the gzip level from 1 (fastest) to 9 (smallest), invalid levels use DefaultCompressionLevel
+	Level int
+	// MinSize is the size in bytes below which a response is sent as is, compressing it costs more than it saves
+	MinSize int
+}
+
+// DefaultCompressionPolicy balances cpu against size and leaves small json responses alone
+var DefaultCompressionPolicy = CompressionPolicy{
+	Level:   DefaultCompressionLevel,
+	MinSize: 1024,
+}
+
+// compressible returns true for the content types that benefit from compression,
+// images and binary files (ex: pinned profile images) are already compressed and event streams are flushed per event
+func compressible(contentType string) bool {
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	switch {
+	case mt == "text/event-stream":
+		return false
+	case strings.HasPrefix(mt, "text/"):
+		return true
+	case mt == "application/json", strings.HasSuffix(mt, "+json"):
+		return true
+	case mt == "application/javascript", mt == "application/xml", strings.HasSuffix(mt, "+xml"):
+		return true
+	}
+
+	return false
+}
+
+// acceptsGzip returns true if the client accepts a gzip encoded response
+func acceptsGzip(r *http.Request) bool {
+	for _, enc := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		name, params, _ := strings.Cut(enc, ";")
+
+		name = strings.TrimSpace(name)
+		if !strings.EqualFold(name, "gzip") && name != "*" {
+			continue
+		}
+
+		// gzip;q=0 refuses the encoding
+		params = strings.TrimSpace(params)
+		if q, ok := strings.CutPrefix(params, "q="); ok {
+			v, err := strconv.ParseFloat(q, 64)
+			return err == nil && v > 0
+		}
+
+		return true
+	}
+
+	return false
+}
+
+// CompressMiddleware gzip compresses the responses that are large enough and of a compressible content type
+//
+// websocket upgrades and responses that already have a content encoding are passed through
+func CompressMiddleware(p CompressionPolicy) func(http.Handler) http.Handler {
+	level := p.Level
+	if level < gzip.BestSpeed || level > gzip.BestCompression {
+		level = DefaultCompressionLevel
+	}
+
+	pool := &sync.Pool{
+		New: func() any {
+			gz, _ := gzip.NewWriterLevel(nil, level)
+			return gz
+		},
+	}
+
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+				h.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Add("Vary", "Accept-Encoding")
+
+			if !acceptsGzip(r) {
+				h.ServeHTTP(w, r)
+				return
+			}
+
+			cw := &compressWriter{ResponseWriter: w, minSize: p.MinSize, pool: pool}
+
+			// not deferred, a panic is left for the recover middleware to respond to
+			h.ServeHTTP(cw, r)
+			cw.Close()
+		})
+	}
+}
+
+// compressWriter buffers the start of a response until it knows whether it is worth compressing
+type compressWriter struct {
+	http.ResponseWriter
+
+	minSize int
+	pool    *sync.Pool
+
+	status  int
+	buf     []byte
+	decided bool
+	gz      *gzip.Writer
+}
+
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+func (cw *compressWriter) WriteHeader(status int) {
+	if cw.decided {
+		cw.ResponseWriter.WriteHeader(status)
+		return
+	}
+
+	if cw.status != 0 {
+		return
+	}
+
+	cw.status = status
+
+	// responses without a body are never compressed
+	if status < http.StatusOK || status == http.StatusNoContent || status == http.StatusNotModified {
+		cw.decide(false)
+	}
+}
+
+func (cw *compressWriter) Write(b []byte) (int, error) {
+	if cw.decided {
+		if cw.gz != nil {
+			return cw.gz.Write(b)
+		}
+
+		return cw.ResponseWriter.Write(b)
+	}
+
+	cw.buf = append(cw.buf, b...)
+
+	if cw.Header().Get("Content-Encoding") != "" {
+		cw.decide(false)
+	} else if len(cw.buf) >= cw.minSize {
+		cw.decide(cw.compressible())
+	}
+
+	return len(b), nil
+}
+
+func (cw *compressWriter) compressible() bool {
+	ct := cw.Header().Get("Content-Type")
+	if ct == "" {
+		ct = http.DetectContentType(cw.buf)
+	}
+
+	return compressible(ct)
+}
+
+// decide writes the header and the buffered start of the response, compressed or not
+func (cw *compressWriter) decide(compress bool) {
+	cw.decided = true
+
+	if compress {
+		cw.Header().Del("Content-Length")
+		cw.Header().Set("Content-Encoding", "gzip")
+
+		cw.gz = cw.pool.Get().(*gzip.Writer)
+		cw.gz.Reset(cw.ResponseWriter)
+	}
+
+	if cw.status != 0 {
+		cw.ResponseWriter.WriteHeader(cw.status)
+	}
+
+	if len(cw.buf) == 0 {
+		return
+	}
+
+	buf := cw.buf
+	cw.buf = nil
+
+	if cw.gz != nil {
+		cw.gz.Write(buf)
+		return
+	}
+
+	cw.ResponseWriter.Write(buf)
+}
+
+// Flush sends what was written so far, a response that is flushed before it reaches the minimum size is streamed
+func (cw *compressWriter) Flush() {
+	if !cw.decided {
+		cw.decide(len(cw.buf) > 0 && cw.compressible())
+	}
+
+	if cw.gz != nil {
+		cw.gz.Flush()
+	}
+
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Close sends a response that stayed below the minimum size as is and finishes a compressed one
+func (cw *compressWriter) Close() {
+	if !cw.decided {
+		cw.decide(false)
+	}
+
+	if cw.gz == nil {
+		return
+	}
+
+	cw.gz.Close()
+	cw.gz.Reset(nil)
+	cw.pool.Put(cw.gz)
+	cw.gz = nil
+}
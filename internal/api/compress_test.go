@@ -0,0 +1,101 @@
+package api
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestCompressMiddleware(t *testing.T) {
+	large := `{"data":"` + strings.Repeat("a", 2048) + `"}`
+
+	cr := chi.NewRouter()
+	cr.Use(CompressMiddleware(CompressionPolicy{Level: 5, MinSize: 1024}))
+
+	cr.Get("/large", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		// written in pieces smaller than the minimum size
+		w.Write([]byte(large[:512]))
+		w.Write([]byte(large[512:]))
+	})
+	cr.Get("/small", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":"a"}`))
+	})
+	cr.Get("/image", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte(strings.Repeat("a", 2048)))
+	})
+	cr.Get("/encoded", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Encoding", "br")
+		w.Write([]byte(large))
+	})
+	cr.Get("/empty", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	tests := []struct {
+		name           string
+		path           string
+		acceptEncoding string
+		upgrade        string
+		status         int
+		encoding       string
+		body           string
+	}{
+		{"large", "/large", "gzip, deflate", "", http.StatusCreated, "gzip", large},
+		{"large without gzip", "/large", "deflate", "", http.StatusCreated, "", large},
+		{"large refusing gzip", "/large", "gzip;q=0, deflate", "", http.StatusCreated, "", large},
+		{"small", "/small", "gzip", "", http.StatusOK, "", `{"data":"a"}`},
+		{"image", "/image", "gzip", "", http.StatusOK, "", strings.Repeat("a", 2048)},
+		{"already encoded", "/encoded", "gzip", "", http.StatusOK, "br", large},
+		{"no content", "/empty", "gzip", "", http.StatusNoContent, "", ""},
+		{"websocket upgrade", "/large", "gzip", "websocket", http.StatusCreated, "", large},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			if tt.upgrade != "" {
+				req.Header.Set("Upgrade", tt.upgrade)
+			}
+
+			rec := httptest.NewRecorder()
+			cr.ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.status)
+			}
+
+			if got := rec.Header().Get("Content-Encoding"); got != tt.encoding {
+				t.Fatalf("content encoding = %q, want %q", got, tt.encoding)
+			}
+
+			var body io.Reader = rec.Body
+			if tt.encoding == "gzip" {
+				gz, err := gzip.NewReader(rec.Body)
+				if err != nil {
+					t.Fatal(err)
+				}
+				body = gz
+			}
+
+			b, err := io.ReadAll(body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(b) != tt.body {
+				t.Fatalf("body = %q, want %q", b, tt.body)
+			}
+		})
+	}
+}
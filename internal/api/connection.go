@@ -0,0 +1,98 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"golang.org/x/crypto/acme/autocert"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// ConnectionPolicy bounds how long a client can hold a connection and how much it can send in headers
+//
+// the timeouts apply to every request, TimeoutMiddleware lifts them for the long lived routes (ex: event streams)
+type ConnectionPolicy struct {
+	// ReadHeaderTimeout is how long a client has to send the headers of a request, it stops slow-loris clients
+	ReadHeaderTimeout time.Duration
+	// ReadTimeout is how long a client has to send a whole request, body included
+	ReadTimeout time.Duration
+	// WriteTimeout is how long a response can take to be written, it should be longer than the request timeout
+	WriteTimeout time.Duration
+	// IdleTimeout is how long a keep-alive connection is kept open between requests
+	IdleTimeout time.Duration
+	// MaxHeaderBytes is the largest size of the headers of a request
+	MaxHeaderBytes int
+
+	// TLSCertFile and TLSKeyFile terminate TLS with a certificate, plain HTTP is served when neither TLS option is set
+	TLSCertFile string
+	TLSKeyFile  string
+	// AutocertDomains terminates TLS with Let's Encrypt certificates for these domains, the server must be reachable
+	// on port 443 to answer the challenges
+	AutocertDomains []string
+	// AutocertCacheDir is where the certificates are kept across restarts
+	AutocertCacheDir string
+}
+
+var ErrTLSKeyPair = errors.New("both a tls certificate and key are required")
+
+// DefaultConnectionPolicy leaves room for the default request timeout and closes connections that stall or idle
+var DefaultConnectionPolicy = ConnectionPolicy{
+	ReadHeaderTimeout: 10 * time.Second,
+	ReadTimeout:       60 * time.Second,
+	WriteTimeout:      60 * time.Second,
+	IdleTimeout:       120 * time.Second,
+	MaxHeaderBytes:    1 << 20,
+}
+
+// httpServer returns a server that applies the policy and speaks HTTP/2 without TLS (h2c) as well as HTTP/1.1
+func (p ConnectionPolicy) httpServer(addr string, handler http.Handler) *http.Server {
+	h2 := &http2.Server{
+		IdleTimeout: p.IdleTimeout,
+	}
+
+	return &http.Server{
+		Addr:              addr,
+		Handler:           h2c.NewHandler(handler, h2),
+		ReadHeaderTimeout: p.ReadHeaderTimeout,
+		ReadTimeout:       p.ReadTimeout,
+		WriteTimeout:      p.WriteTimeout,
+		IdleTimeout:       p.IdleTimeout,
+		MaxHeaderBytes:    p.MaxHeaderBytes,
+	}
+}
+
+// listenAndServe serves TLS when it is configured and plain HTTP otherwise, HTTP/2 is negotiated over TLS
+func (p ConnectionPolicy) listenAndServe(srv *http.Server) error {
+	switch {
+	case p.TLSCertFile != "" || p.TLSKeyFile != "":
+		if p.TLSCertFile == "" || p.TLSKeyFile == "" {
+			return ErrTLSKeyPair
+		}
+
+		return srv.ListenAndServeTLS(p.TLSCertFile, p.TLSKeyFile)
+	case len(p.AutocertDomains) > 0:
+		m := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(p.AutocertDomains...),
+		}
+		if p.AutocertCacheDir != "" {
+			m.Cache = autocert.DirCache(p.AutocertCacheDir)
+		}
+
+		srv.TLSConfig = m.TLSConfig()
+
+		return srv.ListenAndServeTLS("", "")
+	}
+
+	return srv.ListenAndServe()
+}
+
+// clearDeadlines lifts the read and write timeouts of the connection for a request that stays open,
+// responses that can't change their deadlines are left as they are
+func clearDeadlines(w http.ResponseWriter) {
+	rc := http.NewResponseController(w)
+	rc.SetReadDeadline(time.Time{})
+	rc.SetWriteDeadline(time.Time{})
+}
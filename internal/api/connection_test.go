@@ -0,0 +1,106 @@
+package api
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"golang.org/x/net/http2"
+)
+
+func TestConnectionPolicy(t *testing.T) {
+	cr := chi.NewRouter()
+	cr.Use(TimeoutMiddleware(TimeoutPolicy{
+		Default: time.Second,
+		Routes: map[string]time.Duration{
+			"/stream": 0,
+		},
+	}))
+
+	slow := func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+		w.Write([]byte(r.Proto))
+	}
+	cr.Get("/slow", slow)
+	cr.Get("/stream", slow)
+
+	p := DefaultConnectionPolicy
+	p.WriteTimeout = 100 * time.Millisecond
+
+	srv := httptest.NewUnstartedServer(nil)
+	srv.Config = p.httpServer("", cr)
+	srv.Start()
+	defer srv.Close()
+
+	t.Run("write timeout", func(t *testing.T) {
+		resp, err := http.Get(srv.URL + "/slow")
+		if err == nil {
+			_, err = io.ReadAll(resp.Body)
+			resp.Body.Close()
+		}
+
+		if err == nil {
+			t.Fatal("expected a response slower than the write timeout to be cut off")
+		}
+	})
+
+	t.Run("stream is not cut off", func(t *testing.T) {
+		resp, err := http.Get(srv.URL + "/stream")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		b, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(b) != "HTTP/1.1" {
+			t.Errorf("expected HTTP/1.1, got %s", b)
+		}
+	})
+
+	t.Run("h2c", func(t *testing.T) {
+		client := &http.Client{
+			Transport: &http2.Transport{
+				AllowHTTP: true,
+				DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+					var d net.Dialer
+					return d.DialContext(ctx, network, addr)
+				},
+			},
+		}
+
+		resp, err := client.Get(srv.URL + "/stream")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		b, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(b) != "HTTP/2.0" {
+			t.Errorf("expected HTTP/2.0, got %s", b)
+		}
+	})
+}
+
+func TestConnectionPolicy_TLSKeyPair(t *testing.T) {
+	p := DefaultConnectionPolicy
+	p.TLSCertFile = "cert.pem"
+
+	err := p.listenAndServe(p.httpServer("127.0.0.1:0", http.NotFoundHandler()))
+	if err != ErrTLSKeyPair {
+		t.Errorf("expected ErrTLSKeyPair, got %v", err)
+	}
+}
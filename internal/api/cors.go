@@ -0,0 +1,183 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/citizenwallet/engine/pkg/engine"
+	"github.com/go-chi/chi/v5"
+)
+
+var (
+	options sync.Map
+
+	allMethods = []string{
+		http.MethodGet,
+		http.MethodPost,
+		http.MethodPatch,
+		http.MethodPut,
+		http.MethodDelete,
+	}
+
+	acceptedHeaders = []string{
+		"Origin",
+		"Content-Type",
+		"Content-Length",
+		"X-Requested-With",
+		"Accept-Encoding",
+		"Authorization",
+		engine.SignatureHeader,
+		engine.AddressHeader,
+		engine.AppVersionHeader,
+		engine.IdempotencyKeyHeader,
+	}
+)
+
+// CORSPolicy configures which cross origin requests are allowed
+type CORSPolicy struct {
+	// AllowedOrigins are exact origins, "*" for any origin or a single wildcard like "https://*.example.com"
+	AllowedOrigins []string
+	// AllowedMethods defaults to the methods of the requested route
+	AllowedMethods []string
+	// AllowedHeaders defaults to the headers used by the api
+	AllowedHeaders   []string
+	AllowCredentials bool
+	// MaxAge is how long a preflight response can be cached, 0 leaves it to the browser
+	MaxAge time.Duration
+}
+
+// DefaultCORSPolicy allows any origin to call the api
+var DefaultCORSPolicy = CORSPolicy{
+	AllowedOrigins: []string{"*"},
+}
+
+// allowsOrigin returns true if the origin matches one of the allowed origins
+func (p CORSPolicy) allowsOrigin(origin string) bool {
+	for _, allowed := range p.AllowedOrigins {
+		if matchOrigin(allowed, origin) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchOrigin matches an origin against an exact origin or a pattern with a single wildcard
+func matchOrigin(pattern, origin string) bool {
+	if pattern == "*" {
+		return true
+	}
+
+	pattern = strings.ToLower(pattern)
+	origin = strings.ToLower(origin)
+
+	prefix, suffix, ok := strings.Cut(pattern, "*")
+	if !ok {
+		return pattern == origin
+	}
+
+	if len(origin) <= len(prefix)+len(suffix) || !strings.HasPrefix(origin, prefix) || !strings.HasSuffix(origin, suffix) {
+		return false
+	}
+
+	// the wildcard only stands for subdomains, not for a path or port
+	wildcard := origin[len(prefix) : len(origin)-len(suffix)]
+
+	return !strings.ContainsAny(wildcard, "/:")
+}
+
+// routeMethods returns the methods the requested path can be called with
+func routeMethods(r *http.Request) string {
+	ctx, _ := r.Context().Value(chi.RouteCtxKey).(*chi.Context)
+
+	var path string
+	if r.URL.RawPath != "" {
+		path = r.URL.RawPath
+	} else {
+		path = r.URL.Path
+	}
+
+	cached, ok := options.Load(path)
+	if ok {
+		return cached.(string)
+	}
+
+	var methods []string
+	for _, method := range allMethods {
+		nctx := chi.NewRouteContext()
+		if ctx != nil && ctx.Routes.Match(nctx, method, path) {
+			methods = append(methods, method)
+		}
+	}
+
+	methods = append(methods, http.MethodOptions)
+	methodsStr := strings.Join(methods, ", ")
+	options.Store(path, methodsStr)
+
+	return methodsStr
+}
+
+// CORSMiddleware sets the CORS headers of requests from allowed origins and answers preflight requests
+func CORSMiddleware(p CORSPolicy) func(http.Handler) http.Handler {
+	anyOrigin := false
+	for _, allowed := range p.AllowedOrigins {
+		if allowed == "*" {
+			anyOrigin = true
+		}
+	}
+
+	headers := strings.Join(acceptedHeaders, ", ")
+	if len(p.AllowedHeaders) > 0 {
+		headers = strings.Join(p.AllowedHeaders, ", ")
+	}
+
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			methodsStr := routeMethods(r)
+
+			// allowed methods
+			w.Header().Set("Allow", methodsStr)
+
+			origin := r.Header.Get("Origin")
+			if origin != "" && p.allowsOrigin(origin) {
+				// browsers reject a wildcard origin on requests with credentials, echo the origin instead
+				if anyOrigin && !p.AllowCredentials {
+					w.Header().Set("Access-Control-Allow-Origin", "*")
+				} else {
+					w.Header().Set("Access-Control-Allow-Origin", origin)
+					w.Header().Add("Vary", "Origin")
+				}
+
+				// allowed methods for CORS
+				if len(p.AllowedMethods) > 0 {
+					w.Header().Set("Access-Control-Allow-Methods", strings.Join(p.AllowedMethods, ", "))
+				} else {
+					w.Header().Set("Access-Control-Allow-Methods", methodsStr)
+				}
+
+				// allowed headers
+				w.Header().Set("Access-Control-Allow-Headers", headers)
+
+				if p.AllowCredentials {
+					w.Header().Set("Access-Control-Allow-Credentials", "true")
+				}
+
+				if r.Method == http.MethodOptions && p.MaxAge > 0 {
+					w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(p.MaxAge.Seconds())))
+				}
+			}
+
+			// actually handle the request
+			if r.Method != http.MethodOptions {
+				h.ServeHTTP(w, r)
+				return
+			}
+
+			// handle OPTIONS requests
+			w.WriteHeader(http.StatusOK)
+		})
+	}
+}
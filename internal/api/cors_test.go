@@ -0,0 +1,98 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestMatchOrigin(t *testing.T) {
+	tests := []struct {
+		pattern string
+		origin  string
+		match   bool
+	}{
+		{"*", "https://anything.example.com", true},
+		{"https://app.example.com", "https://app.example.com", true},
+		{"https://app.example.com", "https://APP.example.com", true},
+		{"https://app.example.com", "https://app.example.com.evil.com", false},
+		{"https://*.example.com", "https://shop.example.com", true},
+		{"https://*.example.com", "https://a.b.example.com", true},
+		{"https://*.example.com", "https://example.com", false},
+		{"https://*.example.com", "https://.example.com", false},
+		{"https://*.example.com", "http://shop.example.com", false},
+		{"https://*.example.com", "https://evil.com/.example.com", false},
+		{"https://*.example.com", "https://evilexample.com", false},
+	}
+
+	for _, tt := range tests {
+		if got := matchOrigin(tt.pattern, tt.origin); got != tt.match {
+			t.Errorf("matchOrigin(%s, %s): expected %v, got %v", tt.pattern, tt.origin, tt.match, got)
+		}
+	}
+}
+
+func TestCORSMiddleware(t *testing.T) {
+	newRouter := func(p CORSPolicy) *chi.Mux {
+		cr := chi.NewRouter()
+		cr.Use(CORSMiddleware(p))
+		cr.Get("/v1/test", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusTeapot)
+		})
+
+		return cr
+	}
+
+	tests := []struct {
+		name        string
+		policy      CORSPolicy
+		method      string
+		origin      string
+		status      int
+		allowOrigin string
+		credentials string
+		maxAge      string
+	}{
+		{"default allows any origin", DefaultCORSPolicy, http.MethodGet, "https://partner.com", http.StatusTeapot, "*", "", ""},
+		{"no origin", DefaultCORSPolicy, http.MethodGet, "", http.StatusTeapot, "", "", ""},
+		{"exact origin", CORSPolicy{AllowedOrigins: []string{"https://partner.com"}}, http.MethodGet, "https://partner.com", http.StatusTeapot, "https://partner.com", "", ""},
+		{"disallowed origin", CORSPolicy{AllowedOrigins: []string{"https://partner.com"}}, http.MethodGet, "https://evil.com", http.StatusTeapot, "", "", ""},
+		{"credentials echo the origin", CORSPolicy{AllowedOrigins: []string{"*"}, AllowCredentials: true}, http.MethodGet, "https://partner.com", http.StatusTeapot, "https://partner.com", "true", ""},
+		{"preflight", CORSPolicy{AllowedOrigins: []string{"https://*.partner.com"}, MaxAge: 10 * time.Minute}, http.MethodOptions, "https://shop.partner.com", http.StatusOK, "https://shop.partner.com", "", "600"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "/v1/test", nil)
+			if tt.origin != "" {
+				r.Header.Set("Origin", tt.origin)
+			}
+
+			w := httptest.NewRecorder()
+			newRouter(tt.policy).ServeHTTP(w, r)
+
+			if w.Code != tt.status {
+				t.Errorf("expected status %d, got %d", tt.status, w.Code)
+			}
+
+			if got := w.Header().Get("Access-Control-Allow-Origin"); got != tt.allowOrigin {
+				t.Errorf("expected allow origin %q, got %q", tt.allowOrigin, got)
+			}
+
+			if got := w.Header().Get("Access-Control-Allow-Credentials"); got != tt.credentials {
+				t.Errorf("expected allow credentials %q, got %q", tt.credentials, got)
+			}
+
+			if got := w.Header().Get("Access-Control-Max-Age"); got != tt.maxAge {
+				t.Errorf("expected max age %q, got %q", tt.maxAge, got)
+			}
+
+			if tt.allowOrigin != "" && w.Header().Get("Access-Control-Allow-Methods") != "GET, OPTIONS" {
+				t.Errorf("expected the route methods, got %q", w.Header().Get("Access-Control-Allow-Methods"))
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+// etagWriter buffers a response so that its ETag can be computed before it is sent
+type etagWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (ew *etagWriter) WriteHeader(status int) {
+	if ew.status == 0 {
+		ew.status = status
+	}
+}
+
+func (ew *etagWriter) Write(b []byte) (int, error) {
+	if ew.status == 0 {
+		ew.status = http.StatusOK
+	}
+
+	return ew.body.Write(b)
+}
+
+func (ew *etagWriter) Unwrap() http.ResponseWriter {
+	return ew.ResponseWriter
+}
+
+// etag returns a weak ETag of a body, it is weak since the body is compressed afterwards
+func etag(body []byte) string {
+	h := sha256.Sum256(body)
+
+	return `W/"` + hex.EncodeToString(h[:16]) + `"`
+}
+
+// etagMatches compares an If-None-Match header with an ETag, ignoring whether they are weak
+func etagMatches(ifNoneMatch, tag string) bool {
+	tag = strings.TrimPrefix(tag, "W/")
+
+	for _, t := range strings.Split(ifNoneMatch, ",") {
+		t = strings.TrimSpace(t)
+		if t == "*" || strings.TrimPrefix(t, "W/") == tag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// withETag is a middleware that tags successful GET responses with the hash of their body and responds with
+// 304 Not Modified when the client already has it
+func withETag(h http.HandlerFunc) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			h(w, r)
+			return
+		}
+
+		ew := &etagWriter{ResponseWriter: w}
+		h(ew, r)
+
+		if ew.status == 0 {
+			ew.status = http.StatusOK
+		}
+
+		if ew.status == http.StatusOK {
+			tag := etag(ew.body.Bytes())
+			w.Header().Set("ETag", tag)
+
+			if etagMatches(r.Header.Get("If-None-Match"), tag) {
+				w.Header().Del("Content-Type")
+				w.Header().Del("Content-Length")
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		}
+
+		w.WriteHeader(ew.status)
+		w.Write(ew.body.Bytes())
+	})
+}
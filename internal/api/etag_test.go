@@ -0,0 +1,63 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestWithETag(t *testing.T) {
+	body := `{"response_type":"array","array":[]}`
+
+	cr := chi.NewRouter()
+	cr.Get("/logs", withETag(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	}))
+	cr.Get("/missing", withETag(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/logs", nil)
+	rec := httptest.NewRecorder()
+	cr.ServeHTTP(rec, req)
+
+	tag := rec.Header().Get("ETag")
+	if rec.Code != http.StatusOK || tag == "" || rec.Body.String() != body {
+		t.Fatalf("got %d %q %q, want 200 with an etag and the body", rec.Code, tag, rec.Body.String())
+	}
+
+	tests := []struct {
+		name        string
+		path        string
+		ifNoneMatch string
+		status      int
+	}{
+		{"unchanged", "/logs", tag, http.StatusNotModified},
+		{"strong comparison", "/logs", tag[2:], http.StatusNotModified},
+		{"one of many", "/logs", `"other", ` + tag, http.StatusNotModified},
+		{"any", "/logs", "*", http.StatusNotModified},
+		{"changed", "/logs", `W/"other"`, http.StatusOK},
+		{"error", "/missing", "*", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			req.Header.Set("If-None-Match", tt.ifNoneMatch)
+
+			rec := httptest.NewRecorder()
+			cr.ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.status)
+			}
+
+			if tt.status == http.StatusNotModified && rec.Body.Len() != 0 {
+				t.Fatalf("body = %q, want empty", rec.Body.String())
+			}
+		})
+	}
+}
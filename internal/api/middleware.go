@@ -2,12 +2,15 @@ package api
 
 import (
 	"context"
+	"crypto/subtle"
 	"encoding/json"
+	"fmt"
 	"io"
+	"log"
 	"math/big"
 	"net/http"
+	"runtime/debug"
 	"strings"
-	"sync"
 	"time"
 
 	comm "github.com/citizenwallet/engine/pkg/common"
@@ -23,99 +26,86 @@ import (
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
 )
 
 var (
-	options sync.Map
-
-	allMethods = []string{
-		http.MethodGet,
-		http.MethodPost,
-		http.MethodPatch,
-		http.MethodPut,
-		http.MethodDelete,
-	}
-
-	acceptedHeaders = []string{
-		"Origin",
-		"Content-Type",
-		"Content-Length",
-		"X-Requested-With",
-		"Accept-Encoding",
-		"Authorization",
-		engine.SignatureHeader,
-		engine.AddressHeader,
-		engine.AppVersionHeader,
-	}
-
 	MAGIC_VALUE = [4]byte{0x16, 0x26, 0xba, 0x7e}
 )
 
-// HealthMiddleware is a middleware that responds to health checks
-func HealthMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.URL.Path == "/health" {
-			w.WriteHeader(http.StatusOK)
-			return
-		}
-
-		next.ServeHTTP(w, r)
-	})
+// healthReporter reports the health of the rpc node
+type healthReporter interface {
+	Health() engine.RPCHealth
 }
 
-// OptionsMiddleware ensures that we return the correct headers for CORS requests
-func OptionsMiddleware(h http.Handler) http.Handler {
-	fn := func(w http.ResponseWriter, r *http.Request) {
-		ctx, _ := r.Context().Value(chi.RouteCtxKey).(*chi.Context)
+// HealthMiddleware is a middleware that responds to health checks, with 503 when the rpc node is down or its head is stale
+func HealthMiddleware(hr healthReporter) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/health" {
+				next.ServeHTTP(w, r)
+				return
+			}
 
-		var path string
-		if r.URL.RawPath != "" {
-			path = r.URL.RawPath
-		} else {
-			path = r.URL.Path
-		}
+			if hr == nil {
+				w.WriteHeader(http.StatusOK)
+				return
+			}
 
-		var methodsStr string
-		cached, ok := options.Load(path)
-		if ok {
-			methodsStr = cached.(string)
-		} else {
-			var methods []string
-			for _, method := range allMethods {
-				nctx := chi.NewRouteContext()
-				if ctx.Routes.Match(nctx, method, path) {
-					methods = append(methods, method)
-				}
+			health := hr.Health()
+
+			// the node has not been checked yet
+			if health.CheckedAt.IsZero() {
+				w.WriteHeader(http.StatusOK)
+				return
 			}
 
-			methods = append(methods, http.MethodOptions)
-			methodsStr = strings.Join(methods, ", ")
-			options.Store(path, methodsStr)
-		}
+			if !health.Healthy {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
 
-		// allowed methods
-		w.Header().Set("Allow", methodsStr)
+			err := comm.Body(w, health, nil)
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+		})
+	}
+}
 
-		// allowed methods for CORS
-		w.Header().Set("Access-Control-Allow-Methods", methodsStr)
+// RecoverMiddleware responds with 500 when a handler panics instead of crashing the server, the panic is logged
+// with the request id and sent to the webhook if there is one
+func RecoverMiddleware(webhook engine.WebhookMessager) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer func() {
+				pv := recover()
+				if pv == nil {
+					return
+				}
 
-		// allowed origins
-		w.Header().Set("Access-Control-Allow-Origin", "*")
+				// the server uses this panic to abort a response on purpose
+				if pv == http.ErrAbortHandler {
+					panic(pv)
+				}
 
-		// allowed headers
-		w.Header().Set("Access-Control-Allow-Headers", strings.Join(acceptedHeaders, ", "))
+				err := fmt.Errorf("panic in %s %s (request id: %s): %v", r.Method, r.URL.Path, middleware.GetReqID(r.Context()), pv)
 
-		// actually handle the request
-		if r.Method != http.MethodOptions {
-			h.ServeHTTP(w, r)
-			return
-		}
+				log.Default().Printf("%s\n%s", err.Error(), debug.Stack())
 
-		// handle OPTIONS requests
-		w.WriteHeader(http.StatusOK)
-	}
+				if webhook != nil {
+					webhook.NotifyError(context.Background(), err)
+				}
+
+				// websocket connections are hijacked, there is no response to write to anymore
+				if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+					w.WriteHeader(http.StatusInternalServerError)
+				}
+			}()
 
-	return http.HandlerFunc(fn)
+			next.ServeHTTP(w, r)
+		})
+	}
 }
 
 func RequestSizeLimitMiddleware(limit int64) func(http.Handler) http.Handler {
@@ -127,6 +117,75 @@ func RequestSizeLimitMiddleware(limit int64) func(http.Handler) http.Handler {
 	}
 }
 
+// ContentNegotiationMiddleware encodes the comm.Body and comm.BodyMultiple responses in the format that the Accept header
+// prefers, ex: application/msgpack, json stays the default
+func ContentNegotiationMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept")
+
+		next.ServeHTTP(comm.WithEncoder(w, comm.NegotiateEncoder(r.Header.Get("Accept"))), r)
+	})
+}
+
+// withAdminKey is a middleware that only allows requests that carry the admin api key as a bearer token
+func withAdminKey(key string, h http.HandlerFunc) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// admin endpoints are disabled when no key is configured
+		if key == "" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(key)) != 1 {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		h(w, r)
+	})
+}
+
+// url params that can hold a CAIP-10 account id instead of a bare address
+var caip10Params = map[string]bool{
+	"acc_addr":         true,
+	"contract_address": true,
+}
+
+// withCAIP10Params is a middleware that converts CAIP-10 account ids in the url params to bare addresses,
+// the chain id of an account id has to match the engine's
+func withCAIP10Params(chainID *big.Int, h http.HandlerFunc) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rctx := chi.RouteContext(r.Context())
+		if rctx == nil {
+			h(w, r)
+			return
+		}
+
+		for i, key := range rctx.URLParams.Keys {
+			v := rctx.URLParams.Values[i]
+			if !caip10Params[key] || !comm.IsCAIP10(v) {
+				continue
+			}
+
+			chid, addr, err := comm.ParseCAIP10(v)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			if chid.Cmp(chainID) != 0 {
+				http.Error(w, "account id is for a different chain", http.StatusBadRequest)
+				return
+			}
+
+			rctx.URLParams.Values[i] = addr.Hex()
+		}
+
+		h(w, r)
+	})
+}
+
 type BodyEncoding string
 
 const (
@@ -138,10 +197,11 @@ type signedBody struct {
 	Encoding BodyEncoding `json:"encoding"`
 	Expiry   int64        `json:"expiry"`
 	Version  int          `json:"version"`
+	Nonce    int64        `json:"nonce,omitempty"`
 }
 
 // withSignature is a middleware that checks the signature of the request against the request headers
-func withSignature(evm engine.EVMRequester, h http.HandlerFunc) http.HandlerFunc {
+func withSignature(evm engine.EVMRequester, guard *replayGuard, h http.HandlerFunc) http.HandlerFunc {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// check signature
 		signature := r.Header.Get(engine.SignatureHeader)
@@ -164,7 +224,11 @@ func withSignature(evm engine.EVMRequester, h http.HandlerFunc) http.HandlerFunc
 			return
 		}
 
-		haccaddr := common.HexToAddress(addr)
+		haccaddr, err := comm.ParseAddress(addr)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
 
 		// check signature
 		switch req.Version {
@@ -189,6 +253,18 @@ func withSignature(evm engine.EVMRequester, h http.HandlerFunc) http.HandlerFunc
 			}
 		}
 
+		// check that the request is not being replayed
+		ok, err := guard.check(req, haccaddr)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		if !ok {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
 		r.Body = io.NopCloser(strings.NewReader(string(req.Data)))
 		r.ContentLength = int64(len(req.Data))
 
@@ -201,7 +277,7 @@ func withSignature(evm engine.EVMRequester, h http.HandlerFunc) http.HandlerFunc
 }
 
 // withMultiPartSignature is a middleware that checks the signature of the request against a multi-part request headers
-func withMultiPartSignature(evm engine.EVMRequester, h http.HandlerFunc) http.HandlerFunc {
+func withMultiPartSignature(evm engine.EVMRequester, guard *replayGuard, h http.HandlerFunc) http.HandlerFunc {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// check signature
 		signature := r.Header.Get(engine.SignatureHeader)
@@ -225,7 +301,11 @@ func withMultiPartSignature(evm engine.EVMRequester, h http.HandlerFunc) http.Ha
 			return
 		}
 
-		haccaddr := common.HexToAddress(addr)
+		haccaddr, err := comm.ParseAddress(addr)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
 
 		// check signature
 		switch req.Version {
@@ -250,6 +330,18 @@ func withMultiPartSignature(evm engine.EVMRequester, h http.HandlerFunc) http.Ha
 			}
 		}
 
+		// check that the request is not being replayed
+		ok, err := guard.check(req, haccaddr)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		if !ok {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
 		r.MultipartForm.Value["body"] = []string{string(req.Data)}
 
 		ctx := context.WithValue(r.Context(), engine.ContextKeyAddress, addr)
@@ -260,7 +352,7 @@ func withMultiPartSignature(evm engine.EVMRequester, h http.HandlerFunc) http.Ha
 }
 
 // with1271Signature is a middleware that checks the owner's signature of the request against the request headers and the actual account on-chain
-func with1271Signature(evm engine.EVMRequester, h http.HandlerFunc) http.HandlerFunc {
+func with1271Signature(evm engine.EVMRequester, guard *replayGuard, h http.HandlerFunc) http.HandlerFunc {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// parse signature from header
 		signature := r.Header.Get(engine.SignatureHeader)
@@ -283,7 +375,11 @@ func with1271Signature(evm engine.EVMRequester, h http.HandlerFunc) http.Handler
 			return
 		}
 
-		haccaddr := common.HexToAddress(addr)
+		haccaddr, err := comm.ParseAddress(addr)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
 
 		// check signature
 		if !verify1271Signature(evm, req, haccaddr, signature) {
@@ -291,6 +387,18 @@ func with1271Signature(evm engine.EVMRequester, h http.HandlerFunc) http.Handler
 			return
 		}
 
+		// check that the request is not being replayed
+		ok, err := guard.check(req, haccaddr)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		if !ok {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
 		r.Body = io.NopCloser(strings.NewReader(string(req.Data)))
 		r.ContentLength = int64(len(req.Data))
 
@@ -1,11 +1,19 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/citizenwallet/engine/pkg/engine"
 	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
 )
 
 func TestSignatureVerification(t *testing.T) {
@@ -73,3 +81,163 @@ func TestSignatureVerification(t *testing.T) {
 		}
 	})
 }
+
+func TestAdminKey(t *testing.T) {
+	ok := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	testCases := []struct {
+		name     string
+		key      string
+		header   string
+		expected int
+	}{
+		{name: "disabled", key: "", header: "Bearer ", expected: http.StatusNotFound},
+		{name: "missing header", key: "secret", header: "", expected: http.StatusUnauthorized},
+		{name: "wrong key", key: "secret", header: "Bearer wrong", expected: http.StatusUnauthorized},
+		{name: "no bearer prefix", key: "secret", header: "secret", expected: http.StatusUnauthorized},
+		{name: "valid key", key: "secret", header: "Bearer secret", expected: http.StatusOK},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/admin/ws/stats", nil)
+			if tc.header != "" {
+				req.Header.Set("Authorization", tc.header)
+			}
+
+			rec := httptest.NewRecorder()
+			withAdminKey(tc.key, ok)(rec, req)
+
+			if rec.Code != tc.expected {
+				t.Errorf("expected status %d, got %d", tc.expected, rec.Code)
+			}
+		})
+	}
+}
+
+func TestCAIP10Params(t *testing.T) {
+	var got string
+	h := func(w http.ResponseWriter, r *http.Request) {
+		got = chi.URLParam(r, "acc_addr")
+	}
+
+	cr := chi.NewRouter()
+	cr.Get("/{acc_addr}/{token}", withCAIP10Params(big.NewInt(137), h))
+
+	tests := []struct {
+		name     string
+		path     string
+		status   int
+		expected string
+	}{
+		{"bare address", "/0x480fbe37526226b6c6e2a7afa449cdf661939d2f/t", http.StatusOK, "0x480fbe37526226b6c6e2a7afa449cdf661939d2f"},
+		{"account id", "/eip155:137:0x480fbe37526226b6c6e2a7afa449cdf661939d2f/t", http.StatusOK, "0x480Fbe37526226b6c6E2a7AfA449cDf661939D2f"},
+		{"other params are untouched", "/eip155:137:0x480fbe37526226b6c6e2a7afa449cdf661939d2f/a:b", http.StatusOK, "0x480Fbe37526226b6c6E2a7AfA449cDf661939D2f"},
+		{"wrong chain", "/eip155:1:0x480fbe37526226b6c6e2a7afa449cdf661939d2f/t", http.StatusBadRequest, ""},
+		{"invalid account id", "/eip155:137:nope/t", http.StatusBadRequest, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got = ""
+
+			w := httptest.NewRecorder()
+			cr.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			if w.Code != tt.status {
+				t.Fatalf("expected status %d, got %d", tt.status, w.Code)
+			}
+
+			if got != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, got)
+			}
+		})
+	}
+}
+
+// errWebhook records the errors it is notified of
+type errWebhook struct {
+	errs []error
+}
+
+func (w *errWebhook) Notify(ctx context.Context, message string) error { return nil }
+
+func (w *errWebhook) NotifyWarning(ctx context.Context, err error) error { return nil }
+
+func (w *errWebhook) NotifyError(ctx context.Context, err error) error {
+	w.errs = append(w.errs, err)
+	return nil
+}
+
+func TestRecoverMiddleware(t *testing.T) {
+	wh := &errWebhook{}
+
+	cr := chi.NewRouter()
+	cr.Use(middleware.RequestID)
+	cr.Use(RecoverMiddleware(wh))
+	cr.Get("/panic", func(w http.ResponseWriter, r *http.Request) {
+		panic("bad request")
+	})
+	cr.Get("/ok", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	r := httptest.NewRequest(http.MethodGet, "/panic", nil)
+	r.Header.Set(middleware.RequestIDHeader, "req-123")
+
+	w := httptest.NewRecorder()
+	cr.ServeHTTP(w, r)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("expected %d, got %d", http.StatusInternalServerError, w.Code)
+	}
+
+	if len(wh.errs) != 1 || !strings.Contains(wh.errs[0].Error(), "req-123") {
+		t.Fatalf("expected a notification with the request id, got %v", wh.errs)
+	}
+
+	w = httptest.NewRecorder()
+	cr.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/ok", nil))
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected %d, got %d", http.StatusOK, w.Code)
+	}
+}
+
+type staticHealth engine.RPCHealth
+
+func (h staticHealth) Health() engine.RPCHealth {
+	return engine.RPCHealth(h)
+}
+
+func TestHealthMiddleware(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+
+	tests := []struct {
+		name     string
+		hr       healthReporter
+		path     string
+		expected int
+	}{
+		{"other paths", nil, "/version", http.StatusTeapot},
+		{"no reporter", nil, "/health", http.StatusOK},
+		{"not checked yet", staticHealth{}, "/health", http.StatusOK},
+		{"healthy", staticHealth{Healthy: true, CheckedAt: time.Now()}, "/health", http.StatusOK},
+		{"stale", staticHealth{Stale: true, CheckedAt: time.Now()}, "/health", http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			HealthMiddleware(tt.hr)(next).ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			if w.Code != tt.expected {
+				t.Errorf("expected %d, got %d", tt.expected, w.Code)
+			}
+		})
+	}
+}
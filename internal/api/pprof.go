@@ -0,0 +1,46 @@
+package api
+
+import (
+	"net/http"
+	"net/http/pprof"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// cpu profiles and traces are collected for as long as the ?seconds param asks, the request timeout doesn't apply to them
+var pprofStreamingRoutes = []string{
+	"/debug/pprof/profile",
+	"/debug/pprof/trace",
+}
+
+// pprofHandler serves the runtime profiles, expected to be mounted on /debug/pprof
+func pprofHandler() http.Handler {
+	cr := chi.NewRouter()
+
+	// the index also serves the named profiles: heap, goroutine, allocs, block, mutex, threadcreate
+	cr.Get("/*", pprof.Index)
+	cr.Get("/cmdline", pprof.Cmdline)
+	cr.Get("/profile", pprof.Profile)
+	cr.Get("/symbol", pprof.Symbol)
+	cr.Post("/symbol", pprof.Symbol)
+	cr.Get("/trace", pprof.Trace)
+
+	return cr
+}
+
+// withoutTimeout returns a copy of the policy that never times out the given route patterns
+func (p TimeoutPolicy) withoutTimeout(patterns ...string) TimeoutPolicy {
+	routes := make(map[string]time.Duration, len(p.Routes)+len(patterns))
+	for k, v := range p.Routes {
+		routes[k] = v
+	}
+
+	for _, pattern := range patterns {
+		routes[pattern] = 0
+	}
+
+	p.Routes = routes
+
+	return p
+}
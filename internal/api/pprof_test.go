@@ -0,0 +1,82 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestPprofHandler(t *testing.T) {
+	cr := chi.NewRouter()
+	cr.Mount("/debug/pprof", withAdminKey("secret", pprofHandler().ServeHTTP))
+
+	tests := []struct {
+		name   string
+		path   string
+		auth   string
+		status int
+		body   string
+	}{
+		{"no key", "/debug/pprof/", "", http.StatusUnauthorized, ""},
+		{"index", "/debug/pprof/", "Bearer secret", http.StatusOK, "goroutine"},
+		{"named profile", "/debug/pprof/goroutine?debug=1", "Bearer secret", http.StatusOK, "goroutine profile"},
+		{"cmdline", "/debug/pprof/cmdline", "Bearer secret", http.StatusOK, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.auth != "" {
+				req.Header.Set("Authorization", tt.auth)
+			}
+
+			w := httptest.NewRecorder()
+			cr.ServeHTTP(w, req)
+
+			if w.Code != tt.status {
+				t.Errorf("expected status %d, got %d", tt.status, w.Code)
+			}
+
+			if !strings.Contains(w.Body.String(), tt.body) {
+				t.Errorf("expected body to contain %q", tt.body)
+			}
+		})
+	}
+}
+
+func TestPprofTimeouts(t *testing.T) {
+	p := DefaultTimeoutPolicy.withoutTimeout(pprofStreamingRoutes...)
+
+	if DefaultTimeoutPolicy.Routes != nil {
+		t.Fatal("expected the default policy to be left untouched")
+	}
+
+	cr := chi.NewRouter()
+	cr.Mount("/debug/pprof", pprofHandler())
+
+	tests := []struct {
+		path    string
+		timeout time.Duration
+	}{
+		{"/debug/pprof/profile", 0},
+		{"/debug/pprof/trace", 0},
+		{"/debug/pprof/heap", DefaultTimeoutPolicy.Default},
+	}
+
+	for _, tt := range tests {
+		rctx := chi.NewRouteContext()
+		rctx.Routes = cr
+
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+		if got := p.timeout(req); got != tt.timeout {
+			t.Errorf("%s: expected timeout %s, got %s", tt.path, tt.timeout, got)
+		}
+	}
+}
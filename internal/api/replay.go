@@ -0,0 +1,79 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	com "github.com/citizenwallet/engine/pkg/common"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// SignaturePolicy configures how signed requests are protected against replays
+type SignaturePolicy struct {
+	// MaxValidity is the longest a signature can be valid for, 0 accepts any expiry
+	MaxValidity time.Duration
+	// RequireNonce rejects signed requests without a nonce
+	RequireNonce bool
+}
+
+type nonceStore interface {
+	UseNonce(account string, nonce int64) (bool, error)
+}
+
+// replayGuard rejects signed requests that have already been used or that stay valid for too long
+type replayGuard struct {
+	policy SignaturePolicy
+	nonces nonceStore
+}
+
+func newReplayGuard(policy SignaturePolicy, nonces nonceStore) *replayGuard {
+	return &replayGuard{
+		policy: policy,
+		nonces: nonces,
+	}
+}
+
+// check should be called once the signature of the request is verified, a nonce is used up by a successful check
+func (g *replayGuard) check(req signedBody, addr common.Address) (bool, error) {
+	if g == nil {
+		return true, nil
+	}
+
+	// legacy signatures only sign the data, the expiry and nonce can be changed
+	if req.Version == 0 && (g.policy.MaxValidity > 0 || g.policy.RequireNonce) {
+		return false, nil
+	}
+
+	if g.policy.MaxValidity > 0 && req.Expiry > time.Now().UTC().Add(g.policy.MaxValidity).Unix() {
+		return false, nil
+	}
+
+	if req.Nonce == 0 {
+		return !g.policy.RequireNonce, nil
+	}
+
+	return g.nonces.UseNonce(addr.Hex(), req.Nonce)
+}
+
+type signatureFormat struct {
+	Version      int      `json:"version"`
+	Fields       []string `json:"fields"`
+	MaxValidity  int64    `json:"max_validity"`
+	RequireNonce bool     `json:"require_nonce"`
+}
+
+// Format describes how clients should construct a signed request
+//
+// the signed message is the json of the request body with its fields in the listed order,
+// expiry is a unix timestamp in seconds and nonce has to increase with every request of an account
+func (g *replayGuard) Format(w http.ResponseWriter, r *http.Request) {
+	err := com.Body(w, &signatureFormat{
+		Version:      3,
+		Fields:       []string{"data", "encoding", "expiry", "version", "nonce"},
+		MaxValidity:  int64(g.policy.MaxValidity.Seconds()),
+		RequireNonce: g.policy.RequireNonce,
+	}, nil)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// memoryNonces keeps the last nonce of every account in memory
+type memoryNonces map[string]int64
+
+func (m memoryNonces) UseNonce(account string, nonce int64) (bool, error) {
+	if nonce <= m[account] {
+		return false, nil
+	}
+
+	m[account] = nonce
+
+	return true, nil
+}
+
+func TestReplayGuard(t *testing.T) {
+	addr := common.HexToAddress("0x1")
+	soon := time.Now().Add(time.Minute).Unix()
+	later := time.Now().Add(time.Hour).Unix()
+
+	tests := []struct {
+		name     string
+		policy   SignaturePolicy
+		requests []signedBody
+		expected []bool
+	}{
+		{
+			name:     "no policy",
+			requests: []signedBody{{Version: 3, Expiry: later}, {Version: 3, Expiry: later}},
+			expected: []bool{true, true},
+		},
+		{
+			name:     "expiry too far",
+			policy:   SignaturePolicy{MaxValidity: 5 * time.Minute},
+			requests: []signedBody{{Version: 3, Expiry: soon}, {Version: 3, Expiry: later}},
+			expected: []bool{true, false},
+		},
+		{
+			name:     "reused nonce",
+			requests: []signedBody{{Version: 3, Expiry: soon, Nonce: 1}, {Version: 3, Expiry: soon, Nonce: 1}, {Version: 3, Expiry: soon, Nonce: 2}},
+			expected: []bool{true, false, true},
+		},
+		{
+			name:     "lower nonce",
+			requests: []signedBody{{Version: 3, Expiry: soon, Nonce: 5}, {Version: 3, Expiry: soon, Nonce: 4}},
+			expected: []bool{true, false},
+		},
+		{
+			name:     "missing nonce",
+			policy:   SignaturePolicy{RequireNonce: true},
+			requests: []signedBody{{Version: 3, Expiry: soon}, {Version: 3, Expiry: soon, Nonce: 1}},
+			expected: []bool{false, true},
+		},
+		{
+			name:     "legacy signatures cannot be protected",
+			policy:   SignaturePolicy{RequireNonce: true},
+			requests: []signedBody{{Version: 0, Expiry: soon, Nonce: 1}},
+			expected: []bool{false},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newReplayGuard(tt.policy, memoryNonces{})
+
+			for i, req := range tt.requests {
+				ok, err := g.check(req, addr)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if ok != tt.expected[i] {
+					t.Errorf("request %d: expected %v, got %v", i, tt.expected[i], ok)
+				}
+			}
+		})
+	}
+}
@@ -2,14 +2,19 @@ package api
 
 import (
 	"github.com/citizenwallet/engine/internal/accounts"
+	"github.com/citizenwallet/engine/internal/admin"
+	"github.com/citizenwallet/engine/internal/balances"
 	"github.com/citizenwallet/engine/internal/bucket"
 	"github.com/citizenwallet/engine/internal/chain"
+	"github.com/citizenwallet/engine/internal/contracts"
 	"github.com/citizenwallet/engine/internal/events"
 	"github.com/citizenwallet/engine/internal/logs"
 	"github.com/citizenwallet/engine/internal/paymaster"
+	"github.com/citizenwallet/engine/internal/permit"
 	"github.com/citizenwallet/engine/internal/profiles"
 	"github.com/citizenwallet/engine/internal/push"
 	"github.com/citizenwallet/engine/internal/rpc"
+	"github.com/citizenwallet/engine/internal/stats"
 	"github.com/citizenwallet/engine/internal/userop"
 	"github.com/citizenwallet/engine/internal/version"
 	"github.com/citizenwallet/engine/pkg/engine"
@@ -28,12 +33,15 @@ func (s *Server) AddMiddleware(cr *chi.Mux) *chi.Mux {
 	// configure middleware
 	cr.Use(middleware.RequestID)
 	cr.Use(middleware.Logger)
+	cr.Use(RecoverMiddleware(s.webhook))
 
 	// configure custom middleware
-	cr.Use(OptionsMiddleware)
-	cr.Use(HealthMiddleware)
+	cr.Use(CORSMiddleware(s.corsPolicy))
+	cr.Use(HealthMiddleware(s.healthReporter()))
 	cr.Use(RequestSizeLimitMiddleware(10 << 20)) // Limit request bodies to 10MB
-	cr.Use(middleware.Compress(9))
+	cr.Use(CompressMiddleware(s.compressionPolicy))
+	cr.Use(TimeoutMiddleware(s.timeoutPolicy))
+	cr.Use(TracingMiddleware) // after the timeout so that the span sees the route context the request is routed with
 
 	return cr
 }
@@ -41,77 +49,144 @@ func (s *Server) AddMiddleware(cr *chi.Mux) *chi.Mux {
 func (s *Server) AddRoutes(cr *chi.Mux, b *bucket.Bucket) *chi.Mux {
 	// instantiate handlers
 	v := version.NewService()
-	l := logs.NewService(s.chainID, s.db, s.evm)
+	l := logs.NewService(s.chainID, s.db, s.evm, s.pools, s.pollTimeout)
 	events := events.NewHandlers(s.db, s.pools)
-	rpc := rpc.NewHandlers()
 	pm := paymaster.NewService(s.evm, s.db)
-	uop := userop.NewService(s.evm, s.db, s.userOpQueue, s.chainID)
+	uop := userop.NewService(s.evm, s.db, s.userOpQueue, s.userOps, s.chainID, s.entryPoints)
+	pmt := permit.NewService(s.evm, s.db, s.userOpQueue, s.chainID)
 	ch := chain.NewService(s.evm, s.chainID)
 	pr := profiles.NewService(b, s.evm)
 	pu := push.NewService(s.db)
 	acc := accounts.NewService(s.evm, s.db)
+	con := contracts.NewService(s.evm)
+	bal := balances.NewService(s.db)
+	st := stats.NewService(s.db)
+	adm := admin.NewService(s.db, s.pools, s.sponsorMonitor, s.userOps, s.indexer)
+	guard := newReplayGuard(s.signaturePolicy, s.db.NonceDB)
+
+	// rpc methods, available over http and websocket
+	methods := map[string]engine.RPCHandlerFunc{
+		"pm_sponsorUserOperation":   pm.Sponsor,
+		"pm_ooSponsorUserOperation": pm.OOSponsor,
+		"pm_getFeeEstimate":         pm.FeeEstimate,
+		"eth_sendUserOperation":     uop.Send,
+		"eth_supportedEntryPoints":  uop.SupportedEntryPoints,
+		"pm_relayPermit":            pmt.Relay,
+		"eth_multicall":             con.Multicall,
+		"eth_chainId":               ch.ChainId,
+		"eth_call":                  ch.EthCall,
+		"eth_blockNumber":           ch.EthBlockNumber,
+		"eth_getBlockByNumber":      ch.EthGetBlockByNumber,
+		"eth_maxPriorityFeePerGas":  ch.EthMaxPriorityFeePerGas,
+		"eth_feeHistory":            ch.EthFeeHistory,
+		"eth_getTransactionReceipt": ch.EthGetTransactionReceipt,
+		"eth_getCode":               ch.EthGetCode,
+		"eth_getStorageAt":          ch.EthGetStorageAt,
+	}
+
+	// cache the methods that return immutable results
+	for method, h := range methods {
+		methods[method] = s.rpcCache.Wrap(method, h)
+	}
+
+	rpc := rpc.NewHandlers(s.evm, s.pools, methods)
 
 	// configure routes
 	cr.Route("/version", func(cr chi.Router) {
 		cr.Get("/", v.Current)
 	})
 
+	cr.Route("/admin", func(cr chi.Router) {
+		cr.Get("/ws/stats", withAdminKey(s.adminKey, adm.WSStats))
+		cr.Get("/sponsors/balances", withAdminKey(s.adminKey, adm.SponsorBalances))
+		cr.Get("/userops/inprogress", withAdminKey(s.adminKey, adm.InProgress))
+		cr.Post("/sponsors", withAdminKey(s.adminKey, adm.AddSponsor))
+		cr.Delete("/sponsors/{paymaster}", withAdminKey(s.adminKey, adm.RemoveSponsor))
+		cr.Get("/webhooks", withAdminKey(s.adminKey, adm.LogWebhooks))
+		cr.Post("/webhooks", withAdminKey(s.adminKey, adm.AddLogWebhook))
+		cr.Delete("/webhooks/{id}", withAdminKey(s.adminKey, adm.RemoveLogWebhook))
+		cr.Post("/logs/validate", withAdminKey(s.adminKey, adm.ValidateLogData))
+		cr.Get("/contracts/{contract_address}/metadata", withAdminKey(s.adminKey, adm.ContractMetadata))
+		cr.Put("/contracts/{contract_address}/metadata", withAdminKey(s.adminKey, adm.SetContractMetadata))
+		cr.Get("/events/{contract}/reindex", withAdminKey(s.adminKey, adm.ReindexProgress))
+		cr.Post("/events/{contract}/reindex", withAdminKey(s.adminKey, adm.Reindex))
+	})
+
+	if s.pprof {
+		cr.Mount("/debug/pprof", withAdminKey(s.adminKey, pprofHandler().ServeHTTP))
+	}
+
 	// cr.Route("/legacy", func(cr chi.Router) {
 	// 	// TODO: implement legacy routes
 	// 	cr.Get("/account/{address}/exists", l.Get)
 	// })
 
 	cr.Route("/v1", func(cr chi.Router) {
+		// signatures
+		cr.Get("/signature/format", guard.Format)
+
 		// accounts
 		cr.Route("/accounts", func(cr chi.Router) {
-			cr.Get("/{acc_addr}/exists", acc.Exists)
+			cr.Get("/{acc_addr}/exists", withCAIP10Params(s.chainID, acc.Exists))
 		})
 
+		// contracts
+		cr.Get("/contracts/{contract_address}/read/{method}", withCAIP10Params(s.chainID, con.Read))
+
+		// balances
+		cr.Get("/balances/{contract_address}/{acc_addr}", withCAIP10Params(s.chainID, bal.Get))
+
+		// stats
+		cr.Get("/stats/{contract_address}", withCAIP10Params(s.chainID, st.Get))
+
 		// profiles
 		cr.Route("/profiles", func(cr chi.Router) {
 			cr.Route("/{contract_address}", func(cr chi.Router) {
-				cr.Put("/{acc_addr}", withMultiPartSignature(s.evm, pr.PinMultiPartProfile))
-				cr.Patch("/{acc_addr}", withSignature(s.evm, pr.PinProfile))
-				cr.Delete("/{acc_addr}", withSignature(s.evm, pr.Unpin))
+				cr.Put("/{acc_addr}", withCAIP10Params(s.chainID, withMultiPartSignature(s.evm, guard, pr.PinMultiPartProfile)))
+				cr.Patch("/{acc_addr}", withCAIP10Params(s.chainID, withSignature(s.evm, guard, pr.PinProfile)))
+				cr.Delete("/{acc_addr}", withCAIP10Params(s.chainID, withSignature(s.evm, guard, pr.Unpin)))
 			})
 		})
 
 		// push
 		cr.Route("/push/{contract_address}", func(cr chi.Router) {
-			cr.Put("/{acc_addr}", withSignature(s.evm, pu.AddToken))
-			cr.Delete("/{acc_addr}/{token}", withSignature(s.evm, pu.RemoveAccountToken))
+			cr.Put("/{acc_addr}", withCAIP10Params(s.chainID, withSignature(s.evm, guard, pu.AddToken)))
+			cr.Delete("/{acc_addr}/{token}", withCAIP10Params(s.chainID, withSignature(s.evm, guard, pu.RemoveAccountToken)))
 		})
 
 		// logs
 		cr.Route("/logs/{contract_address}", func(cr chi.Router) {
+			cr.Use(ContentNegotiationMiddleware)
+
+			cr.Get("/", withETag(l.GetContractLogs))
+
 			cr.Route("/{signature}", func(cr chi.Router) {
-				cr.Get("/", l.Get)
-				cr.Get("/all", l.GetAll)
+				cr.Get("/", withETag(l.Get))
+				cr.Get("/all", withETag(l.GetAll))
+
+				cr.Get("/new", withETag(l.GetNew))
+				cr.Get("/new/all", withETag(l.GetAllNew))
 
-				cr.Get("/new", l.GetNew)
-				cr.Get("/new/all", l.GetAllNew)
+				cr.Get("/poll", l.Poll)
 			})
 
-			cr.Get("/tx/{hash}", l.GetSingle)
+			cr.Get("/tx/{hash}", withETag(l.GetSingle))
+			cr.Get("/txs/{tx_hash}", withETag(l.GetByTxHash))
+			cr.Get("/account/{acc_addr}", withETag(l.GetAccountHistory))
 		})
 
 		// rpc
 		cr.Route("/rpc/{pm_address}", func(cr chi.Router) {
-			cr.Post("/", withJSONRPCRequest(map[string]engine.RPCHandlerFunc{
-				"pm_sponsorUserOperation":   pm.Sponsor,
-				"pm_ooSponsorUserOperation": pm.OOSponsor,
-				"eth_sendUserOperation":     uop.Send,
-				"eth_chainId":               ch.ChainId,
-				"eth_call":                  ch.EthCall,
-				"eth_blockNumber":           ch.EthBlockNumber,
-				"eth_getBlockByNumber":      ch.EthGetBlockByNumber,
-				"eth_maxPriorityFeePerGas":  ch.EthMaxPriorityFeePerGas,
-				"eth_getTransactionReceipt": ch.EthGetTransactionReceipt,
-			}))
+			cr.Post("/", withJSONRPCRequest(methods))
+			cr.Get("/", rpc.HandleConnection) // for sending RPC calls over a websocket
 		})
 
-		cr.Get("/events/{contract}/{topic}", events.HandleConnection) // for listening to events
-		cr.Get("/rpc", rpc.HandleConnection)                          // for sending RPC calls
+		// events
+		cr.Get("/events", events.List)
+		cr.Get("/events/{contract}", events.List)
+		cr.Get("/events/{contract}/{topic}", events.HandleConnection)    // for listening to events
+		cr.Get("/events/{contract}/{topic}/stream", events.HandleStream) // for listening to events without a websocket
+		cr.Get("/rpc", rpc.HandleConnection)                             // for sending RPC calls
 	})
 
 	return cr
@@ -5,9 +5,13 @@ import (
 	"log"
 	"math/big"
 	"net/http"
+	"time"
 
+	"github.com/citizenwallet/engine/internal/chain"
 	"github.com/citizenwallet/engine/internal/db"
+	"github.com/citizenwallet/engine/internal/indexer"
 	"github.com/citizenwallet/engine/internal/queue"
+	"github.com/citizenwallet/engine/internal/sponsors"
 	"github.com/citizenwallet/engine/internal/ws"
 	"github.com/citizenwallet/engine/pkg/engine"
 )
@@ -17,17 +21,61 @@ type Server struct {
 	db          *db.DB
 	evm         engine.EVMRequester
 	userOpQueue *queue.Service
+	userOps     *queue.UserOpService
 	pools       *ws.ConnectionPools
+	rpcCache    *chain.Cache
+	adminKey    string
+	entryPoints engine.EntryPoints
+	pprof       bool
+
+	signaturePolicy   SignaturePolicy
+	corsPolicy        CORSPolicy
+	timeoutPolicy     TimeoutPolicy
+	pollTimeout       time.Duration
+	compressionPolicy CompressionPolicy
+	connectionPolicy  ConnectionPolicy
+	webhook           engine.WebhookMessager
+	sponsorMonitor    *sponsors.Monitor
+	indexer           *indexer.Indexer // nil when the engine doesn't index
+}
+
+// event streams stay open for as long as the client listens
+var eventStreamRoutes = []string{
+	"/v1/events/{contract}/{topic}/stream",
 }
 
-func NewServer(chainID *big.Int, db *db.DB, evm engine.EVMRequester, userOpQueue *queue.Service, pools *ws.ConnectionPools) *Server {
-	return &Server{chainID: chainID, db: db, evm: evm, userOpQueue: userOpQueue, pools: pools}
+// long polls are bounded by the poll timeout instead of the request timeout
+var longPollRoutes = []string{
+	"/v1/logs/{contract_address}/{signature}/poll",
+}
+
+func NewServer(chainID *big.Int, db *db.DB, evm engine.EVMRequester, userOpQueue *queue.Service, userOps *queue.UserOpService, entryPoints engine.EntryPoints, pools *ws.ConnectionPools, rpcCache *chain.Cache, signaturePolicy SignaturePolicy, corsPolicy CORSPolicy, timeoutPolicy TimeoutPolicy, pollTimeout time.Duration, compressionPolicy CompressionPolicy, connectionPolicy ConnectionPolicy, webhook engine.WebhookMessager, sponsorMonitor *sponsors.Monitor, indexer *indexer.Indexer, pprof bool, adminKey string) *Server {
+	timeoutPolicy = timeoutPolicy.withoutTimeout(eventStreamRoutes...)
+	timeoutPolicy = timeoutPolicy.withoutTimeout(longPollRoutes...)
+	if pprof {
+		timeoutPolicy = timeoutPolicy.withoutTimeout(pprofStreamingRoutes...)
+	}
+
+	return &Server{chainID: chainID, db: db, evm: evm, userOpQueue: userOpQueue, userOps: userOps, entryPoints: entryPoints, pools: pools, rpcCache: rpcCache, signaturePolicy: signaturePolicy, corsPolicy: corsPolicy, timeoutPolicy: timeoutPolicy, pollTimeout: pollTimeout, compressionPolicy: compressionPolicy, connectionPolicy: connectionPolicy, webhook: webhook, sponsorMonitor: sponsorMonitor, indexer: indexer, pprof: pprof, adminKey: adminKey}
+}
+
+// healthReporter returns the evm as a health reporter if it monitors the rpc node
+func (s *Server) healthReporter() healthReporter {
+	hr, ok := s.evm.(healthReporter)
+	if !ok {
+		return nil
+	}
+
+	return hr
 }
 
 func (s *Server) Start(port int, handler http.Handler) error {
 	// start the server
 	log.Printf("API server starting on :%v", port)
-	return http.ListenAndServe(fmt.Sprintf(":%v", port), handler)
+
+	srv := s.connectionPolicy.httpServer(fmt.Sprintf(":%v", port), handler)
+
+	return s.connectionPolicy.listenAndServe(srv)
 }
 
 func (s *Server) Stop() {
@@ -0,0 +1,169 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	com "github.com/citizenwallet/engine/pkg/common"
+	"github.com/go-chi/chi/v5"
+)
+
+// TimeoutPolicy bounds how long a request can take before it is canceled
+type TimeoutPolicy struct {
+	// Default applies to every route without an override, 0 disables the timeout
+	Default time.Duration
+	// Routes overrides the timeout per route pattern, ex: /v1/profiles/{contract_address}/{acc_addr}
+	Routes map[string]time.Duration
+}
+
+// DefaultTimeoutPolicy gives every request 30 seconds
+var DefaultTimeoutPolicy = TimeoutPolicy{
+	Default: 30 * time.Second,
+}
+
+// timeout returns the timeout of the route that matches the request
+func (p TimeoutPolicy) timeout(r *http.Request) time.Duration {
+	if len(p.Routes) == 0 {
+		return p.Default
+	}
+
+	ctx, _ := r.Context().Value(chi.RouteCtxKey).(*chi.Context)
+	if ctx == nil {
+		return p.Default
+	}
+
+	nctx := chi.NewRouteContext()
+	if !ctx.Routes.Match(nctx, r.Method, r.URL.Path) {
+		return p.Default
+	}
+
+	if t, ok := p.Routes[nctx.RoutePattern()]; ok {
+		return t
+	}
+
+	return p.Default
+}
+
+// TimeoutMiddleware cancels the context of requests that take too long and responds with a 504 json error
+//
+// the response is buffered so that a handler that ignores the cancellation can't write after the timeout,
+// websocket upgrades are long lived and are never timed out, neither are they by the connection policy
+func TimeoutMiddleware(p TimeoutPolicy) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			t := p.timeout(r)
+			if t <= 0 || strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+				// the connection timeouts would cut the stream off as well
+				clearDeadlines(w)
+
+				h.ServeHTTP(w, r)
+				return
+			}
+
+			ctx, cancel := context.WithTimeout(r.Context(), t)
+			defer cancel()
+
+			// chi reuses its route context once ServeHTTP returns, the handler could still be using it after a timeout
+			if rctx, ok := ctx.Value(chi.RouteCtxKey).(*chi.Context); ok {
+				ctx = context.WithValue(ctx, chi.RouteCtxKey, copyRouteContext(rctx))
+			}
+
+			tw := &timeoutWriter{header: make(http.Header)}
+
+			done := make(chan struct{})
+			panicked := make(chan any, 1)
+			go func() {
+				defer func() {
+					if pv := recover(); pv != nil {
+						panicked <- pv
+					}
+				}()
+
+				h.ServeHTTP(tw, r.WithContext(ctx))
+				close(done)
+			}()
+
+			select {
+			case pv := <-panicked:
+				// let the panic reach the middleware of the calling goroutine
+				panic(pv)
+			case <-done:
+				tw.mu.Lock()
+				defer tw.mu.Unlock()
+
+				for k, v := range tw.header {
+					w.Header()[k] = v
+				}
+
+				if tw.status == 0 {
+					tw.status = http.StatusOK
+				}
+
+				w.WriteHeader(tw.status)
+				w.Write(tw.body.Bytes())
+			case <-ctx.Done():
+				tw.mu.Lock()
+				defer tw.mu.Unlock()
+
+				tw.timedOut = true
+
+				com.WriteError(w, http.StatusGatewayTimeout, "the request timed out")
+			}
+		})
+	}
+}
+
+func copyRouteContext(rctx *chi.Context) *chi.Context {
+	nctx := chi.NewRouteContext()
+	nctx.Routes = rctx.Routes
+	nctx.RoutePath = rctx.RoutePath
+	nctx.RouteMethod = rctx.RouteMethod
+	nctx.RoutePatterns = append(nctx.RoutePatterns, rctx.RoutePatterns...)
+	nctx.URLParams.Keys = append(nctx.URLParams.Keys, rctx.URLParams.Keys...)
+	nctx.URLParams.Values = append(nctx.URLParams.Values, rctx.URLParams.Values...)
+
+	return nctx
+}
+
+// timeoutWriter buffers a response until the handler is done
+type timeoutWriter struct {
+	mu       sync.Mutex
+	header   http.Header
+	body     bytes.Buffer
+	status   int
+	timedOut bool
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.header
+}
+
+func (tw *timeoutWriter) Write(b []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+
+	if tw.status == 0 {
+		tw.status = http.StatusOK
+	}
+
+	return tw.body.Write(b)
+}
+
+func (tw *timeoutWriter) WriteHeader(status int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if tw.timedOut || tw.status != 0 {
+		return
+	}
+
+	tw.status = status
+}
@@ -0,0 +1,93 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestTimeoutMiddleware(t *testing.T) {
+	timedOut := `{"error":{"code":504,"message":"the request timed out"}}`
+
+	canceled := make(chan struct{}, 1)
+
+	cr := chi.NewRouter()
+	cr.Use(TimeoutMiddleware(TimeoutPolicy{
+		Default: 50 * time.Millisecond,
+		Routes: map[string]time.Duration{
+			"/slow/{id}": 200 * time.Millisecond,
+			"/stream":    0,
+		},
+	}))
+
+	cr.Get("/fast", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Test", "fast")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("ok"))
+	})
+	cr.Get("/blocking", func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		canceled <- struct{}{}
+	})
+	cr.Get("/ignoring", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(150 * time.Millisecond)
+		w.Write([]byte("too late"))
+	})
+	cr.Get("/slow/{id}", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+		w.Write([]byte("slow"))
+	})
+	cr.Get("/stream", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+		w.Write([]byte("stream"))
+	})
+
+	tests := []struct {
+		name   string
+		path   string
+		status int
+		body   string
+	}{
+		{"fast", "/fast", http.StatusCreated, "ok"},
+		{"blocking", "/blocking", http.StatusGatewayTimeout, timedOut},
+		{"ignoring the context", "/ignoring", http.StatusGatewayTimeout, timedOut},
+		{"route override", "/slow/1", http.StatusOK, "slow"},
+		{"disabled", "/stream", http.StatusOK, "stream"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			cr.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			if w.Code != tt.status {
+				t.Errorf("expected status %d, got %d", tt.status, w.Code)
+			}
+
+			if w.Body.String() != tt.body {
+				t.Errorf("expected body %q, got %q", tt.body, w.Body.String())
+			}
+		})
+	}
+
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Error("expected the context of the blocking handler to be canceled")
+	}
+
+	w := httptest.NewRecorder()
+	cr.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/fast", nil))
+	if w.Header().Get("X-Test") != "fast" {
+		t.Errorf("expected the handler headers to be copied, got %v", w.Header())
+	}
+
+	w = httptest.NewRecorder()
+	cr.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/blocking", nil))
+	if w.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("expected a json timeout error, got content type %q", w.Header().Get("Content-Type"))
+	}
+}
@@ -0,0 +1,81 @@
+package balances
+
+import (
+	"net/http"
+
+	"github.com/citizenwallet/engine/internal/db"
+	com "github.com/citizenwallet/engine/pkg/common"
+	"github.com/citizenwallet/engine/pkg/engine"
+	"github.com/go-chi/chi/v5"
+)
+
+type Service struct {
+	db *db.DB
+}
+
+func NewService(db *db.DB) *Service {
+	return &Service{
+		db: db,
+	}
+}
+
+type balanceResponse struct {
+	Contract string `json:"contract"`
+	Account  string `json:"account"`
+	Balance  string `json:"balance"`
+	Pending  bool   `json:"pending"`
+}
+
+// Get returns the balance of an account computed from the indexed Transfer logs
+//
+// the confirmed balance is read from the balances table which is updated together with every
+// success log the indexer inserts, it is only as fresh as the indexer and transfers that were
+// missed while the indexer was down are not counted, clients that need the exact on-chain
+// balance should still call the contract.
+//
+// with ?pending=true the optimistic sending and pending logs are summed on top of it, this gives
+// a balance that reacts instantly to a transfer but that can go back if the user operation fails
+// or is dropped.
+func (s *Service) Get(w http.ResponseWriter, r *http.Request) {
+	// parse contract address from url params
+	contract, err := com.NormalizeAddress(chi.URLParam(r, "contract_address"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	// parse address from url params
+	acc, err := com.NormalizeAddress(chi.URLParam(r, "acc_addr"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	pending := r.URL.Query().Get("pending") == "true"
+
+	balance, err := s.db.BalanceDB.GetBalance(contract, acc)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if pending {
+		inProgress, err := s.db.LogDB.GetBalance(r.Context(), contract, acc, []engine.LogStatus{engine.LogStatusSending, engine.LogStatusPending})
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		balance.Add(balance, inProgress)
+	}
+
+	err = com.Body(w, &balanceResponse{
+		Contract: contract,
+		Account:  acc,
+		Balance:  balance.String(),
+		Pending:  pending,
+	}, nil)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,256 @@
+package chain
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/citizenwallet/engine/pkg/engine"
+)
+
+const (
+	// Forever caches a result for as long as the engine runs
+	Forever = time.Duration(math.MaxInt64)
+
+	maxCacheEntries = 10000
+)
+
+// CachePolicy describes how long the results of an rpc method can be cached
+type CachePolicy struct {
+	TTL time.Duration
+
+	// Cacheable decides if a result can be cached, all results are cacheable if it is nil
+	Cacheable func(params json.RawMessage, result any) bool
+}
+
+// DefaultCachePolicies are the cache policies of the rpc methods that return immutable results
+var DefaultCachePolicies = map[string]CachePolicy{
+	"eth_chainId": {
+		TTL: Forever,
+	},
+	"eth_blockNumber": {
+		TTL: 1 * time.Second,
+	},
+	"eth_getBlockByNumber": {
+		TTL:       1 * time.Hour,
+		Cacheable: isNumberedBlock,
+	},
+	"eth_getTransactionReceipt": {
+		TTL:       1 * time.Hour,
+		Cacheable: isMined,
+	},
+}
+
+// isNumberedBlock returns true for blocks requested by number, a block tag like "latest" moves
+func isNumberedBlock(params json.RawMessage, result any) bool {
+	if result == nil {
+		return false
+	}
+
+	var p []json.RawMessage
+	if err := json.Unmarshal(params, &p); err != nil || len(p) == 0 {
+		return false
+	}
+
+	var number string
+	if err := json.Unmarshal(p[0], &number); err != nil {
+		return false
+	}
+
+	return strings.HasPrefix(number, "0x")
+}
+
+// isMined returns true once a receipt exists, before that the transaction is still pending
+func isMined(params json.RawMessage, result any) bool {
+	return result != nil
+}
+
+type cacheEntry struct {
+	result  any
+	expires time.Time // zero if the entry never expires
+}
+
+func (e cacheEntry) expired(now time.Time) bool {
+	return !e.expires.IsZero() && now.After(e.expires)
+}
+
+// cacheCall is a call of a handler that the concurrent requests for the same key wait for
+type cacheCall struct {
+	done   chan struct{}
+	result any
+	err    error
+}
+
+// Cache caches the results of rpc methods according to their policy
+type Cache struct {
+	policies map[string]CachePolicy
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+
+	// calls coalesces the requests that miss the cache at the same time, ex: wallets polling for the same receipt
+	calls map[string]*cacheCall
+}
+
+// NewCache creates a cache with the default policies, ttls overrides the ttl of a method, a ttl of 0 disables caching
+func NewCache(ttls map[string]time.Duration) *Cache {
+	policies := make(map[string]CachePolicy, len(DefaultCachePolicies))
+	for method, policy := range DefaultCachePolicies {
+		policies[method] = policy
+	}
+
+	for method, ttl := range ttls {
+		policy := policies[method]
+		policy.TTL = ttl
+
+		policies[method] = policy
+	}
+
+	return &Cache{
+		policies: policies,
+		entries:  make(map[string]cacheEntry),
+		calls:    make(map[string]*cacheCall),
+	}
+}
+
+// Wrap caches the results of h for method, h is returned as is if the method is not cacheable
+func (c *Cache) Wrap(method string, h engine.RPCHandlerFunc) engine.RPCHandlerFunc {
+	policy, ok := c.policies[method]
+	if !ok || policy.TTL <= 0 {
+		return h
+	}
+
+	return func(r *http.Request) (any, error) {
+		params, err := io.ReadAll(r.Body)
+		if err != nil {
+			return nil, err
+		}
+
+		// params that only differ in whitespace share the same entry
+		var compact bytes.Buffer
+		if err := json.Compact(&compact, params); err != nil {
+			compact.Reset()
+			compact.Write(params)
+		}
+
+		key := method + ":" + compact.String()
+
+		if result, ok := c.get(key); ok {
+			return result, nil
+		}
+
+		r.Body = io.NopCloser(bytes.NewReader(params))
+
+		call, first := c.join(key)
+		if !first {
+			select {
+			case <-call.done:
+			case <-r.Context().Done():
+				return nil, r.Context().Err()
+			}
+
+			// the error could be specific to the first request (ex: it was canceled), the others try on their own
+			if call.err == nil {
+				return call.result, nil
+			}
+
+			return h(r)
+		}
+
+		result, err := h(r)
+		if err == nil && (policy.Cacheable == nil || policy.Cacheable(params, result)) {
+			c.set(key, result, policy.TTL)
+		}
+
+		c.leave(key, call, result, err)
+
+		return result, err
+	}
+}
+
+// join returns the call in flight for key, first is true if there was none and the caller has to make it
+func (c *Cache) join(key string) (call *cacheCall, first bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if call, ok := c.calls[key]; ok {
+		return call, false
+	}
+
+	call = &cacheCall{done: make(chan struct{})}
+	c.calls[key] = call
+
+	return call, true
+}
+
+// leave stores the outcome of a call and releases the requests that are waiting for it
+func (c *Cache) leave(key string, call *cacheCall, result any, err error) {
+	c.mu.Lock()
+	delete(c.calls, key)
+	c.mu.Unlock()
+
+	call.result, call.err = result, err
+	close(call.done)
+}
+
+func (c *Cache) get(key string) (any, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	if entry.expired(time.Now()) {
+		delete(c.entries, key)
+		return nil, false
+	}
+
+	return entry.result, true
+}
+
+func (c *Cache) set(key string, result any, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.entries) >= maxCacheEntries {
+		c.evict()
+	}
+
+	var expires time.Time
+	if ttl != Forever {
+		expires = time.Now().Add(ttl)
+	}
+
+	c.entries[key] = cacheEntry{result: result, expires: expires}
+}
+
+// evict removes expired entries, if the cache is still full an arbitrary half of it is dropped
+func (c *Cache) evict() {
+	now := time.Now()
+	for key, entry := range c.entries {
+		if entry.expired(now) {
+			delete(c.entries, key)
+		}
+	}
+
+	if len(c.entries) < maxCacheEntries {
+		return
+	}
+
+	n := len(c.entries) / 2
+	for key := range c.entries {
+		if n == 0 {
+			break
+		}
+
+		delete(c.entries, key)
+		n--
+	}
+}
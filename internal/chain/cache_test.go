@@ -0,0 +1,183 @@
+package chain
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingHandler returns result and counts how often it was called
+func countingHandler(calls *int, result any) func(r *http.Request) (any, error) {
+	return func(r *http.Request) (any, error) {
+		*calls++
+
+		if _, err := io.ReadAll(r.Body); err != nil {
+			return nil, err
+		}
+
+		return result, nil
+	}
+}
+
+func call(t *testing.T, h func(r *http.Request) (any, error), params string) any {
+	t.Helper()
+
+	r, err := http.NewRequest(http.MethodPost, "/", strings.NewReader(params))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := h(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return result
+}
+
+func TestCacheWrap(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   string
+		ttls     map[string]time.Duration
+		result   any
+		params   []string
+		expected int
+	}{
+		{
+			name:     "numbered block",
+			method:   "eth_getBlockByNumber",
+			result:   map[string]any{"number": "0x1"},
+			params:   []string{`["0x1", false]`, `["0x1",false]`},
+			expected: 1,
+		},
+		{
+			name:     "latest block",
+			method:   "eth_getBlockByNumber",
+			result:   map[string]any{"number": "0x1"},
+			params:   []string{`["latest", false]`, `["latest", false]`},
+			expected: 2,
+		},
+		{
+			name:     "pending receipt",
+			method:   "eth_getTransactionReceipt",
+			result:   nil,
+			params:   []string{`["0xabc"]`, `["0xabc"]`},
+			expected: 2,
+		},
+		{
+			name:     "mined receipt",
+			method:   "eth_getTransactionReceipt",
+			result:   map[string]any{"status": "0x1"},
+			params:   []string{`["0xabc"]`, `["0xabc"]`, `["0xdef"]`},
+			expected: 2,
+		},
+		{
+			name:     "uncached method",
+			method:   "eth_call",
+			result:   "0x",
+			params:   []string{`[{}]`, `[{}]`},
+			expected: 2,
+		},
+		{
+			name:     "disabled by config",
+			method:   "eth_chainId",
+			ttls:     map[string]time.Duration{"eth_chainId": 0},
+			result:   "100",
+			params:   []string{`[]`, `[]`},
+			expected: 2,
+		},
+		{
+			name:     "enabled by config",
+			method:   "eth_getCode",
+			ttls:     map[string]time.Duration{"eth_getCode": time.Minute},
+			result:   "0x60",
+			params:   []string{`["0x1", "latest"]`, `["0x1", "latest"]`},
+			expected: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			h := NewCache(tt.ttls).Wrap(tt.method, countingHandler(&calls, tt.result))
+
+			for _, params := range tt.params {
+				call(t, h, params)
+			}
+
+			if calls != tt.expected {
+				t.Errorf("expected %d calls, got %d", tt.expected, calls)
+			}
+		})
+	}
+}
+
+func TestCacheExpires(t *testing.T) {
+	c := NewCache(map[string]time.Duration{"eth_blockNumber": 10 * time.Millisecond})
+
+	calls := 0
+	h := c.Wrap("eth_blockNumber", countingHandler(&calls, "0x1"))
+
+	call(t, h, `[]`)
+	call(t, h, `[]`)
+
+	time.Sleep(20 * time.Millisecond)
+
+	call(t, h, `[]`)
+
+	if calls != 2 {
+		t.Errorf("expected %d calls, got %d", 2, calls)
+	}
+}
+
+func TestCacheCoalesces(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected int32
+	}{
+		{"pending receipt", nil, 1},
+		{"failed call", errors.New("connection refused"), 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			release := make(chan struct{})
+
+			h := NewCache(nil).Wrap("eth_getTransactionReceipt", func(r *http.Request) (any, error) {
+				if calls.Add(1) == 1 {
+					<-release
+				}
+
+				return nil, tt.err
+			})
+
+			var wg sync.WaitGroup
+			for i := 0; i < 5; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+
+					r, _ := http.NewRequest(http.MethodPost, "/", strings.NewReader(`["0xabc"]`))
+					h(r)
+				}()
+			}
+
+			// let the requests pile up behind the first one
+			time.Sleep(50 * time.Millisecond)
+			close(release)
+			wg.Wait()
+
+			if calls.Load() != tt.expected {
+				t.Errorf("expected %d calls, got %d", tt.expected, calls.Load())
+			}
+		})
+	}
+}
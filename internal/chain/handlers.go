@@ -2,10 +2,18 @@ package chain
 
 import (
 	"encoding/json"
+	"errors"
 	"math/big"
 	"net/http"
 
+	com "github.com/citizenwallet/engine/pkg/common"
 	"github.com/citizenwallet/engine/pkg/engine"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+var (
+	ErrMissingParams = errors.New("missing params")
 )
 
 type Service struct {
@@ -34,10 +42,10 @@ func (s *Service) EthCall(r *http.Request) (any, error) {
 	}
 
 	var result any
-	err := s.evm.Call("eth_call", &result, params)
+	err := s.evm.Call(r.Context(), "eth_call", &result, params)
 	if err != nil {
 		println(err.Error())
-		return nil, err
+		return nil, com.WithRevertReason(err)
 	}
 
 	return result, nil
@@ -51,7 +59,7 @@ func (s *Service) EthBlockNumber(r *http.Request) (any, error) {
 	}
 
 	var result any
-	err := s.evm.Call("eth_blockNumber", &result, params)
+	err := s.evm.Call(r.Context(), "eth_blockNumber", &result, params)
 	if err != nil {
 		println(err.Error())
 		return nil, err
@@ -68,7 +76,7 @@ func (s *Service) EthGetBlockByNumber(r *http.Request) (any, error) {
 	}
 
 	var result any
-	err := s.evm.Call("eth_getBlockByNumber", &result, params)
+	err := s.evm.Call(r.Context(), "eth_getBlockByNumber", &result, params)
 	if err != nil {
 		println(err.Error())
 		return nil, err
@@ -85,7 +93,7 @@ func (s *Service) EthMaxPriorityFeePerGas(r *http.Request) (any, error) {
 	}
 
 	var result any
-	err := s.evm.Call("eth_maxPriorityFeePerGas", &result, params)
+	err := s.evm.Call(r.Context(), "eth_maxPriorityFeePerGas", &result, params)
 	if err != nil {
 		println(err.Error())
 		return nil, err
@@ -103,7 +111,144 @@ func (s *Service) EthGetTransactionReceipt(r *http.Request) (any, error) {
 	}
 
 	var result any
-	err := s.evm.Call("eth_getTransactionReceipt", &result, params)
+	err := s.evm.Call(r.Context(), "eth_getTransactionReceipt", &result, params)
+	if err != nil {
+		println(err.Error())
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (s *Service) EthGetCode(r *http.Request) (any, error) {
+
+	var params []json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		return nil, err
+	}
+
+	if len(params) < 1 {
+		return nil, ErrMissingParams
+	}
+
+	var addr common.Address
+	if err := json.Unmarshal(params[0], &addr); err != nil {
+		return nil, err
+	}
+
+	blockNumber, err := com.ParseBlockTag(paramAt(params, 1))
+	if err != nil {
+		return nil, err
+	}
+
+	code, err := s.evm.CodeAt(r.Context(), addr, blockNumber)
+	if err != nil {
+		println(err.Error())
+		return nil, err
+	}
+
+	return hexutil.Bytes(code), nil
+}
+
+func (s *Service) EthGetStorageAt(r *http.Request) (any, error) {
+
+	var params []json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		return nil, err
+	}
+
+	if len(params) < 2 {
+		return nil, ErrMissingParams
+	}
+
+	var addr common.Address
+	if err := json.Unmarshal(params[0], &addr); err != nil {
+		return nil, err
+	}
+
+	// slots can be sent as a quantity or as a full 32 byte hash
+	var slot string
+	if err := json.Unmarshal(params[1], &slot); err != nil {
+		return nil, err
+	}
+
+	blockNumber, err := com.ParseBlockTag(paramAt(params, 2))
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := s.evm.StorageAt(addr, common.HexToHash(slot), blockNumber)
+	if err != nil {
+		println(err.Error())
+		return nil, err
+	}
+
+	return hexutil.Bytes(value), nil
+}
+
+// paramAt returns the param at index i or nil if it was not sent
+func paramAt(params []json.RawMessage, i int) json.RawMessage {
+	if i >= len(params) {
+		return nil
+	}
+
+	return params[i]
+}
+
+var (
+	ErrInvalidBlockCount  = errors.New("invalid block count")
+	ErrInvalidPercentiles = errors.New("reward percentiles must be increasing values between 0 and 100")
+)
+
+func (s *Service) EthFeeHistory(r *http.Request) (any, error) {
+
+	var params []json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		return nil, err
+	}
+
+	if len(params) < 2 {
+		return nil, ErrMissingParams
+	}
+
+	// block count can be sent as a quantity or as a number
+	var blockCount hexutil.Uint64
+	if err := json.Unmarshal(params[0], &blockCount); err != nil {
+		var n uint64
+		if err := json.Unmarshal(params[0], &n); err != nil {
+			return nil, ErrInvalidBlockCount
+		}
+		blockCount = hexutil.Uint64(n)
+	}
+
+	if blockCount == 0 {
+		return nil, ErrInvalidBlockCount
+	}
+
+	if _, err := com.ParseBlockTag(params[1]); err != nil {
+		return nil, err
+	}
+
+	percentiles := []float64{}
+	if p := paramAt(params, 2); p != nil && string(p) != "null" {
+		if err := json.Unmarshal(p, &percentiles); err != nil {
+			return nil, ErrInvalidPercentiles
+		}
+	}
+
+	for i, p := range percentiles {
+		if p < 0 || p > 100 || (i > 0 && p < percentiles[i-1]) {
+			return nil, ErrInvalidPercentiles
+		}
+	}
+
+	args, err := json.Marshal([]any{blockCount, params[1], percentiles})
+	if err != nil {
+		return nil, err
+	}
+
+	var result any
+	err = s.evm.Call(r.Context(), "eth_feeHistory", &result, args)
 	if err != nil {
 		println(err.Error())
 		return nil, err
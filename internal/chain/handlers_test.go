@@ -0,0 +1,69 @@
+package chain
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/citizenwallet/engine/pkg/engine"
+)
+
+// callEVM records the calls that are forwarded to the node
+type callEVM struct {
+	engine.EVMRequester
+
+	method string
+	params string
+}
+
+func (e *callEVM) Call(ctx context.Context, method string, result any, params json.RawMessage) error {
+	e.method = method
+	e.params = string(params)
+
+	return nil
+}
+
+func TestEthFeeHistory(t *testing.T) {
+	tests := []struct {
+		name     string
+		params   string
+		expected string
+		err      bool
+	}{
+		{"quantity", `["0x5", "latest", [25, 75]]`, `["0x5","latest",[25,75]]`, false},
+		{"number", `[5, "0x10"]`, `["0x5","0x10",[]]`, false},
+		{"missing newest block", `["0x5"]`, "", true},
+		{"zero blocks", `["0x0", "latest"]`, "", true},
+		{"invalid block tag", `["0x5", "tomorrow"]`, "", true},
+		{"decreasing percentiles", `["0x5", "latest", [75, 25]]`, "", true},
+		{"percentile out of range", `["0x5", "latest", [101]]`, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			evm := &callEVM{}
+			s := NewService(evm, big.NewInt(1))
+
+			r, err := http.NewRequest(http.MethodPost, "/", strings.NewReader(tt.params))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = s.EthFeeHistory(r)
+			if (err != nil) != tt.err {
+				t.Fatalf("expected error %v, got %v", tt.err, err)
+			}
+
+			if tt.err {
+				return
+			}
+
+			if evm.method != "eth_feeHistory" || evm.params != tt.expected {
+				t.Errorf("expected eth_feeHistory %s, got %s %s", tt.expected, evm.method, evm.params)
+			}
+		})
+	}
+}
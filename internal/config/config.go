@@ -2,8 +2,15 @@ package config
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
+	"math/big"
+	"strings"
+	"time"
 
+	com "github.com/citizenwallet/engine/pkg/common"
+	"github.com/citizenwallet/engine/pkg/engine"
 	"github.com/joho/godotenv"
 	"github.com/sethvargo/go-envconfig"
 )
@@ -18,10 +25,87 @@ type Config struct {
 	DBHost          string `env:"DB_HOST,required"`
 	DBPort          string `env:"DB_PORT,required"`
 	DBReaderHost    string `env:"DB_READER_HOST,required"`
-	DBSecret        string `env:"DB_SECRET,required"`
+	DBSecret        string `env:"DB_SECRET"`
 	PinataBaseURL   string `env:"PINATA_BASE_URL"`
 	PinataAPIKey    string `env:"PINATA_API_KEY"`
 	PinataAPISecret string `env:"PINATA_API_SECRET"`
+	AdminAPIKey     string `env:"ADMIN_API_KEY"`
+
+	KeyCipher string `env:"KEY_CIPHER,default=local"`
+	KMSKeyID  string `env:"KMS_KEY_ID"`
+
+	SupportedEntryPoints []string `env:"SUPPORTED_ENTRYPOINTS"`
+
+	SponsorStrategy         engine.SponsorStrategy `env:"SPONSOR_STRATEGY,default=round-robin"`
+	SponsorBalanceThreshold string                 `env:"SPONSOR_BALANCE_THRESHOLD,default=100000000000000000"`
+	SponsorCheckInterval    time.Duration          `env:"SPONSOR_CHECK_INTERVAL,default=5m"`
+
+	WebhookURL string `env:"WEBHOOK_URL"`
+
+	OTLPEndpoint string `env:"OTEL_EXPORTER_OTLP_ENDPOINT"`
+
+	SignatureMaxValidity  time.Duration `env:"SIGNATURE_MAX_VALIDITY"`
+	SignatureRequireNonce bool          `env:"SIGNATURE_REQUIRE_NONCE"`
+
+	CORSAllowedOrigins   []string      `env:"CORS_ALLOWED_ORIGINS"`
+	CORSAllowedMethods   []string      `env:"CORS_ALLOWED_METHODS"`
+	CORSAllowedHeaders   []string      `env:"CORS_ALLOWED_HEADERS"`
+	CORSAllowCredentials bool          `env:"CORS_ALLOW_CREDENTIALS"`
+	CORSMaxAge           time.Duration `env:"CORS_MAX_AGE"`
+
+	RequestTimeout  time.Duration            `env:"REQUEST_TIMEOUT"`
+	RequestTimeouts map[string]time.Duration `env:"REQUEST_TIMEOUTS"`
+	PollTimeout     time.Duration            `env:"POLL_TIMEOUT"`
+
+	HTTPReadHeaderTimeout time.Duration `env:"HTTP_READ_HEADER_TIMEOUT"`
+	HTTPReadTimeout       time.Duration `env:"HTTP_READ_TIMEOUT"`
+	HTTPWriteTimeout      time.Duration `env:"HTTP_WRITE_TIMEOUT"`
+	HTTPIdleTimeout       time.Duration `env:"HTTP_IDLE_TIMEOUT"`
+	HTTPMaxHeaderBytes    int           `env:"HTTP_MAX_HEADER_BYTES"`
+
+	TLSCertFile      string   `env:"TLS_CERT_FILE"`
+	TLSKeyFile       string   `env:"TLS_KEY_FILE"`
+	AutocertDomains  []string `env:"TLS_AUTOCERT_DOMAINS"`
+	AutocertCacheDir string   `env:"TLS_AUTOCERT_CACHE_DIR,default=.autocert"`
+
+	CompressionLevel   int `env:"COMPRESSION_LEVEL"`
+	CompressionMinSize int `env:"COMPRESSION_MIN_SIZE"`
+
+	RPCCacheTTLs map[string]time.Duration `env:"RPC_CACHE_TTLS"`
+
+	RPCMonitorInterval time.Duration `env:"RPC_MONITOR_INTERVAL"`
+	RPCStaleAfter      time.Duration `env:"RPC_STALE_AFTER"`
+	RPCMaxLatency      time.Duration `env:"RPC_MAX_LATENCY"`
+
+	RPCBreakerThreshold int           `env:"RPC_BREAKER_THRESHOLD"`
+	RPCBreakerCooldown  time.Duration `env:"RPC_BREAKER_COOLDOWN"`
+
+	UserOpBatchMinWait  time.Duration `env:"USEROP_BATCH_MIN_WAIT"`
+	UserOpBatchMaxWait  time.Duration `env:"USEROP_BATCH_MAX_WAIT"`
+	UserOpInProgressTTL time.Duration `env:"USEROP_INPROGRESS_TTL"`
+	UserOpMaxBatchGas   uint64        `env:"USEROP_MAX_BATCH_GAS"`
+	UserOpMaxInFlight   int           `env:"USEROP_MAX_INFLIGHT_PER_SENDER"`
+
+	DBStatementTimeout time.Duration `env:"DB_STATEMENT_TIMEOUT"`
+	DBStatsTimeout     time.Duration `env:"DB_STATS_TIMEOUT"`
+	DBQueryExecMode    string        `env:"DB_QUERY_EXEC_MODE"`
+	DBStatementCache   int           `env:"DB_STATEMENT_CACHE_CAPACITY"`
+
+	EventsManifest string `env:"EVENTS_MANIFEST"`
+
+	IndexerConfirmationDepth uint64        `env:"INDEXER_CONFIRMATION_DEPTH"`
+	IndexerFinality          uint64        `env:"INDEXER_FINALITY_CONFIRMATIONS"`
+	IndexerReconcileWindow   uint64        `env:"INDEXER_RECONCILE_WINDOW"`
+	IndexerReconcileInterval time.Duration `env:"INDEXER_RECONCILE_INTERVAL"`
+
+	LogWebhookMaxFailures int `env:"LOG_WEBHOOK_MAX_FAILURES"`
+
+	WSOutboxRetention time.Duration `env:"WS_OUTBOX_RETENTION"`
+	WSCompression     bool          `env:"WS_COMPRESSION"`
+
+	FeePercentiles        map[string]float64 `env:"FEE_PERCENTILES"`
+	FeePriorityBuffers    map[string]int64   `env:"FEE_PRIORITY_BUFFERS"`
+	FeeBaseFeeMultipliers map[string]int64   `env:"FEE_BASE_FEE_MULTIPLIERS"`
 }
 
 func New(ctx context.Context, envpath string) (*Config, error) {
@@ -39,5 +123,60 @@ func New(ctx context.Context, envpath string) (*Config, error) {
 		return nil, err
 	}
 
+	if !cfg.SponsorStrategy.IsValid() {
+		return nil, fmt.Errorf("invalid SPONSOR_STRATEGY %q", cfg.SponsorStrategy)
+	}
+
 	return cfg, nil
 }
+
+// FeeSettings returns the fee settings per speed, speeds or values that are not configured keep their defaults
+func (c *Config) FeeSettings() map[engine.FeeSpeed]engine.FeeSettings {
+	settings := make(map[engine.FeeSpeed]engine.FeeSettings, len(engine.DefaultFeeSettings))
+	for speed, s := range engine.DefaultFeeSettings {
+		if v, ok := c.FeePercentiles[string(speed)]; ok {
+			s.Percentile = v
+		}
+
+		if v, ok := c.FeePriorityBuffers[string(speed)]; ok {
+			s.PriorityFeeBuffer = v
+		}
+
+		if v, ok := c.FeeBaseFeeMultipliers[string(speed)]; ok {
+			s.BaseFeeMultiplier = v
+		}
+
+		settings[speed] = s
+	}
+
+	return settings
+}
+
+// EntryPoints returns the entrypoints user operations can be submitted to, at least one is required
+func (c *Config) EntryPoints() (engine.EntryPoints, error) {
+	if len(c.SupportedEntryPoints) == 0 {
+		return nil, errors.New("SUPPORTED_ENTRYPOINTS is required")
+	}
+
+	eps := make(engine.EntryPoints, 0, len(c.SupportedEntryPoints))
+	for _, ep := range c.SupportedEntryPoints {
+		addr, err := com.ParseAddress(strings.TrimSpace(ep))
+		if err != nil {
+			return nil, fmt.Errorf("invalid entrypoint %q in SUPPORTED_ENTRYPOINTS: %w", ep, err)
+		}
+
+		eps = append(eps, addr)
+	}
+
+	return eps, nil
+}
+
+// SponsorBalanceThresholdWei returns the balance in wei below which a sponsor is considered low
+func (c *Config) SponsorBalanceThresholdWei() (*big.Int, error) {
+	threshold, ok := new(big.Int).SetString(c.SponsorBalanceThreshold, 10)
+	if !ok || threshold.Sign() < 0 {
+		return nil, fmt.Errorf("invalid SPONSOR_BALANCE_THRESHOLD %q", c.SponsorBalanceThreshold)
+	}
+
+	return threshold, nil
+}
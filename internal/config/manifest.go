@@ -0,0 +1,102 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	com "github.com/citizenwallet/engine/pkg/common"
+	"github.com/citizenwallet/engine/pkg/engine"
+	"gopkg.in/yaml.v3"
+)
+
+// ManifestEvent is an event that is declared in the events manifest
+type ManifestEvent struct {
+	Contract       string `json:"contract" yaml:"contract"`
+	EventSignature string `json:"event_signature" yaml:"event_signature"`
+	Name           string `json:"name" yaml:"name"` // defaults to the name of the event in the signature
+	Standard       string `json:"standard" yaml:"standard"`
+	StartBlock     int64  `json:"start_block" yaml:"start_block"` // 0 starts from the head when the event is added
+	Symbol         string `json:"symbol" yaml:"symbol"`
+	Decimals       int    `json:"decimals" yaml:"decimals"`
+}
+
+// EventManifest declares the events to index, it is read from a json or yaml file (.yaml or .yml)
+type EventManifest struct {
+	Events []ManifestEvent `json:"events" yaml:"events"`
+}
+
+// LoadEventManifest reads and validates the events of a manifest file
+func LoadEventManifest(path string) ([]*engine.Event, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var m EventManifest
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(b, &m)
+	default:
+		err = json.Unmarshal(b, &m)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid events manifest %s: %w", path, err)
+	}
+
+	return m.toEvents()
+}
+
+// toEvents validates the events of the manifest, an event can only be declared once
+func (m *EventManifest) toEvents() ([]*engine.Event, error) {
+	events := make([]*engine.Event, 0, len(m.Events))
+	seen := map[string]bool{}
+
+	for i, me := range m.Events {
+		contract, err := com.NormalizeAddress(me.Contract)
+		if err != nil {
+			return nil, fmt.Errorf("event %d: invalid contract %q", i, me.Contract)
+		}
+
+		ev := &engine.Event{
+			Contract:       contract,
+			EventSignature: strings.TrimSpace(me.EventSignature),
+			Name:           me.Name,
+			Standard:       me.Standard,
+			Symbol:         me.Symbol,
+			Decimals:       me.Decimals,
+			State:          engine.EventStateActive,
+			LastBlock:      me.StartBlock,
+		}
+
+		err = ev.ValidateEventSignature()
+		if err != nil {
+			return nil, fmt.Errorf("event %d: %w", i, err)
+		}
+
+		if me.StartBlock < 0 {
+			return nil, fmt.Errorf("event %d: invalid start_block %d", i, me.StartBlock)
+		}
+
+		if me.Decimals < 0 {
+			return nil, fmt.Errorf("event %d: invalid decimals %d", i, me.Decimals)
+		}
+
+		if ev.Name == "" {
+			ev.Name, _, _ = ev.ParseEventSignature()
+		}
+
+		key := ev.Contract + ev.EventSignature
+		if seen[key] {
+			return nil, fmt.Errorf("event %d: %s %s is declared more than once", i, ev.Contract, ev.EventSignature)
+		}
+		seen[key] = true
+
+		events = append(events, ev)
+	}
+
+	return events, nil
+}
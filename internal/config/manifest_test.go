@@ -0,0 +1,81 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/citizenwallet/engine/pkg/engine"
+)
+
+func writeManifest(t *testing.T, name, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestLoadEventManifest(t *testing.T) {
+	want := engine.Event{
+		Contract:       "0x5815E61eF72c9E6107b5c5A05FD121F334f7a7f1",
+		EventSignature: "Transfer(address indexed from, address indexed to, uint256 value)",
+		Name:           "Transfer",
+		Standard:       "erc20",
+		Symbol:         "EURe",
+		Decimals:       18,
+		State:          engine.EventStateActive,
+		LastBlock:      100,
+	}
+
+	manifests := map[string]string{
+		"events.json": `{"events": [{"contract": "0x5815e61ef72c9e6107b5c5a05fd121f334f7a7f1", "event_signature": "Transfer(address indexed from, address indexed to, uint256 value)", "standard": "erc20", "start_block": 100, "symbol": "EURe", "decimals": 18}]}`,
+		"events.yaml": `
+events:
+  - contract: "0x5815e61ef72c9e6107b5c5a05fd121f334f7a7f1"
+    event_signature: Transfer(address indexed from, address indexed to, uint256 value)
+    standard: erc20
+    start_block: 100
+    symbol: EURe
+    decimals: 18
+`,
+	}
+
+	for name, content := range manifests {
+		t.Run(name, func(t *testing.T) {
+			evs, err := LoadEventManifest(writeManifest(t, name, content))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(evs) != 1 || *evs[0] != want {
+				t.Fatalf("unexpected events: %+v", evs)
+			}
+		})
+	}
+}
+
+func TestLoadEventManifestInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"invalid contract", `{"events": [{"contract": "0x1234", "event_signature": "Transfer(address from, address to, uint256 value)"}]}`},
+		{"missing parenthesis", `{"events": [{"contract": "0x5815E61eF72c9E6107b5c5A05FD121F334f7a7f1", "event_signature": "Transfer(address from, address to, uint256 value"}]}`},
+		{"empty argument", `{"events": [{"contract": "0x5815E61eF72c9E6107b5c5A05FD121F334f7a7f1", "event_signature": "Transfer(address from,, uint256 value)"}]}`},
+		{"negative start block", `{"events": [{"contract": "0x5815E61eF72c9E6107b5c5A05FD121F334f7a7f1", "event_signature": "Transfer(address from, address to, uint256 value)", "start_block": -1}]}`},
+		{"duplicate", `{"events": [{"contract": "0x5815E61eF72c9E6107b5c5A05FD121F334f7a7f1", "event_signature": "Transfer(address from, address to, uint256 value)"}, {"contract": "0x5815e61ef72c9e6107b5c5a05fd121f334f7a7f1", "event_signature": "Transfer(address from, address to, uint256 value)"}]}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadEventManifest(writeManifest(t, "events.json", tt.content))
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package contracts
+
+import (
+	"errors"
+	"net/http"
+
+	com "github.com/citizenwallet/engine/pkg/common"
+	"github.com/citizenwallet/engine/pkg/engine"
+	"github.com/ethereum/go-ethereum"
+	"github.com/go-chi/chi/v5"
+)
+
+type Service struct {
+	evm engine.EVMRequester
+}
+
+func NewService(evm engine.EVMRequester) *Service {
+	return &Service{
+		evm: evm,
+	}
+}
+
+type readResponse struct {
+	Method string `json:"method"`
+	Result any    `json:"result"`
+}
+
+// Read calls a standard ERC-20 or ERC-721 read method of a contract, args are passed in order as repeated query params
+//
+// ex: /v1/contracts/{contract_address}/read/balanceOf?args=0x...
+func (s *Service) Read(w http.ResponseWriter, r *http.Request) {
+	contract, err := com.ParseAddress(chi.URLParam(r, "contract_address"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	method := chi.URLParam(r, "method")
+
+	data, m, err := encodeCall(method, r.URL.Query()["args"])
+	if err != nil {
+		if errors.Is(err, ErrUnknownMethod) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result, err := s.evm.CallContract(ethereum.CallMsg{
+		To:   &contract,
+		Data: data,
+	}, nil)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	value, err := decodeResult(m, result)
+	if err != nil {
+		// contracts that don't implement the method return nothing
+		http.Error(w, "contract does not implement "+method, http.StatusBadRequest)
+		return
+	}
+
+	err = com.Body(w, &readResponse{Method: method, Result: value}, nil)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
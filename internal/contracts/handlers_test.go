@@ -0,0 +1,96 @@
+package contracts
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/citizenwallet/engine/pkg/engine"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/go-chi/chi/v5"
+)
+
+type readEVM struct {
+	engine.EVMRequester
+	calls map[string][]byte // results by selector
+}
+
+func (e *readEVM) CallContract(call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	result, ok := e.calls[string(call.Data[:4])]
+	if !ok {
+		return nil, errors.New("execution reverted")
+	}
+
+	return result, nil
+}
+
+func TestRead(t *testing.T) {
+	owner := common.HexToAddress("0x1")
+
+	balance, _ := readMethods.Methods["balanceOf"].Outputs.Pack(big.NewInt(1234))
+	symbol, _ := readMethods.Methods["symbol"].Outputs.Pack("CTZN")
+	decimals, _ := readMethods.Methods["decimals"].Outputs.Pack(uint8(6))
+	ownerOf, _ := readMethods.Methods["ownerOf"].Outputs.Pack(owner)
+
+	evm := &readEVM{calls: map[string][]byte{
+		string(readMethods.Methods["balanceOf"].ID): balance,
+		string(readMethods.Methods["symbol"].ID):    symbol,
+		string(readMethods.Methods["decimals"].ID):  decimals,
+		string(readMethods.Methods["ownerOf"].ID):   ownerOf,
+		string(readMethods.Methods["name"].ID):      {},
+	}}
+
+	cr := chi.NewRouter()
+	cr.Get("/contracts/{contract_address}/read/{method}", NewService(evm).Read)
+
+	contract := "/contracts/0x5815E61eF72c9E6107b5c5A05FD121F334f7a7f1/read/"
+
+	tests := []struct {
+		name   string
+		path   string
+		status int
+		result any
+	}{
+		{"uint256", contract + "balanceOf?args=" + owner.Hex(), http.StatusOK, "1234"},
+		{"string", contract + "symbol", http.StatusOK, "CTZN"},
+		{"uint8", contract + "decimals", http.StatusOK, float64(6)},
+		{"address", contract + "ownerOf?args=0x10", http.StatusOK, owner.Hex()},
+		{"unknown method", contract + "mint", http.StatusNotFound, nil},
+		{"missing args", contract + "balanceOf", http.StatusBadRequest, nil},
+		{"invalid args", contract + "balanceOf?args=nope", http.StatusBadRequest, nil},
+		{"reverts", contract + "totalSupply", http.StatusBadRequest, nil},
+		{"not implemented", contract + "name", http.StatusBadRequest, nil},
+		{"invalid contract", "/contracts/nope/read/symbol", http.StatusBadRequest, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			cr.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			if w.Code != tt.status {
+				t.Fatalf("expected status %d, got %d: %s", tt.status, w.Code, w.Body.String())
+			}
+
+			if tt.status != http.StatusOK {
+				return
+			}
+
+			var resp struct {
+				Object readResponse `json:"object"`
+			}
+			if err := json.NewDecoder(bytes.NewReader(w.Body.Bytes())).Decode(&resp); err != nil {
+				t.Fatal(err)
+			}
+
+			if resp.Object.Result != tt.result {
+				t.Errorf("expected %v, got %v", tt.result, resp.Object.Result)
+			}
+		})
+	}
+}
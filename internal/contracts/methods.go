@@ -0,0 +1,114 @@
+package contracts
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+
+	com "github.com/citizenwallet/engine/pkg/common"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+var (
+	ErrUnknownMethod = errors.New("unknown method")
+	ErrInvalidArgs   = errors.New("invalid arguments")
+)
+
+// readABI holds the standard ERC-20 and ERC-721 read methods, balanceOf, name and symbol are shared by both
+const readABI = `[
+	{"type":"function","name":"name","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"string"}]},
+	{"type":"function","name":"symbol","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"string"}]},
+	{"type":"function","name":"decimals","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"uint8"}]},
+	{"type":"function","name":"totalSupply","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"uint256"}]},
+	{"type":"function","name":"balanceOf","stateMutability":"view","inputs":[{"name":"owner","type":"address"}],"outputs":[{"name":"","type":"uint256"}]},
+	{"type":"function","name":"allowance","stateMutability":"view","inputs":[{"name":"owner","type":"address"},{"name":"spender","type":"address"}],"outputs":[{"name":"","type":"uint256"}]},
+	{"type":"function","name":"ownerOf","stateMutability":"view","inputs":[{"name":"tokenId","type":"uint256"}],"outputs":[{"name":"","type":"address"}]},
+	{"type":"function","name":"tokenURI","stateMutability":"view","inputs":[{"name":"tokenId","type":"uint256"}],"outputs":[{"name":"","type":"string"}]},
+	{"type":"function","name":"getApproved","stateMutability":"view","inputs":[{"name":"tokenId","type":"uint256"}],"outputs":[{"name":"","type":"address"}]},
+	{"type":"function","name":"isApprovedForAll","stateMutability":"view","inputs":[{"name":"owner","type":"address"},{"name":"operator","type":"address"}],"outputs":[{"name":"","type":"bool"}]}
+]`
+
+var readMethods = func() abi.ABI {
+	parsed, err := abi.JSON(strings.NewReader(readABI))
+	if err != nil {
+		panic(err)
+	}
+
+	return parsed
+}()
+
+// encodeCall packs the calldata of a read method from its string arguments
+func encodeCall(method string, args []string) ([]byte, *abi.Method, error) {
+	m, ok := readMethods.Methods[method]
+	if !ok {
+		return nil, nil, ErrUnknownMethod
+	}
+
+	if len(args) != len(m.Inputs) {
+		return nil, nil, ErrInvalidArgs
+	}
+
+	values := make([]any, len(args))
+	for i, input := range m.Inputs {
+		v, err := parseArg(input.Type, args[i])
+		if err != nil {
+			return nil, nil, err
+		}
+
+		values[i] = v
+	}
+
+	data, err := m.Inputs.Pack(values...)
+	if err != nil {
+		return nil, nil, ErrInvalidArgs
+	}
+
+	return append(append([]byte{}, m.ID...), data...), &m, nil
+}
+
+func parseArg(t abi.Type, arg string) (any, error) {
+	switch t.T {
+	case abi.AddressTy:
+		addr, err := com.ParseAddress(arg)
+		if err != nil {
+			return nil, ErrInvalidArgs
+		}
+
+		return addr, nil
+	case abi.UintTy:
+		// decimal or 0x prefixed hex
+		n, ok := new(big.Int).SetString(arg, 0)
+		if !ok || n.Sign() < 0 {
+			return nil, ErrInvalidArgs
+		}
+
+		return n, nil
+	}
+
+	return nil, ErrInvalidArgs
+}
+
+// decodeResult unpacks the single output of a read method into a json friendly value
+func decodeResult(m *abi.Method, data []byte) (any, error) {
+	values, err := m.Outputs.Unpack(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(values) != 1 {
+		return nil, errors.New("unexpected result")
+	}
+
+	switch v := values[0].(type) {
+	case *big.Int:
+		// large numbers don't survive json numbers
+		return v.String(), nil
+	case common.Address:
+		return v.Hex(), nil
+	case uint8:
+		return int(v), nil
+	}
+
+	return values[0], nil
+}
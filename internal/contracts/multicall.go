@@ -0,0 +1,210 @@
+package contracts
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	com "github.com/citizenwallet/engine/pkg/common"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// Multicall3 is deployed at the same address on most chains, https://www.multicall3.com
+var multicall3Address = common.HexToAddress("0xcA11bde05977b3631167028862bE2a173976CA11")
+
+const maxMulticallCalls = 100
+
+var (
+	ErrNoCalls       = errors.New("no calls")
+	ErrTooManyCalls  = errors.New("too many calls")
+	ErrMulticallSize = errors.New("unexpected multicall result")
+)
+
+const multicall3ABI = `[{"type":"function","name":"aggregate3","stateMutability":"payable","inputs":[{"name":"calls","type":"tuple[]","components":[{"name":"target","type":"address"},{"name":"allowFailure","type":"bool"},{"name":"callData","type":"bytes"}]}],"outputs":[{"name":"returnData","type":"tuple[]","components":[{"name":"success","type":"bool"},{"name":"returnData","type":"bytes"}]}]}]`
+
+var multicall3 = func() abi.ABI {
+	parsed, err := abi.JSON(strings.NewReader(multicall3ABI))
+	if err != nil {
+		panic(err)
+	}
+
+	return parsed
+}()
+
+type call3 struct {
+	Target       common.Address
+	AllowFailure bool
+	CallData     []byte
+}
+
+type call3Result struct {
+	Success    bool
+	ReturnData []byte
+}
+
+// multicallRequest is either raw calldata or one of the typed read methods with its args
+type multicallRequest struct {
+	To     string        `json:"to"`
+	Data   hexutil.Bytes `json:"data,omitempty"`
+	Method string        `json:"method,omitempty"`
+	Args   []string      `json:"args,omitempty"`
+}
+
+type multicallResult struct {
+	Success    bool          `json:"success"`
+	ReturnData hexutil.Bytes `json:"returnData"`
+	Result     any           `json:"result,omitempty"` // decoded result of a typed call
+	Error      string        `json:"error,omitempty"`
+}
+
+// Multicall batches read calls into a single upstream call through Multicall3, every call succeeds or fails on its own
+//
+// params: [[{"to": "0x...", "data": "0x..."}, {"to": "0x...", "method": "balanceOf", "args": ["0x..."]}]]
+func (s *Service) Multicall(r *http.Request) (any, error) {
+	var params []json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		return nil, err
+	}
+
+	if len(params) == 0 {
+		return nil, ErrNoCalls
+	}
+
+	var reqs []multicallRequest
+	if err := json.Unmarshal(params[0], &reqs); err != nil {
+		return nil, err
+	}
+
+	if len(reqs) == 0 {
+		return nil, ErrNoCalls
+	}
+
+	if len(reqs) > maxMulticallCalls {
+		return nil, ErrTooManyCalls
+	}
+
+	results := make([]*multicallResult, len(reqs))
+	methods := make([]*abi.Method, len(reqs))
+	calls := []call3{}
+	indexes := []int{} // the request of each call
+
+	for i, req := range reqs {
+		target, data, m, err := prepareCall(req)
+		if err != nil {
+			results[i] = &multicallResult{Error: err.Error()}
+			continue
+		}
+
+		methods[i] = m
+		calls = append(calls, call3{Target: target, AllowFailure: true, CallData: data})
+		indexes = append(indexes, i)
+	}
+
+	if len(calls) > 0 {
+		returned, err := s.aggregate(calls)
+		if err != nil {
+			// chains without Multicall3 are called one by one
+			returned = s.callEach(calls)
+		}
+
+		for j, ret := range returned {
+			i := indexes[j]
+			results[i] = toResult(ret, methods[i])
+		}
+	}
+
+	return results, nil
+}
+
+// prepareCall returns the target and calldata of a request, typed calls are encoded
+func prepareCall(req multicallRequest) (common.Address, []byte, *abi.Method, error) {
+	target, err := com.ParseAddress(req.To)
+	if err != nil {
+		return common.Address{}, nil, nil, errors.New("invalid contract address")
+	}
+
+	if req.Method == "" {
+		return target, req.Data, nil, nil
+	}
+
+	data, m, err := encodeCall(req.Method, req.Args)
+	if err != nil {
+		return common.Address{}, nil, nil, err
+	}
+
+	return target, data, m, nil
+}
+
+func toResult(ret call3Result, m *abi.Method) *multicallResult {
+	result := &multicallResult{
+		Success:    ret.Success,
+		ReturnData: ret.ReturnData,
+	}
+
+	if !ret.Success {
+		result.Error = "execution reverted"
+		return result
+	}
+
+	if m == nil {
+		return result
+	}
+
+	value, err := decodeResult(m, ret.ReturnData)
+	if err != nil {
+		result.Success = false
+		result.Error = "contract does not implement " + m.Name
+		return result
+	}
+
+	result.Result = value
+
+	return result
+}
+
+// aggregate sends all calls in one aggregate3 call
+func (s *Service) aggregate(calls []call3) ([]call3Result, error) {
+	data, err := multicall3.Pack("aggregate3", calls)
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := s.evm.CallContract(ethereum.CallMsg{
+		To:   &multicall3Address,
+		Data: data,
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	values, err := multicall3.Unpack("aggregate3", out)
+	if err != nil || len(values) != 1 {
+		return nil, ErrMulticallSize
+	}
+
+	returned := *abi.ConvertType(values[0], new([]call3Result)).(*[]call3Result)
+	if len(returned) != len(calls) {
+		return nil, ErrMulticallSize
+	}
+
+	return returned, nil
+}
+
+// callEach sends the calls one by one
+func (s *Service) callEach(calls []call3) []call3Result {
+	returned := make([]call3Result, len(calls))
+	for i, c := range calls {
+		out, err := s.evm.CallContract(ethereum.CallMsg{
+			To:   &c.Target,
+			Data: c.CallData,
+		}, nil)
+
+		returned[i] = call3Result{Success: err == nil, ReturnData: out}
+	}
+
+	return returned
+}
@@ -0,0 +1,155 @@
+package contracts
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/citizenwallet/engine/pkg/engine"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+type multicallEVM struct {
+	engine.EVMRequester
+	calls       map[string][]byte // results by selector
+	noMulticall bool
+	upstream    int
+}
+
+func (e *multicallEVM) result(data []byte) ([]byte, bool) {
+	if len(data) < 4 {
+		return nil, false
+	}
+
+	result, ok := e.calls[string(data[:4])]
+	return result, ok
+}
+
+func (e *multicallEVM) CallContract(call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	e.upstream++
+
+	if *call.To != multicall3Address {
+		result, ok := e.result(call.Data)
+		if !ok {
+			return nil, errors.New("execution reverted")
+		}
+
+		return result, nil
+	}
+
+	if e.noMulticall {
+		return nil, nil
+	}
+
+	m := multicall3.Methods["aggregate3"]
+	values, err := m.Inputs.Unpack(call.Data[4:])
+	if err != nil {
+		return nil, err
+	}
+
+	calls := *abi.ConvertType(values[0], new([]call3)).(*[]call3)
+
+	returned := make([]call3Result, len(calls))
+	for i, c := range calls {
+		result, ok := e.result(c.CallData)
+		returned[i] = call3Result{Success: ok, ReturnData: result}
+	}
+
+	return m.Outputs.Pack(returned)
+}
+
+func TestMulticall(t *testing.T) {
+	balance, _ := readMethods.Methods["balanceOf"].Outputs.Pack(big.NewInt(42))
+	symbol, _ := readMethods.Methods["symbol"].Outputs.Pack("CTZN")
+
+	token := "0x5815E61eF72c9E6107b5c5A05FD121F334f7a7f1"
+	owner := common.HexToAddress("0x1").Hex()
+
+	body := `[[
+		{"to": "` + token + `", "method": "balanceOf", "args": ["` + owner + `"]},
+		{"to": "` + token + `", "data": "0x95d89b41"},
+		{"to": "` + token + `", "method": "totalSupply"},
+		{"to": "` + token + `", "method": "mint"},
+		{"to": "nope", "method": "symbol"}
+	]]`
+
+	for _, noMulticall := range []bool{false, true} {
+		evm := &multicallEVM{
+			calls: map[string][]byte{
+				string(readMethods.Methods["balanceOf"].ID): balance,
+				string(readMethods.Methods["symbol"].ID):    symbol,
+			},
+			noMulticall: noMulticall,
+		}
+
+		resp, err := NewService(evm).Multicall(httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		results := resp.([]*multicallResult)
+		if len(results) != 5 {
+			t.Fatalf("expected 5 results, got %d", len(results))
+		}
+
+		if !results[0].Success || results[0].Result != "42" {
+			t.Errorf("expected the decoded balance, got %+v", results[0])
+		}
+
+		if !results[1].Success || results[1].Result != nil || len(results[1].ReturnData) == 0 {
+			t.Errorf("expected the raw return data of an untyped call, got %+v", results[1])
+		}
+
+		for i, r := range results[2:] {
+			if r.Success || r.Error == "" {
+				t.Errorf("expected call %d to fail on its own, got %+v", i+2, r)
+			}
+		}
+
+		// typed calls that can't be encoded are never sent
+		expected := 1
+		if noMulticall {
+			expected = 1 + 3
+		}
+
+		if evm.upstream != expected {
+			t.Errorf("expected %d upstream calls, got %d", expected, evm.upstream)
+		}
+
+		b, err := json.Marshal(results)
+		if err != nil || !strings.Contains(string(b), `"returnData":"0x`) {
+			t.Errorf("expected hex encoded return data, got %s", b)
+		}
+	}
+}
+
+func TestMulticall_Limits(t *testing.T) {
+	s := NewService(&multicallEVM{})
+
+	tooMany := "[[" + strings.Repeat(`{"to": "0x1"},`, maxMulticallCalls) + `{"to": "0x1"}]]`
+
+	tests := []struct {
+		name string
+		body string
+		err  error
+	}{
+		{"no params", `[]`, ErrNoCalls},
+		{"no calls", `[[]]`, ErrNoCalls},
+		{"too many calls", tooMany, ErrTooManyCalls},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := s.Multicall(httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body)))
+			if err != tt.err {
+				t.Errorf("expected %v, got %v", tt.err, err)
+			}
+		})
+	}
+}
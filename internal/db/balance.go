@@ -0,0 +1,201 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/citizenwallet/engine/pkg/common"
+	"github.com/citizenwallet/engine/pkg/engine"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const zeroAddress = "0x0000000000000000000000000000000000000000"
+
+type BalanceDB struct {
+	ctx    context.Context
+	suffix string
+	db     *pgxpool.Pool
+	rdb    *pgxpool.Pool
+}
+
+// BalanceMismatch is an account for which the materialized balance does not match the sum of its transfers
+type BalanceMismatch struct {
+	Account      string `json:"account"`
+	Materialized string `json:"materialized"`
+	Computed     string `json:"computed"`
+}
+
+// NewBalanceDB creates a new DB
+func NewBalanceDB(ctx context.Context, db, rdb *pgxpool.Pool, name string) (*BalanceDB, error) {
+	if err := validateSuffix(name); err != nil {
+		return nil, err
+	}
+
+	bdb := &BalanceDB{
+		ctx:    ctx,
+		suffix: name,
+		db:     db,
+		rdb:    rdb,
+	}
+
+	return bdb, nil
+}
+
+// CreateBalanceTable creates a table to store the balances of accounts per contract
+func (db *BalanceDB) CreateBalanceTable() error {
+	_, err := db.db.Exec(db.ctx, fmt.Sprintf(`
+	CREATE TABLE IF NOT EXISTS t_balances_%s(
+		contract text NOT NULL,
+		account text NOT NULL,
+		balance numeric NOT NULL DEFAULT 0,
+		updated_at timestamp NOT NULL DEFAULT current_timestamp,
+		PRIMARY KEY (contract, account)
+	);
+	`, db.suffix))
+
+	return err
+}
+
+// CreateBalanceTableIndexes creates the indexes for the balances table
+func (db *BalanceDB) CreateBalanceTableIndexes() error {
+	suffix := common.ShortenName(db.suffix, 6)
+
+	_, err := db.db.Exec(db.ctx, fmt.Sprintf(`
+	CREATE INDEX IF NOT EXISTS idx_balances_%s_account ON t_balances_%s (account);
+	`, suffix, db.suffix))
+
+	return err
+}
+
+// transfers selects the balance delta of every account involved in the selected transfer logs
+func (db *BalanceDB) transfers(where string) string {
+	return fmt.Sprintf(`
+	SELECT dest AS contract, data->>'to' AS account, (data->>'value')::numeric AS delta
+	FROM t_logs_%s
+	WHERE data->>'topic' = '%s' AND status = 'success' AND %s
+	UNION ALL
+	SELECT dest AS contract, data->>'from' AS account, -(data->>'value')::numeric AS delta
+	FROM t_logs_%s
+	WHERE data->>'topic' = '%s' AND status = 'success' AND %s
+	`, db.suffix, engine.TransferTopic0.Hex(), where, db.suffix, engine.TransferTopic0.Hex(), where)
+}
+
+// applyLog adds the balance delta of a success log, a negative sign reverses it
+func (db *BalanceDB) applyLog(tx pgx.Tx, hash string, sign int) error {
+	_, err := tx.Exec(db.ctx, fmt.Sprintf(`
+	INSERT INTO t_balances_%s (contract, account, balance, updated_at)
+	SELECT contract, account, SUM(delta) * $2::int, current_timestamp
+	FROM (%s) d
+	WHERE account <> '%s'
+	GROUP BY contract, account
+	ON CONFLICT (contract, account) DO UPDATE SET
+		balance = t_balances_%s.balance + EXCLUDED.balance,
+		updated_at = EXCLUDED.updated_at
+	`, db.suffix, db.transfers("hash = $1"), zeroAddress, db.suffix), hash, sign)
+
+	return err
+}
+
+// reverseLogsFrom reverses the balance deltas of the success logs of a contract from a block onward
+func (db *BalanceDB) reverseLogsFrom(ctx context.Context, tx pgx.Tx, contract string, fromBlock int64) error {
+	_, err := tx.Exec(ctx, fmt.Sprintf(`
+	INSERT INTO t_balances_%s (contract, account, balance, updated_at)
+	SELECT contract, account, -SUM(delta), current_timestamp
+	FROM (%s) d
+	WHERE account <> '%s'
+	GROUP BY contract, account
+	ON CONFLICT (contract, account) DO UPDATE SET
+		balance = t_balances_%s.balance + EXCLUDED.balance,
+		updated_at = EXCLUDED.updated_at
+	`, db.suffix, db.transfers("dest = $1 AND block_number >= $2"), zeroAddress, db.suffix), contract, fromBlock)
+
+	return err
+}
+
+// GetBalance returns the materialized balance of an account
+func (db *BalanceDB) GetBalance(contract, account string) (*big.Int, error) {
+	var balance string
+	err := db.rdb.QueryRow(db.ctx, fmt.Sprintf(`
+	SELECT balance::text FROM t_balances_%s WHERE contract = $1 AND account = $2
+	`, db.suffix), contract, account).Scan(&balance)
+	if err == pgx.ErrNoRows {
+		return big.NewInt(0), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	b, ok := new(big.Int).SetString(balance, 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid balance: %s", balance)
+	}
+
+	return b, nil
+}
+
+// Rebuild recomputes the balances of a contract from all its success transfer logs
+func (db *BalanceDB) Rebuild(contract string) error {
+	tx, err := db.db.Begin(db.ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(db.ctx)
+
+	_, err = tx.Exec(db.ctx, fmt.Sprintf(`
+	DELETE FROM t_balances_%s WHERE contract = $1
+	`, db.suffix), contract)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(db.ctx, fmt.Sprintf(`
+	INSERT INTO t_balances_%s (contract, account, balance, updated_at)
+	SELECT contract, account, SUM(delta), current_timestamp
+	FROM (%s) d
+	WHERE account <> '%s'
+	GROUP BY contract, account
+	`, db.suffix, db.transfers("dest = $1"), zeroAddress), contract)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(db.ctx)
+}
+
+// Check compares the materialized balances of a contract against a full re-sum of its transfer logs
+func (db *BalanceDB) Check(contract string) ([]*BalanceMismatch, error) {
+	rows, err := db.rdb.Query(db.ctx, fmt.Sprintf(`
+	WITH computed AS (
+		SELECT account, SUM(delta) AS balance
+		FROM (%s) d
+		WHERE account <> '%s'
+		GROUP BY account
+	), materialized AS (
+		SELECT account, balance FROM t_balances_%s WHERE contract = $1
+	)
+	SELECT COALESCE(m.account, c.account), COALESCE(m.balance, 0)::text, COALESCE(c.balance, 0)::text
+	FROM materialized m
+	FULL OUTER JOIN computed c ON m.account = c.account
+	WHERE COALESCE(m.balance, 0) <> COALESCE(c.balance, 0)
+	ORDER BY 1
+	`, db.transfers("dest = $1"), zeroAddress, db.suffix), contract)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	mismatches := []*BalanceMismatch{}
+	for rows.Next() {
+		var m BalanceMismatch
+		err = rows.Scan(&m.Account, &m.Materialized, &m.Computed)
+		if err != nil {
+			return nil, err
+		}
+
+		mismatches = append(mismatches, &m)
+	}
+
+	return mismatches, rows.Err()
+}
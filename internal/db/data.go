@@ -18,6 +18,10 @@ type DataDB struct {
 
 // NewDataDB creates a new DB
 func NewDataDB(ctx context.Context, db, rdb *pgxpool.Pool, name string) (*DataDB, error) {
+	if err := validateSuffix(name); err != nil {
+		return nil, err
+	}
+
 	datadb := &DataDB{
 		ctx:    ctx,
 		suffix: name,
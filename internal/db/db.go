@@ -7,9 +7,12 @@ import (
 	"log"
 	"math/big"
 	"regexp"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
+	"github.com/citizenwallet/engine/pkg/engine"
 	"github.com/jackc/pgx/v5/pgxpool"
 	_ "github.com/jackc/pgx/v5/pgxpool"
 )
@@ -28,17 +31,32 @@ type DB struct {
 	db      *pgxpool.Pool
 	rdb     *pgxpool.Pool
 
-	EventDB     *EventDB
-	SponsorDB   *SponsorDB
-	LogDB       *LogDB
-	PushTokenDB map[string]*PushTokenDB
+	EventDB      *EventDB
+	SponsorDB    *SponsorDB
+	LogDB        *LogDB
+	BalanceDB    *BalanceDB
+	NonceDB      *NonceDB
+	UserOpDB     *UserOpDB
+	LogWebhookDB *LogWebhookDB
+	OutboxDB     *OutboxDB
+	PushTokenDB  map[string]*PushTokenDB
 }
 
 // NewDB instantiates a new DB
-func NewDB(chainID *big.Int, secret, username, password, dbname, port, host, rhost string) (*DB, error) {
+//
+// queries of the reader pool are aborted by postgres after the statement timeout, 0 disables it
+//
+// both pools send queries with the exec mode (see DefaultQueryExecMode) and keep cacheCapacity prepared statements per
+// connection
+func NewDB(chainID *big.Int, cipher engine.KeyCipher, username, password, dbname, port, host, rhost string, statementTimeout time.Duration, execMode string, cacheCapacity int) (*DB, error) {
 	ctx := context.Background()
 
-	connStr := fmt.Sprintf("user=%s password=%s dbname=%s host=%s port=%s sslmode=disable", username, password, dbname, host, port)
+	params, err := queryParams(execMode, cacheCapacity)
+	if err != nil {
+		return nil, err
+	}
+
+	connStr := fmt.Sprintf("user=%s password=%s dbname=%s host=%s port=%s sslmode=disable %s", username, password, dbname, host, port, params)
 	db, err := pgxpool.New(ctx, connStr)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
@@ -49,129 +67,96 @@ func NewDB(chainID *big.Int, secret, username, password, dbname, port, host, rho
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
-	evname := chainID.String()
+	// a pool of its own so that a runaway read can't block the writes of the indexer and the queues
+	rconfig, err := pgxpool.ParseConfig(connStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse database config: %w", err)
+	}
+
+	if statementTimeout > 0 {
+		rconfig.ConnConfig.RuntimeParams["statement_timeout"] = strconv.FormatInt(statementTimeout.Milliseconds(), 10)
+	}
 
-	eventDB, err := NewEventDB(ctx, db, db, evname)
+	rdb, err := pgxpool.NewWithConfig(ctx, rconfig)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
 
-	sponsorDB, err := NewSponsorDB(ctx, db, db, evname, secret)
+	evname := chainID.String()
+
+	eventDB, err := NewEventDB(ctx, db, rdb, evname)
 	if err != nil {
 		return nil, err
 	}
 
-	datadb, err := NewDataDB(ctx, db, db, evname)
+	sponsorDB, err := NewSponsorDB(ctx, db, rdb, evname, cipher)
 	if err != nil {
 		return nil, err
 	}
 
-	logDB, err := NewLogDB(ctx, db, db, evname, datadb)
+	datadb, err := NewDataDB(ctx, db, rdb, evname)
 	if err != nil {
 		return nil, err
 	}
 
-	d := &DB{
-		ctx:       ctx,
-		chainID:   chainID,
-		db:        db,
-		rdb:       db,
-		EventDB:   eventDB,
-		SponsorDB: sponsorDB,
-		LogDB:     logDB,
+	balanceDB, err := NewBalanceDB(ctx, db, rdb, evname)
+	if err != nil {
+		return nil, err
 	}
 
-	// check if db exists before opening, since we use rwc mode
-	exists, err := d.EventTableExists(evname)
+	logDB, err := NewLogDB(ctx, db, rdb, evname, datadb, balanceDB)
 	if err != nil {
 		return nil, err
 	}
 
-	if !exists {
-		// create table
-		err = eventDB.CreateEventsTable(evname)
-		if err != nil {
-			return nil, err
-		}
-
-		// create indexes
-		err = eventDB.CreateEventsTableIndexes(evname)
-		if err != nil {
-			return nil, err
-		}
+	nonceDB, err := NewNonceDB(ctx, db, rdb, evname)
+	if err != nil {
+		return nil, err
 	}
 
-	// check if db exists before opening, since we use rwc mode
-	exists, err = d.SponsorTableExists(evname)
+	userOpDB, err := NewUserOpDB(ctx, db, rdb, evname)
 	if err != nil {
 		return nil, err
 	}
 
-	if !exists {
-		// create table
-		err = sponsorDB.CreateSponsorsTable(evname)
-		if err != nil {
-			return nil, err
-		}
-
-		// create indexes
-		err = sponsorDB.CreateSponsorsTableIndexes(evname)
-		if err != nil {
-			return nil, err
-		}
+	logWebhookDB, err := NewLogWebhookDB(ctx, db, rdb, evname, cipher)
+	if err != nil {
+		return nil, err
 	}
 
-	log.Default().Println("creating transfer db for: ", evname)
-
-	// check if db exists before opening, since we use rwc mode
-	exists, err = d.LogTableExists(evname)
+	outboxDB, err := NewOutboxDB(ctx, db, rdb, evname)
 	if err != nil {
 		return nil, err
 	}
 
-	if !exists {
-		// create table
-		err = d.LogDB.CreateLogTable()
-		if err != nil {
-			return nil, err
-		}
-
-		// create indexes
-		err = d.LogDB.CreateLogTableIndexes()
-		if err != nil {
-			return nil, err
-		}
+	d := &DB{
+		ctx:          ctx,
+		chainID:      chainID,
+		db:           db,
+		rdb:          rdb,
+		EventDB:      eventDB,
+		SponsorDB:    sponsorDB,
+		LogDB:        logDB,
+		BalanceDB:    balanceDB,
+		NonceDB:      nonceDB,
+		UserOpDB:     userOpDB,
+		LogWebhookDB: logWebhookDB,
+		OutboxDB:     outboxDB,
 	}
 
-	log.Default().Println("creating data db for: ", evname)
-
-	// check if db exists before opening, since we use rwc mode
-	exists, err = d.DataTableExists(evname)
+	// the tables are created or updated by the migrations that were not applied yet
+	err = d.Migrate()
 	if err != nil {
 		return nil, err
 	}
 
-	if !exists {
-		// create table
-		err = datadb.CreateDataTable()
-		if err != nil {
-			return nil, err
-		}
-
-		// create indexes
-		err = datadb.CreateDataTableIndexes()
-		if err != nil {
-			return nil, err
-		}
-	}
-
-	ptdb := map[string]*PushTokenDB{}
-
-	evs, err := eventDB.GetEvents()
+	evs, err := d.EventDB.GetEvents(ctx)
 	if err != nil {
 		return nil, err
 	}
 
+	ptdb := map[string]*PushTokenDB{}
+
 	for _, ev := range evs {
 		name, err := d.TableNameSuffix(ev.Contract)
 		if err != nil {
@@ -180,13 +165,13 @@ func NewDB(chainID *big.Int, secret, username, password, dbname, port, host, rho
 
 		log.Default().Println("creating push token db for: ", name)
 
-		ptdb[name], err = NewPushTokenDB(ctx, db, db, name)
+		ptdb[name], err = NewPushTokenDB(ctx, db, rdb, name)
 		if err != nil {
 			return nil, err
 		}
 
 		// check if db exists before opening, since we use rwc mode
-		exists, err = d.PushTokenTableExists(name)
+		exists, err := d.PushTokenTableExists(name)
 		if err != nil {
 			return nil, err
 		}
@@ -197,20 +182,52 @@ func NewDB(chainID *big.Int, secret, username, password, dbname, port, host, rho
 			if err != nil {
 				return nil, err
 			}
-
-			// create indexes
-			err = ptdb[name].CreatePushTableIndexes()
-			if err != nil {
-				return nil, err
-			}
 		}
 	}
 
 	d.PushTokenDB = ptdb
 
+	// indexes are created on every start, a table created by an older version or an index that failed to be created
+	// would otherwise never get them
+	err = d.EnsureIndexes()
+	if err != nil {
+		return nil, err
+	}
+
 	return d, nil
 }
 
+// EnsureIndexes creates the indexes of every table that are missing, the existing ones are left as they are
+func (d *DB) EnsureIndexes() error {
+	evname := d.chainID.String()
+
+	indexes := []func() error{
+		func() error { return d.EventDB.CreateEventsTableIndexes(evname) },
+		func() error { return d.SponsorDB.CreateSponsorsTableIndexes(evname) },
+		d.LogDB.CreateLogTableIndexes,
+		d.LogDB.datadb.CreateDataTableIndexes,
+		d.UserOpDB.CreateUserOpTableIndexes,
+		d.LogWebhookDB.CreateLogWebhookTableIndexes,
+		d.OutboxDB.CreateOutboxTableIndexes,
+		d.BalanceDB.CreateBalanceTableIndexes,
+	}
+
+	d.mu.Lock()
+	for _, ptdb := range d.PushTokenDB {
+		indexes = append(indexes, ptdb.CreatePushTableIndexes)
+	}
+	d.mu.Unlock()
+
+	for _, create := range indexes {
+		err := create()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 // EventTableExists checks if a table exists in the database
 func (db *DB) EventTableExists(suffix string) (bool, error) {
 	tableName := fmt.Sprintf("t_events_%s", suffix)
@@ -235,6 +252,18 @@ func (db *DB) SponsorTableExists(suffix string) (bool, error) {
 	return exists, nil
 }
 
+// SponsorKeysTableExists checks if a table exists in the database
+func (db *DB) SponsorKeysTableExists(suffix string) (bool, error) {
+	tableName := fmt.Sprintf("t_sponsor_keys_%s", suffix)
+	var exists bool
+	err := db.rdb.QueryRow(db.ctx, "SELECT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = $1)", tableName).Scan(&exists)
+	if err != nil {
+		// A database error occurred
+		return false, err
+	}
+	return exists, nil
+}
+
 // LogTableExists checks if a table exists in the database
 func (db *DB) LogTableExists(suffix string) (bool, error) {
 	tableName := fmt.Sprintf("t_transfers_%s", suffix)
@@ -247,6 +276,30 @@ func (db *DB) LogTableExists(suffix string) (bool, error) {
 	return exists, nil
 }
 
+// BalanceTableExists checks if a table exists in the database
+func (db *DB) BalanceTableExists(suffix string) (bool, error) {
+	tableName := fmt.Sprintf("t_balances_%s", suffix)
+	var exists bool
+	err := db.rdb.QueryRow(db.ctx, "SELECT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = $1)", tableName).Scan(&exists)
+	if err != nil {
+		// A database error occurred
+		return false, err
+	}
+	return exists, nil
+}
+
+// UserOpTableExists checks if a table exists in the database
+func (db *DB) UserOpTableExists(suffix string) (bool, error) {
+	tableName := fmt.Sprintf("t_userops_%s", suffix)
+	var exists bool
+	err := db.rdb.QueryRow(db.ctx, "SELECT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = $1)", tableName).Scan(&exists)
+	if err != nil {
+		// A database error occurred
+		return false, err
+	}
+	return exists, nil
+}
+
 // PushTokenTableExists checks if a table exists in the database
 func (db *DB) PushTokenTableExists(suffix string) (bool, error) {
 	tableName := fmt.Sprintf("t_push_token_%s", suffix)
@@ -271,6 +324,42 @@ func (db *DB) DataTableExists(suffix string) (bool, error) {
 	return exists, nil
 }
 
+// NonceTableExists checks if a table exists in the database
+func (db *DB) NonceTableExists(suffix string) (bool, error) {
+	tableName := fmt.Sprintf("t_signature_nonces_%s", suffix)
+	var exists bool
+	err := db.rdb.QueryRow(db.ctx, "SELECT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = $1)", tableName).Scan(&exists)
+	if err != nil {
+		// A database error occurred
+		return false, err
+	}
+	return exists, nil
+}
+
+// LogWebhookTableExists checks if a table exists in the database
+func (db *DB) LogWebhookTableExists(suffix string) (bool, error) {
+	tableName := fmt.Sprintf("t_log_webhooks_%s", suffix)
+	var exists bool
+	err := db.rdb.QueryRow(db.ctx, "SELECT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = $1)", tableName).Scan(&exists)
+	if err != nil {
+		// A database error occurred
+		return false, err
+	}
+	return exists, nil
+}
+
+// OutboxTableExists checks if a table exists in the database
+func (db *DB) OutboxTableExists(suffix string) (bool, error) {
+	tableName := fmt.Sprintf("t_ws_outbox_%s", suffix)
+	var exists bool
+	err := db.rdb.QueryRow(db.ctx, "SELECT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = $1)", tableName).Scan(&exists)
+	if err != nil {
+		// A database error occurred
+		return false, err
+	}
+	return exists, nil
+}
+
 // TableNameSuffix returns the name of the transfer db for the given contract
 func (d *DB) TableNameSuffix(contract string) (string, error) {
 	re := regexp.MustCompile("^0x[0-9a-fA-F]{40}$")
@@ -281,7 +370,7 @@ func (d *DB) TableNameSuffix(contract string) (string, error) {
 		return suffix, errors.New("bad contract address")
 	}
 
-	return suffix, nil
+	return suffix, validateSuffix(suffix)
 }
 
 // GetPushTokenDB returns true if the push token db for the given contract exists, returns the db if it exists
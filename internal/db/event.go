@@ -3,9 +3,11 @@ package db
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/citizenwallet/engine/pkg/engine"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -18,6 +20,10 @@ type EventDB struct {
 
 // NewEventDB creates a new DB
 func NewEventDB(ctx context.Context, db, rdb *pgxpool.Pool, name string) (*EventDB, error) {
+	if err := validateSuffix(name); err != nil {
+		return nil, err
+	}
+
 	evdb := &EventDB{
 		ctx:    ctx,
 		suffix: name,
@@ -30,11 +36,20 @@ func NewEventDB(ctx context.Context, db, rdb *pgxpool.Pool, name string) (*Event
 
 // createEventsTable creates a table to store events in the given db
 func (db *EventDB) CreateEventsTable(suffix string) error {
+	if err := validateSuffix(suffix); err != nil {
+		return err
+	}
+
 	_, err := db.db.Exec(db.ctx, fmt.Sprintf(`
 	CREATE TABLE IF NOT EXISTS t_events_%s(
 		contract text NOT NULL,
 		event_signature text NOT NULL,
 		name text NOT NULL,
+		standard text NOT NULL DEFAULT '',
+		symbol text NOT NULL DEFAULT '',
+		decimals integer NOT NULL DEFAULT 0,
+		state text NOT NULL DEFAULT 'active',
+		last_block bigint NOT NULL DEFAULT 0,
 		created_at timestamp NOT NULL DEFAULT current_timestamp,
 		updated_at timestamp NOT NULL DEFAULT current_timestamp,
 		UNIQUE (contract, event_signature)
@@ -44,8 +59,45 @@ func (db *EventDB) CreateEventsTable(suffix string) error {
 	return err
 }
 
+// MigrateEventsTable adds the columns that were introduced after the events table was first created
+func (db *EventDB) MigrateEventsTable(suffix string) error {
+	if err := validateSuffix(suffix); err != nil {
+		return err
+	}
+
+	_, err := db.db.Exec(db.ctx, fmt.Sprintf(`
+	ALTER TABLE t_events_%s
+		ADD COLUMN IF NOT EXISTS standard text NOT NULL DEFAULT '',
+		ADD COLUMN IF NOT EXISTS symbol text NOT NULL DEFAULT '',
+		ADD COLUMN IF NOT EXISTS decimals integer NOT NULL DEFAULT 0,
+		ADD COLUMN IF NOT EXISTS state text NOT NULL DEFAULT 'active',
+		ADD COLUMN IF NOT EXISTS last_block bigint NOT NULL DEFAULT 0;
+	`, suffix))
+
+	return err
+}
+
+// MigrateEventsDisplay adds the columns that brand the contracts of the events in notifications
+func (db *EventDB) MigrateEventsDisplay(suffix string) error {
+	if err := validateSuffix(suffix); err != nil {
+		return err
+	}
+
+	_, err := db.db.Exec(db.ctx, fmt.Sprintf(`
+	ALTER TABLE t_events_%s
+		ADD COLUMN IF NOT EXISTS community text NOT NULL DEFAULT '',
+		ADD COLUMN IF NOT EXISTS icon text NOT NULL DEFAULT '';
+	`, suffix))
+
+	return err
+}
+
 // createEventsTableIndexes creates the indexes for events in the given db
 func (db *EventDB) CreateEventsTableIndexes(suffix string) error {
+	if err := validateSuffix(suffix); err != nil {
+		return err
+	}
+
 	_, err := db.db.Exec(db.ctx, fmt.Sprintf(`
     CREATE INDEX IF NOT EXISTS idx_events_%s_contract ON t_events_%s (contract);
     `, suffix, suffix))
@@ -64,9 +116,12 @@ func (db *EventDB) CreateEventsTableIndexes(suffix string) error {
 }
 
 // EventExists checks if an event exists in the db
-func (db *EventDB) EventExists(contract string) (bool, error) {
+func (db *EventDB) EventExists(ctx context.Context, contract string) (bool, error) {
+	ctx, cancel := withQueryTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
 	var exists bool
-	err := db.rdb.QueryRow(db.ctx, fmt.Sprintf(`
+	err := db.rdb.QueryRow(ctx, fmt.Sprintf(`
 	SELECT EXISTS (SELECT 1 FROM t_events_%s WHERE contract = $1)
 	`, db.suffix), contract).Scan(&exists)
 	if err != nil {
@@ -76,13 +131,16 @@ func (db *EventDB) EventExists(contract string) (bool, error) {
 }
 
 // GetEvent gets an event from the db by contract and signature
-func (db *EventDB) GetEvent(contract string, signature string) (*engine.Event, error) {
+func (db *EventDB) GetEvent(ctx context.Context, contract string, signature string) (*engine.Event, error) {
+	ctx, cancel := withQueryTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
 	var event engine.Event
-	err := db.rdb.QueryRow(db.ctx, fmt.Sprintf(`
-	SELECT contract, event_signature, name, created_at, updated_at
+	err := db.rdb.QueryRow(ctx, fmt.Sprintf(`
+	SELECT contract, event_signature, name, standard, symbol, decimals, state, last_block, created_at, updated_at
 	FROM t_events_%s
 	WHERE contract = $1 AND event_signature = $2
-	`, db.suffix), contract, signature).Scan(&event.Contract, &event.EventSignature, &event.Name, &event.CreatedAt, &event.UpdatedAt)
+	`, db.suffix), contract, signature).Scan(&event.Contract, &event.EventSignature, &event.Name, &event.Standard, &event.Symbol, &event.Decimals, &event.State, &event.LastBlock, &event.CreatedAt, &event.UpdatedAt)
 	if err != nil {
 		return nil, err
 	}
@@ -90,10 +148,46 @@ func (db *EventDB) GetEvent(contract string, signature string) (*engine.Event, e
 	return &event, nil
 }
 
+// GetEventByTopic gets the event of a contract whose signature hashes to the given topic
+func (db *EventDB) GetEventByTopic(ctx context.Context, contract string, topic string) (*engine.Event, error) {
+	ctx, cancel := withQueryTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
+	rows, err := db.rdb.Query(ctx, fmt.Sprintf(`
+    SELECT contract, event_signature, name, standard, symbol, decimals, state, last_block, created_at, updated_at
+    FROM t_events_%s
+    WHERE contract = $1
+    `, db.suffix), contract)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var event engine.Event
+		err = rows.Scan(&event.Contract, &event.EventSignature, &event.Name, &event.Standard, &event.Symbol, &event.Decimals, &event.State, &event.LastBlock, &event.CreatedAt, &event.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		if strings.EqualFold(event.GetTopic0FromEventSignature().Hex(), topic) {
+			return &event, nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return nil, pgx.ErrNoRows
+}
+
 // GetEvents gets all events from the db
-func (db *EventDB) GetEvents() ([]*engine.Event, error) {
-	rows, err := db.rdb.Query(db.ctx, fmt.Sprintf(`
-    SELECT contract, event_signature, name, created_at, updated_at
+func (db *EventDB) GetEvents(ctx context.Context) ([]*engine.Event, error) {
+	ctx, cancel := withQueryTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
+	rows, err := db.rdb.Query(ctx, fmt.Sprintf(`
+    SELECT contract, event_signature, name, standard, symbol, decimals, state, last_block, created_at, updated_at
     FROM t_events_%s
     ORDER BY created_at ASC
     `, db.suffix))
@@ -105,7 +199,7 @@ func (db *EventDB) GetEvents() ([]*engine.Event, error) {
 	events := []*engine.Event{}
 	for rows.Next() {
 		var event engine.Event
-		err = rows.Scan(&event.Contract, &event.EventSignature, &event.Name, &event.CreatedAt, &event.UpdatedAt)
+		err = rows.Scan(&event.Contract, &event.EventSignature, &event.Name, &event.Standard, &event.Symbol, &event.Decimals, &event.State, &event.LastBlock, &event.CreatedAt, &event.UpdatedAt)
 		if err != nil {
 			return nil, err
 		}
@@ -116,10 +210,47 @@ func (db *EventDB) GetEvents() ([]*engine.Event, error) {
 	return events, nil
 }
 
+// GetPaginatedEvents gets a page of events from the db, optionally for a single contract or state, along with the total number of matching events
+func (db *EventDB) GetPaginatedEvents(ctx context.Context, contract string, state engine.EventState, limit, offset int) ([]*engine.Event, int, error) {
+	ctx, cancel := withQueryTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
+	query := fmt.Sprintf(`
+    SELECT contract, event_signature, name, standard, symbol, decimals, state, last_block, created_at, updated_at, count(*) OVER()
+    FROM t_events_%s
+    WHERE ($1 = '' OR contract = $1) AND ($2 = '' OR state = $2)
+    ORDER BY created_at ASC
+    LIMIT $3 OFFSET $4
+    `, db.suffix)
+
+	rows, err := db.rdb.Query(ctx, query, contract, string(state), limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	total := 0
+	events := []*engine.Event{}
+	for rows.Next() {
+		var event engine.Event
+		err = rows.Scan(&event.Contract, &event.EventSignature, &event.Name, &event.Standard, &event.Symbol, &event.Decimals, &event.State, &event.LastBlock, &event.CreatedAt, &event.UpdatedAt, &total)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		events = append(events, &event)
+	}
+
+	return events, total, rows.Err()
+}
+
 // GetOutdatedEvents gets all queued events from the db sorted by created_at
-func (db *EventDB) GetOutdatedEvents(currentBlk int64) ([]*engine.Event, error) {
-	rows, err := db.rdb.Query(db.ctx, fmt.Sprintf(`
-    SELECT contract, event_signature, name, created_at, updated_at
+func (db *EventDB) GetOutdatedEvents(ctx context.Context, currentBlk int64) ([]*engine.Event, error) {
+	ctx, cancel := withQueryTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
+	rows, err := db.rdb.Query(ctx, fmt.Sprintf(`
+    SELECT contract, event_signature, name, standard, symbol, decimals, state, last_block, created_at, updated_at
     FROM t_events_%s
     WHERE last_block < $1
     ORDER BY created_at ASC
@@ -132,7 +263,7 @@ func (db *EventDB) GetOutdatedEvents(currentBlk int64) ([]*engine.Event, error)
 	events := []*engine.Event{}
 	for rows.Next() {
 		var event engine.Event
-		err = rows.Scan(&event.Contract, &event.EventSignature, &event.Name, &event.CreatedAt, &event.UpdatedAt)
+		err = rows.Scan(&event.Contract, &event.EventSignature, &event.Name, &event.Standard, &event.Symbol, &event.Decimals, &event.State, &event.LastBlock, &event.CreatedAt, &event.UpdatedAt)
 		if err != nil {
 			return nil, err
 		}
@@ -144,8 +275,11 @@ func (db *EventDB) GetOutdatedEvents(currentBlk int64) ([]*engine.Event, error)
 }
 
 // SetEventLastBlock sets the last block of an event
-func (db *EventDB) SetEventLastBlock(contract string, signature string, lastBlock int64) error {
-	_, err := db.db.Exec(db.ctx, fmt.Sprintf(`
+func (db *EventDB) SetEventLastBlock(ctx context.Context, contract string, signature string, lastBlock int64) error {
+	ctx, cancel := withQueryTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
+	_, err := db.db.Exec(ctx, fmt.Sprintf(`
     UPDATE t_events_%s
     SET last_block = $1, updated_at = $2
     WHERE contract = $3 AND event_signature = $4
@@ -154,21 +288,122 @@ func (db *EventDB) SetEventLastBlock(contract string, signature string, lastBloc
 	return err
 }
 
-// AddEvent adds an event to the db
-func (db *EventDB) AddEvent(contract string, signature string, name string) error {
+// GetContractMetadata gets how a contract is presented in notifications from its events, the name, symbol and decimals
+// are the ones of its first event, the community and icon the ones of the first event that has them
+func (db *EventDB) GetContractMetadata(ctx context.Context, contract string) (*engine.ContractMetadata, error) {
+	ctx, cancel := withQueryTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
+	var meta engine.ContractMetadata
+	err := db.rdb.QueryRow(ctx, fmt.Sprintf(`
+	SELECT e.contract, e.name, e.symbol, e.decimals, COALESCE(d.community, ''), COALESCE(d.icon, '')
+	FROM t_events_%s e
+	LEFT JOIN LATERAL (
+		SELECT community, icon
+		FROM t_events_%s
+		WHERE contract = e.contract AND community <> ''
+		ORDER BY updated_at DESC
+		LIMIT 1
+	) d ON true
+	WHERE e.contract = $1
+	ORDER BY e.created_at ASC
+	LIMIT 1
+	`, db.suffix, db.suffix), contract).Scan(&meta.Contract, &meta.Name, &meta.Symbol, &meta.Decimals, &meta.Community, &meta.Icon)
+	if err != nil {
+		return nil, err
+	}
+
+	return &meta, nil
+}
+
+// SetContractDisplay sets the community and icon of all the events of a contract, it returns pgx.ErrNoRows if the
+// contract has no events
+func (db *EventDB) SetContractDisplay(ctx context.Context, contract, community, icon string) error {
+	ctx, cancel := withQueryTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
+	tag, err := db.db.Exec(ctx, fmt.Sprintf(`
+	UPDATE t_events_%s
+	SET community = $1, icon = $2, updated_at = $3
+	WHERE contract = $4
+	`, db.suffix), community, icon, time.Now().UTC(), contract)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+
+	return nil
+}
+
+// AddEvent adds an event to the db or updates its details if it already exists
+//
+// the last block of an event is where it starts to be indexed from, it only replaces the one of an existing event
+// that was never indexed. Events with an invalid signature, or with the same topic as another signature of the
+// contract (ex: the same event with and without argument names), are rejected
+func (db *EventDB) AddEvent(ctx context.Context, ev *engine.Event) error {
+	ctx, cancel := withQueryTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
+	err := ev.ValidateEventSignature()
+	if err != nil {
+		return err
+	}
+
+	err = db.checkTopicCollision(ctx, ev)
+	if err != nil {
+		return err
+	}
+
 	t := time.Now().UTC()
 
-	_, err := db.db.Exec(db.ctx, fmt.Sprintf(`
-    INSERT INTO t_events_%s (contract, event_signature, name, created_at, updated_at)
-    VALUES ($1, $2, $3, $4, $5)
+	_, err = db.db.Exec(ctx, fmt.Sprintf(`
+    INSERT INTO t_events_%s (contract, event_signature, name, standard, symbol, decimals, last_block, created_at, updated_at)
+    VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
     ON CONFLICT (contract, event_signature)
     DO UPDATE SET
         name = EXCLUDED.name,
+        standard = EXCLUDED.standard,
+        symbol = EXCLUDED.symbol,
+        decimals = EXCLUDED.decimals,
+        last_block = CASE WHEN t_events_%s.last_block = 0 THEN EXCLUDED.last_block ELSE t_events_%s.last_block END,
         updated_at = EXCLUDED.updated_at
-    `, db.suffix), contract, signature, name, t, t)
+    `, db.suffix, db.suffix, db.suffix), ev.Contract, ev.EventSignature, ev.Name, ev.Standard, ev.Symbol, ev.Decimals, ev.LastBlock, t, t)
+
+	return err
+}
+
+// checkTopicCollision returns an error if another signature of the contract of the event has the same topic,
+// both would index the same logs
+func (db *EventDB) checkTopicCollision(ctx context.Context, ev *engine.Event) error {
+	ctx, cancel := withQueryTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
+	rows, err := db.db.Query(ctx, fmt.Sprintf(`
+    SELECT event_signature
+    FROM t_events_%s
+    WHERE contract = $1 AND event_signature <> $2
+    `, db.suffix), ev.Contract, ev.EventSignature)
 	if err != nil {
 		return err
 	}
+	defer rows.Close()
 
-	return err
+	topic := ev.GetTopic0FromEventSignature()
+
+	for rows.Next() {
+		existing := engine.Event{Contract: ev.Contract}
+		err = rows.Scan(&existing.EventSignature)
+		if err != nil {
+			return err
+		}
+
+		if existing.GetTopic0FromEventSignature() == topic {
+			return fmt.Errorf("event %q of %s has the same topic %s as the existing event %q", ev.EventSignature, ev.Contract, topic.Hex(), existing.EventSignature)
+		}
+	}
+
+	return rows.Err()
 }
@@ -0,0 +1,32 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"github.com/citizenwallet/engine/pkg/engine"
+)
+
+func TestAddEventsWithoutArguments(t *testing.T) {
+	d := openTestDB(t)
+	ctx := context.Background()
+
+	contract := "0x5815E61eF72c9E6107b5c5A05FD121F334f7a7f1"
+
+	// both have no arguments, their topics are still different
+	for _, sig := range []string{"Paused()", "Unpaused()"} {
+		err := d.EventDB.AddEvent(ctx, &engine.Event{Contract: contract, EventSignature: sig, Name: sig})
+		if err != nil {
+			t.Fatalf("%s: %v", sig, err)
+		}
+	}
+
+	evs, err := d.EventDB.GetEvents(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(evs) != 2 {
+		t.Fatalf("expected 2 events, got %d", len(evs))
+	}
+}
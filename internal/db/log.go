@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"math/big"
+	"strings"
 	"time"
 
 	"github.com/citizenwallet/engine/pkg/common"
@@ -13,27 +14,207 @@ import (
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+// logs are ordered by time, logs of the same time by their position on chain and by hash so that pages are stable,
+// logs without a position (optimistic ones and those that aren't backfilled yet) come last within the same time
+const (
+	logsOrder      = `l.created_at DESC, l.block_number DESC NULLS LAST, l.log_index DESC NULLS LAST, l.hash DESC`
+	unionLogsOrder = `created_at DESC, block_number DESC, log_index DESC, hash DESC` // positions are empty as 0 in the result of a union
+)
+
 type LogDB struct {
 	ctx    context.Context
 	suffix string
 	db     *pgxpool.Pool
 	rdb    *pgxpool.Pool
 	datadb *DataDB
+	baldb  *BalanceDB
+	sql    logSQL
+
+	statsTimeout time.Duration
 }
 
 // NewLogDB creates a new DB
-func NewLogDB(ctx context.Context, db, rdb *pgxpool.Pool, name string, datadb *DataDB) (*LogDB, error) {
+func NewLogDB(ctx context.Context, db, rdb *pgxpool.Pool, name string, datadb *DataDB, baldb *BalanceDB) (*LogDB, error) {
+	if err := validateSuffix(name); err != nil {
+		return nil, err
+	}
+
 	txdb := &LogDB{
 		ctx:    ctx,
 		suffix: name,
+		sql:    newLogSQL(name),
 		db:     db,
 		rdb:    rdb,
 		datadb: datadb,
+		baldb:  baldb,
 	}
 
 	return txdb, nil
 }
 
+// logSQL holds the queries of a LogDB that only depend on the suffix of its tables, they are formatted once when it
+// is created, the queries with filters are still built on each call
+type logSQL struct {
+	balance        string
+	transferStats  string
+	insertLog      string
+	lockHash       string
+	lockStatus     string
+	promoteLogs    string
+	deleteLog      string
+	lockLogsFrom   string
+	deleteLogsFrom string
+	// upsertLog inserts a log or updates it if it already exists, an empty sender, data or block keeps the existing
+	// one, logs without a block number, like optimistic ones, have no position in the chain yet
+	upsertLog               string
+	setStatus               string
+	removeLog               string
+	unpositionedTxHashes    string
+	setPosition             string
+	removeOldInProgressLogs string
+	confirmedLogExists      string
+	indexedLogExists        string
+	getLog                  string
+	logsByTxHash            string
+	allPaginatedLogs        string
+	accountHistory          string
+	allNewLogs              string
+}
+
+func newLogSQL(suffix string) logSQL {
+	return logSQL{
+		balance: fmt.Sprintf(`
+	SELECT COALESCE(SUM(
+		CASE WHEN lower(data->>'to') = lower($2) THEN (data->>'value')::numeric ELSE 0 END -
+		CASE WHEN lower(data->>'from') = lower($2) THEN (data->>'value')::numeric ELSE 0 END
+	), 0)::text
+	FROM t_logs_%s
+	WHERE dest = $1 AND data->>'topic' = $3 AND status = ANY($4)
+	AND (lower(data->>'to') = lower($2) OR lower(data->>'from') = lower($2))
+	`, suffix),
+		transferStats: fmt.Sprintf(`
+	WITH t AS (
+		SELECT hash, date_trunc($2, created_at) AS period, data->>'from' AS sender, data->>'to' AS recipient, (data->>'value')::numeric AS value
+		FROM t_logs_%s
+		WHERE dest = $1 AND data->>'topic' = $3 AND status = 'success' AND created_at >= $4 AND created_at < $5
+	)
+	SELECT t.period, count(DISTINCT t.hash), count(DISTINCT acc.account) FILTER (WHERE acc.account <> $6), COALESCE(sum(t.value) FILTER (WHERE acc.n = 1), 0)::text
+	FROM t CROSS JOIN LATERAL (VALUES (1, t.sender), (2, t.recipient)) AS acc(n, account)
+	GROUP BY t.period
+	ORDER BY t.period ASC
+	`, suffix),
+		insertLog: fmt.Sprintf(`
+	INSERT INTO t_logs_%s (hash, tx_hash, nonce, sender, dest, value, data, status, created_at, updated_at, block_number, log_index)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, NULLIF($11::bigint, 0), CASE WHEN $11::bigint = 0 THEN NULL ELSE $12::integer END)
+	ON CONFLICT (hash) DO NOTHING
+	`, suffix),
+		lockHash: fmt.Sprintf(`
+	SELECT pg_advisory_xact_lock(hashtext('t_logs_%s'), hashtext($1))
+	`, suffix),
+		lockStatus: fmt.Sprintf(`
+	SELECT status FROM t_logs_%s WHERE hash = $1 FOR UPDATE
+	`, suffix),
+		promoteLogs: fmt.Sprintf(`
+	UPDATE t_logs_%s SET status = 'success', updated_at = $2
+	WHERE status = 'pending' AND block_number IS NOT NULL AND block_number <= $1
+	RETURNING hash
+	`, suffix),
+		deleteLog: fmt.Sprintf(`
+	DELETE FROM t_logs_%s WHERE hash = $1
+	`, suffix),
+		lockLogsFrom: fmt.Sprintf(`
+	SELECT hash FROM t_logs_%s WHERE dest = $1 AND block_number >= $2 FOR UPDATE
+	`, suffix),
+		deleteLogsFrom: fmt.Sprintf(`
+	DELETE FROM t_logs_%s WHERE dest = $1 AND block_number >= $2
+	`, suffix),
+		upsertLog: fmt.Sprintf(`
+	INSERT INTO t_logs_%s (hash, tx_hash, nonce, sender, dest, value, data, status, created_at, updated_at, block_number, log_index)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, NULLIF($11::bigint, 0), CASE WHEN $11::bigint = 0 THEN NULL ELSE $12::integer END)
+	ON CONFLICT (hash) DO UPDATE SET
+		tx_hash = EXCLUDED.tx_hash,
+		nonce = EXCLUDED.nonce,
+		sender = CASE
+			WHEN EXCLUDED.sender = '' THEN t_logs_%s.sender
+			ELSE COALESCE(EXCLUDED.sender, t_logs_%s.sender)
+		END,
+		dest = EXCLUDED.dest,
+		value = EXCLUDED.value,
+		data = COALESCE(EXCLUDED.data, t_logs_%s.data),
+		status = EXCLUDED.status,
+		created_at = EXCLUDED.created_at,
+		updated_at = EXCLUDED.updated_at,
+		block_number = COALESCE(EXCLUDED.block_number, t_logs_%s.block_number),
+		log_index = COALESCE(EXCLUDED.log_index, t_logs_%s.log_index)
+	`, suffix, suffix, suffix, suffix, suffix, suffix),
+		setStatus: fmt.Sprintf(`
+	UPDATE t_logs_%s SET status = $1 WHERE hash = $2 AND status != 'success'
+	`, suffix),
+		removeLog: fmt.Sprintf(`
+	DELETE FROM t_logs_%s WHERE hash = $1 AND status != 'success'
+	`, suffix),
+		unpositionedTxHashes: fmt.Sprintf(`
+	SELECT DISTINCT tx_hash
+	FROM t_logs_%s
+	WHERE dest = $1 AND status = 'success' AND block_number IS NULL AND tx_hash > $2
+	ORDER BY tx_hash ASC
+	LIMIT $3
+	`, suffix),
+		setPosition: fmt.Sprintf(`
+	UPDATE t_logs_%s SET block_number = $1, log_index = $2 WHERE hash = $3 AND block_number IS NULL
+	`, suffix),
+		removeOldInProgressLogs: fmt.Sprintf(`
+	DELETE FROM t_logs_%s WHERE created_at <= $1 AND status IN ('sending', 'pending') AND block_number IS NULL
+	`, suffix),
+		confirmedLogExists: fmt.Sprintf(`
+	SELECT EXISTS (SELECT 1 FROM t_logs_%s WHERE hash = $1 AND status = 'success')
+	`, suffix),
+		indexedLogExists: fmt.Sprintf(`
+	SELECT EXISTS (SELECT 1 FROM t_logs_%s WHERE hash = $1 AND (status = 'success' OR (status = 'pending' AND block_number IS NOT NULL)))
+	`, suffix),
+		getLog: fmt.Sprintf(`
+		SELECT l.hash, l.tx_hash, l.created_at, l.updated_at, l.nonce, l.sender, l.dest, l.value, l.data, l.status, COALESCE(l.block_number, 0) AS block_number, COALESCE(l.log_index, 0) AS log_index, d.data as extra_data
+		FROM t_logs_%s l
+		LEFT JOIN t_logs_data_%s d ON l.hash = d.hash
+		WHERE l.hash = $1
+		`, suffix, suffix),
+		logsByTxHash: fmt.Sprintf(`
+		SELECT l.hash, l.tx_hash, l.created_at, l.updated_at, l.nonce, l.sender, l.dest, l.value, l.data, l.status, COALESCE(l.block_number, 0) AS block_number, COALESCE(l.log_index, 0) AS log_index, d.data as extra_data
+		FROM t_logs_%s l
+		LEFT JOIN t_logs_data_%s d ON l.hash = d.hash
+		WHERE l.tx_hash = $1
+		ORDER BY l.log_index ASC NULLS LAST, l.created_at ASC, l.hash ASC
+		`, suffix, suffix),
+		allPaginatedLogs: fmt.Sprintf(`
+	SELECT l.hash, l.tx_hash, l.created_at, l.updated_at, l.nonce, l.sender, l.dest, l.value, l.data, l.status, COALESCE(l.block_number, 0) AS block_number, COALESCE(l.log_index, 0) AS log_index, d.data as extra_data
+	FROM t_logs_%s l
+	LEFT JOIN t_logs_data_%s d ON l.hash = d.hash
+	WHERE l.dest = $1 AND l.data->>'topic' = $2 AND l.created_at <= $3 AND (cardinality($4::text[]) = 0 OR l.status = ANY($4))
+	ORDER BY %s
+	LIMIT $5 OFFSET $6
+	`, suffix, suffix, logsOrder),
+		accountHistory: fmt.Sprintf(`
+	SELECT l.hash, l.tx_hash, l.created_at, l.updated_at, l.nonce, l.sender, l.dest, l.value, l.data, l.status, COALESCE(l.block_number, 0) AS block_number, COALESCE(l.log_index, 0) AS log_index, d.data as extra_data
+	FROM t_logs_%s l
+	LEFT JOIN t_logs_data_%s d ON l.hash = d.hash
+	WHERE l.dest = $1 AND (l.sender_addr = $2 OR l.recipient_addr = $2) AND l.created_at <= $3
+	ORDER BY %s
+	LIMIT $4 OFFSET $5
+	`, suffix, suffix, logsOrder),
+		allNewLogs: fmt.Sprintf(`
+		SELECT l.hash, l.tx_hash, l.created_at, l.nonce, l.sender, l.dest, l.value, l.data, l.status, COALESCE(l.block_number, 0) AS block_number, COALESCE(l.log_index, 0) AS log_index, d.data as extra_data
+		FROM t_logs_%s l
+		LEFT JOIN t_logs_data_%s d ON l.hash = d.hash
+		WHERE l.dest = $1 AND l.data->>'topic' = $2 AND l.created_at >= $3 AND (cardinality($4::text[]) = 0 OR l.status = ANY($4))
+		`, suffix, suffix),
+	}
+}
+
+// SetStatsTimeout lets the transfer stats run for longer than the statement timeout of the reader pool, 0 keeps it
+func (db *LogDB) SetStatsTimeout(timeout time.Duration) {
+	db.statsTimeout = timeout
+}
+
 // createLogTable creates a table dest store logs in the given db
 func (db *LogDB) CreateLogTable() error {
 	_, err := db.db.Exec(db.ctx, fmt.Sprintf(`
@@ -47,13 +228,73 @@ func (db *LogDB) CreateLogTable() error {
 		dest text NOT NULL,
 		value text NOT NULL,
 		data jsonb DEFAULT NULL,
-		status text NOT NULL DEFAULT 'success'
+		status text NOT NULL DEFAULT 'success',
+		block_number bigint DEFAULT NULL,
+		log_index integer DEFAULT NULL,
+		sender_addr text GENERATED ALWAYS AS (lower(data->>'from')) STORED,
+		recipient_addr text GENERATED ALWAYS AS (lower(data->>'to')) STORED
 	);
 	`, db.suffix))
 
 	return err
 }
 
+// MigrateLogTable adds the columns that were introduced after the log table was first created
+//
+// the block number and log index of existing logs are left empty, the indexer backfills them from the receipts,
+// the address columns are computed for every existing log when they are added
+func (db *LogDB) MigrateLogTable() error {
+	_, err := db.db.Exec(db.ctx, fmt.Sprintf(`
+	ALTER TABLE t_logs_%s
+		ADD COLUMN IF NOT EXISTS block_number bigint DEFAULT NULL,
+		ADD COLUMN IF NOT EXISTS log_index integer DEFAULT NULL,
+		ADD COLUMN IF NOT EXISTS sender_addr text GENERATED ALWAYS AS (lower(data->>'from')) STORED,
+		ADD COLUMN IF NOT EXISTS recipient_addr text GENERATED ALWAYS AS (lower(data->>'to')) STORED;
+	`, db.suffix))
+	if err != nil {
+		return err
+	}
+
+	return db.createAddressIndexes()
+}
+
+// createAddressIndexes creates the indexes for filtering the logs of a contract by sender or recipient,
+// logs of events without addresses are left out of them
+func (db *LogDB) createAddressIndexes() error {
+	suffix := common.ShortenName(db.suffix, 6)
+
+	_, err := db.db.Exec(db.ctx, fmt.Sprintf(`
+	CREATE INDEX IF NOT EXISTS idx_logs_%s_dest_sender_date ON t_logs_%s (dest, sender_addr, created_at) WHERE sender_addr IS NOT NULL;
+	`, suffix, db.suffix))
+	if err != nil {
+		return err
+	}
+
+	_, err = db.db.Exec(db.ctx, fmt.Sprintf(`
+	CREATE INDEX IF NOT EXISTS idx_logs_%s_dest_recipient_date ON t_logs_%s (dest, recipient_addr, created_at) WHERE recipient_addr IS NOT NULL;
+	`, suffix, db.suffix))
+
+	return err
+}
+
+// addressQuery returns the conditions of an address filter on the address columns, its args are numbered from n
+func addressQuery(prefix string, n int, f engine.AddressFilter) (string, []any) {
+	query := ""
+	args := []any{}
+
+	if f.Sender != "" {
+		query += fmt.Sprintf("AND %ssender_addr = $%d ", prefix, n+len(args))
+		args = append(args, strings.ToLower(f.Sender))
+	}
+
+	if f.Recipient != "" {
+		query += fmt.Sprintf("AND %srecipient_addr = $%d ", prefix, n+len(args))
+		args = append(args, strings.ToLower(f.Recipient))
+	}
+
+	return query, args
+}
+
 // createLogTableIndexes creates the indexes for logs in the given db
 func (db *LogDB) CreateLogTableIndexes() error {
 	suffix := common.ShortenName(db.suffix, 6)
@@ -89,20 +330,11 @@ func (db *LogDB) CreateLogTableIndexes() error {
 		return err
 	}
 
-	// filtering by address [CANNOT DO THIS ANYMORE]
-	// _, err = db.db.Exec(db.ctx, fmt.Sprintf(`
-	// CREATE INDEX IF NOT EXISTS idx_logs_%s_to_addr ON t_logs_%s (to_addr);
-	// `, suffix, db.suffix))
-	// if err != nil {
-	// 	return err
-	// }
-
-	// _, err = db.db.Exec(db.ctx, fmt.Sprintf(`
-	// CREATE INDEX IF NOT EXISTS idx_logs_%s_from_addr ON t_logs_%s (from_addr);
-	// `, suffix, db.suffix))
-	// if err != nil {
-	// 	return err
-	// }
+	// filtering by address
+	err = db.createAddressIndexes()
+	if err != nil {
+		return err
+	}
 
 	// // single-token queries
 	// _, err = db.db.Exec(db.ctx, fmt.Sprintf(`
@@ -138,15 +370,87 @@ func (db *LogDB) CreateLogTableIndexes() error {
 	return nil
 }
 
+// GetBalance sums the incoming minus the outgoing transfers of an account for a contract, only logs with one of the given statuses are counted
+func (db *LogDB) GetBalance(ctx context.Context, contract, account string, statuses []engine.LogStatus) (*big.Int, error) {
+	ctx, cancel := withQueryTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
+	sts := statusStrings(statuses)
+
+	var balance string
+	err := db.rdb.QueryRow(ctx, db.sql.balance, contract, account, engine.TransferTopic0.Hex(), sts).Scan(&balance)
+	if err != nil {
+		return nil, err
+	}
+
+	b, ok := new(big.Int).SetString(balance, 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid balance: %s", balance)
+	}
+
+	return b, nil
+}
+
+// statusStrings converts statuses to a text array parameter, it is never nil so that an empty filter is not NULL
+func statusStrings(statuses []engine.LogStatus) []string {
+	sts := make([]string, len(statuses))
+	for i, st := range statuses {
+		sts[i] = string(st)
+	}
+
+	return sts
+}
+
+// GetTransferStats aggregates the success transfers of a contract per period between from and to
+func (db *LogDB) GetTransferStats(ctx context.Context, contract string, period engine.StatsPeriod, from, to time.Time) ([]*engine.TransferStats, error) {
+	ctx, cancel := withQueryTimeout(ctx, max(DefaultQueryTimeout, db.statsTimeout))
+	defer cancel()
+
+	var q querier = db.rdb
+
+	if db.statsTimeout > 0 {
+		// the override only lasts for the transaction
+		tx, err := db.rdb.Begin(ctx)
+		if err != nil {
+			return nil, err
+		}
+		defer tx.Rollback(ctx)
+
+		_, err = tx.Exec(ctx, fmt.Sprintf("SET LOCAL statement_timeout = %d", db.statsTimeout.Milliseconds()))
+		if err != nil {
+			return nil, err
+		}
+
+		q = tx
+	}
+
+	rows, err := q.Query(ctx, db.sql.transferStats, contract, string(period), engine.TransferTopic0.Hex(), from, to, zeroAddress)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stats := []*engine.TransferStats{}
+	for rows.Next() {
+		var s engine.TransferStats
+		err = rows.Scan(&s.Period, &s.Transfers, &s.ActiveAccounts, &s.Volume)
+		if err != nil {
+			return nil, err
+		}
+
+		stats = append(stats, &s)
+	}
+
+	return stats, rows.Err()
+}
+
 // AddLog adds a log dest the db
-func (db *LogDB) AddLog(lg *engine.Log) error {
+func (db *LogDB) AddLog(ctx context.Context, lg *engine.Log) error {
+	ctx, cancel := withQueryTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
 
 	// insert log on conflict do nothing
-	_, err := db.db.Exec(db.ctx, fmt.Sprintf(`
-	INSERT INTO t_logs_%s (hash, tx_hash, nonce, sender, dest, value, data, status, created_at, updated_at)
-	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
-	ON CONFLICT (hash) DO NOTHING
-	`, db.suffix), lg.Hash, lg.TxHash, lg.Nonce, lg.Sender, lg.To, lg.Value.String(), lg.Data, lg.Status, lg.CreatedAt, lg.UpdatedAt)
+	_, err := db.db.Exec(ctx, db.sql.insertLog, lg.Hash, lg.TxHash, lg.Nonce, lg.Sender, lg.To, lg.Value.String(), lg.Data, lg.Status, lg.CreatedAt, lg.UpdatedAt, lg.BlockNumber, lg.LogIndex)
 
 	if err != nil {
 		return err
@@ -164,26 +468,13 @@ func (db *LogDB) AddLog(lg *engine.Log) error {
 }
 
 // AddLogs adds a list of logs dest the db
-func (db *LogDB) AddLogs(lg []*engine.Log) error {
+func (db *LogDB) AddLogs(ctx context.Context, lg []*engine.Log) error {
 
 	for _, t := range lg {
-		_, err := db.db.Exec(db.ctx, fmt.Sprintf(`
-			INSERT INTO t_logs_%s (hash, tx_hash, nonce, sender, dest, value, data, status, created_at, updated_at)
-			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
-			ON CONFLICT (hash) DO UPDATE SET
-				tx_hash = EXCLUDED.tx_hash,
-				nonce = EXCLUDED.nonce,
-				sender = CASE
-					WHEN EXCLUDED.sender = '' THEN t_logs_%s.sender
-					ELSE COALESCE(EXCLUDED.sender, t_logs_%s.sender)
-				END,
-				dest = EXCLUDED.dest,
-				value = EXCLUDED.value,
-				data = COALESCE(EXCLUDED.data, t_logs_%s.data),
-				status = EXCLUDED.status,
-				created_at = EXCLUDED.created_at,
-				updated_at = EXCLUDED.updated_at
-			`, db.suffix, db.suffix, db.suffix, db.suffix), t.Hash, t.TxHash, t.Nonce, t.Sender, t.To, t.Value.String(), t.Data, t.Status, t.CreatedAt, t.UpdatedAt)
+		// a batch can take longer than a query, each log gets its own deadline
+		qctx, cancel := withQueryTimeout(ctx, DefaultQueryTimeout)
+		_, err := db.db.Exec(qctx, db.sql.upsertLog, t.Hash, t.TxHash, t.Nonce, t.Sender, t.To, t.Value.String(), t.Data, t.Status, t.CreatedAt, t.UpdatedAt, t.BlockNumber, t.LogIndex)
+		cancel()
 		if err != nil {
 			return err
 		}
@@ -200,50 +491,295 @@ func (db *LogDB) AddLogs(lg []*engine.Log) error {
 	return nil
 }
 
+// AddConfirmedLog adds a log that was seen on chain and updates the balances in the same transaction
+//
+// the balance delta is only applied the first time a log becomes success, an optimistic log
+// with the same hash that is confirmed is upserted like AddLogs does, a log that is not final yet is stored as pending
+func (db *LogDB) AddConfirmedLog(ctx context.Context, lg *engine.Log) error {
+	ctx, cancel := withQueryTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
+	tx, err := db.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	// FOR UPDATE doesn't lock a log that isn't stored yet, two transactions that index the same new log (ex: the
+	// listener and a reconciliation) would both see no status and both apply its balance delta, they are serialized
+	// on the hash until one of them commits
+	_, err = tx.Exec(ctx, db.sql.lockHash, lg.Hash)
+	if err != nil {
+		return err
+	}
+
+	var status string
+	err = tx.QueryRow(ctx, db.sql.lockStatus, lg.Hash).Scan(&status)
+	if err != nil && err != pgx.ErrNoRows {
+		return err
+	}
+
+	// a log that is indexed again before it is final doesn't go back to pending
+	if status == string(engine.LogStatusSuccess) {
+		lg.Status = engine.LogStatusSuccess
+	}
+
+	_, err = tx.Exec(ctx, db.sql.upsertLog, lg.Hash, lg.TxHash, lg.Nonce, lg.Sender, lg.To, lg.Value.String(), lg.Data, lg.Status, lg.CreatedAt, lg.UpdatedAt, lg.BlockNumber, lg.LogIndex)
+	if err != nil {
+		return err
+	}
+
+	if status != string(engine.LogStatusSuccess) && lg.Status == engine.LogStatusSuccess {
+		err = db.baldb.applyLog(tx, lg.Hash, 1)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return err
+	}
+
+	// If ExtraData exists, store it in the data table
+	if lg.ExtraData != nil {
+		err = db.datadb.UpsertData(lg.Hash, lg.ExtraData)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// PromoteLogs marks the pending logs of blocks up to maxBlock as success and applies their balance deltas,
+// it returns the hashes of the logs that were promoted
+//
+// only indexed logs are promoted, optimistic logs have no block number
+func (db *LogDB) PromoteLogs(ctx context.Context, maxBlock int64) ([]string, error) {
+	ctx, cancel := withQueryTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
+	tx, err := db.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, db.sql.promoteLogs, maxBlock, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+
+	hashes := []string{}
+	for rows.Next() {
+		var hash string
+		err = rows.Scan(&hash)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+
+		hashes = append(hashes, hash)
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, hash := range hashes {
+		err = db.baldb.applyLog(tx, hash, 1)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return hashes, nil
+}
+
+// RemoveConfirmedLog removes a log that was reorged out of the chain and reverses its balance delta
+func (db *LogDB) RemoveConfirmedLog(ctx context.Context, hash string) error {
+	ctx, cancel := withQueryTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
+	tx, err := db.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var status string
+	err = tx.QueryRow(ctx, db.sql.lockStatus, hash).Scan(&status)
+	if err == pgx.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if status == string(engine.LogStatusSuccess) {
+		err = db.baldb.applyLog(tx, hash, -1)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec(ctx, db.sql.deleteLog, hash)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// RemoveIndexedLogsFrom removes the logs of a contract from a block onward and reverses their balance changes in the
+// same transaction, it returns how many were removed
+//
+// logs without a block number (optimistic ones and those that aren't backfilled yet) are kept
+func (db *LogDB) RemoveIndexedLogsFrom(ctx context.Context, contract string, fromBlock int64) (int64, error) {
+	ctx, cancel := withQueryTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
+	tx, err := db.db.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	// logs that are indexed at the same time wait for the removal
+	_, err = tx.Exec(ctx, db.sql.lockLogsFrom, contract, fromBlock)
+	if err != nil {
+		return 0, err
+	}
+
+	err = db.baldb.reverseLogsFrom(ctx, tx, contract, fromBlock)
+	if err != nil {
+		return 0, err
+	}
+
+	tag, err := tx.Exec(ctx, db.sql.deleteLogsFrom, contract, fromBlock)
+	if err != nil {
+		return 0, err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
+
 // SetStatus sets the status of a log dest pending
-func (db *LogDB) SetStatus(status, hash string) error {
+func (db *LogDB) SetStatus(ctx context.Context, status, hash string) error {
+	ctx, cancel := withQueryTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
 	// if status is success, don't update
-	_, err := db.db.Exec(db.ctx, fmt.Sprintf(`
-	UPDATE t_logs_%s SET status = $1 WHERE hash = $2 AND status != 'success'
-	`, db.suffix), status, hash)
+	_, err := db.db.Exec(ctx, db.sql.setStatus, status, hash)
 
 	return err
 }
 
 // RemoveLog removes a sending log from the db
-func (db *LogDB) RemoveLog(hash string) error {
-	_, err := db.db.Exec(db.ctx, fmt.Sprintf(`
-	DELETE FROM t_logs_%s WHERE hash = $1 AND status != 'success'
-	`, db.suffix), hash)
+func (db *LogDB) RemoveLog(ctx context.Context, hash string) error {
+	ctx, cancel := withQueryTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
+	_, err := db.db.Exec(ctx, db.sql.removeLog, hash)
+
+	return err
+}
+
+// GetUnpositionedTxHashes returns the tx hashes after the given one of the success logs of a contract that have no block number
+func (db *LogDB) GetUnpositionedTxHashes(ctx context.Context, contract, after string, limit int) ([]string, error) {
+	ctx, cancel := withQueryTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
+	rows, err := db.rdb.Query(ctx, db.sql.unpositionedTxHashes, contract, after, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hashes := []string{}
+	for rows.Next() {
+		var hash string
+		err = rows.Scan(&hash)
+		if err != nil {
+			return nil, err
+		}
+
+		hashes = append(hashes, hash)
+	}
+
+	return hashes, rows.Err()
+}
+
+// SetPosition sets the block number and log index of a log that doesn't have one yet
+func (db *LogDB) SetPosition(ctx context.Context, hash string, blockNumber, logIndex int64) error {
+	ctx, cancel := withQueryTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
+	_, err := db.db.Exec(ctx, db.sql.setPosition, blockNumber, logIndex, hash)
 
 	return err
 }
 
-// RemoveOldInProgressLogs removes any log that is not success or fail from the db
-func (db *LogDB) RemoveOldInProgressLogs() error {
+// RemoveOldInProgressLogs removes any optimistic log that is not success or fail from the db
+//
+// indexed logs that wait for their confirmations are pending as well, they have a block number and are kept
+func (db *LogDB) RemoveOldInProgressLogs(ctx context.Context) error {
+	ctx, cancel := withQueryTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
 	old := time.Now().UTC().Add(-30 * time.Second)
 
-	_, err := db.db.Exec(db.ctx, fmt.Sprintf(`
-	DELETE FROM t_logs_%s WHERE created_at <= $1 AND status IN ('sending', 'pending')
-	`, db.suffix), old)
+	_, err := db.db.Exec(ctx, db.sql.removeOldInProgressLogs, old)
 
 	return err
 }
 
+// ConfirmedLogExists checks if a log with the given hash was stored as success
+func (db *LogDB) ConfirmedLogExists(ctx context.Context, hash string) (bool, error) {
+	ctx, cancel := withQueryTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
+	var exists bool
+	err := db.rdb.QueryRow(ctx, db.sql.confirmedLogExists, hash).Scan(&exists)
+
+	return exists, err
+}
+
+// IndexedLogExists checks if a log with the given hash was stored by the indexer, as success or pending its confirmations
+func (db *LogDB) IndexedLogExists(ctx context.Context, hash string) (bool, error) {
+	ctx, cancel := withQueryTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
+	var exists bool
+	err := db.rdb.QueryRow(ctx, db.sql.indexedLogExists, hash).Scan(&exists)
+
+	return exists, err
+}
+
 // GetLog returns the log for a given hash
-func (db *LogDB) GetLog(hash string) (*engine.Log, error) {
+func (db *LogDB) GetLog(ctx context.Context, hash string) (*engine.Log, error) {
+	ctx, cancel := withQueryTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
 	var log engine.Log
 	var value string
 	var extraData *json.RawMessage
 
-	row := db.rdb.QueryRow(db.ctx, fmt.Sprintf(`
-		SELECT l.hash, l.tx_hash, l.created_at, l.updated_at, l.nonce, l.sender, l.dest, l.value, l.data, l.status, d.data as extra_data
-		FROM t_logs_%s l
-		LEFT JOIN t_logs_data_%s d ON l.hash = d.hash
-		WHERE l.hash = $1
-		`, db.suffix, db.suffix), hash)
+	row := db.rdb.QueryRow(ctx, db.sql.getLog, hash)
 
-	err := row.Scan(&log.Hash, &log.TxHash, &log.CreatedAt, &log.UpdatedAt, &log.Nonce, &log.Sender, &log.To, &value, &log.Data, &log.Status, &extraData)
+	err := row.Scan(&log.Hash, &log.TxHash, &log.CreatedAt, &log.UpdatedAt, &log.Nonce, &log.Sender, &log.To, &value, &log.Data, &log.Status, &log.BlockNumber, &log.LogIndex, &extraData)
 	if err != nil {
 		return nil, err
 	}
@@ -255,22 +791,113 @@ func (db *LogDB) GetLog(hash string) (*engine.Log, error) {
 	return &log, nil
 }
 
-// GetAllPaginatedLogs returns the logs paginated
-func (db *LogDB) GetAllPaginatedLogs(contract string, signature string, maxDate time.Time, limit, offset int) ([]*engine.Log, error) {
+// GetLogsByTxHash returns the logs of a transaction in the order that they were emitted, logs that are not indexed yet
+// come last
+func (db *LogDB) GetLogsByTxHash(ctx context.Context, txHash string) ([]*engine.Log, error) {
+	ctx, cancel := withQueryTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
+	logs := []*engine.Log{}
+
+	rows, err := db.rdb.Query(ctx, db.sql.logsByTxHash, strings.ToLower(txHash))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var log engine.Log
+		var value string
+		var extraData *json.RawMessage
+
+		err := rows.Scan(&log.Hash, &log.TxHash, &log.CreatedAt, &log.UpdatedAt, &log.Nonce, &log.Sender, &log.To, &value, &log.Data, &log.Status, &log.BlockNumber, &log.LogIndex, &extraData)
+		if err != nil {
+			return nil, err
+		}
+
+		log.Value = new(big.Int)
+		log.Value.SetString(value, 10)
+		log.ExtraData = extraData
+
+		logs = append(logs, &log)
+	}
+
+	return logs, rows.Err()
+}
+
+// GetContractLogs returns the logs of a contract paginated, across all of its events unless a topic is given, only logs
+// with one of the given statuses are returned unless there are none
+func (db *LogDB) GetContractLogs(ctx context.Context, contract string, topic string, maxDate time.Time, addrs engine.AddressFilter, statuses []engine.LogStatus, limit, offset int) ([]*engine.Log, error) {
+	ctx, cancel := withQueryTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
 	logs := []*engine.Log{}
 
 	query := fmt.Sprintf(`
-	SELECT l.hash, l.tx_hash, l.created_at, l.updated_at, l.nonce, l.sender, l.dest, l.value, l.data, l.status, d.data as extra_data
-	FROM t_logs_%s l
-	LEFT JOIN t_logs_data_%s d ON l.hash = d.hash
-	WHERE l.dest = $1 AND l.data->>'topic' = $2 AND l.created_at <= $3
-	ORDER BY l.created_at DESC
-	LIMIT $4 OFFSET $5
-	`, db.suffix, db.suffix)
+		SELECT l.hash, l.tx_hash, l.created_at, l.updated_at, l.nonce, l.sender, l.dest, l.value, l.data, l.status, COALESCE(l.block_number, 0) AS block_number, COALESCE(l.log_index, 0) AS log_index, d.data as extra_data
+		FROM t_logs_%s l
+		LEFT JOIN t_logs_data_%s d ON l.hash = d.hash
+		WHERE l.dest = $1 AND l.created_at <= $2 AND (cardinality($3::text[]) = 0 OR l.status = ANY($3))
+		`, db.suffix, db.suffix)
 
-	args := []any{contract, signature, maxDate, limit, offset}
+	args := []any{contract, maxDate, statusStrings(statuses)}
 
-	rows, err := db.rdb.Query(db.ctx, query, args...)
+	// without a topic the (dest, created_at) index is used, with one the (dest, topic, created_at) index
+	if topic != "" {
+		query += fmt.Sprintf("AND l.data->>'topic' = $%d ", len(args)+1)
+		args = append(args, topic)
+	}
+
+	addrQuery, addrArgs := addressQuery("l.", len(args)+1, addrs)
+	query += addrQuery
+
+	args = append(args, addrArgs...)
+
+	query += fmt.Sprintf(`
+		ORDER BY %s
+		LIMIT $%d OFFSET $%d
+		`, logsOrder, len(args)+1, len(args)+2)
+
+	args = append(args, limit, offset)
+
+	rows, err := db.rdb.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var log engine.Log
+		var value string
+		var extraData *json.RawMessage
+
+		err := rows.Scan(&log.Hash, &log.TxHash, &log.CreatedAt, &log.UpdatedAt, &log.Nonce, &log.Sender, &log.To, &value, &log.Data, &log.Status, &log.BlockNumber, &log.LogIndex, &extraData)
+		if err != nil {
+			return nil, err
+		}
+
+		log.Value = new(big.Int)
+		log.Value.SetString(value, 10)
+		log.ExtraData = extraData
+
+		logs = append(logs, &log)
+	}
+
+	return logs, rows.Err()
+}
+
+// GetAllPaginatedLogs returns the logs paginated, only logs with one of the given statuses are returned unless there are none
+func (db *LogDB) GetAllPaginatedLogs(ctx context.Context, contract string, signature string, maxDate time.Time, statuses []engine.LogStatus, limit, offset int) ([]*engine.Log, error) {
+	ctx, cancel := withQueryTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
+	logs := []*engine.Log{}
+
+	query := db.sql.allPaginatedLogs
+
+	args := []any{contract, signature, maxDate, statusStrings(statuses), limit, offset}
+
+	rows, err := db.rdb.Query(ctx, query, args...)
 	if err != nil {
 		if err == pgx.ErrNoRows {
 			return logs, nil
@@ -285,7 +912,7 @@ func (db *LogDB) GetAllPaginatedLogs(contract string, signature string, maxDate
 		var value string
 		var extraData *json.RawMessage
 
-		err := rows.Scan(&log.Hash, &log.TxHash, &log.CreatedAt, &log.UpdatedAt, &log.Nonce, &log.Sender, &log.To, &value, &log.Data, &log.Status, &extraData)
+		err := rows.Scan(&log.Hash, &log.TxHash, &log.CreatedAt, &log.UpdatedAt, &log.Nonce, &log.Sender, &log.To, &value, &log.Data, &log.Status, &log.BlockNumber, &log.LogIndex, &extraData)
 		if err != nil {
 			return nil, err
 		}
@@ -300,26 +927,71 @@ func (db *LogDB) GetAllPaginatedLogs(contract string, signature string, maxDate
 	return logs, nil
 }
 
-// GetPaginatedLogs returns the logs for a given from_addr or to_addr paginated
-func (db *LogDB) GetPaginatedLogs(contract string, signature string, maxDate time.Time, dataFilters, dataFilters2 map[string]any, limit, offset int) ([]*engine.Log, error) {
+// GetAccountHistory returns the logs of a contract that an account sent or received paginated, a log that an account
+// sends to itself is returned once
+func (db *LogDB) GetAccountHistory(ctx context.Context, contract, account string, maxDate time.Time, limit, offset int) ([]*engine.Log, error) {
+	ctx, cancel := withQueryTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
+	logs := []*engine.Log{}
+
+	rows, err := db.rdb.Query(ctx, db.sql.accountHistory, contract, strings.ToLower(account), maxDate, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var log engine.Log
+		var value string
+		var extraData *json.RawMessage
+
+		err := rows.Scan(&log.Hash, &log.TxHash, &log.CreatedAt, &log.UpdatedAt, &log.Nonce, &log.Sender, &log.To, &value, &log.Data, &log.Status, &log.BlockNumber, &log.LogIndex, &extraData)
+		if err != nil {
+			return nil, err
+		}
+
+		log.Value = new(big.Int)
+		log.Value.SetString(value, 10)
+		log.ExtraData = extraData
+
+		logs = append(logs, &log)
+	}
+
+	return logs, rows.Err()
+}
+
+// GetPaginatedLogs returns the logs for a given sender or recipient paginated, only logs with one of the given statuses are returned unless there are none
+func (db *LogDB) GetPaginatedLogs(ctx context.Context, contract string, signature string, maxDate time.Time, addrs engine.AddressFilter, argNames []string, dataFilters, dataFilters2 map[string]any, statuses []engine.LogStatus, limit, offset int) ([]*engine.Log, error) {
+	ctx, cancel := withQueryTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
 	logs := []*engine.Log{}
 
 	query := fmt.Sprintf(`
-		SELECT l.hash, l.tx_hash, l.created_at, l.updated_at, l.nonce, l.sender, l.dest, l.value, l.data, l.status, d.data as extra_data
+		SELECT l.hash, l.tx_hash, l.created_at, l.updated_at, l.nonce, l.sender, l.dest, l.value, l.data, l.status, COALESCE(l.block_number, 0) AS block_number, COALESCE(l.log_index, 0) AS log_index, d.data as extra_data
 		FROM t_logs_%s l
 		LEFT JOIN t_logs_data_%s d ON l.hash = d.hash
-		WHERE l.dest = $1 AND l.data->>'topic' = $2 AND l.created_at <= $3
+		WHERE l.dest = $1 AND l.data->>'topic' = $2 AND l.created_at <= $3 AND (cardinality($4::text[]) = 0 OR l.status = ANY($4))
 		`, db.suffix, db.suffix)
 
-	args := []any{contract, signature, maxDate}
+	args := []any{contract, signature, maxDate, statusStrings(statuses)}
 
-	orderLimit := `
-		ORDER BY l.created_at DESC
-		LIMIT $4 OFFSET $5
-		`
+	addrQuery, addrArgs := addressQuery("l.", len(args)+1, addrs)
+	query += addrQuery
+
+	args = append(args, addrArgs...)
+
+	orderLimit := fmt.Sprintf(`
+		ORDER BY %s
+		LIMIT $%d OFFSET $%d
+		`, logsOrder, len(args)+1, len(args)+2)
 
 	if len(dataFilters) > 0 {
-		topicQuery, topicArgs := engine.GenerateJSONBQuery("l.", len(args)+1, dataFilters)
+		topicQuery, topicArgs, err := engine.GenerateJSONBQuery("l.", len(args)+1, dataFilters, argNames)
+		if err != nil {
+			return nil, err
+		}
 
 		query += `AND `
 		query += topicQuery
@@ -330,15 +1002,23 @@ func (db *LogDB) GetPaginatedLogs(contract string, signature string, maxDate tim
 			// I'm being lazy here, could be dynamic
 			query += fmt.Sprintf(`
 				UNION ALL
-				SELECT l.hash, l.tx_hash, l.created_at, l.updated_at, l.nonce, l.sender, l.dest, l.value, l.data, l.status, d.data as extra_data
+				SELECT l.hash, l.tx_hash, l.created_at, l.updated_at, l.nonce, l.sender, l.dest, l.value, l.data, l.status, COALESCE(l.block_number, 0) AS block_number, COALESCE(l.log_index, 0) AS log_index, d.data as extra_data
 				FROM t_logs_%s l
 				LEFT JOIN t_logs_data_%s d ON l.hash = d.hash
-				WHERE l.dest = $%d AND l.data->>'topic' = $%d AND l.created_at <= $%d
-				`, db.suffix, db.suffix, len(args)+1, len(args)+2, len(args)+3)
+				WHERE l.dest = $%d AND l.data->>'topic' = $%d AND l.created_at <= $%d AND (cardinality($%d::text[]) = 0 OR l.status = ANY($%d))
+				`, db.suffix, db.suffix, len(args)+1, len(args)+2, len(args)+3, len(args)+4, len(args)+4)
 
-			args = append(args, contract, signature, maxDate)
+			args = append(args, contract, signature, maxDate, statusStrings(statuses))
 
-			topicQuery2, topicArgs2 := engine.GenerateJSONBQuery("l.", len(args)+1, dataFilters2)
+			addrQuery2, addrArgs2 := addressQuery("l.", len(args)+1, addrs)
+			query += addrQuery2
+
+			args = append(args, addrArgs2...)
+
+			topicQuery2, topicArgs2, err := engine.GenerateJSONBQuery("l.", len(args)+1, dataFilters2, argNames)
+			if err != nil {
+				return nil, err
+			}
 
 			query += `AND `
 			query += topicQuery2
@@ -349,15 +1029,15 @@ func (db *LogDB) GetPaginatedLogs(contract string, signature string, maxDate tim
 		argsLength := len(args)
 
 		orderLimit = fmt.Sprintf(`
-			ORDER BY created_at DESC LIMIT $%d OFFSET $%d
-			`, argsLength+1, argsLength+2)
+			ORDER BY %s LIMIT $%d OFFSET $%d
+			`, unionLogsOrder, argsLength+1, argsLength+2)
 	}
 
 	args = append(args, limit, offset)
 
 	query += orderLimit
 
-	rows, err := db.rdb.Query(db.ctx, query, args...)
+	rows, err := db.rdb.Query(ctx, query, args...)
 	if err != nil {
 		if err == pgx.ErrNoRows {
 			return logs, nil
@@ -372,7 +1052,7 @@ func (db *LogDB) GetPaginatedLogs(contract string, signature string, maxDate tim
 		var value string
 		var extraData *json.RawMessage
 
-		err := rows.Scan(&log.Hash, &log.TxHash, &log.CreatedAt, &log.UpdatedAt, &log.Nonce, &log.Sender, &log.To, &value, &log.Data, &log.Status, &extraData)
+		err := rows.Scan(&log.Hash, &log.TxHash, &log.CreatedAt, &log.UpdatedAt, &log.Nonce, &log.Sender, &log.To, &value, &log.Data, &log.Status, &log.BlockNumber, &log.LogIndex, &extraData)
 		if err != nil {
 			return nil, err
 		}
@@ -387,29 +1067,94 @@ func (db *LogDB) GetPaginatedLogs(contract string, signature string, maxDate tim
 	return logs, nil
 }
 
-// GetAllNewLogs returns the logs for a given from_addr or to_addr from a given date
-func (db *LogDB) GetAllNewLogs(contract string, signature string, fromDate time.Time, limit, offset int) ([]*engine.Log, error) {
+// GetLogsInRange returns the logs for a given sender or recipient created between two dates (both included) paginated,
+// only logs with one of the given statuses are returned unless there are none
+func (db *LogDB) GetLogsInRange(ctx context.Context, contract string, signature string, from, to time.Time, addrs engine.AddressFilter, argNames []string, dataFilters map[string]any, statuses []engine.LogStatus, limit, offset int) ([]*engine.Log, error) {
+	ctx, cancel := withQueryTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
 	logs := []*engine.Log{}
 
 	query := fmt.Sprintf(`
-		SELECT l.hash, l.tx_hash, l.created_at, l.nonce, l.sender, l.dest, l.value, l.data, l.status, d.data as extra_data
+		SELECT l.hash, l.tx_hash, l.created_at, l.updated_at, l.nonce, l.sender, l.dest, l.value, l.data, l.status, COALESCE(l.block_number, 0) AS block_number, COALESCE(l.log_index, 0) AS log_index, d.data as extra_data
 		FROM t_logs_%s l
 		LEFT JOIN t_logs_data_%s d ON l.hash = d.hash
-		WHERE l.dest = $1 AND l.data->>'topic' = $2 AND l.created_at >= $3
+		WHERE l.dest = $1 AND l.data->>'topic' = $2 AND l.created_at >= $3 AND l.created_at <= $4 AND (cardinality($5::text[]) = 0 OR l.status = ANY($5))
 		`, db.suffix, db.suffix)
 
-	args := []any{contract, signature, fromDate}
+	args := []any{contract, signature, from, to, statusStrings(statuses)}
+
+	addrQuery, addrArgs := addressQuery("l.", len(args)+1, addrs)
+	query += addrQuery
+
+	args = append(args, addrArgs...)
+
+	if len(dataFilters) > 0 {
+		topicQuery, topicArgs, err := engine.GenerateJSONBQuery("l.", len(args)+1, dataFilters, argNames)
+		if err != nil {
+			return nil, err
+		}
+
+		query += `AND `
+		query += topicQuery
+
+		args = append(args, topicArgs...)
+	}
+
+	query += fmt.Sprintf(`
+		ORDER BY %s
+		LIMIT $%d OFFSET $%d
+		`, logsOrder, len(args)+1, len(args)+2)
+
+	args = append(args, limit, offset)
+
+	rows, err := db.rdb.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var log engine.Log
+		var value string
+		var extraData *json.RawMessage
+
+		err := rows.Scan(&log.Hash, &log.TxHash, &log.CreatedAt, &log.UpdatedAt, &log.Nonce, &log.Sender, &log.To, &value, &log.Data, &log.Status, &log.BlockNumber, &log.LogIndex, &extraData)
+		if err != nil {
+			return nil, err
+		}
+
+		log.Value = new(big.Int)
+		log.Value.SetString(value, 10)
+		log.ExtraData = extraData
+
+		logs = append(logs, &log)
+	}
+
+	return logs, rows.Err()
+}
+
+// GetAllNewLogs returns the logs for a given from_addr or to_addr from a given date, only logs with one of the given statuses are returned unless there are none
+func (db *LogDB) GetAllNewLogs(ctx context.Context, contract string, signature string, fromDate time.Time, statuses []engine.LogStatus, limit, offset int) ([]*engine.Log, error) {
+	ctx, cancel := withQueryTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
+	logs := []*engine.Log{}
+
+	query := db.sql.allNewLogs
+
+	args := []any{contract, signature, fromDate, statusStrings(statuses)}
 
 	orderLimit := `
-		ORDER BY l.created_at DESC
-		LIMIT $4 OFFSET $5
+		ORDER BY ` + logsOrder + `
+		LIMIT $5 OFFSET $6
 		`
 
 	args = append(args, limit, offset)
 
 	query += orderLimit
 
-	rows, err := db.rdb.Query(db.ctx, query, args...)
+	rows, err := db.rdb.Query(ctx, query, args...)
 	if err != nil {
 		if err == pgx.ErrNoRows {
 			return logs, nil
@@ -424,7 +1169,7 @@ func (db *LogDB) GetAllNewLogs(contract string, signature string, fromDate time.
 		var value string
 		var extraData *json.RawMessage
 
-		err := rows.Scan(&log.Hash, &log.TxHash, &log.CreatedAt, &log.Nonce, &log.Sender, &log.To, &value, &log.Data, &log.Status, &extraData)
+		err := rows.Scan(&log.Hash, &log.TxHash, &log.CreatedAt, &log.Nonce, &log.Sender, &log.To, &value, &log.Data, &log.Status, &log.BlockNumber, &log.LogIndex, &extraData)
 		if err != nil {
 			return nil, err
 		}
@@ -439,25 +1184,36 @@ func (db *LogDB) GetAllNewLogs(contract string, signature string, fromDate time.
 	return logs, nil
 }
 
-// GetNewLogs returns the logs for a given from_addr or to_addr from a given date
-func (db *LogDB) GetNewLogs(contract string, signature string, fromDate time.Time, dataFilters, dataFilters2 map[string]any, limit, offset int) ([]*engine.Log, error) {
+// GetNewLogs returns the logs for a given sender or recipient from a given date, only logs with one of the given statuses are returned unless there are none
+func (db *LogDB) GetNewLogs(ctx context.Context, contract string, signature string, fromDate time.Time, addrs engine.AddressFilter, argNames []string, dataFilters, dataFilters2 map[string]any, statuses []engine.LogStatus, limit, offset int) ([]*engine.Log, error) {
+	ctx, cancel := withQueryTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
 	logs := []*engine.Log{}
 
 	query := fmt.Sprintf(`
-		SELECT l.hash, l.tx_hash, l.created_at, l.nonce, l.sender, l.dest, l.value, l.data, l.status, d.data as extra_data
+		SELECT l.hash, l.tx_hash, l.created_at, l.nonce, l.sender, l.dest, l.value, l.data, l.status, COALESCE(l.block_number, 0) AS block_number, COALESCE(l.log_index, 0) AS log_index, d.data as extra_data
 		FROM t_logs_%s l
 		LEFT JOIN t_logs_data_%s d ON l.hash = d.hash
-		WHERE l.dest = $1 AND l.created_at >= $2
+		WHERE l.dest = $1 AND l.created_at >= $2 AND (cardinality($3::text[]) = 0 OR l.status = ANY($3))
 		`, db.suffix, db.suffix)
 
-	args := []any{contract, fromDate}
+	args := []any{contract, fromDate, statusStrings(statuses)}
 
-	orderLimit := `
-		ORDER BY l.created_at DESC
-		LIMIT $3 OFFSET $4
-		`
+	addrQuery, addrArgs := addressQuery("l.", len(args)+1, addrs)
+	query += addrQuery
+
+	args = append(args, addrArgs...)
+
+	orderLimit := fmt.Sprintf(`
+		ORDER BY %s
+		LIMIT $%d OFFSET $%d
+		`, logsOrder, len(args)+1, len(args)+2)
 	if len(dataFilters) > 0 {
-		topicQuery, topicArgs := engine.GenerateJSONBQuery("l.", len(args)+1, dataFilters)
+		topicQuery, topicArgs, err := engine.GenerateJSONBQuery("l.", len(args)+1, dataFilters, argNames)
+		if err != nil {
+			return nil, err
+		}
 
 		query += `AND `
 		query += topicQuery
@@ -468,14 +1224,23 @@ func (db *LogDB) GetNewLogs(contract string, signature string, fromDate time.Tim
 			// I'm being lazy here, could be dynamic
 			query += fmt.Sprintf(`
 				UNION ALL
-				SELECT hash, tx_hash, created_at, nonce, sender, dest, value, data, status
-				FROM t_logs_%s
-				WHERE dest = $%d AND created_at >= $%d
-				`, db.suffix, len(args)+1, len(args)+2)
+				SELECT l.hash, l.tx_hash, l.created_at, l.nonce, l.sender, l.dest, l.value, l.data, l.status, COALESCE(l.block_number, 0) AS block_number, COALESCE(l.log_index, 0) AS log_index, d.data as extra_data
+				FROM t_logs_%s l
+				LEFT JOIN t_logs_data_%s d ON l.hash = d.hash
+				WHERE l.dest = $%d AND l.created_at >= $%d AND (cardinality($%d::text[]) = 0 OR l.status = ANY($%d))
+				`, db.suffix, db.suffix, len(args)+1, len(args)+2, len(args)+3, len(args)+3)
+
+			args = append(args, contract, fromDate, statusStrings(statuses))
+
+			addrQuery2, addrArgs2 := addressQuery("l.", len(args)+1, addrs)
+			query += addrQuery2
 
-			args = append(args, contract, fromDate)
+			args = append(args, addrArgs2...)
 
-			topicQuery2, topicArgs2 := engine.GenerateJSONBQuery("l.", len(args)+1, dataFilters2)
+			topicQuery2, topicArgs2, err := engine.GenerateJSONBQuery("l.", len(args)+1, dataFilters2, argNames)
+			if err != nil {
+				return nil, err
+			}
 
 			query += `AND `
 			query += topicQuery2
@@ -486,15 +1251,15 @@ func (db *LogDB) GetNewLogs(contract string, signature string, fromDate time.Tim
 		argsLength := len(args)
 
 		orderLimit = fmt.Sprintf(`
-			ORDER BY created_at DESC LIMIT $%d OFFSET $%d
-			`, argsLength+1, argsLength+2)
+			ORDER BY %s LIMIT $%d OFFSET $%d
+			`, unionLogsOrder, argsLength+1, argsLength+2)
 	}
 
 	args = append(args, limit, offset)
 
 	query += orderLimit
 
-	rows, err := db.rdb.Query(db.ctx, query, args...)
+	rows, err := db.rdb.Query(ctx, query, args...)
 	if err != nil {
 		if err == pgx.ErrNoRows {
 			return logs, nil
@@ -509,7 +1274,7 @@ func (db *LogDB) GetNewLogs(contract string, signature string, fromDate time.Tim
 		var value string
 		var extraData *json.RawMessage
 
-		err := rows.Scan(&log.Hash, &log.TxHash, &log.CreatedAt, &log.Nonce, &log.Sender, &log.To, &value, &log.Data, &log.Status, &extraData)
+		err := rows.Scan(&log.Hash, &log.TxHash, &log.CreatedAt, &log.Nonce, &log.Sender, &log.To, &value, &log.Data, &log.Status, &log.BlockNumber, &log.LogIndex, &extraData)
 		if err != nil {
 			return nil, err
 		}
@@ -525,7 +1290,10 @@ func (db *LogDB) GetNewLogs(contract string, signature string, fromDate time.Tim
 }
 
 // UpdateLogsWithDB returns the logs with data updated from the db
-func (db *LogDB) UpdateLogsWithDB(txs []*engine.Log) ([]*engine.Log, error) {
+func (db *LogDB) UpdateLogsWithDB(ctx context.Context, txs []*engine.Log) ([]*engine.Log, error) {
+	ctx, cancel := withQueryTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
 	if len(txs) == 0 {
 		return txs, nil
 	}
@@ -542,12 +1310,12 @@ func (db *LogDB) UpdateLogsWithDB(txs []*engine.Log) ([]*engine.Log, error) {
 		hashStr += fmt.Sprintf("('%s'),", lg.Hash)
 	}
 
-	rows, err := db.rdb.Query(db.ctx, fmt.Sprintf(`
+	rows, err := db.rdb.Query(ctx, fmt.Sprintf(`
 		WITH b(hash) AS (
 			VALUES
 			%s
 		)
-		SELECT lg.hash, lg.tx_hash, lg.created_at, lg.nonce, lg.sender, lg.dest, lg.value, lg.data, lg.status, d.data as extra_data
+		SELECT lg.hash, lg.tx_hash, lg.created_at, lg.nonce, lg.sender, lg.dest, lg.value, lg.data, lg.status, COALESCE(lg.block_number, 0), COALESCE(lg.log_index, 0), d.data as extra_data
 		FROM t_logs_%s lg
 		JOIN b ON lg.hash = b.hash
 		LEFT JOIN t_logs_data_%s d ON lg.hash = d.hash;
@@ -571,7 +1339,7 @@ func (db *LogDB) UpdateLogsWithDB(txs []*engine.Log) ([]*engine.Log, error) {
 		var value string
 		var extraData *json.RawMessage
 
-		err := rows.Scan(&log.Hash, &log.TxHash, &log.CreatedAt, &log.Nonce, &log.Sender, &log.To, &value, &log.Data, &log.Status, &extraData)
+		err := rows.Scan(&log.Hash, &log.TxHash, &log.CreatedAt, &log.Nonce, &log.Sender, &log.To, &value, &log.Data, &log.Status, &log.BlockNumber, &log.LogIndex, &extraData)
 		if err != nil {
 			return nil, err
 		}
@@ -0,0 +1,135 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/citizenwallet/engine/pkg/engine"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// openTestDB creates the tables of a new chain in the database of DB_TEST_URL (postgres://...), the test is skipped
+// without it
+func openTestDB(t *testing.T) *DB {
+	t.Helper()
+
+	url := os.Getenv("DB_TEST_URL")
+	if url == "" {
+		t.Skip("DB_TEST_URL is not set")
+	}
+
+	config, err := pgxpool.ParseConfig(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cc := config.ConnConfig
+	chainID := big.NewInt(time.Now().UnixNano())
+
+	d, err := NewDB(chainID, nil, cc.User, cc.Password, cc.Database, fmt.Sprint(cc.Port), cc.Host, cc.Host, 0, DefaultQueryExecMode, DefaultStatementCacheCapacity)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return d
+}
+
+func TestAddConfirmedLogConcurrently(t *testing.T) {
+	d := openTestDB(t)
+	ctx := context.Background()
+
+	contract := "0x5815E61eF72c9E6107b5c5A05FD121F334f7a7f1"
+	to := "0x7079253c0358eF9Fd87E16488299Ef6e06F403B6"
+
+	data := json.RawMessage(fmt.Sprintf(`{"topic": %q, "from": "0x0000000000000000000000000000000000000001", "to": %q, "value": "100"}`, engine.TransferTopic0.Hex(), to))
+
+	newLog := func() *engine.Log {
+		l := &engine.Log{
+			TxHash:      "0x1b86e5ea2c7b2a5fa2d5c8fd5e4a4bba7cde0e3a1c3f9a3dba5cf50d1b6b4c8d",
+			To:          contract,
+			Value:       big.NewInt(0),
+			Data:        &data,
+			Status:      engine.LogStatusSuccess,
+			CreatedAt:   time.Now().UTC(),
+			UpdatedAt:   time.Now().UTC(),
+			BlockNumber: 10,
+			LogIndex:    1,
+		}
+		l.Hash = l.GenerateUniqueHash()
+
+		return l
+	}
+
+	// the same new log is indexed by several transactions at once, its delta is only applied once
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- d.LogDB.AddConfirmedLog(ctx, newLog())
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	balance, err := d.BalanceDB.GetBalance(contract, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if balance.Cmp(big.NewInt(100)) != 0 {
+		t.Fatalf("expected a balance of 100, got %s", balance)
+	}
+}
+
+func TestSetStatus(t *testing.T) {
+	d := openTestDB(t)
+	ctx := context.Background()
+
+	data := json.RawMessage(fmt.Sprintf(`{"topic": %q, "from": "0x0000000000000000000000000000000000000001", "to": "0x7079253c0358eF9Fd87E16488299Ef6e06F403B6", "value": "100"}`, engine.TransferTopic0.Hex()))
+
+	l := &engine.Log{
+		TxHash:    "0x1b86e5ea2c7b2a5fa2d5c8fd5e4a4bba7cde0e3a1c3f9a3dba5cf50d1b6b4c8d",
+		To:        "0x5815E61eF72c9E6107b5c5A05FD121F334f7a7f1",
+		Value:     big.NewInt(0),
+		Data:      &data,
+		Status:    engine.LogStatusSending,
+		CreatedAt: time.Now().UTC(),
+		UpdatedAt: time.Now().UTC(),
+	}
+	l.Hash = l.GenerateUniqueHash()
+
+	err := d.LogDB.AddLog(ctx, l)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, status := range []engine.LogStatus{engine.LogStatusPending, engine.LogStatusFail} {
+		err = d.LogDB.SetStatus(ctx, string(status), l.Hash)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := d.LogDB.GetLog(ctx, l.Hash)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got.Status != status {
+			t.Fatalf("expected the status %s, got %s", status, got.Status)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/citizenwallet/engine/pkg/common"
+	"github.com/citizenwallet/engine/pkg/engine"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type LogWebhookDB struct {
+	ctx    context.Context
+	suffix string
+	db     *pgxpool.Pool
+	rdb    *pgxpool.Pool
+	cipher engine.KeyCipher
+}
+
+// NewLogWebhookDB creates a new DB
+func NewLogWebhookDB(ctx context.Context, db, rdb *pgxpool.Pool, name string, cipher engine.KeyCipher) (*LogWebhookDB, error) {
+	if err := validateSuffix(name); err != nil {
+		return nil, err
+	}
+
+	wdb := &LogWebhookDB{
+		ctx:    ctx,
+		suffix: name,
+		db:     db,
+		rdb:    rdb,
+		cipher: cipher,
+	}
+
+	return wdb, nil
+}
+
+// CreateLogWebhookTable creates a table to store the callback urls that logs are posted to
+func (db *LogWebhookDB) CreateLogWebhookTable() error {
+	_, err := db.db.Exec(db.ctx, fmt.Sprintf(`
+	CREATE TABLE IF NOT EXISTS t_log_webhooks_%s(
+		id text NOT NULL PRIMARY KEY,
+		url text NOT NULL,
+		secret text NOT NULL,
+		contract text NOT NULL,
+		topic text NOT NULL,
+		address text NOT NULL DEFAULT '',
+		status text NOT NULL DEFAULT 'active',
+		failures integer NOT NULL DEFAULT 0,
+		last_delivery_at timestamp DEFAULT NULL,
+		last_error text NOT NULL DEFAULT '',
+		created_at timestamp NOT NULL DEFAULT current_timestamp,
+		updated_at timestamp NOT NULL DEFAULT current_timestamp
+	);
+	`, db.suffix))
+
+	return err
+}
+
+// CreateLogWebhookTableIndexes creates the indexes for the log webhooks table
+func (db *LogWebhookDB) CreateLogWebhookTableIndexes() error {
+	suffix := common.ShortenName(db.suffix, 6)
+
+	_, err := db.db.Exec(db.ctx, fmt.Sprintf(`
+	CREATE INDEX IF NOT EXISTS idx_log_webhooks_%s_contract_topic ON t_log_webhooks_%s (contract, topic, status);
+	`, suffix, db.suffix))
+
+	return err
+}
+
+// AddLogWebhook stores a webhook, its secret is encrypted
+func (db *LogWebhookDB) AddLogWebhook(h *engine.LogWebhook) error {
+	encrypted, err := db.cipher.Encrypt(h.Secret)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.db.Exec(db.ctx, fmt.Sprintf(`
+	INSERT INTO t_log_webhooks_%s (id, url, secret, contract, topic, address, status, created_at, updated_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`, db.suffix), h.ID, h.URL, encrypted, h.Contract, h.Topic, h.Address, h.Status, h.CreatedAt, h.UpdatedAt)
+
+	return err
+}
+
+// GetLogWebhooks returns all the webhooks without their secrets
+func (db *LogWebhookDB) GetLogWebhooks() ([]*engine.LogWebhook, error) {
+	rows, err := db.rdb.Query(db.ctx, fmt.Sprintf(`
+	SELECT id, url, contract, topic, address, status, failures, last_delivery_at, last_error, created_at, updated_at
+	FROM t_log_webhooks_%s
+	ORDER BY created_at ASC
+	`, db.suffix))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hooks := []*engine.LogWebhook{}
+	for rows.Next() {
+		var h engine.LogWebhook
+		err = rows.Scan(&h.ID, &h.URL, &h.Contract, &h.Topic, &h.Address, &h.Status, &h.Failures, &h.LastDeliveryAt, &h.LastError, &h.CreatedAt, &h.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		hooks = append(hooks, &h)
+	}
+
+	return hooks, rows.Err()
+}
+
+// GetActiveLogWebhooks returns the active webhooks of a contract and topic with their decrypted secrets
+func (db *LogWebhookDB) GetActiveLogWebhooks(contract, topic string) ([]*engine.LogWebhook, error) {
+	rows, err := db.rdb.Query(db.ctx, fmt.Sprintf(`
+	SELECT id, url, secret, contract, topic, address, status, failures, created_at, updated_at
+	FROM t_log_webhooks_%s
+	WHERE contract = $1 AND topic = $2 AND status = $3
+	`, db.suffix), contract, topic, engine.LogWebhookStatusActive)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hooks := []*engine.LogWebhook{}
+	for rows.Next() {
+		var h engine.LogWebhook
+		err = rows.Scan(&h.ID, &h.URL, &h.Secret, &h.Contract, &h.Topic, &h.Address, &h.Status, &h.Failures, &h.CreatedAt, &h.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		h.Secret, err = db.cipher.Decrypt(h.Secret)
+		if err != nil {
+			return nil, err
+		}
+
+		hooks = append(hooks, &h)
+	}
+
+	return hooks, rows.Err()
+}
+
+// SetDeliveryResult records the outcome of a delivery, a failure that reaches maxFailures in a row disables the webhook
+//
+// it returns the status of the webhook after the delivery
+func (db *LogWebhookDB) SetDeliveryResult(id string, deliveryErr error, maxFailures int) (engine.LogWebhookStatus, error) {
+	now := time.Now().UTC()
+
+	lastError := ""
+	if deliveryErr != nil {
+		lastError = deliveryErr.Error()
+	}
+
+	var status engine.LogWebhookStatus
+	err := db.db.QueryRow(db.ctx, fmt.Sprintf(`
+	UPDATE t_log_webhooks_%s
+	SET failures = CASE WHEN $2 = '' THEN 0 ELSE failures + 1 END,
+		status = CASE WHEN $2 <> '' AND failures + 1 >= $3 THEN $4 ELSE status END,
+		last_delivery_at = $5,
+		last_error = $2,
+		updated_at = $5
+	WHERE id = $1
+	RETURNING status
+	`, db.suffix), id, lastError, maxFailures, engine.LogWebhookStatusDisabled, now).Scan(&status)
+	if err == pgx.ErrNoRows {
+		// the webhook was removed while it was being delivered to
+		return engine.LogWebhookStatusDisabled, nil
+	}
+
+	return status, err
+}
+
+// RemoveLogWebhook removes a webhook, returns false if it doesn't exist
+func (db *LogWebhookDB) RemoveLogWebhook(id string) (bool, error) {
+	tag, err := db.db.Exec(db.ctx, fmt.Sprintf(`
+	DELETE FROM t_log_webhooks_%s WHERE id = $1
+	`, db.suffix), id)
+	if err != nil {
+		return false, err
+	}
+
+	return tag.RowsAffected() == 1, nil
+}
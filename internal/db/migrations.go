@@ -0,0 +1,289 @@
+package db
+
+import (
+	"fmt"
+	"log"
+)
+
+// migration is a change of the schema of a chain, migrations are applied once and in the order of their version
+//
+// a migration that fails after some of its statements ran is applied again on the next start, its statements have
+// to be idempotent (ex: CREATE TABLE IF NOT EXISTS, ADD COLUMN IF NOT EXISTS)
+type migration struct {
+	version int
+	name    string
+	up      func(d *DB, evname string) error
+}
+
+// migrations of the schema, new ones are appended with the next version
+var migrations = []migration{
+	{version: 1, name: "create the tables", up: (*DB).createTables},
+	{version: 2, name: "add the display fields of the contracts", up: func(d *DB, evname string) error {
+		return d.EventDB.MigrateEventsDisplay(evname)
+	}},
+	{version: 3, name: "add the pool sequence numbers of the ws outbox", up: func(d *DB, evname string) error {
+		return d.OutboxDB.MigrateOutboxPoolSeq()
+	}},
+}
+
+// Migrate applies the migrations that were not applied yet to the tables of the chain and records them in
+// t_schema_migrations_<chain id>
+//
+// engines that start at the same time wait for each other, the first one applies the migrations
+func (d *DB) Migrate() error {
+	evname := d.chainID.String()
+
+	conn, err := d.db.Acquire(d.ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	// the lock belongs to the connection, it is released with it if the unlock fails
+	lock := "schema_migrations_" + evname
+
+	_, err = conn.Exec(d.ctx, "SELECT pg_advisory_lock(hashtext($1))", lock)
+	if err != nil {
+		return err
+	}
+	defer conn.Exec(d.ctx, "SELECT pg_advisory_unlock(hashtext($1))", lock)
+
+	_, err = conn.Exec(d.ctx, fmt.Sprintf(`
+	CREATE TABLE IF NOT EXISTS t_schema_migrations_%s(
+		version integer NOT NULL PRIMARY KEY,
+		name text NOT NULL,
+		applied_at timestamp NOT NULL DEFAULT current_timestamp
+	);
+	`, evname))
+	if err != nil {
+		return err
+	}
+
+	rows, err := conn.Query(d.ctx, fmt.Sprintf(`
+	SELECT version FROM t_schema_migrations_%s
+	`, evname))
+	if err != nil {
+		return err
+	}
+
+	applied := map[int]bool{}
+	for rows.Next() {
+		var version int
+		err = rows.Scan(&version)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+
+		applied[version] = true
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		if applied[m.version] {
+			continue
+		}
+
+		log.Default().Printf("applying migration %d (%s) for: %s\n", m.version, m.name, evname)
+
+		err = m.up(d, evname)
+		if err != nil {
+			return fmt.Errorf("migration %d (%s): %w", m.version, m.name, err)
+		}
+
+		_, err = conn.Exec(d.ctx, fmt.Sprintf(`
+		INSERT INTO t_schema_migrations_%s (version, name) VALUES ($1, $2)
+		`, evname), m.version, m.name)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// createTables creates the tables of a chain that don't exist yet and adds the columns that the tables created by
+// older versions are missing, the balances are seeded from the logs that were indexed before their table existed
+func (d *DB) createTables(evname string) error {
+	// check if db exists before opening, since we use rwc mode
+	exists, err := d.EventTableExists(evname)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		// create table
+		err = d.EventDB.CreateEventsTable(evname)
+		if err != nil {
+			return err
+		}
+	}
+
+	// tables created by older versions are missing some columns
+	err = d.EventDB.MigrateEventsTable(evname)
+	if err != nil {
+		return err
+	}
+
+	// check if db exists before opening, since we use rwc mode
+	exists, err = d.SponsorTableExists(evname)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		// create table
+		err = d.SponsorDB.CreateSponsorsTable(evname)
+		if err != nil {
+			return err
+		}
+	}
+
+	// check if db exists before opening, since we use rwc mode
+	exists, err = d.SponsorKeysTableExists(evname)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		// create table
+		err = d.SponsorDB.CreateSponsorKeysTable(evname)
+		if err != nil {
+			return err
+		}
+	}
+
+	log.Default().Println("creating transfer db for: ", evname)
+
+	// check if db exists before opening, since we use rwc mode
+	exists, err = d.LogTableExists(evname)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		// create table
+		err = d.LogDB.CreateLogTable()
+		if err != nil {
+			return err
+		}
+	}
+
+	// tables created by older versions are missing some columns
+	err = d.LogDB.MigrateLogTable()
+	if err != nil {
+		return err
+	}
+
+	log.Default().Println("creating data db for: ", evname)
+
+	// check if db exists before opening, since we use rwc mode
+	exists, err = d.DataTableExists(evname)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		// create table
+		err = d.LogDB.datadb.CreateDataTable()
+		if err != nil {
+			return err
+		}
+	}
+
+	// check if db exists before opening, since we use rwc mode
+	exists, err = d.NonceTableExists(evname)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		// create table
+		err = d.NonceDB.CreateNonceTable()
+		if err != nil {
+			return err
+		}
+	}
+
+	// check if db exists before opening, since we use rwc mode
+	exists, err = d.UserOpTableExists(evname)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		// create table
+		err = d.UserOpDB.CreateUserOpTable()
+		if err != nil {
+			return err
+		}
+	}
+
+	// check if db exists before opening, since we use rwc mode
+	exists, err = d.LogWebhookTableExists(evname)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		// create table
+		err = d.LogWebhookDB.CreateLogWebhookTable()
+		if err != nil {
+			return err
+		}
+	}
+
+	// check if db exists before opening, since we use rwc mode
+	exists, err = d.OutboxTableExists(evname)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		// create table
+		err = d.OutboxDB.CreateOutboxTable()
+		if err != nil {
+			return err
+		}
+	}
+
+	evs, err := d.EventDB.GetEvents(d.ctx)
+	if err != nil {
+		return err
+	}
+
+	// check if db exists before opening, since we use rwc mode
+	exists, err = d.BalanceTableExists(evname)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		// create table
+		err = d.BalanceDB.CreateBalanceTable()
+		if err != nil {
+			return err
+		}
+
+		// seed the balances from the logs that were indexed before the table existed
+		seeded := map[string]bool{}
+		for _, ev := range evs {
+			if seeded[ev.Contract] {
+				continue
+			}
+
+			err = d.BalanceDB.Rebuild(ev.Contract)
+			if err != nil {
+				return err
+			}
+
+			seeded[ev.Contract] = true
+		}
+	}
+
+	return nil
+}
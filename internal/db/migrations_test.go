@@ -0,0 +1,15 @@
+package db
+
+import "testing"
+
+func TestMigrationsOrder(t *testing.T) {
+	for i, m := range migrations {
+		if m.version != i+1 {
+			t.Errorf("expected migration %q to have version %d, got %d", m.name, i+1, m.version)
+		}
+
+		if m.name == "" || m.up == nil {
+			t.Errorf("expected migration %d to have a name and an up function", m.version)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type NonceDB struct {
+	ctx    context.Context
+	suffix string
+	db     *pgxpool.Pool
+	rdb    *pgxpool.Pool
+}
+
+// NewNonceDB creates a new DB
+func NewNonceDB(ctx context.Context, db, rdb *pgxpool.Pool, name string) (*NonceDB, error) {
+	if err := validateSuffix(name); err != nil {
+		return nil, err
+	}
+
+	ndb := &NonceDB{
+		ctx:    ctx,
+		suffix: name,
+		db:     db,
+		rdb:    rdb,
+	}
+
+	return ndb, nil
+}
+
+// CreateNonceTable creates a table to store the last signature nonce of every account
+func (db *NonceDB) CreateNonceTable() error {
+	_, err := db.db.Exec(db.ctx, fmt.Sprintf(`
+	CREATE TABLE IF NOT EXISTS t_signature_nonces_%s(
+		account text NOT NULL PRIMARY KEY,
+		nonce bigint NOT NULL,
+		created_at timestamp NOT NULL DEFAULT current_timestamp,
+		updated_at timestamp NOT NULL DEFAULT current_timestamp
+	);
+	`, db.suffix))
+
+	return err
+}
+
+// UseNonce stores the nonce of an account if it is higher than the last one used, returns false if it is not
+func (db *NonceDB) UseNonce(account string, nonce int64) (bool, error) {
+	now := time.Now().UTC()
+
+	tag, err := db.db.Exec(db.ctx, fmt.Sprintf(`
+	INSERT INTO t_signature_nonces_%s (account, nonce, created_at, updated_at)
+	VALUES ($1, $2, $3, $3)
+	ON CONFLICT (account)
+	DO UPDATE SET nonce = EXCLUDED.nonce, updated_at = EXCLUDED.updated_at
+	WHERE t_signature_nonces_%s.nonce < EXCLUDED.nonce
+	`, db.suffix, db.suffix), strings.ToLower(account), nonce, now)
+	if err != nil {
+		return false, err
+	}
+
+	return tag.RowsAffected() == 1, nil
+}
@@ -0,0 +1,213 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/citizenwallet/engine/pkg/common"
+	"github.com/citizenwallet/engine/pkg/engine"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type OutboxDB struct {
+	ctx    context.Context
+	suffix string
+	db     *pgxpool.Pool
+	rdb    *pgxpool.Pool
+}
+
+// NewOutboxDB creates a new DB
+func NewOutboxDB(ctx context.Context, db, rdb *pgxpool.Pool, name string) (*OutboxDB, error) {
+	if err := validateSuffix(name); err != nil {
+		return nil, err
+	}
+
+	odb := &OutboxDB{
+		ctx:    ctx,
+		suffix: name,
+		db:     db,
+		rdb:    rdb,
+	}
+
+	return odb, nil
+}
+
+// CreateOutboxTable creates a table to store the broadcast messages in the order they were sent
+func (db *OutboxDB) CreateOutboxTable() error {
+	_, err := db.db.Exec(db.ctx, fmt.Sprintf(`
+	CREATE TABLE IF NOT EXISTS t_ws_outbox_%s(
+		seq bigserial PRIMARY KEY,
+		pool text NOT NULL,
+		data bytea NOT NULL,
+		created_at timestamp NOT NULL DEFAULT current_timestamp
+	);
+	`, db.suffix))
+
+	return err
+}
+
+// CreateOutboxTableIndexes creates the indexes for the outbox table
+func (db *OutboxDB) CreateOutboxTableIndexes() error {
+	suffix := common.ShortenName(db.suffix, 6)
+
+	_, err := db.db.Exec(db.ctx, fmt.Sprintf(`
+	CREATE INDEX IF NOT EXISTS idx_ws_outbox_%s_pool_seq ON t_ws_outbox_%s (pool, seq);
+	`, suffix, db.suffix))
+	if err != nil {
+		return err
+	}
+
+	_, err = db.db.Exec(db.ctx, fmt.Sprintf(`
+	CREATE INDEX IF NOT EXISTS idx_ws_outbox_%s_created_at ON t_ws_outbox_%s (created_at);
+	`, suffix, db.suffix))
+	if err != nil {
+		return err
+	}
+
+	_, err = db.db.Exec(db.ctx, fmt.Sprintf(`
+	CREATE INDEX IF NOT EXISTS idx_ws_outbox_%s_pool_pool_seq ON t_ws_outbox_%s (pool, pool_seq);
+	`, suffix, db.suffix))
+
+	return err
+}
+
+// MigrateOutboxPoolSeq adds the sequence number of the pool of every message, the messages that were recorded
+// before have it in their data
+func (db *OutboxDB) MigrateOutboxPoolSeq() error {
+	_, err := db.db.Exec(db.ctx, fmt.Sprintf(`
+	ALTER TABLE t_ws_outbox_%s ADD COLUMN IF NOT EXISTS pool_seq bigint NOT NULL DEFAULT 0;
+	`, db.suffix))
+	if err != nil {
+		return err
+	}
+
+	_, err = db.db.Exec(db.ctx, fmt.Sprintf(`
+	UPDATE t_ws_outbox_%s
+	SET pool_seq = COALESCE((convert_from(data, 'UTF8')::jsonb->>'seq')::bigint, 0)
+	WHERE pool_seq = 0
+	`, db.suffix))
+
+	return err
+}
+
+// AppendOutbox records a broadcast message with the sequence number of its pool and returns its sequence number
+func (db *OutboxDB) AppendOutbox(pool string, poolSeq uint64, data []byte) (uint64, error) {
+	var seq uint64
+	err := db.db.QueryRow(db.ctx, fmt.Sprintf(`
+	INSERT INTO t_ws_outbox_%s (pool, pool_seq, data)
+	VALUES ($1, $2, $3)
+	RETURNING seq
+	`, db.suffix), pool, poolSeq, data).Scan(&seq)
+
+	return seq, err
+}
+
+// GetRecentOutbox returns the last messages of the outbox in the order they were sent
+func (db *OutboxDB) GetRecentOutbox(limit int) ([]*engine.OutboxMessage, error) {
+	rows, err := db.rdb.Query(db.ctx, fmt.Sprintf(`
+	SELECT seq, pool, pool_seq, data, created_at FROM (
+		SELECT seq, pool, pool_seq, data, created_at
+		FROM t_ws_outbox_%s
+		ORDER BY seq DESC
+		LIMIT $1
+	) AS recent
+	ORDER BY seq ASC
+	`, db.suffix), limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanOutbox(rows)
+}
+
+// GetOutboxSince returns the messages of a pool that were sent after a sequence number
+func (db *OutboxDB) GetOutboxSince(pool string, after uint64, limit int) ([]*engine.OutboxMessage, error) {
+	rows, err := db.rdb.Query(db.ctx, fmt.Sprintf(`
+	SELECT seq, pool, pool_seq, data, created_at
+	FROM t_ws_outbox_%s
+	WHERE pool = $1 AND seq > $2
+	ORDER BY seq ASC
+	LIMIT $3
+	`, db.suffix), pool, after, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanOutbox(rows)
+}
+
+// GetOutboxSincePoolSeq returns the messages of a pool that were sent after a sequence number of the pool
+func (db *OutboxDB) GetOutboxSincePoolSeq(pool string, poolSeq uint64, limit int) ([]*engine.OutboxMessage, error) {
+	rows, err := db.rdb.Query(db.ctx, fmt.Sprintf(`
+	SELECT seq, pool, pool_seq, data, created_at
+	FROM t_ws_outbox_%s
+	WHERE pool = $1 AND pool_seq > $2
+	ORDER BY pool_seq ASC
+	LIMIT $3
+	`, db.suffix), pool, poolSeq, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanOutbox(rows)
+}
+
+// GetOutboxPoolSeqs returns the last sequence number of every pool in the outbox
+func (db *OutboxDB) GetOutboxPoolSeqs() (map[string]uint64, error) {
+	rows, err := db.rdb.Query(db.ctx, fmt.Sprintf(`
+	SELECT pool, max(pool_seq) FROM t_ws_outbox_%s GROUP BY pool
+	`, db.suffix))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	seqs := map[string]uint64{}
+	for rows.Next() {
+		var pool string
+		var seq uint64
+		err := rows.Scan(&pool, &seq)
+		if err != nil {
+			return nil, err
+		}
+
+		seqs[pool] = seq
+	}
+
+	return seqs, rows.Err()
+}
+
+// PruneOutbox removes the messages that are older than the retention and returns how many were removed
+//
+// the last message of every pool is kept so that the sequence numbers of the pools carry on after a restart
+func (db *OutboxDB) PruneOutbox(retention time.Duration) (int64, error) {
+	tag, err := db.db.Exec(db.ctx, fmt.Sprintf(`
+	DELETE FROM t_ws_outbox_%s
+	WHERE created_at < current_timestamp - make_interval(secs => $1)
+	AND seq NOT IN (SELECT max(seq) FROM t_ws_outbox_%s GROUP BY pool)
+	`, db.suffix, db.suffix), retention.Seconds())
+	if err != nil {
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+func scanOutbox(rows pgx.Rows) ([]*engine.OutboxMessage, error) {
+	defer rows.Close()
+
+	msgs := []*engine.OutboxMessage{}
+	for rows.Next() {
+		var m engine.OutboxMessage
+		err := rows.Scan(&m.Seq, &m.Pool, &m.PoolSeq, &m.Data, &m.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		msgs = append(msgs, &m)
+	}
+
+	return msgs, rows.Err()
+}
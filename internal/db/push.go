@@ -20,6 +20,10 @@ type PushTokenDB struct {
 
 // NewPushTokenDB creates a new DB
 func NewPushTokenDB(ctx context.Context, db, rdb *pgxpool.Pool, name string) (*PushTokenDB, error) {
+	if err := validateSuffix(name); err != nil {
+		return nil, err
+	}
+
 	txdb := &PushTokenDB{
 		ctx:    ctx,
 		suffix: name,
@@ -0,0 +1,35 @@
+package db
+
+import (
+	"fmt"
+	"slices"
+)
+
+const (
+	// DefaultQueryExecMode prepares every distinct query once per connection and reuses its plan after
+	//
+	// the queries of a sub db only differ by the suffix of its tables, which is fixed when it is created, so a query
+	// has the same text on every call and hits the cache
+	DefaultQueryExecMode = "cache_statement"
+
+	// DefaultStatementCacheCapacity is the number of prepared statements that each connection keeps, the filters of the
+	// log queries make a few variants of each
+	DefaultStatementCacheCapacity = 512
+)
+
+// query exec modes of pgx, exec and simple_protocol don't prepare statements and work behind a transaction pooler
+// (ex: pgbouncer)
+var queryExecModes = []string{"cache_statement", "cache_describe", "describe_exec", "exec", "simple_protocol"}
+
+// queryParams returns the connection string params that set how the pools send queries
+func queryParams(execMode string, cacheCapacity int) (string, error) {
+	if !slices.Contains(queryExecModes, execMode) {
+		return "", fmt.Errorf("invalid query exec mode %q, expected one of %v", execMode, queryExecModes)
+	}
+
+	if cacheCapacity <= 0 {
+		return "", fmt.Errorf("invalid statement cache capacity %d", cacheCapacity)
+	}
+
+	return fmt.Sprintf("default_query_exec_mode=%s statement_cache_capacity=%d", execMode, cacheCapacity), nil
+}
@@ -0,0 +1,101 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func TestQueryParams(t *testing.T) {
+	params, err := queryParams(DefaultQueryExecMode, DefaultStatementCacheCapacity)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := pgxpool.ParseConfig("user=engine dbname=engine host=localhost port=5432 sslmode=disable " + params)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if config.ConnConfig.DefaultQueryExecMode != pgx.QueryExecModeCacheStatement {
+		t.Errorf("expected the statement cache, got %s", config.ConnConfig.DefaultQueryExecMode)
+	}
+
+	if config.ConnConfig.StatementCacheCapacity != DefaultStatementCacheCapacity {
+		t.Errorf("expected a capacity of %d, got %d", DefaultStatementCacheCapacity, config.ConnConfig.StatementCacheCapacity)
+	}
+
+	if _, err := queryParams("prepared", DefaultStatementCacheCapacity); err == nil {
+		t.Error("expected an unknown exec mode to be rejected")
+	}
+
+	if _, err := queryParams("exec", 0); err == nil {
+		t.Error("expected an empty cache to be rejected")
+	}
+}
+
+func TestPrecomputedQueries(t *testing.T) {
+	suffix := "100_0x5815e61ef72c9e6107b5c5a05fd121f334f7a7f1"
+
+	for _, queries := range []any{newLogSQL(suffix), newUserOpSQL(suffix)} {
+		v := reflect.ValueOf(queries)
+		for i := 0; i < v.NumField(); i++ {
+			name := v.Type().Name() + "." + v.Type().Field(i).Name
+			q := v.Field(i).String()
+
+			if !strings.Contains(q, suffix) {
+				t.Errorf("%s: expected the query to use the suffix", name)
+			}
+
+			if strings.Contains(q, "%!") || strings.Contains(q, "%s") {
+				t.Errorf("%s: expected the query to be fully formatted, got %s", name, q)
+			}
+		}
+	}
+}
+
+// BenchmarkQueryExecModes compares the throughput of a log query with and without the statement cache, it needs a
+// database with the tables of chain 1 (DB_BENCH_URL=postgres://...)
+func BenchmarkQueryExecModes(b *testing.B) {
+	url := os.Getenv("DB_BENCH_URL")
+	if url == "" {
+		b.Skip("DB_BENCH_URL is not set")
+	}
+
+	for _, mode := range []string{"exec", DefaultQueryExecMode} {
+		b.Run(mode, func(b *testing.B) {
+			config, err := pgxpool.ParseConfig(fmt.Sprintf("%s?default_query_exec_mode=%s", url, mode))
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			pool, err := pgxpool.NewWithConfig(context.Background(), config)
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer pool.Close()
+
+			ldb, err := NewLogDB(context.Background(), pool, pool, "1", nil, nil)
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					_, err := ldb.GetLogsByTxHash(context.Background(), "0x1b86e5ea2c7b2a5fa2d5c8fd5e4a4bba7cde0e3a1c3f9a3dba5cf50d1b6b4c8d")
+					if err != nil {
+						b.Error(err)
+						return
+					}
+				}
+			})
+		})
+	}
+}
@@ -6,24 +6,28 @@ import (
 
 	"github.com/citizenwallet/engine/pkg/common"
 	"github.com/citizenwallet/engine/pkg/engine"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 type SponsorDB struct {
 	ctx    context.Context
 	suffix string
-	secret string
+	cipher engine.KeyCipher
 	db     *pgxpool.Pool
 	rdb    *pgxpool.Pool
 }
 
 // NewSponsorDB creates a new DB
-func NewSponsorDB(ctx context.Context, db, rdb *pgxpool.Pool, name, secret string) (*SponsorDB, error) {
+func NewSponsorDB(ctx context.Context, db, rdb *pgxpool.Pool, name string, cipher engine.KeyCipher) (*SponsorDB, error) {
+	if err := validateSuffix(name); err != nil {
+		return nil, err
+	}
 
 	sdb := &SponsorDB{
 		ctx:    ctx,
 		suffix: name,
-		secret: secret,
+		cipher: cipher,
 		db:     db,
 		rdb:    rdb,
 	}
@@ -33,6 +37,10 @@ func NewSponsorDB(ctx context.Context, db, rdb *pgxpool.Pool, name, secret strin
 
 // createSponsorsTable creates a table to store sponsors in the given db
 func (db *SponsorDB) CreateSponsorsTable(suffix string) error {
+	if err := validateSuffix(suffix); err != nil {
+		return err
+	}
+
 	_, err := db.db.Exec(db.ctx, fmt.Sprintf(`
 	CREATE TABLE t_sponsors_%s(
 		contract TEXT NOT NULL PRIMARY KEY,
@@ -50,6 +58,26 @@ func (db *SponsorDB) CreateSponsorsTableIndexes(suffix string) error {
 	return nil
 }
 
+// CreateSponsorKeysTable creates a table to store the additional sponsor keys of a contract
+func (db *SponsorDB) CreateSponsorKeysTable(suffix string) error {
+	if err := validateSuffix(suffix); err != nil {
+		return err
+	}
+
+	_, err := db.db.Exec(db.ctx, fmt.Sprintf(`
+	CREATE TABLE t_sponsor_keys_%s(
+		contract TEXT NOT NULL,
+		address TEXT NOT NULL,
+		pk text NOT NULL,
+		created_at timestamp NOT NULL DEFAULT current_timestamp,
+		updated_at timestamp NOT NULL DEFAULT current_timestamp,
+		PRIMARY KEY (contract, address)
+	);
+	`, suffix))
+
+	return err
+}
+
 // GetSponsor gets a sponsor from the db by contract
 func (db *SponsorDB) GetSponsor(contract string) (*engine.Sponsor, error) {
 	var sponsor engine.Sponsor
@@ -62,7 +90,7 @@ func (db *SponsorDB) GetSponsor(contract string) (*engine.Sponsor, error) {
 		return nil, err
 	}
 
-	decrypted, err := common.Decrypt(sponsor.PrivateKey, db.secret)
+	decrypted, err := db.cipher.Decrypt(sponsor.PrivateKey)
 	if err != nil {
 		return nil, err
 	}
@@ -72,9 +100,115 @@ func (db *SponsorDB) GetSponsor(contract string) (*engine.Sponsor, error) {
 	return &sponsor, nil
 }
 
+// GetSponsors gets all the sponsors of a contract, the main sponsor first followed by the additional keys
+func (db *SponsorDB) GetSponsors(contract string) ([]*engine.Sponsor, error) {
+	sponsor, err := db.GetSponsor(contract)
+	if err != nil {
+		return nil, err
+	}
+
+	sponsors := []*engine.Sponsor{sponsor}
+
+	rows, err := db.rdb.Query(db.ctx, fmt.Sprintf(`
+	SELECT contract, pk, created_at, updated_at
+	FROM t_sponsor_keys_%s
+	WHERE contract = $1
+	ORDER BY created_at ASC
+	`, db.suffix), contract)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var sponsor engine.Sponsor
+		err := rows.Scan(&sponsor.Contract, &sponsor.PrivateKey, &sponsor.CreatedAt, &sponsor.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		decrypted, err := db.cipher.Decrypt(sponsor.PrivateKey)
+		if err != nil {
+			return nil, err
+		}
+
+		sponsor.PrivateKey = decrypted
+
+		sponsors = append(sponsors, &sponsor)
+	}
+
+	return sponsors, rows.Err()
+}
+
+// GetAllSponsors gets the sponsors of every contract, main sponsors and additional keys
+func (db *SponsorDB) GetAllSponsors() ([]*engine.Sponsor, error) {
+	rows, err := db.rdb.Query(db.ctx, fmt.Sprintf(`
+	SELECT contract, pk, created_at, updated_at
+	FROM t_sponsors_%s
+	UNION ALL
+	SELECT contract, pk, created_at, updated_at
+	FROM t_sponsor_keys_%s
+	ORDER BY contract ASC, created_at ASC
+	`, db.suffix, db.suffix))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sponsors := []*engine.Sponsor{}
+	for rows.Next() {
+		var sponsor engine.Sponsor
+		err := rows.Scan(&sponsor.Contract, &sponsor.PrivateKey, &sponsor.CreatedAt, &sponsor.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		decrypted, err := db.cipher.Decrypt(sponsor.PrivateKey)
+		if err != nil {
+			return nil, err
+		}
+
+		sponsor.PrivateKey = decrypted
+
+		sponsors = append(sponsors, &sponsor)
+	}
+
+	return sponsors, rows.Err()
+}
+
+// AddSponsorKey adds an additional sponsor key to a contract, the main sponsor keeps signing for the paymaster
+func (db *SponsorDB) AddSponsorKey(sponsor *engine.Sponsor) error {
+	key, err := common.HexToPrivateKey(sponsor.PrivateKey)
+	if err != nil {
+		return err
+	}
+
+	encrypted, err := db.cipher.Encrypt(sponsor.PrivateKey)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.db.Exec(db.ctx, fmt.Sprintf(`
+	INSERT INTO t_sponsor_keys_%s(contract, address, pk, created_at, updated_at)
+	VALUES($1, $2, $3, $4, $5)
+	`, db.suffix), sponsor.Contract, crypto.PubkeyToAddress(key.PublicKey).Hex(), encrypted, sponsor.CreatedAt, sponsor.UpdatedAt)
+
+	return err
+}
+
+// RemoveSponsorKey removes an additional sponsor key from a contract
+func (db *SponsorDB) RemoveSponsorKey(contract, address string) error {
+	_, err := db.db.Exec(db.ctx, fmt.Sprintf(`
+	DELETE FROM t_sponsor_keys_%s
+	WHERE contract = $1 AND address = $2
+	`, db.suffix), contract, address)
+
+	return err
+}
+
 // AddSponsor adds a sponsor to the db
 func (db *SponsorDB) AddSponsor(sponsor *engine.Sponsor) error {
-	encrypted, err := common.Encrypt(sponsor.PrivateKey, db.secret)
+	encrypted, err := db.cipher.Encrypt(sponsor.PrivateKey)
 	if err != nil {
 		return err
 	}
@@ -92,7 +226,7 @@ func (db *SponsorDB) AddSponsor(sponsor *engine.Sponsor) error {
 
 // UpdateSponsor updates a sponsor in the db
 func (db *SponsorDB) UpdateSponsor(sponsor *engine.Sponsor) error {
-	encrypted, err := common.Encrypt(sponsor.PrivateKey, db.secret)
+	encrypted, err := db.cipher.Encrypt(sponsor.PrivateKey)
 	if err != nil {
 		return err
 	}
@@ -108,3 +242,30 @@ func (db *SponsorDB) UpdateSponsor(sponsor *engine.Sponsor) error {
 
 	return nil
 }
+
+// RemoveSponsor removes the sponsor of a contract and its additional keys, returns false if there was no sponsor
+func (db *SponsorDB) RemoveSponsor(contract string) (bool, error) {
+	tx, err := db.db.Begin(db.ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(db.ctx)
+
+	tag, err := tx.Exec(db.ctx, fmt.Sprintf(`
+	DELETE FROM t_sponsors_%s
+	WHERE contract = $1
+	`, db.suffix), contract)
+	if err != nil {
+		return false, err
+	}
+
+	_, err = tx.Exec(db.ctx, fmt.Sprintf(`
+	DELETE FROM t_sponsor_keys_%s
+	WHERE contract = $1
+	`, db.suffix), contract)
+	if err != nil {
+		return false, err
+	}
+
+	return tag.RowsAffected() > 0, tx.Commit(db.ctx)
+}
@@ -0,0 +1,21 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+var ErrInvalidSuffix = errors.New("invalid table name suffix")
+
+// table names are built by formatting a suffix into the queries, it can't be a query parameter
+var suffixPattern = regexp.MustCompile(`^[a-zA-Z0-9_]+$`)
+
+// validateSuffix makes sure that a table name suffix can't change the structure of the queries it is formatted into
+func validateSuffix(suffix string) error {
+	if !suffixPattern.MatchString(suffix) {
+		return fmt.Errorf("%w: %q", ErrInvalidSuffix, suffix)
+	}
+
+	return nil
+}
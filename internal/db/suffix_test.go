@@ -0,0 +1,61 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestValidateSuffix(t *testing.T) {
+	valid := []string{
+		"1",
+		"100_0x5815e61ef72c9e6107b5c5a05fd121f334f7a7f1",
+		"ABC_123",
+	}
+
+	for _, s := range valid {
+		if err := validateSuffix(s); err != nil {
+			t.Errorf("expected %q to be valid, got %v", s, err)
+		}
+	}
+
+	invalid := []string{
+		"",
+		"1; DROP TABLE t_logs_1",
+		"1_0xabc'--",
+		"1 OR 1=1",
+		`1"`,
+		"1_0xabc\n",
+		"t_logs_1(",
+	}
+
+	for _, s := range invalid {
+		if err := validateSuffix(s); !errors.Is(err, ErrInvalidSuffix) {
+			t.Errorf("expected %q to be invalid, got %v", s, err)
+		}
+	}
+}
+
+func TestConstructorsRejectInvalidSuffix(t *testing.T) {
+	ctx := context.Background()
+	suffix := "1; DROP TABLE t_logs_1"
+
+	constructors := map[string]func() error{
+		"balance": func() error { _, err := NewBalanceDB(ctx, nil, nil, suffix); return err },
+		"data":    func() error { _, err := NewDataDB(ctx, nil, nil, suffix); return err },
+		"event":   func() error { _, err := NewEventDB(ctx, nil, nil, suffix); return err },
+		"log":     func() error { _, err := NewLogDB(ctx, nil, nil, suffix, nil, nil); return err },
+		"webhook": func() error { _, err := NewLogWebhookDB(ctx, nil, nil, suffix, nil); return err },
+		"nonce":   func() error { _, err := NewNonceDB(ctx, nil, nil, suffix); return err },
+		"outbox":  func() error { _, err := NewOutboxDB(ctx, nil, nil, suffix); return err },
+		"push":    func() error { _, err := NewPushTokenDB(ctx, nil, nil, suffix); return err },
+		"sponsor": func() error { _, err := NewSponsorDB(ctx, nil, nil, suffix, nil); return err },
+		"userop":  func() error { _, err := NewUserOpDB(ctx, nil, nil, suffix); return err },
+	}
+
+	for name, fn := range constructors {
+		if err := fn(); !errors.Is(err, ErrInvalidSuffix) {
+			t.Errorf("%s: expected ErrInvalidSuffix, got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const (
+	// DefaultStatementTimeout is how long a query of the reader pool can run before postgres aborts it
+	DefaultStatementTimeout = 30 * time.Second
+
+	// DefaultStatsTimeout overrides the statement timeout for the aggregations of the stats endpoints
+	DefaultStatsTimeout = 2 * time.Minute
+
+	// DefaultQueryTimeout bounds the queries of a call whose context has no deadline, ex: the ones of the indexer and
+	// the queues, requests already have the deadline of their route
+	DefaultQueryTimeout = 30 * time.Second
+)
+
+// postgres cancels a statement that runs past its statement_timeout with query_canceled
+const pgQueryCanceled = "57014"
+
+// IsQueryTimeout returns true if a query was aborted because it took too long
+func IsQueryTimeout(err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code == pgQueryCanceled
+	}
+
+	return errors.Is(err, context.DeadlineExceeded)
+}
+
+type querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}
+
+// withQueryTimeout gives a context a deadline if it doesn't have one yet, so that a query can't hold a connection of
+// the pool forever
+func withQueryTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok || timeout <= 0 {
+		return ctx, func() {}
+	}
+
+	return context.WithTimeout(ctx, timeout)
+}
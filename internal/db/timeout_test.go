@@ -0,0 +1,45 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestWithQueryTimeout(t *testing.T) {
+	ctx, cancel := withQueryTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	deadline, ok := ctx.Deadline()
+	if !ok || time.Until(deadline) > time.Minute {
+		t.Errorf("expected a deadline within a minute, got %v", deadline)
+	}
+
+	rctx, rcancel := context.WithTimeout(context.Background(), time.Second)
+	defer rcancel()
+
+	ctx, cancel = withQueryTimeout(rctx, time.Minute)
+	defer cancel()
+
+	if d, _ := ctx.Deadline(); !d.Equal(mustDeadline(t, rctx)) {
+		t.Errorf("expected the deadline of the request to be kept, got %v", d)
+	}
+
+	ctx, cancel = withQueryTimeout(context.Background(), 0)
+	defer cancel()
+
+	if _, ok := ctx.Deadline(); ok {
+		t.Error("expected no deadline without a timeout")
+	}
+}
+
+func mustDeadline(t *testing.T, ctx context.Context) time.Time {
+	t.Helper()
+
+	d, ok := ctx.Deadline()
+	if !ok {
+		t.Fatal("expected a deadline")
+	}
+
+	return d
+}
@@ -0,0 +1,160 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/citizenwallet/engine/pkg/common"
+	"github.com/citizenwallet/engine/pkg/engine"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type UserOpDB struct {
+	ctx    context.Context
+	suffix string
+	db     *pgxpool.Pool
+	rdb    *pgxpool.Pool
+	sql    userOpSQL
+}
+
+// userOpSQL holds the queries of a UserOpDB, they are formatted once with the suffix of its table
+type userOpSQL struct {
+	reserveKey    string
+	getSubmission string
+	setResult     string
+}
+
+func newUserOpSQL(suffix string) userOpSQL {
+	return userOpSQL{
+		reserveKey: fmt.Sprintf(`
+	INSERT INTO t_userops_%s (sender, idempotency_key, id, status, created_at, updated_at)
+	VALUES ($1, $2, $3, $4, $5, $5)
+	ON CONFLICT (sender, idempotency_key)
+	DO UPDATE SET status = EXCLUDED.status, tx_hash = '', error = '', updated_at = EXCLUDED.updated_at
+	WHERE t_userops_%s.id = EXCLUDED.id AND (t_userops_%s.status = $6 OR (t_userops_%s.status = $4 AND t_userops_%s.updated_at < $7))
+	`, suffix, suffix, suffix, suffix, suffix),
+		getSubmission: fmt.Sprintf(`
+	SELECT sender, idempotency_key, id, tx_hash, status, error, updated_at
+	FROM t_userops_%s
+	WHERE sender = $1 AND idempotency_key = $2
+	`, suffix),
+		setResult: fmt.Sprintf(`
+	UPDATE t_userops_%s
+	SET status = $1, tx_hash = $2, error = $3, updated_at = $4
+	WHERE sender = $5 AND idempotency_key = $6
+	`, suffix),
+	}
+}
+
+// UserOpSubmission is a user operation that was submitted with an idempotency key
+type UserOpSubmission struct {
+	Sender         string
+	IdempotencyKey string
+	ID             string
+	TxHash         string
+	Status         engine.UserOpStatus
+	Error          string
+	UpdatedAt      time.Time
+}
+
+// NewUserOpDB creates a new DB
+func NewUserOpDB(ctx context.Context, db, rdb *pgxpool.Pool, name string) (*UserOpDB, error) {
+	if err := validateSuffix(name); err != nil {
+		return nil, err
+	}
+
+	udb := &UserOpDB{
+		ctx:    ctx,
+		suffix: name,
+		db:     db,
+		rdb:    rdb,
+		sql:    newUserOpSQL(name),
+	}
+
+	return udb, nil
+}
+
+// CreateUserOpTable creates a table to store the user operations that were submitted with an idempotency key
+func (db *UserOpDB) CreateUserOpTable() error {
+	_, err := db.db.Exec(db.ctx, fmt.Sprintf(`
+	CREATE TABLE IF NOT EXISTS t_userops_%s(
+		sender text NOT NULL,
+		idempotency_key text NOT NULL,
+		id text NOT NULL,
+		tx_hash text NOT NULL DEFAULT '',
+		status text NOT NULL DEFAULT 'pending',
+		error text NOT NULL DEFAULT '',
+		created_at timestamp NOT NULL DEFAULT current_timestamp,
+		updated_at timestamp NOT NULL DEFAULT current_timestamp,
+		PRIMARY KEY (sender, idempotency_key)
+	);
+	`, db.suffix))
+
+	return err
+}
+
+// CreateUserOpTableIndexes creates the indexes for the userops table
+func (db *UserOpDB) CreateUserOpTableIndexes() error {
+	suffix := common.ShortenName(db.suffix, 6)
+
+	_, err := db.db.Exec(db.ctx, fmt.Sprintf(`
+	CREATE INDEX IF NOT EXISTS idx_userops_%s_id ON t_userops_%s (id);
+	`, suffix, db.suffix))
+
+	return err
+}
+
+// ReserveIdempotencyKey claims a key for a user operation of sender, returns the existing submission if the key is already claimed
+//
+// a key can be claimed again when its last submission failed or when it has been pending for longer than stale
+func (db *UserOpDB) ReserveIdempotencyKey(ctx context.Context, sender, key, id string, stale time.Duration) (bool, *UserOpSubmission, error) {
+	ctx, cancel := withQueryTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
+	now := time.Now().UTC()
+
+	tag, err := db.db.Exec(ctx, db.sql.reserveKey, sender, key, id, engine.UserOpStatusPending, now, engine.UserOpStatusFail, now.Add(-stale))
+	if err != nil {
+		return false, nil, err
+	}
+
+	if tag.RowsAffected() == 1 {
+		return true, nil, nil
+	}
+
+	sub, err := db.GetSubmission(ctx, sender, key)
+	if err != nil {
+		return false, nil, err
+	}
+
+	return false, sub, nil
+}
+
+// GetSubmission returns the submission of sender for an idempotency key
+func (db *UserOpDB) GetSubmission(ctx context.Context, sender, key string) (*UserOpSubmission, error) {
+	ctx, cancel := withQueryTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
+	var sub UserOpSubmission
+	err := db.db.QueryRow(ctx, db.sql.getSubmission, sender, key).Scan(&sub.Sender, &sub.IdempotencyKey, &sub.ID, &sub.TxHash, &sub.Status, &sub.Error, &sub.UpdatedAt)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &sub, nil
+}
+
+// SetSubmissionResult stores the outcome of a submission
+func (db *UserOpDB) SetSubmissionResult(ctx context.Context, sender, key string, status engine.UserOpStatus, txHash, errMsg string) error {
+	ctx, cancel := withQueryTimeout(ctx, DefaultQueryTimeout)
+	defer cancel()
+
+	_, err := db.db.Exec(ctx, db.sql.setResult, status, txHash, errMsg, time.Now().UTC(), sender, key)
+
+	return err
+}
@@ -0,0 +1,159 @@
+package ethrequest
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/citizenwallet/engine/pkg/engine"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// BreakerSettings configures when calls to the rpc node are fast-failed
+type BreakerSettings struct {
+	Threshold int           // consecutive failures that open the breaker, 0 disables it
+	Cooldown  time.Duration // how long the breaker stays open before a call is let through to probe the node
+}
+
+var DefaultBreakerSettings = BreakerSettings{
+	Threshold: 5,
+	Cooldown:  30 * time.Second,
+}
+
+// breaker stops calling an rpc node that keeps failing so that an outage of the provider is not amplified by retries
+//
+// once open, calls fail with engine.ErrRPCUnavailable until the cooldown is over, the next call then probes the node
+// (half open) and closes the breaker if it succeeds or opens it again if it fails
+type breaker struct {
+	mu       sync.Mutex
+	s        BreakerSettings
+	state    engine.BreakerState
+	failures int
+	openedAt time.Time
+	probing  bool
+
+	now func() time.Time
+}
+
+func newBreaker(s BreakerSettings) *breaker {
+	return &breaker{s: s, state: engine.BreakerClosed, now: time.Now}
+}
+
+// State returns the state of the breaker, a nil breaker is always closed
+func (b *breaker) State() engine.BreakerState {
+	if b == nil {
+		return engine.BreakerClosed
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == engine.BreakerOpen && b.now().Sub(b.openedAt) >= b.s.Cooldown {
+		return engine.BreakerHalfOpen
+	}
+
+	return b.state
+}
+
+// allow returns engine.ErrRPCUnavailable if a call can't be made now, only one probe is in flight while half open
+func (b *breaker) allow() error {
+	if b == nil {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case engine.BreakerOpen:
+		if b.now().Sub(b.openedAt) < b.s.Cooldown {
+			return engine.ErrRPCUnavailable
+		}
+
+		b.state = engine.BreakerHalfOpen
+		b.probing = true
+	case engine.BreakerHalfOpen:
+		if b.probing {
+			return engine.ErrRPCUnavailable
+		}
+
+		b.probing = true
+	}
+
+	return nil
+}
+
+// done records the outcome of a call that was allowed
+func (b *breaker) done(err error) {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == engine.BreakerHalfOpen {
+		b.probing = false
+	}
+
+	if errors.Is(err, context.Canceled) {
+		// the caller gave up, it says nothing about the node
+		return
+	}
+
+	if !isNodeFailure(err) {
+		if b.state != engine.BreakerClosed {
+			log.Default().Println("rpc node is available again, closing the breaker")
+		}
+
+		b.state = engine.BreakerClosed
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if b.state == engine.BreakerHalfOpen || (b.s.Threshold > 0 && b.failures >= b.s.Threshold) {
+		if b.state == engine.BreakerClosed {
+			log.Default().Printf("rpc node failed %d times in a row, opening the breaker for %s\n", b.failures, b.s.Cooldown)
+		}
+
+		b.state = engine.BreakerOpen
+		b.openedAt = b.now()
+	}
+}
+
+// isNodeFailure returns true if the node could not answer a call, an error response (ex: execution reverted, nonce
+// too low) means that the node is up
+func isNodeFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var rpcErr rpc.Error
+	return !errors.As(err, &rpcErr)
+}
+
+// guarded makes a call to the rpc node through the breaker
+func guarded[T any](b *breaker, call func() (T, error)) (T, error) {
+	if err := b.allow(); err != nil {
+		var zero T
+		return zero, err
+	}
+
+	v, err := call()
+	b.done(err)
+
+	return v, err
+}
+
+// SetBreakerSettings replaces the settings of the breaker, it resets its state
+func (e *EthService) SetBreakerSettings(s BreakerSettings) {
+	e.breaker = newBreaker(s)
+}
+
+// BreakerState returns the state of the breaker in front of the rpc node
+func (e *EthService) BreakerState() engine.BreakerState {
+	return e.breaker.State()
+}
@@ -0,0 +1,96 @@
+package ethrequest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/citizenwallet/engine/pkg/engine"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+type testRPCError struct{}
+
+func (testRPCError) Error() string  { return "execution reverted" }
+func (testRPCError) ErrorCode() int { return 3 }
+
+var _ rpc.Error = testRPCError{}
+
+func TestBreaker(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	b := newBreaker(BreakerSettings{Threshold: 3, Cooldown: time.Minute})
+	b.now = func() time.Time { return now }
+
+	fail := func() (int, error) { return 0, errors.New("connection refused") }
+	ok := func() (int, error) { return 1, nil }
+
+	// error responses mean that the node is up
+	for i := 0; i < 5; i++ {
+		guarded(b, func() (int, error) { return 0, testRPCError{} })
+	}
+	if b.State() != engine.BreakerClosed {
+		t.Fatalf("expected error responses to keep the breaker closed, got %s", b.State())
+	}
+
+	// a success resets the failures
+	guarded(b, fail)
+	guarded(b, fail)
+	guarded(b, ok)
+	guarded(b, fail)
+	if b.State() != engine.BreakerClosed {
+		t.Fatalf("expected the breaker to be closed, got %s", b.State())
+	}
+
+	// canceled calls don't count
+	guarded(b, func() (int, error) { return 0, context.Canceled })
+	guarded(b, fail)
+	guarded(b, fail)
+	if b.State() != engine.BreakerOpen {
+		t.Fatalf("expected the breaker to open after 3 failures, got %s", b.State())
+	}
+
+	calls := 0
+	_, err := guarded(b, func() (int, error) { calls++; return 1, nil })
+	if !errors.Is(err, engine.ErrRPCUnavailable) || calls != 0 {
+		t.Fatalf("expected an open breaker to fail fast, got %v after %d calls", err, calls)
+	}
+
+	// the probe after the cooldown fails and opens the breaker again
+	now = now.Add(time.Minute)
+	if b.State() != engine.BreakerHalfOpen {
+		t.Fatalf("expected the breaker to be half open, got %s", b.State())
+	}
+
+	guarded(b, fail)
+	if b.State() != engine.BreakerOpen {
+		t.Fatalf("expected a failed probe to open the breaker, got %s", b.State())
+	}
+
+	// only one probe is in flight while half open, a successful one closes the breaker
+	now = now.Add(time.Minute)
+	_, err = guarded(b, func() (int, error) {
+		_, err := guarded(b, ok)
+		if !errors.Is(err, engine.ErrRPCUnavailable) {
+			t.Errorf("expected a second probe to fail fast, got %v", err)
+		}
+
+		return 1, nil
+	})
+	if err != nil || b.State() != engine.BreakerClosed {
+		t.Fatalf("expected a successful probe to close the breaker, got %v, %s", err, b.State())
+	}
+}
+
+func TestBreakerDisabled(t *testing.T) {
+	b := newBreaker(BreakerSettings{})
+
+	for i := 0; i < 100; i++ {
+		guarded(b, func() (int, error) { return 0, errors.New("connection refused") })
+	}
+
+	if b.State() != engine.BreakerClosed {
+		t.Errorf("expected a breaker without a threshold to stay closed, got %s", b.State())
+	}
+}
@@ -0,0 +1,105 @@
+package ethrequest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/citizenwallet/engine/pkg/engine"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// revertError is the error of a node for a call that reverts
+type revertError struct {
+	data string
+}
+
+func (e revertError) Error() string          { return "execution reverted" }
+func (e revertError) ErrorCode() int         { return 3 }
+func (e revertError) ErrorData() interface{} { return e.data }
+
+type estimateService struct {
+	err error
+}
+
+func (s *estimateService) EstimateGas(args map[string]any, block *string) (hexutil.Uint64, error) {
+	if s.err != nil {
+		return 0, s.err
+	}
+
+	return 21000, nil
+}
+
+// revertData encodes a revert with Error(string)
+func revertData(t *testing.T, reason string) string {
+	t.Helper()
+
+	typ, err := abi.NewType("string", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	packed, err := abi.Arguments{{Type: typ}}.Pack(reason)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return hexutil.Encode(append([]byte{0x08, 0xc3, 0x79, 0xa0}, packed...))
+}
+
+func TestEstimateGasLimit(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		code   int
+		reason string
+	}{
+		{"success", nil, 0, ""},
+		{"revert with reason", revertError{data: revertData(t, "insufficient balance")}, 3, "insufficient balance"},
+		{"revert without data", revertError{}, 3, ""},
+		{"node error", errors.New("nonce too low"), -32000, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := rpc.NewServer()
+			if err := srv.RegisterName("eth", &estimateService{err: tt.err}); err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(srv.Stop)
+
+			c := rpc.DialInProc(srv)
+			e := &EthService{rpc: c, client: ethclient.NewClient(c), ctx: context.Background()}
+
+			to := common.HexToAddress("0x5815E61eF72c9E6107b5c5A05FD121F334f7a7f1")
+			gas, err := e.EstimateGasLimit(ethereum.CallMsg{To: &to})
+			if tt.err == nil {
+				if err != nil || gas != 21000 {
+					t.Fatalf("expected 21000 gas, got %d, %v", gas, err)
+				}
+				return
+			}
+
+			var estimateErr *engine.EstimateGasError
+			if !errors.As(err, &estimateErr) {
+				t.Fatalf("expected an EstimateGasError, got %v", err)
+			}
+
+			if estimateErr.Code != tt.code || estimateErr.Reason != tt.reason {
+				t.Errorf("expected code %d and reason %q, got %d and %q", tt.code, tt.reason, estimateErr.Code, estimateErr.Reason)
+			}
+		})
+	}
+}
+
+func TestEstimateGasError_Unreachable(t *testing.T) {
+	err := estimateGasError(engine.ErrRPCUnavailable)
+	if err != engine.ErrRPCUnavailable {
+		t.Errorf("expected the error to be returned as is, got %v", err)
+	}
+}
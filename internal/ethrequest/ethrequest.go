@@ -7,8 +7,11 @@ import (
 	"fmt"
 	"log"
 	"math/big"
+	"sync"
 	"time"
 
+	com "github.com/citizenwallet/engine/pkg/common"
+	"github.com/citizenwallet/engine/pkg/engine"
 	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
@@ -34,6 +37,17 @@ type EthService struct {
 	rpc    *rpc.Client
 	client *ethclient.Client
 	ctx    context.Context
+
+	// calls coalesces identical requests that are in flight at the same time
+	mu    sync.Mutex
+	calls map[string]*flight
+
+	fees map[engine.FeeSpeed]engine.FeeSettings
+
+	healthMu sync.Mutex
+	health   engine.RPCHealth
+
+	breaker *breaker
 }
 
 func (e *EthService) Context() context.Context {
@@ -48,7 +62,16 @@ func NewEthService(ctx context.Context, endpoint string) (*EthService, error) {
 
 	client := ethclient.NewClient(rpc)
 
-	return &EthService{rpc, client, ctx}, nil
+	return &EthService{rpc: rpc, client: client, ctx: ctx, fees: defaultFees(), breaker: newBreaker(DefaultBreakerSettings)}, nil
+}
+
+func defaultFees() map[engine.FeeSpeed]engine.FeeSettings {
+	fees := make(map[engine.FeeSpeed]engine.FeeSettings, len(engine.DefaultFeeSettings))
+	for speed, s := range engine.DefaultFeeSettings {
+		fees[speed] = s
+	}
+
+	return fees
 }
 
 func (e *EthService) Close() {
@@ -57,8 +80,11 @@ func (e *EthService) Close() {
 
 func (e *EthService) BlockTime(number *big.Int) (uint64, error) {
 	// Some blockchains have a slightly different format than Ethereum Blocks, so we need to use a custom Block struct
-	var blk *EthBlock
-	err := e.rpc.Call(&blk, "eth_getBlockByNumber", fmt.Sprintf("0x%s", number.Text(16)), true)
+	blk, err := guarded(e.breaker, func() (*EthBlock, error) {
+		var blk *EthBlock
+		err := e.rpc.Call(&blk, "eth_getBlockByNumber", fmt.Sprintf("0x%s", number.Text(16)), true)
+		return blk, err
+	})
 	if err != nil {
 		return 0, err
 	}
@@ -80,14 +106,79 @@ func (e *EthService) Backend() bind.ContractBackend {
 }
 
 func (e *EthService) CallContract(call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
-	return e.client.CallContract(e.ctx, call, blockNumber)
+	return guarded(e.breaker, func() ([]byte, error) {
+		return e.client.CallContract(e.ctx, call, blockNumber)
+	})
 }
 
+// ListenForLogs subscribes to the logs of a query until the subscription fails or the context is done, the caller
+// resubscribes
+//
+// subscriptions only deliver new logs, those from q.FromBlock up to the head are fetched and sent first so that a
+// resubscription from the last processed block doesn't miss any, some of them can be sent twice
 func (e *EthService) ListenForLogs(ctx context.Context, q ethereum.FilterQuery, ch chan<- types.Log) error {
+	sub, err := e.client.SubscribeFilterLogs(ctx, q, ch)
+	if err != nil {
+		return err
+	}
+	defer sub.Unsubscribe()
+
+	if q.FromBlock != nil {
+		err = e.backfillLogs(ctx, q, ch)
+		if err != nil {
+			return err
+		}
+	}
+
+	select {
+	case <-ctx.Done():
+		log.Default().Println("context done, unsubscribing")
+
+		return ctx.Err()
+	case err := <-sub.Err():
+		return err
+	}
+}
+
+// blocks per request when fetching the logs of a gap, rpc nodes limit the range of eth_getLogs
+const backfillBlockRange = 1000
+
+// backfillLogs sends the logs from q.FromBlock up to the current head
+func (e *EthService) backfillLogs(ctx context.Context, q ethereum.FilterQuery, ch chan<- types.Log) error {
+	head, err := e.client.BlockNumber(ctx)
+	if err != nil {
+		return err
+	}
+
+	for from := q.FromBlock.Uint64(); from <= head; from += backfillBlockRange {
+		to := min(from+backfillBlockRange-1, head)
+
+		bq := q
+		bq.FromBlock = new(big.Int).SetUint64(from)
+		bq.ToBlock = new(big.Int).SetUint64(to)
+
+		logs, err := e.client.FilterLogs(ctx, bq)
+		if err != nil {
+			return err
+		}
+
+		for _, l := range logs {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case ch <- l:
+			}
+		}
+	}
+
+	return nil
+}
+
+func (e *EthService) ListenForHeads(ctx context.Context, ch chan<- *types.Header) error {
 	for {
-		sub, err := e.client.SubscribeFilterLogs(ctx, q, ch)
+		sub, err := e.client.SubscribeNewHead(ctx, ch)
 		if err != nil {
-			log.Default().Println("error subscribing to logs", err.Error())
+			log.Default().Println("error subscribing to heads", err.Error())
 
 			<-time.After(1 * time.Second)
 
@@ -113,16 +204,28 @@ func (e *EthService) ListenForLogs(ctx context.Context, q ethereum.FilterQuery,
 }
 
 func (e *EthService) CodeAt(ctx context.Context, account common.Address, blockNumber *big.Int) ([]byte, error) {
-	return e.client.CodeAt(e.ctx, account, blockNumber)
+	return guarded(e.breaker, func() ([]byte, error) {
+		return e.client.CodeAt(e.ctx, account, blockNumber)
+	})
 }
 
 func (e *EthService) NonceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (uint64, error) {
-	return e.client.NonceAt(e.ctx, account, blockNumber)
+	return guarded(e.breaker, func() (uint64, error) {
+		return e.client.NonceAt(e.ctx, account, blockNumber)
+	})
+}
+
+func (e *EthService) BalanceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (*big.Int, error) {
+	return guarded(e.breaker, func() (*big.Int, error) {
+		return e.client.BalanceAt(ctx, account, blockNumber)
+	})
 }
 
 func (e *EthService) BaseFee() (*big.Int, error) {
 	// Get the latest block header
-	header, err := e.client.HeaderByNumber(context.Background(), nil)
+	header, err := guarded(e.breaker, func() (*types.Header, error) {
+		return e.client.HeaderByNumber(context.Background(), nil)
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -130,30 +233,124 @@ func (e *EthService) BaseFee() (*big.Int, error) {
 }
 
 func (e *EthService) EstimateGasPrice() (*big.Int, error) {
-	return e.client.SuggestGasPrice(e.ctx)
+	return guarded(e.breaker, func() (*big.Int, error) {
+		return e.client.SuggestGasPrice(e.ctx)
+	})
 }
 
 func (e *EthService) EstimateGasLimit(msg ethereum.CallMsg) (uint64, error) {
-	return e.client.EstimateGas(e.ctx, msg)
+	gas, err := guarded(e.breaker, func() (uint64, error) {
+		return e.client.EstimateGas(e.ctx, msg)
+	})
+	if err != nil {
+		return 0, estimateGasError(err)
+	}
+
+	return gas, nil
 }
 
-func (e *EthService) NewTx(nonce uint64, from, to common.Address, data []byte, extraGas bool) (*types.Transaction, error) {
+// estimateGasError wraps the errors that the node returns for an estimation in an engine.EstimateGasError with the
+// decoded revert reason, other errors (ex: the node is unreachable) are returned as is
+func estimateGasError(err error) error {
+	var rpcErr rpc.Error
+	if !errors.As(err, &rpcErr) {
+		return err
+	}
+
+	estimateErr := &engine.EstimateGasError{Code: rpcErr.ErrorCode(), Err: err}
+
+	var dataErr rpc.DataError
+	if errors.As(err, &dataErr) {
+		estimateErr.Data = dataErr.ErrorData()
+		estimateErr.Reason = com.RevertReason(err)
+	}
+
+	return estimateErr
+}
+
+const (
+	// amount of blocks to look back for priority fees
+	feeHistoryBlocks = 10
+)
+
+// SetFeeSettings overrides the fee settings of the given speeds
+func (e *EthService) SetFeeSettings(settings map[engine.FeeSpeed]engine.FeeSettings) {
+	for speed, s := range settings {
+		e.fees[speed] = s
+	}
+}
+
+// GetFeeEstimates returns the fees that the engine would use for a transaction in the next blocks
+func (e *EthService) GetFeeEstimates(speed engine.FeeSpeed) (*engine.FeeEstimate, error) {
+	settings, ok := e.fees[speed]
+	if !ok {
+		return nil, engine.ErrUnknownFeeSpeed
+	}
+
 	baseFee, err := e.BaseFee()
 	if err != nil {
 		return nil, err
 	}
 
 	// Set the priority fee per gas (miner tip)
-	tip, err := e.MaxPriorityFeePerGas()
+	tip, err := e.priorityFeeAt(settings.Percentile)
 	if err != nil {
 		return nil, err
 	}
 
-	buffer := new(big.Int).Div(tip, big.NewInt(100))
+	buffer := new(big.Int).Div(new(big.Int).Mul(tip, big.NewInt(settings.PriorityFeeBuffer)), big.NewInt(100))
 
 	maxPriorityFeePerGas := new(big.Int).Add(tip, buffer)
 
-	maxFeePerGas := new(big.Int).Add(maxPriorityFeePerGas, new(big.Int).Mul(baseFee, big.NewInt(2)))
+	maxFeePerGas := new(big.Int).Add(maxPriorityFeePerGas, new(big.Int).Mul(baseFee, big.NewInt(settings.BaseFeeMultiplier)))
+
+	return &engine.FeeEstimate{
+		Speed:                speed,
+		BaseFee:              baseFee,
+		MaxPriorityFeePerGas: maxPriorityFeePerGas,
+		MaxFeePerGas:         maxFeePerGas,
+		Percentile:           settings.Percentile,
+		PriorityFeeBuffer:    settings.PriorityFeeBuffer,
+		BaseFeeMultiplier:    settings.BaseFeeMultiplier,
+	}, nil
+}
+
+// priorityFeeAt returns the average priority fee at a percentile over the last blocks,
+// the node's suggestion is used if the recent blocks have no rewards
+func (e *EthService) priorityFeeAt(percentile float64) (*big.Int, error) {
+	history, err := guarded(e.breaker, func() (*ethereum.FeeHistory, error) {
+		return e.client.FeeHistory(e.ctx, feeHistoryBlocks, nil, []float64{percentile})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sum := new(big.Int)
+	count := int64(0)
+	for _, rewards := range history.Reward {
+		if len(rewards) == 0 || rewards[0] == nil {
+			continue
+		}
+
+		sum.Add(sum, rewards[0])
+		count++
+	}
+
+	if count == 0 || sum.Sign() == 0 {
+		return e.MaxPriorityFeePerGas()
+	}
+
+	return sum.Div(sum, big.NewInt(count)), nil
+}
+
+func (e *EthService) NewTx(nonce uint64, from, to common.Address, data []byte, extraGas bool) (*types.Transaction, error) {
+	fees, err := e.GetFeeEstimates(engine.FeeSpeedStandard)
+	if err != nil {
+		return nil, err
+	}
+
+	maxPriorityFeePerGas := fees.MaxPriorityFeePerGas
+	maxFeePerGas := fees.MaxFeePerGas
 
 	// Prepare the call message
 	msg := ethereum.CallMsg{
@@ -204,16 +401,30 @@ func (e *EthService) EstimateFullGas(from common.Address, tx *types.Transaction)
 		AccessList: tx.AccessList(),
 	}
 
-	return e.client.EstimateGas(e.ctx, msg)
+	gas, err := guarded(e.breaker, func() (uint64, error) {
+		return e.client.EstimateGas(e.ctx, msg)
+	})
+	if err != nil {
+		return 0, estimateGasError(err)
+	}
+
+	return gas, nil
 }
 
 func (e *EthService) SendTransaction(tx *types.Transaction) error {
-	return e.client.SendTransaction(e.ctx, tx)
+	_, err := guarded(e.breaker, func() (struct{}, error) {
+		return struct{}{}, e.client.SendTransaction(e.ctx, tx)
+	})
+
+	return err
 }
 
 func (e *EthService) MaxPriorityFeePerGas() (*big.Int, error) {
-	var hexFee string
-	err := e.rpc.Call(&hexFee, "eth_maxPriorityFeePerGas")
+	hexFee, err := guarded(e.breaker, func() (string, error) {
+		var hexFee string
+		err := e.rpc.Call(&hexFee, "eth_maxPriorityFeePerGas")
+		return hexFee, err
+	})
 	if err != nil {
 		return common.Big0, err
 	}
@@ -227,12 +438,16 @@ func (e *EthService) MaxPriorityFeePerGas() (*big.Int, error) {
 	return fee, nil
 }
 
-func (e *EthService) StorageAt(addr common.Address, slot common.Hash) ([]byte, error) {
-	return e.client.StorageAt(e.ctx, addr, slot, nil)
+func (e *EthService) StorageAt(addr common.Address, slot common.Hash, blockNumber *big.Int) ([]byte, error) {
+	return guarded(e.breaker, func() ([]byte, error) {
+		return e.client.StorageAt(e.ctx, addr, slot, blockNumber)
+	})
 }
 
 func (e *EthService) ChainID() (*big.Int, error) {
-	chid, err := e.client.ChainID(e.ctx)
+	chid, err := guarded(e.breaker, func() (*big.Int, error) {
+		return e.client.ChainID(e.ctx)
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -240,19 +455,113 @@ func (e *EthService) ChainID() (*big.Int, error) {
 	return chid, nil
 }
 
-func (e *EthService) Call(method string, result any, params json.RawMessage) error {
+// flight is an upstream call shared by all the identical calls that are waiting for it
+type flight struct {
+	done    chan struct{}
+	result  json.RawMessage
+	err     error
+	waiters int
+	cancel  context.CancelFunc
+}
+
+func (e *EthService) Call(ctx context.Context, method string, result any, params json.RawMessage) error {
 	var args []any
 
 	if err := json.Unmarshal(params, &args); err != nil {
 		return fmt.Errorf("failed to unmarshal request body: %w", err)
 	}
 
-	return e.client.Client().Call(result, method, args...)
+	// the key is built from the parsed params so that formatting differences don't matter
+	key, err := json.Marshal(args)
+	if err != nil {
+		return err
+	}
+
+	f := e.join(method+":"+string(key), method, args)
+
+	// concurrent identical calls share the same upstream request, errors are only shared with the calls that are waiting
+	select {
+	case <-f.done:
+		if f.err != nil {
+			return f.err
+		}
+
+		return json.Unmarshal(f.result, result)
+	case <-ctx.Done():
+		e.leave(method+":"+string(key), f)
+
+		return ctx.Err()
+	}
+}
+
+// join returns the flight for key, the upstream call is started if there is none
+func (e *EthService) join(key, method string, args []any) *flight {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.calls == nil {
+		e.calls = map[string]*flight{}
+	}
+
+	if f, ok := e.calls[key]; ok {
+		f.waiters++
+		return f
+	}
+
+	ctx, cancel := context.WithCancel(e.ctx)
+
+	f := &flight{done: make(chan struct{}), waiters: 1, cancel: cancel}
+	e.calls[key] = f
+
+	go func() {
+		defer cancel()
+
+		raw, err := guarded(e.breaker, func() (json.RawMessage, error) {
+			var raw json.RawMessage
+			err := e.client.Client().CallContext(ctx, &raw, method, args...)
+			return raw, err
+		})
+
+		e.mu.Lock()
+		if e.calls[key] == f {
+			delete(e.calls, key)
+		}
+		e.mu.Unlock()
+
+		f.result, f.err = raw, err
+		close(f.done)
+	}()
+
+	return f
+}
+
+// leave stops waiting for a flight, the upstream call is aborted once nobody is waiting for it anymore
+func (e *EthService) leave(key string, f *flight) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	f.waiters--
+	if f.waiters > 0 {
+		return
+	}
+
+	if e.calls[key] == f {
+		delete(e.calls, key)
+	}
+
+	f.cancel()
 }
 
 func (e *EthService) LatestBlock() (*big.Int, error) {
-	var blk *EthBlock
-	err := e.rpc.Call(&blk, "eth_getBlockByNumber", "latest", true)
+	return e.latestBlock(context.Background())
+}
+
+func (e *EthService) latestBlock(ctx context.Context) (*big.Int, error) {
+	blk, err := guarded(e.breaker, func() (*EthBlock, error) {
+		var blk *EthBlock
+		err := e.rpc.CallContext(ctx, &blk, "eth_getBlockByNumber", "latest", true)
+		return blk, err
+	})
 	if err != nil {
 		return common.Big0, err
 	}
@@ -265,7 +574,9 @@ func (e *EthService) LatestBlock() (*big.Int, error) {
 }
 
 func (e *EthService) FilterLogs(q ethereum.FilterQuery) ([]types.Log, error) {
-	return e.client.FilterLogs(e.ctx, q)
+	return guarded(e.breaker, func() ([]types.Log, error) {
+		return e.client.FilterLogs(e.ctx, q)
+	})
 }
 
 func (e *EthService) WaitForTx(tx *types.Transaction, timeout int) error {
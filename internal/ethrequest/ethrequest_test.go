@@ -0,0 +1,243 @@
+package ethrequest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// slowService answers with a delay so that concurrent calls overlap
+type slowService struct {
+	calls    atomic.Int64
+	canceled atomic.Int64
+}
+
+func (s *slowService) Block(tag string) (map[string]string, error) {
+	s.calls.Add(1)
+	time.Sleep(100 * time.Millisecond)
+
+	return map[string]string{"tag": tag}, nil
+}
+
+// Wait blocks until the call is canceled
+func (s *slowService) Wait(ctx context.Context) (any, error) {
+	s.calls.Add(1)
+	<-ctx.Done()
+	s.canceled.Add(1)
+
+	return nil, ctx.Err()
+}
+
+func (s *slowService) Fail() (any, error) {
+	s.calls.Add(1)
+	time.Sleep(100 * time.Millisecond)
+
+	return nil, errors.New("upstream failure")
+}
+
+func newTestService(t *testing.T) (*EthService, *slowService) {
+	t.Helper()
+
+	svc := &slowService{}
+
+	srv := rpc.NewServer()
+	if err := srv.RegisterName("test", svc); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Stop)
+
+	c := rpc.DialInProc(srv)
+
+	return &EthService{rpc: c, client: ethclient.NewClient(c), ctx: context.Background()}, svc
+}
+
+func TestCallCoalescesIdenticalRequests(t *testing.T) {
+	e, svc := newTestService(t)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			// formatting differences should not prevent coalescing
+			params := json.RawMessage(`["latest"]`)
+			if i%2 == 0 {
+				params = json.RawMessage(`[ "latest" ]`)
+			}
+
+			var result map[string]string
+			if err := e.Call(context.Background(), "test_block", &result, params); err != nil {
+				t.Error(err)
+				return
+			}
+
+			if result["tag"] != "latest" {
+				t.Errorf("expected %s, got %s", "latest", result["tag"])
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if n := svc.calls.Load(); n != 1 {
+		t.Errorf("expected 1 upstream call, got %d", n)
+	}
+
+	// different params are separate requests
+	var result map[string]string
+	if err := e.Call(context.Background(), "test_block", &result, json.RawMessage(`["0x1"]`)); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := svc.calls.Load(); n != 2 {
+		t.Errorf("expected 2 upstream calls, got %d", n)
+	}
+}
+
+func TestCallDoesNotCacheErrors(t *testing.T) {
+	e, svc := newTestService(t)
+
+	for i := 0; i < 2; i++ {
+		var result any
+		if err := e.Call(context.Background(), "test_fail", &result, json.RawMessage(`[]`)); err == nil {
+			t.Fatal("expected an error")
+		}
+	}
+
+	if n := svc.calls.Load(); n != 2 {
+		t.Errorf("expected 2 upstream calls, got %d", n)
+	}
+}
+
+func TestCallAbortsWhenAllCallersAreGone(t *testing.T) {
+	svc := &slowService{}
+
+	srv := rpc.NewServer()
+	if err := srv.RegisterName("test", svc); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Stop)
+
+	// over http an aborted call closes the request, which cancels it on the node
+	hs := httptest.NewServer(srv)
+	t.Cleanup(hs.Close)
+
+	c, err := rpc.DialHTTP(hs.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e := &EthService{rpc: c, client: ethclient.NewClient(c), ctx: context.Background()}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// a second caller keeps the shared call alive until it gives up too
+	ctx2, cancel2 := context.WithCancel(context.Background())
+
+	errs := make(chan error, 2)
+	go func() {
+		var result any
+		errs <- e.Call(ctx, "test_wait", &result, json.RawMessage(`[]`))
+	}()
+	go func() {
+		var result any
+		errs <- e.Call(ctx2, "test_wait", &result, json.RawMessage(`[]`))
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	if err := <-errs; !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected %v, got %v", context.Canceled, err)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	if n := svc.canceled.Load(); n != 0 {
+		t.Fatalf("expected the upstream call to continue while a caller waits, got %d cancellations", n)
+	}
+
+	cancel2()
+
+	if err := <-errs; !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected %v, got %v", context.Canceled, err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for svc.canceled.Load() == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if n := svc.canceled.Load(); n != 1 {
+		t.Errorf("expected the upstream call to be canceled, got %d cancellations", n)
+	}
+
+	if n := svc.calls.Load(); n != 1 {
+		t.Errorf("expected 1 upstream call, got %d", n)
+	}
+}
+
+// feeService answers eth_feeHistory and eth_maxPriorityFeePerGas
+type feeService struct {
+	rewards [][]*hexutil.Big
+}
+
+func (s *feeService) FeeHistory(blocks hexutil.Uint64, last string, percentiles []float64) (map[string]any, error) {
+	return map[string]any{
+		"oldestBlock":   (*hexutil.Big)(big.NewInt(1)),
+		"reward":        s.rewards,
+		"baseFeePerGas": []*hexutil.Big{},
+		"gasUsedRatio":  []float64{},
+	}, nil
+}
+
+func (s *feeService) MaxPriorityFeePerGas() (*hexutil.Big, error) {
+	return (*hexutil.Big)(big.NewInt(7)), nil
+}
+
+func TestPriorityFeeAt(t *testing.T) {
+	hb := func(v int64) *hexutil.Big {
+		return (*hexutil.Big)(big.NewInt(v))
+	}
+
+	tests := []struct {
+		name     string
+		rewards  [][]*hexutil.Big
+		expected int64
+	}{
+		{"average", [][]*hexutil.Big{{hb(10)}, {hb(20)}, {hb(30)}}, 20},
+		{"skips empty blocks", [][]*hexutil.Big{{hb(10)}, {}, {hb(30)}}, 20},
+		{"falls back to the node", [][]*hexutil.Big{{}, {}}, 7},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := rpc.NewServer()
+			if err := srv.RegisterName("eth", &feeService{rewards: tt.rewards}); err != nil {
+				t.Fatal(err)
+			}
+			defer srv.Stop()
+
+			c := rpc.DialInProc(srv)
+			e := &EthService{rpc: c, client: ethclient.NewClient(c), ctx: context.Background()}
+
+			fee, err := e.priorityFeeAt(50)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if fee.Int64() != tt.expected {
+				t.Errorf("expected %d, got %d", tt.expected, fee.Int64())
+			}
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package ethrequest
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// logsService is a node with logs in past blocks that sends one new log to a subscription
+type logsService struct {
+	mu     sync.Mutex
+	head   uint64
+	logs   []types.Log
+	ranges [][2]uint64
+	newLog types.Log
+}
+
+func (s *logsService) BlockNumber() hexutil.Uint64 {
+	return hexutil.Uint64(s.head)
+}
+
+type filterArg struct {
+	FromBlock hexutil.Uint64 `json:"fromBlock"`
+	ToBlock   hexutil.Uint64 `json:"toBlock"`
+}
+
+func (s *logsService) GetLogs(arg filterArg) ([]types.Log, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.ranges = append(s.ranges, [2]uint64{uint64(arg.FromBlock), uint64(arg.ToBlock)})
+
+	logs := []types.Log{}
+	for _, l := range s.logs {
+		if l.BlockNumber >= uint64(arg.FromBlock) && l.BlockNumber <= uint64(arg.ToBlock) {
+			logs = append(logs, l)
+		}
+	}
+
+	return logs, nil
+}
+
+// Logs ignores the from block like geth, subscriptions only deliver new logs
+func (s *logsService) Logs(ctx context.Context, arg map[string]any) (*rpc.Subscription, error) {
+	notifier, ok := rpc.NotifierFromContext(ctx)
+	if !ok {
+		return nil, rpc.ErrNotificationsUnsupported
+	}
+
+	sub := notifier.CreateSubscription()
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		notifier.Notify(sub.ID, s.newLog)
+	}()
+
+	return sub, nil
+}
+
+func testLog(block uint64) types.Log {
+	return types.Log{
+		Address:     common.HexToAddress("0x1"),
+		Topics:      []common.Hash{common.HexToHash("0x2")},
+		Data:        []byte{},
+		BlockNumber: block,
+		TxHash:      common.BigToHash(new(big.Int).SetUint64(block)),
+	}
+}
+
+func TestListenForLogsBackfillsFromBlock(t *testing.T) {
+	svc := &logsService{
+		head:   2500,
+		logs:   []types.Log{testLog(3), testLog(10), testLog(1500), testLog(2400)},
+		newLog: testLog(2501),
+	}
+
+	srv := rpc.NewServer()
+	if err := srv.RegisterName("eth", svc); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Stop)
+
+	c := rpc.DialInProc(srv)
+	e := &EthService{rpc: c, client: ethclient.NewClient(c), ctx: context.Background()}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ch := make(chan types.Log)
+	done := make(chan error, 1)
+	go func() {
+		done <- e.ListenForLogs(ctx, ethereum.FilterQuery{FromBlock: big.NewInt(5)}, ch)
+	}()
+
+	// the logs of the gap come first, then the new ones
+	for _, want := range []uint64{10, 1500, 2400, 2501} {
+		select {
+		case l := <-ch:
+			if l.BlockNumber != want {
+				t.Fatalf("expected a log of block %d, got %d", want, l.BlockNumber)
+			}
+		case <-time.After(2 * time.Second):
+			select {
+			case err := <-done:
+				t.Fatalf("ListenForLogs returned %v", err)
+			default:
+			}
+			t.Fatalf("timed out waiting for the log of block %d", want)
+		}
+	}
+
+	svc.mu.Lock()
+	ranges := svc.ranges
+	svc.mu.Unlock()
+
+	expected := [][2]uint64{{5, 1004}, {1005, 2004}, {2005, 2500}}
+	if len(ranges) != len(expected) {
+		t.Fatalf("expected ranges %v, got %v", expected, ranges)
+	}
+	for i := range expected {
+		if ranges[i] != expected[i] {
+			t.Fatalf("expected ranges %v, got %v", expected, ranges)
+		}
+	}
+
+	cancel()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected the context error, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected ListenForLogs to return when the context is done")
+	}
+}
@@ -0,0 +1,101 @@
+package ethrequest
+
+import (
+	"context"
+	"log"
+	"math/big"
+	"time"
+
+	"github.com/citizenwallet/engine/pkg/engine"
+)
+
+// MonitorSettings configures how the rpc node is checked
+type MonitorSettings struct {
+	Interval   time.Duration // how often the node is checked
+	StaleAfter time.Duration // the head is stale when it hasn't advanced for this long
+	MaxLatency time.Duration // calls slower than this mark the node as degraded
+}
+
+var DefaultMonitorSettings = MonitorSettings{
+	Interval:   15 * time.Second,
+	StaleAfter: 1 * time.Minute,
+	MaxLatency: 2 * time.Second,
+}
+
+// Health returns the state of the rpc node as last seen by the monitor, the node is not healthy while the breaker
+// is open
+func (e *EthService) Health() engine.RPCHealth {
+	e.healthMu.Lock()
+	h := e.health
+	e.healthMu.Unlock()
+
+	h.Breaker = e.breaker.State()
+	if h.Breaker == engine.BreakerOpen {
+		h.Healthy = false
+	}
+
+	return h
+}
+
+// Monitor periodically checks the latency of the rpc node and whether its head is advancing, until the context is done
+func (e *EthService) Monitor(s MonitorSettings) error {
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+
+	for {
+		e.check(s)
+
+		select {
+		case <-e.ctx.Done():
+			return e.ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func (e *EthService) check(s MonitorSettings) {
+	// a node that doesn't answer in time is as bad as one that errors
+	ctx, cancel := context.WithTimeout(e.ctx, s.Interval)
+	defer cancel()
+
+	start := time.Now()
+	block, err := e.latestBlock(ctx)
+	latency := time.Since(start)
+
+	e.healthMu.Lock()
+	prev := e.health
+	e.health = nextHealth(prev, block, latency, err, time.Now().UTC(), s)
+	health := e.health
+	e.healthMu.Unlock()
+
+	if health.Stale && !prev.Stale {
+		log.Default().Printf("rpc head is stale, block %d has not advanced since %s\n", health.BlockNumber, health.LastBlockAt.Format(time.RFC3339))
+	}
+
+	if health.Degraded && !prev.Degraded {
+		log.Default().Printf("rpc node is degraded, latency %dms\n", health.Latency)
+	}
+}
+
+// nextHealth computes the health of the node after a check
+func nextHealth(prev engine.RPCHealth, block *big.Int, latency time.Duration, err error, now time.Time, s MonitorSettings) engine.RPCHealth {
+	h := engine.RPCHealth{
+		Latency:     latency.Milliseconds(),
+		BlockNumber: prev.BlockNumber,
+		LastBlockAt: prev.LastBlockAt,
+		CheckedAt:   now,
+	}
+
+	if err != nil {
+		h.Error = err.Error()
+	} else if block.Uint64() > prev.BlockNumber {
+		h.BlockNumber = block.Uint64()
+		h.LastBlockAt = now
+	}
+
+	h.Stale = !h.LastBlockAt.IsZero() && now.Sub(h.LastBlockAt) > s.StaleAfter
+	h.Degraded = err != nil || latency > s.MaxLatency
+	h.Healthy = err == nil && !h.Stale && !h.LastBlockAt.IsZero()
+
+	return h
+}
@@ -0,0 +1,50 @@
+package ethrequest
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/citizenwallet/engine/pkg/engine"
+)
+
+func TestNextHealth(t *testing.T) {
+	s := MonitorSettings{Interval: time.Second, StaleAfter: time.Minute, MaxLatency: time.Second}
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// the first block makes the node healthy
+	h := nextHealth(engine.RPCHealth{}, big.NewInt(10), 100*time.Millisecond, nil, start, s)
+	if !h.Healthy || h.Stale || h.Degraded || h.BlockNumber != 10 {
+		t.Fatalf("expected a healthy node at block 10, got %+v", h)
+	}
+
+	// slow calls degrade the node but it stays healthy
+	h = nextHealth(h, big.NewInt(11), 2*time.Second, nil, start.Add(10*time.Second), s)
+	if !h.Healthy || !h.Degraded || h.BlockNumber != 11 {
+		t.Fatalf("expected a degraded but healthy node at block 11, got %+v", h)
+	}
+
+	// the head stops advancing
+	h = nextHealth(h, big.NewInt(11), 100*time.Millisecond, nil, start.Add(30*time.Second), s)
+	if !h.Healthy || h.Stale {
+		t.Fatalf("expected the head to not be stale yet, got %+v", h)
+	}
+
+	h = nextHealth(h, big.NewInt(11), 100*time.Millisecond, nil, start.Add(2*time.Minute), s)
+	if h.Healthy || !h.Stale {
+		t.Fatalf("expected a stale head, got %+v", h)
+	}
+
+	// errors keep the last known block
+	h = nextHealth(h, nil, 100*time.Millisecond, errors.New("connection refused"), start.Add(3*time.Minute), s)
+	if h.Healthy || !h.Degraded || h.Error == "" || h.BlockNumber != 11 {
+		t.Fatalf("expected an unhealthy node at block 11, got %+v", h)
+	}
+
+	// the head advances again
+	h = nextHealth(h, big.NewInt(20), 100*time.Millisecond, nil, start.Add(4*time.Minute), s)
+	if !h.Healthy || h.Stale || h.Degraded || h.BlockNumber != 20 {
+		t.Fatalf("expected a healthy node at block 20, got %+v", h)
+	}
+}
@@ -3,12 +3,17 @@ package events
 import (
 	"fmt"
 	"net/http"
+	"strconv"
 
 	"github.com/citizenwallet/engine/internal/db"
 	"github.com/citizenwallet/engine/internal/ws"
+	com "github.com/citizenwallet/engine/pkg/common"
+	"github.com/citizenwallet/engine/pkg/engine"
 	"github.com/go-chi/chi/v5"
 )
 
+const maxEventsLimit = 100
+
 type Handlers struct {
 	db    *db.DB
 	pools *ws.ConnectionPools
@@ -34,7 +39,7 @@ func (h *Handlers) HandleConnection(w http.ResponseWriter, r *http.Request) {
 	println("contract", contract)
 	println("topic", topic)
 
-	exists, err := h.db.EventDB.EventExists(contract)
+	exists, err := h.db.EventDB.EventExists(r.Context(), contract)
 	if err != nil || !exists {
 		http.Error(w, "event does not exist", http.StatusNotFound)
 		return
@@ -44,3 +49,74 @@ func (h *Handlers) HandleConnection(w http.ResponseWriter, r *http.Request) {
 
 	h.pools.Connect(w, r, poolName)
 }
+
+// HandleStream streams the broadcasts of an event as server-sent events, for clients that can't use websockets
+func (h *Handlers) HandleStream(w http.ResponseWriter, r *http.Request) {
+	contract := chi.URLParam(r, "contract")
+	topic := chi.URLParam(r, "topic")
+	if contract == "" || topic == "" {
+		http.Error(w, "contract and topic are required", http.StatusBadRequest)
+		return
+	}
+
+	exists, err := h.db.EventDB.EventExists(r.Context(), contract)
+	if err != nil || !exists {
+		http.Error(w, "event does not exist", http.StatusNotFound)
+		return
+	}
+
+	poolName := fmt.Sprintf("%s/%s", contract, topic)
+
+	h.pools.Stream(w, r, poolName)
+}
+
+// List returns the events that are indexed, optionally for a single contract and filtered by state
+func (h *Handlers) List(w http.ResponseWriter, r *http.Request) {
+	// parse contract address from url params
+	contract := chi.URLParam(r, "contract")
+	if contract != "" {
+		addr, err := com.NormalizeAddress(contract)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		contract = addr
+	}
+
+	// parse state from url query
+	state := engine.EventState(r.URL.Query().Get("state"))
+	if state != "" && !state.IsValid() {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	// parse pagination params from url query
+	limitq := r.URL.Query().Get("limit")
+	offsetq := r.URL.Query().Get("offset")
+
+	limit, err := strconv.Atoi(limitq)
+	if err != nil || limit <= 0 {
+		limit = 20
+	}
+
+	if limit > maxEventsLimit {
+		limit = maxEventsLimit
+	}
+
+	offset, err := strconv.Atoi(offsetq)
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	evs, total, err := h.db.EventDB.GetPaginatedEvents(r.Context(), contract, state, limit, offset)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	err = com.BodyMultiple(w, evs, com.Pagination{Limit: limit, Offset: offset, Total: total})
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
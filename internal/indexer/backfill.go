@@ -0,0 +1,84 @@
+package indexer
+
+import (
+	"encoding/json"
+	"log"
+	"strings"
+
+	"github.com/citizenwallet/engine/pkg/engine"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+const backfillBatchSize = 100
+
+// BackfillPositions sets the block number and log index of the logs of an event that were indexed before they were stored
+//
+// this is best effort, the receipt of every transaction is fetched once and logs that can't be matched are left empty
+func (i *Indexer) BackfillPositions(ev *engine.Event) error {
+	contract := common.HexToAddress(ev.Contract)
+	topic0 := ev.GetTopic0FromEventSignature()
+
+	after := ""
+	for {
+		select {
+		case <-i.ctx.Done():
+			return i.ctx.Err()
+		default:
+		}
+
+		hashes, err := i.db.LogDB.GetUnpositionedTxHashes(i.ctx, ev.Contract, after, backfillBatchSize)
+		if err != nil {
+			return err
+		}
+
+		if len(hashes) == 0 {
+			return nil
+		}
+
+		for _, hash := range hashes {
+			after = hash
+
+			if strings.HasPrefix(hash, engine.TEMP_HASH_PREFIX) {
+				continue
+			}
+
+			err = i.backfillTx(ev, contract, topic0, hash)
+			if err != nil {
+				log.Default().Printf("error backfilling positions of tx %s: %s\n", hash, err.Error())
+			}
+		}
+	}
+}
+
+func (i *Indexer) backfillTx(ev *engine.Event, contract common.Address, topic0 common.Hash, hash string) error {
+	params, err := json.Marshal([]string{hash})
+	if err != nil {
+		return err
+	}
+
+	var receipt *types.Receipt
+	err = i.evm.Call(i.ctx, "eth_getTransactionReceipt", &receipt, params)
+	if err != nil || receipt == nil {
+		return err
+	}
+
+	for _, rl := range receipt.Logs {
+		if rl.Address != contract || len(rl.Topics) == 0 || rl.Topics[0] != topic0 {
+			continue
+		}
+
+		// the hash is generated the same way as when the log was indexed
+		l, err := newLog(ev, *rl, 0)
+		if err != nil {
+			return err
+		}
+
+		err = i.db.LogDB.SetPosition(i.ctx, l.Hash, receipt.BlockNumber.Int64(), int64(rl.Index))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,104 @@
+package indexer
+
+import (
+	"sync"
+	"time"
+
+	"github.com/citizenwallet/engine/pkg/engine"
+)
+
+const (
+	// DefaultConfirmationDepth is the number of confirmations after which a log stops being re-broadcast
+	DefaultConfirmationDepth = 12
+
+	confirmationsInterval = 5 * time.Second
+)
+
+type trackedLog struct {
+	log   *engine.Log
+	block uint64
+}
+
+// confirmations keeps the indexed logs that haven't reached the confirmation depth yet
+type confirmations struct {
+	mu     sync.Mutex
+	depth  uint64
+	latest uint64 // the latest block that was seen
+	logs   map[string]*trackedLog
+}
+
+func newConfirmations(depth uint64) *confirmations {
+	return &confirmations{
+		depth: depth,
+		logs:  map[string]*trackedLog{},
+	}
+}
+
+// track sets the confirmations of a log that was just indexed and keeps it until it is deep enough
+func (c *confirmations) track(l *engine.Log) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	block := uint64(l.BlockNumber)
+
+	if c.latest < block {
+		c.latest = block
+	}
+
+	l.Confirmations = int64(c.latest - block)
+	if uint64(l.Confirmations) >= c.depth {
+		return
+	}
+
+	c.logs[l.Hash] = &trackedLog{log: l, block: block}
+}
+
+// head returns the latest block that was seen
+func (c *confirmations) head() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.latest
+}
+
+// untrack drops a log that was reorged out of the chain
+func (c *confirmations) untrack(hash string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.logs, hash)
+}
+
+// advance updates the confirmations of the tracked logs to the latest block and returns the logs that changed,
+// logs that reach the depth are returned one last time
+func (c *confirmations) advance(latest uint64) []*engine.Log {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if latest <= c.latest {
+		return nil
+	}
+
+	c.latest = latest
+
+	changed := []*engine.Log{}
+	for hash, t := range c.logs {
+		confs := int64(min(latest-t.block, c.depth))
+		if confs == t.log.Confirmations {
+			continue
+		}
+
+		// the broadcast log is not shared with the next updates
+		l := *t.log
+		l.Confirmations = confs
+		t.log = &l
+
+		changed = append(changed, &l)
+
+		if uint64(confs) >= c.depth {
+			delete(c.logs, hash)
+		}
+	}
+
+	return changed
+}
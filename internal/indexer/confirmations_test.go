@@ -0,0 +1,90 @@
+package indexer
+
+import (
+	"testing"
+
+	"github.com/citizenwallet/engine/pkg/engine"
+)
+
+func TestConfirmations(t *testing.T) {
+	c := newConfirmations(3)
+
+	a := &engine.Log{Hash: "0xa", BlockNumber: 10}
+	c.track(a)
+	if a.Confirmations != 0 {
+		t.Fatalf("expected 0 confirmations, got %d", a.Confirmations)
+	}
+
+	// the head is already ahead of b
+	c.advance(12)
+	b := &engine.Log{Hash: "0xb", BlockNumber: 11}
+	c.track(b)
+	if b.Confirmations != 1 {
+		t.Fatalf("expected 1 confirmation, got %d", b.Confirmations)
+	}
+
+	if changed := c.advance(12); len(changed) != 0 {
+		t.Fatalf("expected no updates for the same block, got %d", len(changed))
+	}
+
+	changed := c.advance(13)
+	confs := map[string]int64{}
+	for _, l := range changed {
+		confs[l.Hash] = l.Confirmations
+	}
+
+	if len(confs) != 2 || confs["0xa"] != 3 || confs["0xb"] != 2 {
+		t.Fatalf("unexpected confirmations: %v", confs)
+	}
+
+	if b.Confirmations != 1 {
+		t.Fatalf("expected the tracked log not to change once broadcast, got %d", b.Confirmations)
+	}
+
+	// a reached the depth and is no longer tracked, b is reorged out
+	c.untrack("0xb")
+	if changed := c.advance(20); len(changed) != 0 {
+		t.Fatalf("expected no more updates, got %d", len(changed))
+	}
+
+	// logs that are already deep enough are not tracked
+	d := &engine.Log{Hash: "0xd", BlockNumber: 15}
+	c.track(d)
+	if d.Confirmations != 5 || len(c.logs) != 0 {
+		t.Fatalf("expected an untracked log with 5 confirmations, got %d and %d tracked", d.Confirmations, len(c.logs))
+	}
+}
+
+func TestStatusAt(t *testing.T) {
+	i := &Indexer{confirmations: newConfirmations(DefaultConfirmationDepth)}
+
+	if s := i.statusAt(10); s != engine.LogStatusSuccess {
+		t.Fatalf("expected success without a finality threshold, got %s", s)
+	}
+
+	i.SetFinality(3)
+
+	// the head is not known yet
+	if s := i.statusAt(10); s != engine.LogStatusPending {
+		t.Fatalf("expected pending before the head is known, got %s", s)
+	}
+
+	i.confirmations.advance(12)
+
+	tests := []struct {
+		block  uint64
+		status engine.LogStatus
+	}{
+		{8, engine.LogStatusSuccess},
+		{9, engine.LogStatusSuccess},
+		{10, engine.LogStatusPending},
+		{12, engine.LogStatusPending},
+		{13, engine.LogStatusPending},
+	}
+
+	for _, tt := range tests {
+		if s := i.statusAt(tt.block); s != tt.status {
+			t.Errorf("block %d: expected %s, got %s", tt.block, tt.status, s)
+		}
+	}
+}
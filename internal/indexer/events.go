@@ -2,7 +2,9 @@ package indexer
 
 import (
 	"encoding/json"
+	"log"
 	"math/big"
+	"sync/atomic"
 	"time"
 
 	"github.com/citizenwallet/engine/pkg/engine"
@@ -23,6 +25,9 @@ type cleanup struct {
 	b uint64
 }
 
+// resubscribeDelay is how long to wait before subscribing again after a subscription failed
+const resubscribeDelay = 1 * time.Second
+
 func (i *Indexer) ListenToLogs(ev *engine.Event, quitAck chan error) error {
 	logch := make(chan types.Log)
 
@@ -31,10 +36,24 @@ func (i *Indexer) ListenToLogs(ev *engine.Event, quitAck chan error) error {
 		return err
 	}
 
+	// the last block that logs were indexed from, it is where a resubscription starts from
+	var lastBlock atomic.Int64
+	lastBlock.Store(max(ev.LastBlock, q.FromBlock.Int64()-1))
+
 	go func() {
-		err := i.evm.ListenForLogs(i.ctx, *q, logch)
-		if err != nil {
-			quitAck <- err
+		for {
+			err := i.evm.ListenForLogs(i.ctx, *q, logch)
+			if i.ctx.Err() != nil {
+				quitAck <- err
+				return
+			}
+
+			log.Default().Printf("log subscription of %s failed, resubscribing from block %d: %v\n", ev.Contract, lastBlock.Load(), err)
+
+			<-time.After(resubscribeDelay)
+
+			// the logs of the last block are indexed again, a block could have been cut off in the middle
+			q = i.filterQuery(ev, big.NewInt(lastBlock.Load()))
 		}
 	}()
 
@@ -64,63 +83,128 @@ func (i *Indexer) ListenToLogs(ev *engine.Event, quitAck chan error) error {
 			toDelete = append(toDelete, cleanup{t: blk.Time + 60, b: blk.Number})
 		}
 
-		topics, err := engine.ParseTopicsFromHashes(ev, log.Topics, log.Data)
+		err = i.indexLog(ev, log, blk.Time)
 		if err != nil {
 			return err
 		}
 
-		b, err := topics.MarshalJSON()
-		if err != nil {
-			return err
+		if log.Removed {
+			continue
 		}
 
-		l := &engine.Log{
-			TxHash:    log.TxHash.Hex(),
-			CreatedAt: time.Unix(int64(blk.Time), 0).UTC(),
-			UpdatedAt: time.Now().UTC(),
-			Nonce:     int64(0),
-			To:        log.Address.Hex(),
-			Value:     big.NewInt(0), // Set to 0 as we don't have this information from the log
-			Data:      (*json.RawMessage)(&b),
-			ExtraData: nil,                     // Set to nil as we don't have extra data
-			Status:    engine.LogStatusSuccess, // Assuming a default status of Pending
+		if bn := int64(log.BlockNumber); bn > lastBlock.Load() {
+			lastBlock.Store(bn)
+
+			// restarts start from here as well
+			err = i.db.EventDB.SetEventLastBlock(i.ctx, ev.Contract, ev.EventSignature, bn)
+			if err != nil {
+				return err
+			}
 		}
 
-		l.Hash = l.GenerateUniqueHash()
+		// TODO: cleanup old sending logs which have no data
 
-		err = i.db.LogDB.AddLogs([]*engine.Log{l})
+		// cleanup old pending and sending transfers
+		err = i.db.LogDB.RemoveOldInProgressLogs(i.ctx)
 		if err != nil {
 			return err
 		}
+	}
 
-		dbLog, err := i.db.LogDB.GetLog(l.Hash)
-		if err != nil {
-			return err
-		}
+	return nil
+}
 
-		i.pools.BroadcastMessage(engine.WSMessageTypeUpdate, dbLog)
+// newLog converts a log of an event to the log that is stored, its hash doesn't depend on the block time
+func newLog(ev *engine.Event, log types.Log, blockTime uint64) (*engine.Log, error) {
+	topics, err := engine.ParseTopicsFromHashes(ev, log.Topics, log.Data)
+	if err != nil {
+		return nil, err
+	}
 
-		// TODO: cleanup old sending logs which have no data
+	b, err := topics.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
 
-		// cleanup old pending and sending transfers
-		err = i.db.LogDB.RemoveOldInProgressLogs()
+	l := &engine.Log{
+		TxHash:    log.TxHash.Hex(),
+		CreatedAt: time.Unix(int64(blockTime), 0).UTC(),
+		UpdatedAt: time.Now().UTC(),
+		Nonce:     int64(0),
+		To:        log.Address.Hex(),
+		Value:     big.NewInt(0), // Set to 0 as we don't have this information from the log
+		Data:      (*json.RawMessage)(&b),
+		ExtraData: nil,                     // Set to nil as we don't have extra data
+		Status:    engine.LogStatusSuccess, // Assuming a default status of Pending
+
+		BlockNumber: int64(log.BlockNumber),
+		LogIndex:    int64(log.Index),
+	}
+
+	l.Hash = l.GenerateUniqueHash()
+
+	return l, nil
+}
+
+// indexLog stores a log that was seen on chain, or removes it if it was reorged out, and broadcasts the change
+func (i *Indexer) indexLog(ev *engine.Event, log types.Log, blockTime uint64) error {
+	l, err := newLog(ev, log, blockTime)
+	if err != nil {
+		return err
+	}
+
+	if log.Removed {
+		// the log was reorged out of the chain, remove it and reverse its balance change
+		err = i.db.LogDB.RemoveConfirmedLog(i.ctx, l.Hash)
 		if err != nil {
 			return err
 		}
+
+		i.confirmations.untrack(l.Hash)
+		i.recent.remove(l.Hash)
+
+		i.pools.BroadcastMessage(engine.WSMessageTypeRemove, l)
+
+		return nil
+	}
+
+	// the log stays pending until it has enough confirmations, it is promoted as the head advances
+	l.Status = i.statusAt(log.BlockNumber)
+
+	err = i.db.LogDB.AddConfirmedLog(i.ctx, l)
+	if err != nil {
+		return err
+	}
+
+	dbLog, err := i.db.LogDB.GetLog(i.ctx, l.Hash)
+	if err != nil {
+		return err
+	}
+
+	i.confirmations.track(dbLog)
+
+	// logs around the block that a subscription resumes from are indexed twice, they are only announced once
+	if !i.recent.add(dbLog.Hash) {
+		return nil
+	}
+
+	i.pools.BroadcastMessage(engine.WSMessageTypeUpdate, dbLog)
+
+	// webhooks only get final logs
+	if i.dispatcher != nil && dbLog.Status == engine.LogStatusSuccess {
+		i.dispatcher.Dispatch(dbLog)
 	}
 
 	return nil
 }
 
+// FilterQueryFromEvent returns the query of the logs of an event, it starts from the last block that logs were
+// indexed from, or from the next block for an event that wasn't indexed yet
 func (i *Indexer) FilterQueryFromEvent(ev *engine.Event) (*ethereum.FilterQuery, error) {
-	topic0 := ev.GetTopic0FromEventSignature()
-
-	topics := [][]common.Hash{
-		{topic0},
+	if ev.LastBlock > 0 {
+		return i.filterQuery(ev, big.NewInt(ev.LastBlock)), nil
 	}
 
-	// Calculate the starting block for the filter query
-	// It's the last block that was indexed plus one
 	currentBlock, err := i.evm.LatestBlock()
 	if err != nil {
 		return nil, err
@@ -128,11 +212,21 @@ func (i *Indexer) FilterQueryFromEvent(ev *engine.Event) (*ethereum.FilterQuery,
 
 	fromBlock := currentBlock.Add(currentBlock, big.NewInt(1))
 
+	return i.filterQuery(ev, fromBlock), nil
+}
+
+func (i *Indexer) filterQuery(ev *engine.Event, fromBlock *big.Int) *ethereum.FilterQuery {
+	topic0 := ev.GetTopic0FromEventSignature()
+
+	topics := [][]common.Hash{
+		{topic0},
+	}
+
 	contractAddr := common.HexToAddress(ev.Contract)
 
 	return &ethereum.FilterQuery{
 		FromBlock: fromBlock,
 		Addresses: []common.Address{contractAddr},
 		Topics:    topics,
-	}, nil
+	}
 }
@@ -3,8 +3,11 @@ package indexer
 import (
 	"context"
 	"errors"
+	"log"
+	"time"
 
 	"github.com/citizenwallet/engine/internal/db"
+	"github.com/citizenwallet/engine/internal/webhook"
 	"github.com/citizenwallet/engine/internal/ws"
 	"github.com/citizenwallet/engine/pkg/engine"
 )
@@ -20,22 +23,69 @@ type Indexer struct {
 	db  *db.DB
 	evm engine.EVMRequester
 
-	pools *ws.ConnectionPools
+	pools   *ws.ConnectionPools
+	webhook engine.WebhookMessager
+
+	confirmations *confirmations
+	finality      uint64 // confirmations before an indexed log is success, 0 marks it success right away
+	dispatcher    *webhook.Dispatcher
+	recent        *recentLogs
+	reindexes     *reindexJobs
+}
+
+func NewIndexer(ctx context.Context, db *db.DB, evm engine.EVMRequester, pools *ws.ConnectionPools, webhook engine.WebhookMessager) *Indexer {
+	return &Indexer{ctx: ctx, db: db, evm: evm, pools: pools, webhook: webhook, confirmations: newConfirmations(DefaultConfirmationDepth), recent: newRecentLogs(recentLogsSize), reindexes: newReindexJobs()}
 }
 
-func NewIndexer(ctx context.Context, db *db.DB, evm engine.EVMRequester, pools *ws.ConnectionPools) *Indexer {
-	return &Indexer{ctx: ctx, db: db, evm: evm, pools: pools}
+// SetDispatcher posts the indexed logs to the webhooks that subscribed to them
+func (i *Indexer) SetDispatcher(d *webhook.Dispatcher) {
+	i.dispatcher = d
+}
+
+// SetConfirmationDepth sets the number of confirmations up to which indexed logs are re-broadcast
+func (i *Indexer) SetConfirmationDepth(depth uint64) {
+	i.confirmations = newConfirmations(depth)
+}
+
+// SetFinality sets the number of confirmations that an indexed log waits for as pending before it becomes success,
+// a log that is reorged out before then is removed
+func (i *Indexer) SetFinality(confirmations uint64) {
+	i.finality = confirmations
+}
+
+// statusAt returns the status of a log of a block given the latest block that was seen
+func (i *Indexer) statusAt(block uint64) engine.LogStatus {
+	if i.finality == 0 {
+		return engine.LogStatusSuccess
+	}
+
+	if head := i.confirmations.head(); head >= block && head-block >= i.finality {
+		return engine.LogStatusSuccess
+	}
+
+	return engine.LogStatusPending
 }
 
 func (i *Indexer) Start() error {
-	evs, err := i.db.EventDB.GetEvents()
+	evs, err := i.db.EventDB.GetEvents(i.ctx)
 	if err != nil {
 		return err
 	}
 
 	quitAck := make(chan error)
 
+	go func() {
+		quitAck <- i.TrackConfirmations()
+	}()
+
 	for _, ev := range evs {
+		go func() {
+			err := i.BackfillPositions(ev)
+			if err != nil {
+				log.Default().Println("error backfilling log positions: ", err.Error())
+			}
+		}()
+
 		go func() {
 			err := i.ListenToLogs(ev, quitAck)
 			if err != nil {
@@ -46,3 +96,61 @@ func (i *Indexer) Start() error {
 
 	return <-quitAck
 }
+
+// TrackConfirmations re-broadcasts the indexed logs as their confirmations increase and promotes the pending ones
+// that are final, until the context is done
+func (i *Indexer) TrackConfirmations() error {
+	ticker := time.NewTicker(confirmationsInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-i.ctx.Done():
+			return i.ctx.Err()
+		case <-ticker.C:
+			latest, err := i.evm.LatestBlock()
+			if err != nil {
+				log.Default().Println("error fetching latest block: ", err.Error())
+				continue
+			}
+
+			for _, l := range i.confirmations.advance(latest.Uint64()) {
+				i.pools.BroadcastMessage(engine.WSMessageTypeUpdate, l)
+			}
+
+			err = i.promoteLogs(latest.Uint64())
+			if err != nil {
+				log.Default().Println("error promoting final logs: ", err.Error())
+			}
+		}
+	}
+}
+
+// promoteLogs marks the pending logs that have enough confirmations as success and announces them
+func (i *Indexer) promoteLogs(latest uint64) error {
+	if i.finality == 0 || latest < i.finality {
+		return nil
+	}
+
+	hashes, err := i.db.LogDB.PromoteLogs(i.ctx, int64(latest-i.finality))
+	if err != nil {
+		return err
+	}
+
+	for _, hash := range hashes {
+		l, err := i.db.LogDB.GetLog(i.ctx, hash)
+		if err != nil {
+			return err
+		}
+
+		l.Confirmations = int64(latest) - l.BlockNumber
+
+		i.pools.BroadcastMessage(engine.WSMessageTypeUpdate, l)
+
+		if i.dispatcher != nil {
+			i.dispatcher.Dispatch(l)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,62 @@
+package indexer
+
+import "sync"
+
+// recentLogsSize is the number of indexed log hashes that are remembered to not broadcast a log twice, it covers
+// more than the logs of the blocks that are indexed again when a subscription resumes
+const recentLogsSize = 4096
+
+type recentEntry struct {
+	hash string
+	gen  uint64
+}
+
+// recentLogs is a bounded set of the hashes of recently broadcast logs, the oldest are forgotten first
+type recentLogs struct {
+	mu     sync.Mutex
+	size   int
+	gen    uint64
+	hashes map[string]uint64 // the generation of the entry that added the hash
+	order  []recentEntry
+}
+
+func newRecentLogs(size int) *recentLogs {
+	return &recentLogs{
+		size:   size,
+		hashes: map[string]uint64{},
+	}
+}
+
+// add remembers a hash and returns false if it was already there
+func (r *recentLogs) add(hash string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.hashes[hash]; ok {
+		return false
+	}
+
+	r.gen++
+	r.hashes[hash] = r.gen
+	r.order = append(r.order, recentEntry{hash: hash, gen: r.gen})
+
+	for len(r.order) > r.size {
+		oldest := r.order[0]
+		r.order = r.order[1:]
+
+		// a hash that was removed and added again belongs to its newer entry
+		if r.hashes[oldest.hash] == oldest.gen {
+			delete(r.hashes, oldest.hash)
+		}
+	}
+
+	return true
+}
+
+// remove forgets a hash, a log that was reorged out is broadcast again if it comes back
+func (r *recentLogs) remove(hash string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.hashes, hash)
+}
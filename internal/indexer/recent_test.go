@@ -0,0 +1,59 @@
+package indexer
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestRecentLogsOverlap(t *testing.T) {
+	r := newRecentLogs(100)
+
+	broadcast := 0
+	index := func(from, to int) {
+		for b := from; b <= to; b++ {
+			if r.add(fmt.Sprintf("0x%d", b)) {
+				broadcast++
+			}
+		}
+	}
+
+	// the backfill and the live subscription both deliver blocks 8 to 10
+	index(1, 10)
+	index(8, 15)
+
+	if broadcast != 15 {
+		t.Fatalf("expected 15 broadcasts, got %d", broadcast)
+	}
+
+	// a log that was reorged out is broadcast again when it comes back
+	r.remove("0x9")
+	if !r.add("0x9") {
+		t.Fatalf("expected a removed log to be added again")
+	}
+}
+
+func TestRecentLogsEviction(t *testing.T) {
+	r := newRecentLogs(3)
+
+	r.add("0xa")
+	r.add("0xb")
+	r.add("0xc")
+
+	// removing and adding a hash again makes it the newest
+	r.remove("0xa")
+	r.add("0xa")
+
+	r.add("0xd")
+
+	if len(r.hashes) != 3 {
+		t.Fatalf("expected 3 hashes, got %d", len(r.hashes))
+	}
+
+	if r.add("0xa") {
+		t.Fatalf("expected 0xa to be remembered")
+	}
+
+	if !r.add("0xb") {
+		t.Fatalf("expected 0xb to be forgotten")
+	}
+}
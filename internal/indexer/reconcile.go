@@ -0,0 +1,143 @@
+package indexer
+
+import (
+	"fmt"
+	"log"
+	"math/big"
+	"time"
+
+	"github.com/citizenwallet/engine/pkg/engine"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const (
+	// DefaultReconcileWindow is the number of recent blocks that are compared against the db
+	DefaultReconcileWindow = 100
+
+	// DefaultReconcileInterval is how often the recent blocks are compared against the db
+	DefaultReconcileInterval = 5 * time.Minute
+)
+
+// Reconcile periodically fetches the logs of every event in a window of recent blocks and indexes the ones
+// that are missing, until the context is done
+//
+// this catches the logs that were missed while the subscription was down
+func (i *Indexer) Reconcile(window uint64, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-i.ctx.Done():
+			return i.ctx.Err()
+		case <-ticker.C:
+			evs, err := i.db.EventDB.GetEvents(i.ctx)
+			if err != nil {
+				log.Default().Println("error fetching events: ", err.Error())
+				continue
+			}
+
+			for _, ev := range evs {
+				// the logs of a contract that is being re-indexed are missing until the job is done
+				if i.reindexes.running(ev.Contract) {
+					continue
+				}
+
+				filled, err := i.reconcileEvent(ev, window)
+				if err != nil {
+					log.Default().Printf("error reconciling logs of %s: %s\n", ev.Contract, err.Error())
+				}
+
+				if filled > 0 {
+					i.reportGaps(ev, filled)
+				}
+			}
+		}
+	}
+}
+
+// reconcileEvent indexes the logs of an event in the window that weren't indexed in the db, it returns how many there were
+func (i *Indexer) reconcileEvent(ev *engine.Event, window uint64) (int, error) {
+	latest, err := i.evm.LatestBlock()
+	if err != nil {
+		return 0, err
+	}
+
+	from := new(big.Int).Sub(latest, new(big.Int).SetUint64(window))
+	if from.Sign() < 0 {
+		from = big.NewInt(0)
+	}
+
+	logs, err := i.evm.FilterLogs(ethereum.FilterQuery{
+		FromBlock: from,
+		ToBlock:   latest,
+		Addresses: []common.Address{common.HexToAddress(ev.Contract)},
+		Topics:    [][]common.Hash{{ev.GetTopic0FromEventSignature()}},
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	blks := map[uint64]uint64{}
+	filled := 0
+
+	for _, lg := range logs {
+		if lg.Removed {
+			continue
+		}
+
+		l, err := newLog(ev, lg, 0)
+		if err != nil {
+			return filled, err
+		}
+
+		exists, err := i.db.LogDB.IndexedLogExists(i.ctx, l.Hash)
+		if err != nil {
+			return filled, err
+		}
+
+		if exists {
+			continue
+		}
+
+		t, ok := blks[lg.BlockNumber]
+		if !ok {
+			t, err = i.evm.BlockTime(new(big.Int).SetUint64(lg.BlockNumber))
+			if err != nil {
+				return filled, err
+			}
+
+			blks[lg.BlockNumber] = t
+		}
+
+		// the upsert is idempotent, a log that the listener indexes at the same time is only counted once in the balances
+		err = i.indexLog(ev, lg, t)
+		if err != nil {
+			return filled, err
+		}
+
+		filled++
+	}
+
+	return filled, nil
+}
+
+func (i *Indexer) reportGaps(ev *engine.Event, filled int) {
+	name := ev.Name
+	if meta, err := i.db.EventDB.GetContractMetadata(i.ctx, ev.Contract); err == nil {
+		name = meta.DisplayName()
+	}
+
+	msg := fmt.Sprintf("reconciliation indexed %d missing logs of %s (%s)", filled, ev.Contract, name)
+
+	log.Default().Println(msg)
+
+	if i.webhook == nil {
+		return
+	}
+
+	if err := i.webhook.Notify(i.ctx, msg); err != nil {
+		log.Default().Println("error sending reconciliation report: ", err.Error())
+	}
+}
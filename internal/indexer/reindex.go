@@ -0,0 +1,287 @@
+package indexer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/citizenwallet/engine/pkg/engine"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+const (
+	// reindexBlockRange is the number of blocks whose logs are fetched at once when a contract is re-indexed
+	reindexBlockRange = 1000
+
+	// reindexRemoveTimeout is how long the removal of the logs of a contract can take, it can be most of its logs
+	reindexRemoveTimeout = 10 * time.Minute
+)
+
+var (
+	ErrReindexNotIndexed = errors.New("the contract is not indexed")
+	ErrReindexRunning    = errors.New("the contract is already being re-indexed")
+	ErrReindexFromBlock  = errors.New("invalid block to re-index from")
+)
+
+// reindexJobs holds the last re-indexing job of each contract, by the lowercase address of the contract
+type reindexJobs struct {
+	mu   sync.Mutex
+	jobs map[string]*engine.ReindexJob
+}
+
+func newReindexJobs() *reindexJobs {
+	return &reindexJobs{jobs: map[string]*engine.ReindexJob{}}
+}
+
+// start registers a new job for a contract, unless one is still running
+func (r *reindexJobs) start(job *engine.ReindexJob) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := strings.ToLower(job.Contract)
+	if prev, ok := r.jobs[key]; ok && prev.Status == engine.ReindexStatusRunning {
+		return ErrReindexRunning
+	}
+
+	r.jobs[key] = job
+
+	return nil
+}
+
+// update applies a change to the job of a contract
+func (r *reindexJobs) update(contract string, change func(job *engine.ReindexJob)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	job, ok := r.jobs[strings.ToLower(contract)]
+	if !ok {
+		return
+	}
+
+	change(job)
+	job.UpdatedAt = time.Now().UTC()
+}
+
+// get returns a copy of the job of a contract, or nil if it was never re-indexed
+func (r *reindexJobs) get(contract string) *engine.ReindexJob {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	job, ok := r.jobs[strings.ToLower(contract)]
+	if !ok {
+		return nil
+	}
+
+	cp := *job
+	return &cp
+}
+
+// running returns true if the logs of the contract are being re-indexed
+func (r *reindexJobs) running(contract string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	job, ok := r.jobs[strings.ToLower(contract)]
+	return ok && job.Status == engine.ReindexStatusRunning
+}
+
+// Reindex removes the logs of the events of a contract from a block onward and indexes them again up to the latest
+// block, the progress is returned by ReindexProgress
+//
+// the listener of the contract keeps indexing its new logs in the meantime, a log that both index is stored by
+// AddConfirmedLog, which serializes the transactions of the same log so that its balance delta is only applied once.
+// Re-indexed logs are not announced again, they were already, and the reconciliation skips the contract until the
+// job is done so that the removed logs aren't reported as missing
+func (i *Indexer) Reindex(contract string, fromBlock int64) (*engine.ReindexJob, error) {
+	if fromBlock < 0 {
+		return nil, fmt.Errorf("%w: %d", ErrReindexFromBlock, fromBlock)
+	}
+
+	evs, err := i.db.EventDB.GetEvents(i.ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	cevs := []*engine.Event{}
+	for _, ev := range evs {
+		if strings.EqualFold(ev.Contract, contract) {
+			cevs = append(cevs, ev)
+		}
+	}
+
+	if len(cevs) == 0 {
+		return nil, ErrReindexNotIndexed
+	}
+
+	latest, err := i.evm.LatestBlock()
+	if err != nil {
+		return nil, err
+	}
+
+	if fromBlock > latest.Int64() {
+		return nil, fmt.Errorf("%w: %d is after the latest block %d", ErrReindexFromBlock, fromBlock, latest.Int64())
+	}
+
+	now := time.Now().UTC()
+	job := &engine.ReindexJob{
+		Contract:  cevs[0].Contract,
+		FromBlock: fromBlock,
+		ToBlock:   latest.Int64(),
+		Block:     fromBlock - 1,
+		Status:    engine.ReindexStatusRunning,
+		StartedAt: now,
+		UpdatedAt: now,
+	}
+
+	err = i.reindexes.start(job)
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		err := i.reindex(job.Contract, cevs, fromBlock, job.ToBlock)
+
+		i.reindexes.update(job.Contract, func(job *engine.ReindexJob) {
+			job.Status = engine.ReindexStatusDone
+			if err != nil {
+				job.Status = engine.ReindexStatusFailed
+				job.Error = err.Error()
+			}
+		})
+
+		i.reportReindex(i.reindexes.get(job.Contract))
+	}()
+
+	return i.reindexes.get(job.Contract), nil
+}
+
+// ReindexProgress returns the last re-indexing job of a contract, or nil if it was never re-indexed
+func (i *Indexer) ReindexProgress(contract string) *engine.ReindexJob {
+	return i.reindexes.get(contract)
+}
+
+// reindex removes the logs of a contract from a block and indexes the logs of its events again, a batch of blocks
+// at a time
+func (i *Indexer) reindex(contract string, evs []*engine.Event, fromBlock, toBlock int64) error {
+	log.Default().Printf("re-indexing the logs of %s from block %d to %d\n", contract, fromBlock, toBlock)
+
+	ctx, cancel := context.WithTimeout(i.ctx, reindexRemoveTimeout)
+	removed, err := i.db.LogDB.RemoveIndexedLogsFrom(ctx, contract, fromBlock)
+	cancel()
+	if err != nil {
+		return err
+	}
+
+	i.reindexes.update(contract, func(job *engine.ReindexJob) {
+		job.Removed = removed
+	})
+
+	topics := []common.Hash{}
+	byTopic := map[common.Hash]*engine.Event{}
+	for _, ev := range evs {
+		topic := ev.GetTopic0FromEventSignature()
+
+		topics = append(topics, topic)
+		byTopic[topic] = ev
+	}
+
+	for from := fromBlock; from <= toBlock; from += reindexBlockRange {
+		select {
+		case <-i.ctx.Done():
+			return i.ctx.Err()
+		default:
+		}
+
+		to := min(from+reindexBlockRange-1, toBlock)
+
+		logs, err := i.evm.FilterLogs(ethereum.FilterQuery{
+			FromBlock: big.NewInt(from),
+			ToBlock:   big.NewInt(to),
+			Addresses: []common.Address{common.HexToAddress(contract)},
+			Topics:    [][]common.Hash{topics},
+		})
+		if err != nil {
+			return err
+		}
+
+		indexed, err := i.reindexLogs(byTopic, logs)
+		if err != nil {
+			return err
+		}
+
+		i.reindexes.update(contract, func(job *engine.ReindexJob) {
+			job.Block = to
+			job.Indexed += indexed
+		})
+	}
+
+	return nil
+}
+
+// reindexLogs stores the logs of a batch without announcing them, it returns how many were stored
+func (i *Indexer) reindexLogs(byTopic map[common.Hash]*engine.Event, logs []types.Log) (int64, error) {
+	blks := map[uint64]uint64{}
+	indexed := int64(0)
+
+	for _, lg := range logs {
+		if lg.Removed || len(lg.Topics) == 0 {
+			continue
+		}
+
+		ev, ok := byTopic[lg.Topics[0]]
+		if !ok {
+			continue
+		}
+
+		t, ok := blks[lg.BlockNumber]
+		if !ok {
+			var err error
+			t, err = i.evm.BlockTime(new(big.Int).SetUint64(lg.BlockNumber))
+			if err != nil {
+				return indexed, err
+			}
+
+			blks[lg.BlockNumber] = t
+		}
+
+		l, err := newLog(ev, lg, t)
+		if err != nil {
+			return indexed, err
+		}
+
+		l.Status = i.statusAt(lg.BlockNumber)
+
+		err = i.db.LogDB.AddConfirmedLog(i.ctx, l)
+		if err != nil {
+			return indexed, err
+		}
+
+		indexed++
+	}
+
+	return indexed, nil
+}
+
+func (i *Indexer) reportReindex(job *engine.ReindexJob) {
+	msg := fmt.Sprintf("re-indexing of %s from block %d %s: %d logs removed, %d indexed up to block %d", job.Contract, job.FromBlock, job.Status, job.Removed, job.Indexed, job.Block)
+	if job.Error != "" {
+		msg += ": " + job.Error
+	}
+
+	log.Default().Println(msg)
+
+	if i.webhook == nil {
+		return
+	}
+
+	if err := i.webhook.Notify(i.ctx, msg); err != nil {
+		log.Default().Println("error sending re-indexing report: ", err.Error())
+	}
+}
@@ -0,0 +1,114 @@
+package indexer
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/citizenwallet/engine/internal/db"
+	"github.com/citizenwallet/engine/internal/ws"
+	"github.com/citizenwallet/engine/pkg/engine"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// blockTimeEVM only answers the block times, the other calls of the indexer are not made by the test
+type blockTimeEVM struct {
+	engine.EVMRequester
+}
+
+func (e *blockTimeEVM) BlockTime(number *big.Int) (uint64, error) {
+	return uint64(time.Now().Unix()), nil
+}
+
+// openTestDB creates the tables of a new chain in the database of DB_TEST_URL (postgres://...), the test is skipped
+// without it
+func openTestDB(t *testing.T) *db.DB {
+	t.Helper()
+
+	url := os.Getenv("DB_TEST_URL")
+	if url == "" {
+		t.Skip("DB_TEST_URL is not set")
+	}
+
+	config, err := pgxpool.ParseConfig(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cc := config.ConnConfig
+	chainID := big.NewInt(time.Now().UnixNano())
+
+	d, err := db.NewDB(chainID, nil, cc.User, cc.Password, cc.Database, fmt.Sprint(cc.Port), cc.Host, cc.Host, 0, db.DefaultQueryExecMode, db.DefaultStatementCacheCapacity)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return d
+}
+
+func TestReindexWhileListening(t *testing.T) {
+	d := openTestDB(t)
+
+	i := NewIndexer(context.Background(), d, &blockTimeEVM{}, ws.NewConnectionPools(), nil)
+
+	contract := common.HexToAddress("0x5815E61eF72c9E6107b5c5A05FD121F334f7a7f1")
+	to := common.HexToAddress("0x7079253c0358eF9Fd87E16488299Ef6e06F403B6")
+
+	ev := &engine.Event{
+		Contract:       contract.Hex(),
+		EventSignature: "Transfer(address indexed from, address indexed to, uint256 value)",
+		Name:           "Transfer",
+	}
+
+	lg := types.Log{
+		Address:     contract,
+		Topics:      []common.Hash{engine.TransferTopic0, common.BytesToHash(common.HexToAddress("0x0000000000000000000000000000000000000001").Bytes()), common.BytesToHash(to.Bytes())},
+		Data:        common.LeftPadBytes(big.NewInt(100).Bytes(), 32),
+		BlockNumber: 10,
+		TxHash:      common.HexToHash("0x1b86e5ea2c7b2a5fa2d5c8fd5e4a4bba7cde0e3a1c3f9a3dba5cf50d1b6b4c8d"),
+		Index:       1,
+	}
+
+	byTopic := map[common.Hash]*engine.Event{engine.TransferTopic0: ev}
+
+	// the listener and a re-indexing store the same new log at the same time
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for n := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			if n%2 == 0 {
+				errs <- i.indexLog(ev, lg, uint64(time.Now().Unix()))
+				return
+			}
+
+			_, err := i.reindexLogs(byTopic, []types.Log{lg})
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	balance, err := d.BalanceDB.GetBalance(contract.Hex(), to.Hex())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if balance.Cmp(big.NewInt(100)) != 0 {
+		t.Fatalf("expected a balance of 100, got %s", balance)
+	}
+}
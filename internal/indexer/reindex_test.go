@@ -0,0 +1,58 @@
+package indexer
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/citizenwallet/engine/pkg/engine"
+)
+
+func TestReindexJobs(t *testing.T) {
+	r := newReindexJobs()
+
+	contract := "0x5815E61eF72c9E6107b5c5A05FD121F334f7a7f1"
+	err := r.start(&engine.ReindexJob{Contract: contract, Status: engine.ReindexStatusRunning})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the address of a contract is not case sensitive
+	if !r.running("0x5815e61ef72c9e6107b5c5a05fd121f334f7a7f1") {
+		t.Fatalf("expected the job to be running")
+	}
+
+	err = r.start(&engine.ReindexJob{Contract: contract, Status: engine.ReindexStatusRunning})
+	if !errors.Is(err, ErrReindexRunning) {
+		t.Fatalf("expected ErrReindexRunning, got %v", err)
+	}
+
+	// the progress is a copy, it doesn't change under the caller
+	job := r.get(contract)
+	r.update(contract, func(job *engine.ReindexJob) {
+		job.Indexed = 10
+		job.Status = engine.ReindexStatusDone
+	})
+
+	if job.Indexed != 0 || r.get(contract).Indexed != 10 {
+		t.Fatalf("expected the update to only change the job, got %d and %d", job.Indexed, r.get(contract).Indexed)
+	}
+
+	// a contract can be re-indexed again once its job is done
+	err = r.start(&engine.ReindexJob{Contract: contract, Status: engine.ReindexStatusRunning})
+	if err != nil {
+		t.Fatalf("expected a new job to start, got %v", err)
+	}
+
+	if r.get("0x7079253c0358eF9Fd87E16488299Ef6e06F403B6") != nil {
+		t.Fatalf("expected no job for a contract that was never re-indexed")
+	}
+}
+
+func TestReindexFromBlock(t *testing.T) {
+	i := &Indexer{reindexes: newReindexJobs()}
+
+	_, err := i.Reindex("0x5815E61eF72c9E6107b5c5A05FD121F334f7a7f1", -1)
+	if !errors.Is(err, ErrReindexFromBlock) {
+		t.Fatalf("expected ErrReindexFromBlock, got %v", err)
+	}
+}
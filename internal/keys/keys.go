@@ -0,0 +1,43 @@
+package keys
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/citizenwallet/engine/pkg/engine"
+)
+
+type CipherType string
+
+const (
+	CipherTypeLocal CipherType = "local"
+	CipherTypeKMS   CipherType = "kms"
+)
+
+// NewCipher returns the cipher used to store sponsor keys
+//
+// when a secret is configured alongside kms, keys that were encrypted before the switch can still be read
+func NewCipher(ctx context.Context, t CipherType, secret, kmsKeyID string) (engine.KeyCipher, error) {
+	var local *LocalCipher
+	if secret != "" {
+		local = NewLocalCipher(secret)
+	}
+
+	switch t {
+	case CipherTypeLocal:
+		if local == nil {
+			return nil, errors.New("DB_SECRET is required for the local key cipher")
+		}
+
+		return local, nil
+	case CipherTypeKMS:
+		if kmsKeyID == "" {
+			return nil, errors.New("KMS_KEY_ID is required for the kms key cipher")
+		}
+
+		return NewKMSCipher(ctx, kmsKeyID, local)
+	}
+
+	return nil, fmt.Errorf("invalid key cipher %q", t)
+}
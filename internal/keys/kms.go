@@ -0,0 +1,165 @@
+package keys
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+)
+
+// ciphertexts of the kms cipher are prefixed to tell them apart from the ones of the local cipher
+const kmsPrefix = "kms:"
+
+var ErrInvalidCiphertext = errors.New("invalid ciphertext")
+
+// kmsClient is the part of the kms api the cipher uses
+type kmsClient interface {
+	GenerateDataKey(ctx context.Context, params *kms.GenerateDataKeyInput, optFns ...func(*kms.Options)) (*kms.GenerateDataKeyOutput, error)
+	Decrypt(ctx context.Context, params *kms.DecryptInput, optFns ...func(*kms.Options)) (*kms.DecryptOutput, error)
+}
+
+// KMSCipher encrypts every key with its own data key, the data key is wrapped by a kms master key and stored next to it
+type KMSCipher struct {
+	ctx      context.Context
+	client   kmsClient
+	keyID    string
+	fallback *LocalCipher
+
+	mu       sync.Mutex
+	dataKeys map[string][]byte // unwrapped data keys by wrapped data key, saves a kms call on every read
+}
+
+// NewKMSCipher creates a cipher using the aws kms master key, credentials and region come from the default aws config
+func NewKMSCipher(ctx context.Context, keyID string, fallback *LocalCipher) (*KMSCipher, error) {
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return newKMSCipher(ctx, kms.NewFromConfig(cfg), keyID, fallback), nil
+}
+
+func newKMSCipher(ctx context.Context, client kmsClient, keyID string, fallback *LocalCipher) *KMSCipher {
+	return &KMSCipher{
+		ctx:      ctx,
+		client:   client,
+		keyID:    keyID,
+		fallback: fallback,
+		dataKeys: map[string][]byte{},
+	}
+}
+
+func (c *KMSCipher) Encrypt(plaintext string) (string, error) {
+	out, err := c.client.GenerateDataKey(c.ctx, &kms.GenerateDataKeyInput{
+		KeyId:   aws.String(c.keyID),
+		KeySpec: types.DataKeySpecAes256,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	gcm, err := newGCM(out.Plaintext)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+
+	sealed := gcm.Seal(nonce, nonce, []byte(plaintext), nil)
+
+	return kmsPrefix + base64.StdEncoding.EncodeToString(out.CiphertextBlob) + ":" + hex.EncodeToString(sealed), nil
+}
+
+func (c *KMSCipher) Decrypt(ciphertext string) (string, error) {
+	if !strings.HasPrefix(ciphertext, kmsPrefix) {
+		if c.fallback == nil {
+			return "", ErrInvalidCiphertext
+		}
+
+		// encrypted before kms was enabled
+		return c.fallback.Decrypt(ciphertext)
+	}
+
+	wrapped, sealedHex, ok := strings.Cut(strings.TrimPrefix(ciphertext, kmsPrefix), ":")
+	if !ok {
+		return "", ErrInvalidCiphertext
+	}
+
+	sealed, err := hex.DecodeString(sealedHex)
+	if err != nil {
+		return "", ErrInvalidCiphertext
+	}
+
+	dataKey, err := c.unwrap(wrapped)
+	if err != nil {
+		return "", err
+	}
+
+	gcm, err := newGCM(dataKey)
+	if err != nil {
+		return "", err
+	}
+
+	if len(sealed) < gcm.NonceSize() {
+		return "", ErrInvalidCiphertext
+	}
+
+	plaintext, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", err
+	}
+
+	return string(plaintext), nil
+}
+
+// unwrap decrypts a data key with the master key
+func (c *KMSCipher) unwrap(wrapped string) ([]byte, error) {
+	c.mu.Lock()
+	dataKey, ok := c.dataKeys[wrapped]
+	c.mu.Unlock()
+	if ok {
+		return dataKey, nil
+	}
+
+	blob, err := base64.StdEncoding.DecodeString(wrapped)
+	if err != nil {
+		return nil, ErrInvalidCiphertext
+	}
+
+	out, err := c.client.Decrypt(c.ctx, &kms.DecryptInput{
+		KeyId:          aws.String(c.keyID),
+		CiphertextBlob: blob,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.dataKeys[wrapped] = out.Plaintext
+	c.mu.Unlock()
+
+	return out.Plaintext, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,105 @@
+package keys
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+)
+
+// fakeKMS wraps data keys by prefixing them, good enough to check the envelope
+type fakeKMS struct {
+	decrypts int
+}
+
+func (f *fakeKMS) GenerateDataKey(ctx context.Context, params *kms.GenerateDataKeyInput, optFns ...func(*kms.Options)) (*kms.GenerateDataKeyOutput, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+
+	return &kms.GenerateDataKeyOutput{
+		Plaintext:      key,
+		CiphertextBlob: append([]byte("wrapped"), key...),
+	}, nil
+}
+
+func (f *fakeKMS) Decrypt(ctx context.Context, params *kms.DecryptInput, optFns ...func(*kms.Options)) (*kms.DecryptOutput, error) {
+	f.decrypts++
+
+	key, ok := strings.CutPrefix(string(params.CiphertextBlob), "wrapped")
+	if !ok {
+		return nil, errors.New("unknown data key")
+	}
+
+	return &kms.DecryptOutput{Plaintext: []byte(key)}, nil
+}
+
+const testSecret = "c82fc59c202be1250b611d42bfdb2a9f02d8abf469e7655146c3edb8c64fc81a"
+
+func TestKMSCipher(t *testing.T) {
+	client := &fakeKMS{}
+	c := newKMSCipher(context.Background(), client, "key", nil)
+
+	encrypted, err := c.Encrypt("secret key")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if !strings.HasPrefix(encrypted, kmsPrefix) || strings.Contains(encrypted, "secret key") {
+		t.Fatalf("unexpected ciphertext %s", encrypted)
+	}
+
+	for i := 0; i < 2; i++ {
+		decrypted, err := c.Decrypt(encrypted)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if decrypted != "secret key" {
+			t.Errorf("expected secret key, got %s", decrypted)
+		}
+	}
+
+	if client.decrypts != 1 {
+		t.Errorf("expected the data key to be unwrapped once, got %d", client.decrypts)
+	}
+
+	// tampering with the ciphertext is detected
+	tampered := encrypted[:len(encrypted)-2] + "00"
+	if tampered == encrypted {
+		tampered = encrypted[:len(encrypted)-2] + "11"
+	}
+
+	if _, err := c.Decrypt(tampered); err == nil {
+		t.Error("expected an error for a tampered ciphertext")
+	}
+}
+
+func TestKMSCipher_Fallback(t *testing.T) {
+	local := NewLocalCipher(testSecret)
+
+	encrypted, err := local.Encrypt("secret key")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	without := newKMSCipher(context.Background(), &fakeKMS{}, "key", nil)
+	if _, err := without.Decrypt(encrypted); err != ErrInvalidCiphertext {
+		t.Errorf("expected %v, got %v", ErrInvalidCiphertext, err)
+	}
+
+	with := newKMSCipher(context.Background(), &fakeKMS{}, "key", local)
+
+	decrypted, err := with.Decrypt(encrypted)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if decrypted != "secret key" {
+		t.Errorf("expected secret key, got %s", decrypted)
+	}
+}
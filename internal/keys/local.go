@@ -0,0 +1,20 @@
+package keys
+
+import "github.com/citizenwallet/engine/pkg/common"
+
+// LocalCipher encrypts keys with a secret from the environment
+type LocalCipher struct {
+	secret string
+}
+
+func NewLocalCipher(secret string) *LocalCipher {
+	return &LocalCipher{secret: secret}
+}
+
+func (c *LocalCipher) Encrypt(plaintext string) (string, error) {
+	return common.Encrypt(plaintext, c.secret)
+}
+
+func (c *LocalCipher) Decrypt(ciphertext string) (string, error) {
+	return common.Decrypt(ciphertext, c.secret)
+}
@@ -1,31 +1,134 @@
 package logs
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"math/big"
 	"net/http"
 	"net/url"
+	"regexp"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/citizenwallet/engine/internal/db"
+	"github.com/citizenwallet/engine/internal/ws"
 	com "github.com/citizenwallet/engine/pkg/common"
 	"github.com/citizenwallet/engine/pkg/engine"
 	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
 )
 
+// DefaultPollTimeout is how long a poll waits for new logs before it responds without any
+const DefaultPollTimeout = 25 * time.Second
+
+// log and transaction hashes are keccak256 hashes
+var hashPattern = regexp.MustCompile(`^0x[0-9a-fA-F]{64}$`)
+
 type Service struct {
 	chainID *big.Int
 	db      *db.DB
 
-	evm engine.EVMRequester
+	evm   engine.EVMRequester
+	pools *ws.ConnectionPools
+
+	pollTimeout time.Duration
 }
 
-func NewService(chainID *big.Int, db *db.DB, evm engine.EVMRequester) *Service {
+func NewService(chainID *big.Int, db *db.DB, evm engine.EVMRequester, pools *ws.ConnectionPools, pollTimeout time.Duration) *Service {
 	return &Service{
-		chainID: chainID,
-		db:      db,
-		evm:     evm,
+		chainID:     chainID,
+		db:          db,
+		evm:         evm,
+		pools:       pools,
+		pollTimeout: pollTimeout,
+	}
+}
+
+// filterArgNames returns the argument names of the event that the data filters can match on, there are none
+// without filters so that the event is only looked up when it is needed
+func (s *Service) filterArgNames(ctx context.Context, contract, topic string, filters ...map[string]any) []string {
+	for _, f := range filters {
+		if len(f) == 0 {
+			continue
+		}
+
+		ev, err := s.db.EventDB.GetEventByTopic(ctx, contract, topic)
+		if err != nil {
+			return nil
+		}
+
+		return ev.DataArgNames()
+	}
+
+	return nil
+}
+
+// parseStatuses parses the comma separated statuses of the status query param, no statuses means all of them
+func parseStatuses(q url.Values) ([]engine.LogStatus, error) {
+	statuses := []engine.LogStatus{}
+
+	statusq := q.Get("status")
+	if statusq == "" {
+		return statuses, nil
+	}
+
+	for _, st := range strings.Split(statusq, ",") {
+		status, err := engine.LogStatusFromString(strings.TrimSpace(st))
+		if err != nil {
+			return nil, err
+		}
+
+		statuses = append(statuses, status)
+	}
+
+	return statuses, nil
+}
+
+// parseAddressFilter parses the sender and recipient query params, both are optional
+func parseAddressFilter(q url.Values) (engine.AddressFilter, error) {
+	var f engine.AddressFilter
+
+	if sender := q.Get("sender"); sender != "" {
+		addr, err := com.NormalizeAddress(sender)
+		if err != nil {
+			return f, err
+		}
+
+		f.Sender = addr
+	}
+
+	if recipient := q.Get("recipient"); recipient != "" {
+		addr, err := com.NormalizeAddress(recipient)
+		if err != nil {
+			return f, err
+		}
+
+		f.Recipient = addr
 	}
+
+	return f, nil
+}
+
+// parseFromDate parses the fromDate query param that bounds a query up to maxDate, there is no range without it
+func parseFromDate(q url.Values, maxDate time.Time) (time.Time, bool, error) {
+	fromDateq := q.Get("fromDate")
+	if fromDateq == "" {
+		return time.Time{}, false, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, fromDateq)
+	if err != nil {
+		return time.Time{}, false, errors.New("invalid fromDate")
+	}
+
+	fromDate := t.UTC()
+	if fromDate.After(maxDate) {
+		return time.Time{}, false, errors.New("fromDate is after maxDate")
+	}
+
+	return fromDate, true, nil
 }
 
 func (s *Service) GetSingle(w http.ResponseWriter, r *http.Request) {
@@ -33,19 +136,165 @@ func (s *Service) GetSingle(w http.ResponseWriter, r *http.Request) {
 	hash := chi.URLParam(r, "hash")
 
 	if hash == "" {
-		w.WriteHeader(http.StatusBadRequest)
+		com.WriteError(w, http.StatusBadRequest, "missing hash")
 		return
 	}
 
-	tx, err := s.db.LogDB.GetLog(hash)
+	if !hashPattern.MatchString(hash) {
+		com.WriteError(w, http.StatusBadRequest, "invalid hash")
+		return
+	}
+
+	tx, err := s.db.LogDB.GetLog(r.Context(), hash)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+		if errors.Is(err, pgx.ErrNoRows) {
+			com.WriteError(w, http.StatusNotFound, "log not found")
+			return
+		}
+
+		if db.IsQueryTimeout(err) {
+			com.WriteError(w, http.StatusServiceUnavailable, "query timed out")
+			return
+		}
+
+		com.WriteError(w, http.StatusInternalServerError, "error fetching log")
 		return
 	}
 
 	err = com.Body(w, tx, nil)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		com.WriteError(w, http.StatusInternalServerError, "error writing response")
+	}
+}
+
+// GetByTxHash godoc
+//
+//	@Summary		Fetch the logs of a transaction
+//	@Description	get the logs of a contract that a transaction emitted, in the order that they were emitted
+//	@Tags			logs
+//	@Accept			json
+//	@Produce		json,application/msgpack
+//	@Param			contract_address	path		string	true	"Contract Address"
+//	@Param			tx_hash	path		string	true	"Hash of the transaction"
+//	@Success		200	{object}	common.Response
+//	@Failure		400
+//	@Failure		500
+//	@Router			/logs/{contract_address}/txs/{tx_hash} [get]
+func (s *Service) GetByTxHash(w http.ResponseWriter, r *http.Request) {
+	contractAddr, err := com.NormalizeAddress(chi.URLParam(r, "contract_address"))
+	if err != nil {
+		com.WriteError(w, http.StatusBadRequest, "invalid contract address")
+		return
+	}
+
+	txHash := chi.URLParam(r, "tx_hash")
+	if !hashPattern.MatchString(txHash) {
+		com.WriteError(w, http.StatusBadRequest, "invalid transaction hash")
+		return
+	}
+
+	txLogs, err := s.db.LogDB.GetLogsByTxHash(r.Context(), txHash)
+	if err != nil {
+		if db.IsQueryTimeout(err) {
+			com.WriteError(w, http.StatusServiceUnavailable, "query timed out")
+			return
+		}
+
+		com.WriteError(w, http.StatusInternalServerError, "error fetching logs")
+		return
+	}
+
+	// a transaction can emit logs of other contracts
+	logs := []*engine.Log{}
+	for _, l := range txLogs {
+		if l.To == contractAddr {
+			logs = append(logs, l)
+		}
+	}
+
+	err = com.BodyMultiple(w, logs, nil)
+	if err != nil {
+		com.WriteError(w, http.StatusInternalServerError, "error writing response")
+	}
+}
+
+// GetContractLogs godoc
+//
+//	@Summary		Fetch the logs of a contract
+//	@Description	get the logs of a contract across all of its events, or of a single event with the topic query param
+//	@Tags			logs
+//	@Accept			json
+//	@Produce		json,application/msgpack
+//	@Param			contract_address	path		string	true	"Contract Address"
+//	@Param			topic	query		string	false	"Topic of the event to filter on"
+//	@Param			maxDate	query		string	false	"Logs created at or before this date (RFC3339)"
+//	@Param			status	query		string	false	"Comma separated statuses to filter on, ex: success"
+//	@Param			sender	query		string	false	"Address that the logs are from"
+//	@Param			recipient	query		string	false	"Address that the logs are to"
+//	@Success		200	{object}	common.Response
+//	@Failure		400
+//	@Failure		500
+//	@Router			/logs/{contract_address} [get]
+func (s *Service) GetContractLogs(w http.ResponseWriter, r *http.Request) {
+	contractAddr, err := com.NormalizeAddress(chi.URLParam(r, "contract_address"))
+	if err != nil {
+		com.WriteError(w, http.StatusBadRequest, "invalid contract address")
+		return
+	}
+
+	q := r.URL.Query()
+
+	topic := q.Get("topic")
+	if topic != "" && !hashPattern.MatchString(topic) {
+		com.WriteError(w, http.StatusBadRequest, "invalid topic")
+		return
+	}
+
+	t, err := time.Parse(time.RFC3339, q.Get("maxDate"))
+	if err != nil {
+		t = time.Now()
+	}
+	maxDate := t.UTC()
+
+	limit, err := strconv.Atoi(q.Get("limit"))
+	if err != nil {
+		limit = 20
+	}
+
+	offset, err := strconv.Atoi(q.Get("offset"))
+	if err != nil {
+		offset = 0
+	}
+
+	statuses, err := parseStatuses(q)
+	if err != nil {
+		com.WriteError(w, http.StatusBadRequest, "invalid status")
+		return
+	}
+
+	addrs, err := parseAddressFilter(q)
+	if err != nil {
+		com.WriteError(w, http.StatusBadRequest, "invalid sender or recipient")
+		return
+	}
+
+	logs, err := s.db.LogDB.GetContractLogs(r.Context(), contractAddr, strings.ToLower(topic), maxDate, addrs, statuses, limit, offset)
+	if err != nil {
+		if db.IsQueryTimeout(err) {
+			com.WriteError(w, http.StatusServiceUnavailable, "query timed out")
+			return
+		}
+
+		com.WriteError(w, http.StatusInternalServerError, "error fetching logs")
+		return
+	}
+
+	// TODO: remove legacy support
+	total := offset + limit
+
+	err = com.BodyMultiple(w, logs, com.Pagination{Limit: limit, Offset: offset, Total: total})
+	if err != nil {
+		com.WriteError(w, http.StatusInternalServerError, "error writing response")
 	}
 }
 
@@ -53,14 +302,14 @@ func (s *Service) GetAll(w http.ResponseWriter, r *http.Request) {
 	// parse contract address from url params
 	contractAddr := chi.URLParam(r, "contract_address")
 	if contractAddr == "" {
-		w.WriteHeader(http.StatusBadRequest)
+		com.WriteError(w, http.StatusBadRequest, "missing contract address")
 		return
 	}
 
 	// parse signature from url query
 	signature := chi.URLParam(r, "signature")
 	if signature == "" {
-		w.WriteHeader(http.StatusBadRequest)
+		com.WriteError(w, http.StatusBadRequest, "missing signature")
 		return
 	}
 
@@ -87,10 +336,21 @@ func (s *Service) GetAll(w http.ResponseWriter, r *http.Request) {
 		offset = 0
 	}
 
+	statuses, err := parseStatuses(r.URL.Query())
+	if err != nil {
+		com.WriteError(w, http.StatusBadRequest, "invalid status")
+		return
+	}
+
 	// get logs from db
-	logs, err := s.db.LogDB.GetAllPaginatedLogs(com.ChecksumAddress(contractAddr), signature, maxDate, limit, offset)
+	logs, err := s.db.LogDB.GetAllPaginatedLogs(r.Context(), com.ChecksumAddress(contractAddr), signature, maxDate, statuses, limit, offset)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		if db.IsQueryTimeout(err) {
+			com.WriteError(w, http.StatusServiceUnavailable, "query timed out")
+			return
+		}
+
+		com.WriteError(w, http.StatusInternalServerError, "error fetching logs")
 		return
 	}
 
@@ -99,7 +359,7 @@ func (s *Service) GetAll(w http.ResponseWriter, r *http.Request) {
 
 	err = com.BodyMultiple(w, logs, com.Pagination{Limit: limit, Offset: offset, Total: total})
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		com.WriteError(w, http.StatusInternalServerError, "error writing response")
 	}
 }
 
@@ -107,14 +367,14 @@ func (s *Service) GetAllNew(w http.ResponseWriter, r *http.Request) {
 	// parse contract address from url params
 	contractAddr := chi.URLParam(r, "contract_address")
 	if contractAddr == "" {
-		w.WriteHeader(http.StatusBadRequest)
+		com.WriteError(w, http.StatusBadRequest, "missing contract address")
 		return
 	}
 
 	// parse signature from url query
 	signature := chi.URLParam(r, "signature")
 	if signature == "" {
-		w.WriteHeader(http.StatusBadRequest)
+		com.WriteError(w, http.StatusBadRequest, "missing signature")
 		return
 	}
 
@@ -141,10 +401,21 @@ func (s *Service) GetAllNew(w http.ResponseWriter, r *http.Request) {
 		offset = 0
 	}
 
+	statuses, err := parseStatuses(r.URL.Query())
+	if err != nil {
+		com.WriteError(w, http.StatusBadRequest, "invalid status")
+		return
+	}
+
 	// get logs from db
-	logs, err := s.db.LogDB.GetAllNewLogs(com.ChecksumAddress(contractAddr), signature, fromDate, limit, offset)
+	logs, err := s.db.LogDB.GetAllNewLogs(r.Context(), com.ChecksumAddress(contractAddr), signature, fromDate, statuses, limit, offset)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		if db.IsQueryTimeout(err) {
+			com.WriteError(w, http.StatusServiceUnavailable, "query timed out")
+			return
+		}
+
+		com.WriteError(w, http.StatusInternalServerError, "error fetching logs")
 		return
 	}
 
@@ -153,7 +424,7 @@ func (s *Service) GetAllNew(w http.ResponseWriter, r *http.Request) {
 
 	err = com.BodyMultiple(w, logs, com.Pagination{Limit: limit, Offset: offset, Total: total})
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		com.WriteError(w, http.StatusInternalServerError, "error writing response")
 	}
 }
 
@@ -163,9 +434,14 @@ func (s *Service) GetAllNew(w http.ResponseWriter, r *http.Request) {
 //		@Description	get transfer logs for a given token and account
 //		@Tags			logs
 //		@Accept			json
-//		@Produce		json
+//		@Produce		json,application/msgpack
 //		@Param			contract_address	path		string	true	"Token Contract Address"
 //	 	@Param			acc_address	path		string	true	"Address of the account"
+//		@Param			status	query		string	false	"Comma separated statuses to filter on, ex: success"
+//		@Param			sender	query		string	false	"Address that the logs are from"
+//		@Param			recipient	query		string	false	"Address that the logs are to"
+//		@Param			maxDate	query		string	false	"Logs created at or before this date (RFC3339), defaults to now"
+//		@Param			fromDate	query		string	false	"Logs created at or after this date (RFC3339), bounds the query with maxDate"
 //		@Success		200	{object}	common.Response
 //		@Failure		400
 //		@Failure		404
@@ -175,14 +451,14 @@ func (s *Service) Get(w http.ResponseWriter, r *http.Request) {
 	// parse contract address from url params
 	contractAddr := chi.URLParam(r, "contract_address")
 	if contractAddr == "" {
-		w.WriteHeader(http.StatusBadRequest)
+		com.WriteError(w, http.StatusBadRequest, "missing contract address")
 		return
 	}
 
 	// parse signature from url query
 	signature := chi.URLParam(r, "signature")
 	if signature == "" {
-		w.WriteHeader(http.StatusBadRequest)
+		com.WriteError(w, http.StatusBadRequest, "missing signature")
 		return
 	}
 
@@ -209,14 +485,54 @@ func (s *Service) Get(w http.ResponseWriter, r *http.Request) {
 		offset = 0
 	}
 
+	statuses, err := parseStatuses(r.URL.Query())
+	if err != nil {
+		com.WriteError(w, http.StatusBadRequest, "invalid status")
+		return
+	}
+
+	addrs, err := parseAddressFilter(r.URL.Query())
+	if err != nil {
+		com.WriteError(w, http.StatusBadRequest, "invalid sender or recipient")
+		return
+	}
+
 	dataFilters := engine.ParseJSONBFilters(r.URL.Query(), "data")
 
 	dataFilters2 := engine.ParseJSONBFilters(r.URL.Query(), "data2")
 
+	fromDate, ranged, err := parseFromDate(r.URL.Query(), maxDate)
+	if err != nil {
+		com.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if ranged && len(dataFilters2) > 0 {
+		com.WriteError(w, http.StatusBadRequest, "data2 filters can't be combined with fromDate")
+		return
+	}
+
 	// get logs from db
-	logs, err := s.db.LogDB.GetPaginatedLogs(com.ChecksumAddress(contractAddr), signature, maxDate, dataFilters, dataFilters2, limit, offset) // TODO: add topics
+	argNames := s.filterArgNames(r.Context(), com.ChecksumAddress(contractAddr), signature, dataFilters, dataFilters2)
+
+	var logs []*engine.Log
+	if ranged {
+		logs, err = s.db.LogDB.GetLogsInRange(r.Context(), com.ChecksumAddress(contractAddr), signature, fromDate, maxDate, addrs, argNames, dataFilters, statuses, limit, offset)
+	} else {
+		logs, err = s.db.LogDB.GetPaginatedLogs(r.Context(), com.ChecksumAddress(contractAddr), signature, maxDate, addrs, argNames, dataFilters, dataFilters2, statuses, limit, offset) // TODO: add topics
+	}
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		if db.IsQueryTimeout(err) {
+			com.WriteError(w, http.StatusServiceUnavailable, "query timed out")
+			return
+		}
+
+		if errors.Is(err, engine.ErrUnknownFilterKey) {
+			com.WriteError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		com.WriteError(w, http.StatusInternalServerError, "error fetching logs")
 		return
 	}
 
@@ -225,7 +541,81 @@ func (s *Service) Get(w http.ResponseWriter, r *http.Request) {
 
 	err = com.BodyMultiple(w, logs, com.Pagination{Limit: limit, Offset: offset, Total: total})
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		com.WriteError(w, http.StatusInternalServerError, "error writing response")
+	}
+}
+
+// GetAccountHistory godoc
+//
+//	@Summary		Fetch the history of an account
+//	@Description	get the logs of a token that an account sent or received, most recent first
+//	@Tags			logs
+//	@Accept			json
+//	@Produce		json,application/msgpack
+//	@Param			contract_address	path		string	true	"Token Contract Address"
+//	@Param			acc_addr	path		string	true	"Address of the account"
+//	@Param			maxDate	query		string	false	"Most recent date of the logs (RFC3339), defaults to now"
+//	@Success		200	{object}	common.Response
+//	@Failure		400
+//	@Failure		500
+//	@Failure		503
+//	@Router			/logs/{contract_address}/account/{acc_addr} [get]
+func (s *Service) GetAccountHistory(w http.ResponseWriter, r *http.Request) {
+	// parse contract address from url params
+	contractAddr := chi.URLParam(r, "contract_address")
+	if contractAddr == "" {
+		com.WriteError(w, http.StatusBadRequest, "missing contract address")
+		return
+	}
+
+	// parse account address from url params
+	accAddr, err := com.NormalizeAddress(chi.URLParam(r, "acc_addr"))
+	if err != nil {
+		com.WriteError(w, http.StatusBadRequest, "invalid account address")
+		return
+	}
+
+	// parse maxDate from url query
+	maxDateq, _ := url.QueryUnescape(r.URL.Query().Get("maxDate"))
+
+	t, err := time.Parse(time.RFC3339, maxDateq)
+	if err != nil {
+		t = time.Now()
+	}
+	maxDate := t.UTC()
+
+	// parse pagination params from url query
+	limitq := r.URL.Query().Get("limit")
+	offsetq := r.URL.Query().Get("offset")
+
+	limit, err := strconv.Atoi(limitq)
+	if err != nil {
+		limit = 20
+	}
+
+	offset, err := strconv.Atoi(offsetq)
+	if err != nil {
+		offset = 0
+	}
+
+	// get logs from db
+	logs, err := s.db.LogDB.GetAccountHistory(r.Context(), com.ChecksumAddress(contractAddr), accAddr, maxDate, limit, offset)
+	if err != nil {
+		if db.IsQueryTimeout(err) {
+			com.WriteError(w, http.StatusServiceUnavailable, "query timed out")
+			return
+		}
+
+		com.WriteError(w, http.StatusInternalServerError, "error fetching logs")
+		return
+	}
+
+	// TODO: remove legacy support
+	total := offset + limit
+
+	err = com.BodyMultiple(w, logs, com.Pagination{Limit: limit, Offset: offset, Total: total})
+	if err != nil {
+		com.WriteError(w, http.StatusInternalServerError, "error writing response")
 	}
 }
 
@@ -233,14 +623,14 @@ func (s *Service) GetNew(w http.ResponseWriter, r *http.Request) {
 	// parse contract address from url params
 	contractAddr := chi.URLParam(r, "contract_address")
 	if contractAddr == "" {
-		w.WriteHeader(http.StatusBadRequest)
+		com.WriteError(w, http.StatusBadRequest, "missing contract address")
 		return
 	}
 
 	// parse signature from url query
 	signature := chi.URLParam(r, "signature")
 	if signature == "" {
-		w.WriteHeader(http.StatusBadRequest)
+		com.WriteError(w, http.StatusBadRequest, "missing signature")
 		return
 	}
 
@@ -267,14 +657,38 @@ func (s *Service) GetNew(w http.ResponseWriter, r *http.Request) {
 		offset = 0
 	}
 
+	statuses, err := parseStatuses(r.URL.Query())
+	if err != nil {
+		com.WriteError(w, http.StatusBadRequest, "invalid status")
+		return
+	}
+
+	addrs, err := parseAddressFilter(r.URL.Query())
+	if err != nil {
+		com.WriteError(w, http.StatusBadRequest, "invalid sender or recipient")
+		return
+	}
+
 	dataFilters := engine.ParseJSONBFilters(r.URL.Query(), "data")
 
 	dataFilters2 := engine.ParseJSONBFilters(r.URL.Query(), "data2")
 
 	// get logs from db
-	logs, err := s.db.LogDB.GetNewLogs(com.ChecksumAddress(contractAddr), signature, fromDate, dataFilters, dataFilters2, limit, offset)
+	argNames := s.filterArgNames(r.Context(), com.ChecksumAddress(contractAddr), signature, dataFilters, dataFilters2)
+
+	logs, err := s.db.LogDB.GetNewLogs(r.Context(), com.ChecksumAddress(contractAddr), signature, fromDate, addrs, argNames, dataFilters, dataFilters2, statuses, limit, offset)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		if db.IsQueryTimeout(err) {
+			com.WriteError(w, http.StatusServiceUnavailable, "query timed out")
+			return
+		}
+
+		if errors.Is(err, engine.ErrUnknownFilterKey) {
+			com.WriteError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		com.WriteError(w, http.StatusInternalServerError, "error fetching logs")
 		return
 	}
 
@@ -283,6 +697,58 @@ func (s *Service) GetNew(w http.ResponseWriter, r *http.Request) {
 
 	err = com.BodyMultiple(w, logs, com.Pagination{Limit: limit, Offset: offset, Total: total})
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		com.WriteError(w, http.StatusInternalServerError, "error writing response")
+	}
+}
+
+type pollMeta struct {
+	Cursor string `json:"cursor"`
+}
+
+// Poll waits for new logs of a contract and topic that match the data filters, for clients that can't keep a websocket open
+//
+// the logs that were broadcast after the since cursor are returned immediately, without a cursor only new logs are returned,
+// the cursor for the next poll is in the meta of the response
+func (s *Service) Poll(w http.ResponseWriter, r *http.Request) {
+	// parse contract address from url params
+	contractAddr := chi.URLParam(r, "contract_address")
+	if contractAddr == "" {
+		com.WriteError(w, http.StatusBadRequest, "missing contract address")
+		return
+	}
+
+	// parse signature from url query
+	signature := chi.URLParam(r, "signature")
+	if signature == "" {
+		com.WriteError(w, http.StatusBadRequest, "missing signature")
+		return
+	}
+
+	q := r.URL.Query()
+
+	cursor := s.pools.Cursor()
+	if sinceq := q.Get("since"); sinceq != "" {
+		since, err := strconv.ParseUint(sinceq, 10, 64)
+		if err != nil {
+			com.WriteError(w, http.StatusBadRequest, "invalid since cursor")
+			return
+		}
+
+		cursor = since
+	}
+
+	// the remaining params are the data filters, like the query of a websocket client
+	q.Del("since")
+
+	ctx, cancel := context.WithTimeout(r.Context(), s.pollTimeout)
+	defer cancel()
+
+	topic := fmt.Sprintf("%s/%s", com.ChecksumAddress(contractAddr), signature)
+
+	msgs, next := s.pools.Poll(ctx, topic, q.Encode(), cursor)
+
+	err := com.BodyMultiple(w, msgs, pollMeta{Cursor: strconv.FormatUint(next, 10)})
+	if err != nil {
+		com.WriteError(w, http.StatusInternalServerError, "error writing response")
 	}
 }
@@ -0,0 +1,201 @@
+package logs
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/citizenwallet/engine/internal/ws"
+	"github.com/citizenwallet/engine/pkg/engine"
+	"github.com/go-chi/chi/v5"
+)
+
+func TestParseStatuses(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		want    []engine.LogStatus
+		wantErr bool
+	}{
+		{"no status", "", []engine.LogStatus{}, false},
+		{"single status", "status=success", []engine.LogStatus{engine.LogStatusSuccess}, false},
+		{"several statuses", "status=success,%20pending", []engine.LogStatus{engine.LogStatusSuccess, engine.LogStatusPending}, false},
+		{"unknown status", "status=confirmed", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := parseStatuses(q)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseStatuses() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseStatuses() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseAddressFilter(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		want    engine.AddressFilter
+		wantErr bool
+	}{
+		{"no addresses", "", engine.AddressFilter{}, false},
+		{"sender", "sender=0x5566d6d4df27a6fd7856b7564f848b3f57e8f706", engine.AddressFilter{Sender: "0x5566d6d4DF27a6fd7856b7564F848b3f57e8f706"}, false},
+		{"sender and recipient", "sender=0x5566d6d4DF27a6fd7856b7564F848b3f57e8f706&recipient=0x0000000000000000000000000000000000000001", engine.AddressFilter{Sender: "0x5566d6d4DF27a6fd7856b7564F848b3f57e8f706", Recipient: "0x0000000000000000000000000000000000000001"}, false},
+		{"invalid recipient", "recipient=0x1234", engine.AddressFilter{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := parseAddressFilter(q)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseAddressFilter() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("parseAddressFilter() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetSingle_InvalidHash(t *testing.T) {
+	s := NewService(nil, nil, nil, nil, DefaultPollTimeout)
+
+	cr := chi.NewRouter()
+	cr.Get("/logs/{hash}", s.GetSingle)
+
+	hashes := []string{
+		"0x1234",
+		"1b86e5ea2c7b2a5fa2d5c8fd5e4a4bba7cde0e3a1c3f9a3dba5cf50d1b6b4c8d",
+		"0xzz86e5ea2c7b2a5fa2d5c8fd5e4a4bba7cde0e3a1c3f9a3dba5cf50d1b6b4c8d",
+		"0x1b86e5ea2c7b2a5fa2d5c8fd5e4a4bba7cde0e3a1c3f9a3dba5cf50d1b6b4c8d00",
+	}
+
+	for _, hash := range hashes {
+		w := httptest.NewRecorder()
+		cr.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/logs/"+hash, nil))
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected %d, got %d", hash, http.StatusBadRequest, w.Code)
+		}
+	}
+}
+
+func TestGetByTxHash_InvalidParams(t *testing.T) {
+	s := NewService(nil, nil, nil, nil, DefaultPollTimeout)
+
+	cr := chi.NewRouter()
+	cr.Get("/logs/{contract_address}/txs/{tx_hash}", s.GetByTxHash)
+
+	paths := []string{
+		"/logs/0x123/txs/0x1b86e5ea2c7b2a5fa2d5c8fd5e4a4bba7cde0e3a1c3f9a3dba5cf50d1b6b4c8d",
+		"/logs/0x5815E61eF72c9E6107b5c5A05FD121F334f7a7f1/txs/0x1b86",
+	}
+
+	for _, path := range paths {
+		w := httptest.NewRecorder()
+		cr.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected %d, got %d", path, http.StatusBadRequest, w.Code)
+		}
+	}
+}
+
+func TestGetContractLogs_InvalidParams(t *testing.T) {
+	s := NewService(nil, nil, nil, nil, DefaultPollTimeout)
+
+	cr := chi.NewRouter()
+	cr.Get("/logs/{contract_address}", s.GetContractLogs)
+
+	paths := []string{
+		"/logs/0x123",
+		"/logs/0x5815E61eF72c9E6107b5c5A05FD121F334f7a7f1?topic=Transfer",
+		"/logs/0x5815E61eF72c9E6107b5c5A05FD121F334f7a7f1?status=confirmed",
+		"/logs/0x5815E61eF72c9E6107b5c5A05FD121F334f7a7f1?sender=0x1",
+	}
+
+	for _, path := range paths {
+		w := httptest.NewRecorder()
+		cr.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected %d, got %d", path, http.StatusBadRequest, w.Code)
+		}
+	}
+}
+
+func TestParseFromDate(t *testing.T) {
+	maxDate := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		query   string
+		want    time.Time
+		ranged  bool
+		wantErr bool
+	}{
+		{"no range", "", time.Time{}, false, false},
+		{"month", "fromDate=2024-01-01T00:00:00Z", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), true, false},
+		{"same date", "fromDate=2024-02-01T00:00:00Z", maxDate, true, false},
+		{"after maxDate", "fromDate=2024-02-01T00:00:01Z", time.Time{}, false, true},
+		{"invalid", "fromDate=yesterday", time.Time{}, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, ranged, err := parseFromDate(q, maxDate)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseFromDate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if !got.Equal(tt.want) || ranged != tt.ranged {
+				t.Errorf("parseFromDate() = %v, %t, want %v, %t", got, ranged, tt.want, tt.ranged)
+			}
+		})
+	}
+}
+
+func TestPollTimeout(t *testing.T) {
+	s := NewService(nil, nil, nil, ws.NewConnectionPools(), 50*time.Millisecond)
+
+	cr := chi.NewRouter()
+	cr.Get("/logs/{contract_address}/{signature}/poll", s.Poll)
+
+	start := time.Now()
+
+	w := httptest.NewRecorder()
+	cr.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/logs/0x5815E61eF72c9E6107b5c5A05FD121F334f7a7f1/0xtopic/poll", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected %d, got %d", http.StatusOK, w.Code)
+	}
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected the poll to give up after its timeout, it took %s", elapsed)
+	}
+}
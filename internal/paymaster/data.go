@@ -0,0 +1,88 @@
+package paymaster
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// paymasterAndData is laid out as [address(20)][validity(64)][signature(65)]
+const (
+	VALID_TIMESTAMP_OFFSET = common.AddressLength
+	SIGNATURE_OFFSET       = VALID_TIMESTAMP_OFFSET + 64
+	SIGNATURE_LENGTH       = crypto.SignatureLength
+)
+
+var (
+	ErrPaymasterDataTooShort = errors.New("paymaster and data is too short")
+	ErrInvalidSignatureLen   = errors.New("paymaster signature must be 65 bytes")
+	ErrInvalidValidity       = errors.New("paymaster validity is out of range")
+)
+
+var validityArgs = func() abi.Arguments {
+	uint48Ty, _ := abi.NewType("uint48", "uint48", nil)
+
+	return abi.Arguments{
+		abi.Argument{
+			Type: uint48Ty,
+		},
+		abi.Argument{
+			Type: uint48Ty,
+		},
+	}
+}()
+
+// ParsePaymasterAndData splits the paymaster and data of a user operation into its paymaster, validity period and signature
+func ParsePaymasterAndData(data []byte) (common.Address, *big.Int, *big.Int, []byte, error) {
+	if len(data) < SIGNATURE_OFFSET {
+		return common.Address{}, nil, nil, nil, ErrPaymasterDataTooShort
+	}
+
+	if len(data)-SIGNATURE_OFFSET != SIGNATURE_LENGTH {
+		return common.Address{}, nil, nil, nil, ErrInvalidSignatureLen
+	}
+
+	validity, err := validityArgs.Unpack(data[VALID_TIMESTAMP_OFFSET:SIGNATURE_OFFSET])
+	if err != nil {
+		return common.Address{}, nil, nil, nil, ErrInvalidValidity
+	}
+
+	validUntil, ok := validity[0].(*big.Int)
+	if !ok || validUntil.BitLen() > 48 {
+		return common.Address{}, nil, nil, nil, ErrInvalidValidity
+	}
+
+	validAfter, ok := validity[1].(*big.Int)
+	if !ok || validAfter.BitLen() > 48 {
+		return common.Address{}, nil, nil, nil, ErrInvalidValidity
+	}
+
+	sig := make([]byte, SIGNATURE_LENGTH)
+	copy(sig, data[SIGNATURE_OFFSET:])
+
+	return common.BytesToAddress(data[:VALID_TIMESTAMP_OFFSET]), validUntil, validAfter, sig, nil
+}
+
+// EncodePaymasterAndData builds the paymaster and data of a user operation, the result is checked with ParsePaymasterAndData
+func EncodePaymasterAndData(addr common.Address, validUntil, validAfter *big.Int, sig []byte) ([]byte, error) {
+	if validUntil.BitLen() > 48 || validAfter.BitLen() > 48 {
+		return nil, ErrInvalidValidity
+	}
+
+	validity, err := validityArgs.Pack(validUntil, validAfter)
+	if err != nil {
+		return nil, err
+	}
+
+	data := append(addr.Bytes(), validity...)
+	data = append(data, sig...)
+
+	if _, _, _, _, err := ParsePaymasterAndData(data); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
@@ -0,0 +1,67 @@
+package paymaster
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestPaymasterAndData(t *testing.T) {
+	addr := common.HexToAddress("0x5651B04d5f3E7fE2B4d468b0ae7Ecd1d2bA3F2cd")
+	validUntil, validAfter := big.NewInt(1700000060), big.NewInt(1699999990)
+	sig := bytes.Repeat([]byte{0xab}, SIGNATURE_LENGTH)
+
+	data, err := EncodePaymasterAndData(addr, validUntil, validAfter, sig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(data) != SIGNATURE_OFFSET+SIGNATURE_LENGTH {
+		t.Fatalf("expected %d bytes, got %d", SIGNATURE_OFFSET+SIGNATURE_LENGTH, len(data))
+	}
+
+	paddr, until, after, psig, err := ParsePaymasterAndData(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if paddr != addr || until.Cmp(validUntil) != 0 || after.Cmp(validAfter) != 0 || !bytes.Equal(psig, sig) {
+		t.Errorf("expected the paymaster and data to round trip, got %s %s %s %x", paddr.Hex(), until, after, psig)
+	}
+
+	// the signature is a copy, callers can adjust v without touching the user operation
+	psig[0] = 0
+	if data[SIGNATURE_OFFSET] != 0xab {
+		t.Error("expected the signature to be copied")
+	}
+
+	outOfRange := append([]byte{}, data...)
+	outOfRange[VALID_TIMESTAMP_OFFSET+25] = 0x01 // bit 48 of validUntil
+
+	tests := []struct {
+		name string
+		data []byte
+		err  error
+	}{
+		{"empty", nil, ErrPaymasterDataTooShort},
+		{"no signature", data[:SIGNATURE_OFFSET-1], ErrPaymasterDataTooShort},
+		{"short signature", data[:len(data)-1], ErrInvalidSignatureLen},
+		{"long signature", append(append([]byte{}, data...), 0x00), ErrInvalidSignatureLen},
+		{"validity out of range", outOfRange, ErrInvalidValidity},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, _, _, err := ParsePaymasterAndData(tt.data)
+			if err != tt.err {
+				t.Errorf("expected %v, got %v", tt.err, err)
+			}
+		})
+	}
+
+	if _, err := EncodePaymasterAndData(addr, new(big.Int).Lsh(big.NewInt(1), 48), validAfter, sig); err != ErrInvalidValidity {
+		t.Errorf("expected %v when encoding, got %v", ErrInvalidValidity, err)
+	}
+}
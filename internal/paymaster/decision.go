@@ -0,0 +1,79 @@
+package paymaster
+
+import (
+	"errors"
+	"math/big"
+)
+
+type DecisionStatus string
+
+const (
+	DecisionAccepted DecisionStatus = "accepted"
+	DecisionRejected DecisionStatus = "rejected"
+)
+
+type RejectReason string
+
+const (
+	RejectInvalidUserOp        RejectReason = "invalid_user_op"
+	RejectPaymasterNotDeployed RejectReason = "paymaster_not_deployed"
+	RejectNoSponsor            RejectReason = "no_sponsor"
+	RejectInternal             RejectReason = "internal"
+)
+
+// Decision explains why a user operation was or wasn't sponsored, it is returned as the data of a rejection
+// and as part of the response when the paymaster context asks for debug info
+type Decision struct {
+	Status     DecisionStatus `json:"status"`
+	Reason     RejectReason   `json:"reason,omitempty"`
+	ValidUntil int64          `json:"validUntil,omitempty"`
+	ValidAfter int64          `json:"validAfter,omitempty"`
+}
+
+func accepted(validUntil, validAfter *big.Int) *Decision {
+	return &Decision{
+		Status:     DecisionAccepted,
+		ValidUntil: validUntil.Int64(),
+		ValidAfter: validAfter.Int64(),
+	}
+}
+
+// RejectedError is a JSON RPC error that carries the decision as its data
+type RejectedError struct {
+	Decision Decision
+	err      error
+}
+
+func (e *RejectedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *RejectedError) Unwrap() error {
+	return e.err
+}
+
+// ErrorCode keeps the generic server error code that clients already handle
+func (e *RejectedError) ErrorCode() int {
+	return -32000
+}
+
+func (e *RejectedError) ErrorData() any {
+	return e.Decision
+}
+
+func reject(reason RejectReason, err error) error {
+	return &RejectedError{
+		Decision: Decision{Status: DecisionRejected, Reason: reason},
+		err:      err,
+	}
+}
+
+// rejection makes sure that every error of the sponsor methods carries a decision, untagged errors are internal
+func rejection(err error) error {
+	var rerr *RejectedError
+	if errors.As(err, &rerr) {
+		return err
+	}
+
+	return reject(RejectInternal, err)
+}
@@ -0,0 +1,56 @@
+package paymaster
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	comm "github.com/citizenwallet/engine/pkg/common"
+)
+
+func TestRejection(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		reason RejectReason
+	}{
+		{"tagged", reject(RejectNoSponsor, errors.New("error not allowed to operate this paymaster")), RejectNoSponsor},
+		{"untagged", errors.New("error signing hash"), RejectInternal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rejected := rejection(tt.err)
+
+			var rerr *RejectedError
+			if !errors.As(rejected, &rerr) {
+				t.Fatalf("expected a rejected error, got %T", rejected)
+			}
+
+			if rerr.Decision.Status != DecisionRejected || rerr.Decision.Reason != tt.reason {
+				t.Errorf("expected rejected/%s, got %+v", tt.reason, rerr.Decision)
+			}
+
+			// the decision is sent as the data of the json rpc error
+			b, err := json.Marshal(comm.NewJSONRPCResponse(1, nil, rejected))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var resp struct {
+				Error struct {
+					Code    int      `json:"code"`
+					Message string   `json:"message"`
+					Data    Decision `json:"data"`
+				} `json:"error"`
+			}
+			if err := json.Unmarshal(b, &resp); err != nil {
+				t.Fatal(err)
+			}
+
+			if resp.Error.Code != -32000 || resp.Error.Message != tt.err.Error() || resp.Error.Data.Reason != tt.reason {
+				t.Errorf("unexpected response %s", b)
+			}
+		})
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"io"
 	"math/big"
 	"net/http"
 	"strconv"
@@ -42,17 +43,33 @@ func NewService(evm engine.EVMRequester, db *db.DB) *Service {
 }
 
 type paymasterType struct {
-	Type string `json:"type"`
+	Type  string `json:"type"`
+	Debug bool   `json:"debug"` // adds the sponsorship decision to the response
 }
 
 type paymasterData struct {
-	PaymasterAndData     string `json:"paymasterAndData"`
-	PreVerificationGas   string `json:"preVerificationGas"`
-	VerificationGasLimit string `json:"verificationGasLimit"`
-	CallGasLimit         string `json:"callGasLimit"`
+	PaymasterAndData     string    `json:"paymasterAndData"`
+	PreVerificationGas   string    `json:"preVerificationGas"`
+	VerificationGasLimit string    `json:"verificationGasLimit"`
+	CallGasLimit         string    `json:"callGasLimit"`
+	Sponsorship          *Decision `json:"sponsorship,omitempty"`
 }
 
+// Sponsor signs the paymaster data of a user operation for the next minute
+//
+// the user operation is sent with its own nonce, which follows the sequence of its nonce key
+//
+// a rejection carries the reason as its error data, {"debug": true} in the paymaster context also returns the accepted decision
 func (s *Service) Sponsor(r *http.Request) (any, error) {
+	pd, err := s.sponsor(r)
+	if err != nil {
+		return nil, rejection(err)
+	}
+
+	return pd, nil
+}
+
+func (s *Service) sponsor(r *http.Request) (*paymasterData, error) {
 	// parse contract address from url params
 	contractAddr := chi.URLParam(r, "pm_address")
 
@@ -66,7 +83,7 @@ func (s *Service) Sponsor(r *http.Request) (any, error) {
 
 	// Check if the contract is deployed
 	if len(bytecode) == 0 {
-		return nil, errors.New("paymaster contract not deployed")
+		return nil, reject(RejectPaymasterNotDeployed, errors.New("paymaster contract not deployed"))
 	}
 
 	// instantiate paymaster contract
@@ -80,7 +97,7 @@ func (s *Service) Sponsor(r *http.Request) (any, error) {
 	var params []any
 	err = json.NewDecoder(r.Body).Decode(&params)
 	if err != nil {
-		return nil, err
+		return nil, reject(RejectInvalidUserOp, err)
 	}
 
 	var userop engine.UserOp
@@ -92,61 +109,54 @@ func (s *Service) Sponsor(r *http.Request) (any, error) {
 		case 0:
 			v, ok := param.(map[string]interface{})
 			if !ok {
-				return nil, errors.New("error parsing user operation")
+				return nil, reject(RejectInvalidUserOp, errors.New("error parsing user operation"))
 			}
 			b, err := json.Marshal(v)
 			if err != nil {
-				return nil, err
+				return nil, reject(RejectInvalidUserOp, err)
 			}
 
 			err = json.Unmarshal(b, &userop)
 			if err != nil {
-				return nil, err
+				return nil, reject(RejectInvalidUserOp, err)
 			}
 		case 1:
 			v, ok := param.(string)
 			if !ok {
-				return nil, errors.New("error parsing entrypoint address")
+				return nil, reject(RejectInvalidUserOp, errors.New("error parsing entrypoint address"))
 			}
 
 			epAddr = v
 		case 2:
 			v, ok := param.(map[string]interface{})
 			if !ok {
-				return nil, errors.New("error parsing paymaster type")
+				return nil, reject(RejectInvalidUserOp, errors.New("error parsing paymaster type"))
 			}
 
 			b, err := json.Marshal(v)
 			if err != nil {
-				return nil, errors.New("error marshalling paymaster type")
+				return nil, reject(RejectInvalidUserOp, errors.New("error marshalling paymaster type"))
 			}
 
 			err = json.Unmarshal(b, &pt)
 			if err != nil {
-				return nil, errors.New("error unmarshalling paymaster type")
+				return nil, reject(RejectInvalidUserOp, errors.New("error unmarshalling paymaster type"))
 			}
 		}
 	}
 
 	if epAddr == "" {
-		return nil, errors.New("error entrypoint address is empty")
+		return nil, reject(RejectInvalidUserOp, errors.New("error entrypoint address is empty"))
 	}
 
-	// verify the nonce
-
-	// get nonce using the account factory since we are not sure if the account has been created yet
-	nonce := userop.Nonce
-
-	// verify the init code
-	initCode := hexutil.Encode(userop.InitCode)
-
-	// if the nonce is not 0, then the init code should be empty
-	if nonce.Cmp(big.NewInt(0)) == 1 && initCode != "0x" {
-		return nil, errors.New("error init code is not empty even though nonce is not 0")
+	// verify the nonce and the init code
+	deploys, err := verifyInitCode(userop.Nonce, userop.InitCode)
+	if err != nil {
+		return nil, reject(RejectInvalidUserOp, err)
 	}
 
-	// if the nonce is 0, then check that the factory exists
-	if nonce.Cmp(big.NewInt(0)) == 0 && len(userop.InitCode) > 20 {
+	// if the account is deployed by this user operation, then check that the factory exists
+	if deploys {
 		factoryaddr := common.BytesToAddress(userop.InitCode[:20])
 
 		// Get the contract's bytecode
@@ -157,18 +167,18 @@ func (s *Service) Sponsor(r *http.Request) (any, error) {
 
 		// Check if the contract is deployed
 		if len(bytecode) == 0 {
-			return nil, errors.New("error factory contract not found")
+			return nil, reject(RejectInvalidUserOp, errors.New("error factory contract not found"))
 		}
 	}
 
 	if len(userop.CallData) < 4 {
-		return nil, errors.New("error call data is too short")
+		return nil, reject(RejectInvalidUserOp, errors.New("error call data is too short"))
 	}
 
 	// verify the calldata, it should only be allowed to contain the function signatures we allow
 	funcSig := userop.CallData[:4]
 	if !bytes.Equal(funcSig, engine.FuncSigSingle) && !bytes.Equal(funcSig, engine.FuncSigBatch) && !bytes.Equal(funcSig, engine.FuncSigSafeExecFromModule) {
-		return nil, errors.New("error invalid function signature. supported signatures: execute, executeBatch, execTransactionFromModule")
+		return nil, reject(RejectInvalidUserOp, errors.New("error invalid function signature. supported signatures: execute, executeBatch, execTransactionFromModule"))
 	}
 
 	addressArg, _ := abi.NewType("address", "address", nil)
@@ -207,26 +217,26 @@ func (s *Service) Sponsor(r *http.Request) (any, error) {
 	// Unpack the values
 	callValues, err := callArgs.Unpack(userop.CallData[4:])
 	if err != nil {
-		return nil, err
+		return nil, reject(RejectInvalidUserOp, err)
 	}
 
 	// destination address
 	_, ok := callValues[0].(common.Address)
 	if !ok {
-		return nil, errors.New("error invalid destination address")
+		return nil, reject(RejectInvalidUserOp, errors.New("error invalid destination address"))
 	}
 
 	// value in uint256
 	callValue, ok := callValues[1].(*big.Int)
 	if !ok || callValue.Cmp(big.NewInt(0)) != 0 {
 		// shouldn't have any value
-		return nil, errors.New("error invalid call value")
+		return nil, reject(RejectInvalidUserOp, errors.New("error invalid call value"))
 	}
 
 	// data in bytes
 	_, ok = callValues[2].([]byte)
 	if !ok {
-		return nil, errors.New("error invalid call data")
+		return nil, reject(RejectInvalidUserOp, errors.New("error invalid call data"))
 	}
 
 	// validity period
@@ -239,23 +249,6 @@ func (s *Service) Sponsor(r *http.Request) (any, error) {
 		return nil, errors.New("error invalid validity period")
 	}
 
-	// Define the arguments
-	uint48Ty, _ := abi.NewType("uint48", "uint48", nil)
-	args := abi.Arguments{
-		abi.Argument{
-			Type: uint48Ty,
-		},
-		abi.Argument{
-			Type: uint48Ty,
-		},
-	}
-
-	// Encode the values
-	validity, err := args.Pack(validUntil, validAfter)
-	if err != nil {
-		return nil, err
-	}
-
 	hash, err := pm.GetHash(nil, pay.UserOperation(userop), validUntil, validAfter)
 	if err != nil {
 		return nil, err
@@ -267,7 +260,7 @@ func (s *Service) Sponsor(r *http.Request) (any, error) {
 	// fetch the sponsor's corresponding private key from the db
 	sponsorKey, err := s.db.SponsorDB.GetSponsor(addr.Hex())
 	if err != nil {
-		return nil, errors.New("error not allowed to operate this paymaster")
+		return nil, reject(RejectNoSponsor, errors.New("error not allowed to operate this paymaster"))
 	}
 
 	// Generate ecdsa.PrivateKey from bytes
@@ -286,8 +279,10 @@ func (s *Service) Sponsor(r *http.Request) (any, error) {
 		sig[crypto.RecoveryIDOffset] += 27
 	}
 
-	data := append(addr.Bytes(), validity...)
-	data = append(data, sig...)
+	data, err := EncodePaymasterAndData(addr, validUntil, validAfter, sig)
+	if err != nil {
+		return nil, err
+	}
 
 	pd := &paymasterData{
 		PaymasterAndData:     hexutil.Encode(data),
@@ -296,11 +291,29 @@ func (s *Service) Sponsor(r *http.Request) (any, error) {
 		CallGasLimit:         hexutil.EncodeBig(userop.CallGasLimit),
 	}
 
+	if pt.Debug {
+		pd.Sponsorship = accepted(validUntil, validAfter)
+	}
+
 	return pd, nil
 }
 
 // OOSponsor generates multiple signatures that can be used to send user operations in the future
+//
+// unlike Sponsor the nonce of the user operation is ignored, every copy gets a nonce with a key of its own
+// and a sequence of 0 so that the copies can be sent in any order and don't invalidate each other
+//
+// a rejection carries the reason as its error data
 func (s *Service) OOSponsor(r *http.Request) (any, error) {
+	userops, err := s.ooSponsor(r)
+	if err != nil {
+		return nil, rejection(err)
+	}
+
+	return userops, nil
+}
+
+func (s *Service) ooSponsor(r *http.Request) ([]*engine.UserOp, error) {
 	// parse contract address from url params
 	contractAddr := chi.URLParam(r, "pm_address")
 
@@ -314,7 +327,7 @@ func (s *Service) OOSponsor(r *http.Request) (any, error) {
 
 	// Check if the contract is deployed
 	if len(bytecode) == 0 {
-		return nil, errors.New("error paymaster contract not deployed")
+		return nil, reject(RejectPaymasterNotDeployed, errors.New("error paymaster contract not deployed"))
 	}
 
 	// instantiate paymaster contract
@@ -328,7 +341,7 @@ func (s *Service) OOSponsor(r *http.Request) (any, error) {
 	var params []any
 	err = json.NewDecoder(r.Body).Decode(&params)
 	if err != nil {
-		return nil, err
+		return nil, reject(RejectInvalidUserOp, err)
 	}
 
 	var userop engine.UserOp
@@ -341,38 +354,38 @@ func (s *Service) OOSponsor(r *http.Request) (any, error) {
 		case 0:
 			v, ok := param.(map[string]interface{})
 			if !ok {
-				return nil, errors.New("error parsing user operation")
+				return nil, reject(RejectInvalidUserOp, errors.New("error parsing user operation"))
 			}
 			b, err := json.Marshal(v)
 			if err != nil {
-				return nil, err
+				return nil, reject(RejectInvalidUserOp, err)
 			}
 
 			err = json.Unmarshal(b, &userop)
 			if err != nil {
-				return nil, err
+				return nil, reject(RejectInvalidUserOp, err)
 			}
 		case 1:
 			v, ok := param.(string)
 			if !ok {
-				return nil, errors.New("error parsing entrypoint address")
+				return nil, reject(RejectInvalidUserOp, errors.New("error parsing entrypoint address"))
 			}
 
 			epAddr = v
 		case 2:
 			v, ok := param.(map[string]interface{})
 			if !ok {
-				return nil, errors.New("error parsing paymaster type")
+				return nil, reject(RejectInvalidUserOp, errors.New("error parsing paymaster type"))
 			}
 
 			b, err := json.Marshal(v)
 			if err != nil {
-				return nil, errors.New("error marshalling paymaster type")
+				return nil, reject(RejectInvalidUserOp, errors.New("error marshalling paymaster type"))
 			}
 
 			err = json.Unmarshal(b, &pt)
 			if err != nil {
-				return nil, err
+				return nil, reject(RejectInvalidUserOp, err)
 			}
 		case 3:
 			v, ok := param.(float64) // json marshalling converts numbers to float64
@@ -393,14 +406,17 @@ func (s *Service) OOSponsor(r *http.Request) (any, error) {
 	}
 
 	if epAddr == "" {
-		return nil, errors.New("error entrypoint address is empty")
+		return nil, reject(RejectInvalidUserOp, errors.New("error entrypoint address is empty"))
+	}
+
+	if len(userop.CallData) < 4 {
+		return nil, reject(RejectInvalidUserOp, errors.New("error call data is too short"))
 	}
 
 	// verify the calldata, it should only be allowed to contain the function signatures we allow
 	funcSig := userop.CallData[:4]
 	if !bytes.Equal(funcSig, engine.FuncSigSingle) && !bytes.Equal(funcSig, engine.FuncSigBatch) && !bytes.Equal(funcSig, engine.FuncSigSafeExecFromModule) {
-		return nil, errors.New("error invalid function signature. supported signatures: execute, executeBatch, execTransactionFromModule")
-
+		return nil, reject(RejectInvalidUserOp, errors.New("error invalid function signature. supported signatures: execute, executeBatch, execTransactionFromModule"))
 	}
 
 	addressArg, _ := abi.NewType("address", "address", nil)
@@ -439,26 +455,26 @@ func (s *Service) OOSponsor(r *http.Request) (any, error) {
 	// Unpack the values
 	callValues, err := callArgs.Unpack(userop.CallData[4:])
 	if err != nil {
-		return nil, err
+		return nil, reject(RejectInvalidUserOp, err)
 	}
 
 	// destination address
 	_, ok := callValues[0].(common.Address)
 	if !ok {
-		return nil, errors.New("error invalid destination address")
+		return nil, reject(RejectInvalidUserOp, errors.New("error invalid destination address"))
 	}
 
 	// value in uint256
 	callValue, ok := callValues[1].(*big.Int)
 	if !ok || callValue.Cmp(big.NewInt(0)) != 0 {
 		// shouldn't have any value
-		return nil, errors.New("error invalid call value")
+		return nil, reject(RejectInvalidUserOp, errors.New("error invalid call value"))
 	}
 
 	// data in bytes
 	_, ok = callValues[2].([]byte)
 	if !ok {
-		return nil, errors.New("error invalid call data")
+		return nil, reject(RejectInvalidUserOp, errors.New("error invalid call data"))
 	}
 
 	// validity period
@@ -472,27 +488,10 @@ func (s *Service) OOSponsor(r *http.Request) (any, error) {
 		return nil, errors.New("error invalid validity period")
 	}
 
-	// Define the arguments
-	uint48Ty, _ := abi.NewType("uint48", "uint48", nil)
-	args := abi.Arguments{
-		abi.Argument{
-			Type: uint48Ty,
-		},
-		abi.Argument{
-			Type: uint48Ty,
-		},
-	}
-
-	// Encode the values
-	validity, err := args.Pack(validUntil, validAfter)
-	if err != nil {
-		return nil, err
-	}
-
 	// fetch the sponsor's corresponding private key from the db
 	sponsorKey, err := s.db.SponsorDB.GetSponsor(addr.Hex())
 	if err != nil {
-		return nil, errors.New("error not allowed to operate this paymaster")
+		return nil, reject(RejectNoSponsor, errors.New("error not allowed to operate this paymaster"))
 	}
 
 	// Generate ecdsa.PrivateKey from bytes
@@ -504,15 +503,15 @@ func (s *Service) OOSponsor(r *http.Request) (any, error) {
 	userops := []*engine.UserOp{}
 
 	// generate an amount of nonces equivalent to the amount requested
-	for i := 0; i < amount; i++ {
-		op := userop.Copy()
+	nonces, err := newOONonces(amount)
+	if err != nil {
+		return nil, errors.New("error generating nonce")
+	}
 
-		nonce, err := comm.NewNonce()
-		if err != nil {
-			return nil, errors.New("error generating nonce")
-		}
+	for _, nonce := range nonces {
+		op := userop.Copy()
 
-		op.Nonce = nonce.BigInt()
+		op.Nonce = nonce
 
 		hash, err := pm.GetHash(nil, pay.UserOperation(op), validUntil, validAfter)
 		if err != nil {
@@ -532,8 +531,10 @@ func (s *Service) OOSponsor(r *http.Request) (any, error) {
 			sig[crypto.RecoveryIDOffset] += 27
 		}
 
-		data := append(addr.Bytes(), validity...)
-		data = append(data, sig...)
+		data, err := EncodePaymasterAndData(addr, validUntil, validAfter, sig)
+		if err != nil {
+			return nil, err
+		}
 
 		op.PaymasterAndData = data
 
@@ -542,3 +543,47 @@ func (s *Service) OOSponsor(r *http.Request) (any, error) {
 
 	return userops, nil
 }
+
+type feeEstimate struct {
+	Speed                engine.FeeSpeed `json:"speed"`
+	BaseFee              string          `json:"baseFee"`
+	MaxPriorityFeePerGas string          `json:"maxPriorityFeePerGas"`
+	MaxFeePerGas         string          `json:"maxFeePerGas"`
+	Percentile           float64         `json:"percentile"`
+	PriorityFeeBuffer    int64           `json:"priorityFeeBuffer"`
+	BaseFeeMultiplier    int64           `json:"baseFeeMultiplier"`
+}
+
+// FeeEstimate returns the fees the engine uses for its own transactions, user ops priced below them risk not being sent
+// an optional speed (slow, standard or fast) can be passed as the first param
+func (s *Service) FeeEstimate(r *http.Request) (any, error) {
+	// params are optional
+	var params []string
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	speed := engine.FeeSpeedStandard
+	if len(params) > 0 {
+		var err error
+		speed, err = engine.FeeSpeedFromString(params[0])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	fees, err := s.evm.GetFeeEstimates(speed)
+	if err != nil {
+		return nil, err
+	}
+
+	return &feeEstimate{
+		Speed:                fees.Speed,
+		BaseFee:              hexutil.EncodeBig(fees.BaseFee),
+		MaxPriorityFeePerGas: hexutil.EncodeBig(fees.MaxPriorityFeePerGas),
+		MaxFeePerGas:         hexutil.EncodeBig(fees.MaxFeePerGas),
+		Percentile:           fees.Percentile,
+		PriorityFeeBuffer:    fees.PriorityFeeBuffer,
+		BaseFeeMultiplier:    fees.BaseFeeMultiplier,
+	}, nil
+}
@@ -0,0 +1,47 @@
+package paymaster
+
+import (
+	"errors"
+	"math/big"
+
+	comm "github.com/citizenwallet/engine/pkg/common"
+)
+
+// verifyInitCode checks that the init code is only set on the first user operation of an account and returns whether it deploys the account
+//
+// nonces are key based, the first user operation of an account is at sequence 0 of whatever key it uses
+func verifyInitCode(nonce *big.Int, initCode []byte) (bool, error) {
+	if nonce == nil {
+		return false, errors.New("error nonce is missing")
+	}
+
+	// the first operation of any key could deploy the account, an account that is already deployed will revert
+	if comm.ParseNonce(nonce).Seq > 0 && len(initCode) > 0 {
+		return false, errors.New("error init code is not empty even though nonce is not 0")
+	}
+
+	return len(initCode) > 20, nil
+}
+
+// newOONonces generates nonces with distinct keys at sequence 0, each of them can be used independently of the others
+func newOONonces(amount int) ([]*big.Int, error) {
+	nonces := []*big.Int{}
+	seen := map[string]bool{}
+
+	for len(nonces) < amount {
+		nonce, err := comm.NewNonce()
+		if err != nil {
+			return nil, err
+		}
+
+		key := nonce.Key.String()
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		nonces = append(nonces, nonce.BigInt())
+	}
+
+	return nonces, nil
+}
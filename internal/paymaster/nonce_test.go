@@ -0,0 +1,71 @@
+package paymaster
+
+import (
+	"math/big"
+	"testing"
+
+	comm "github.com/citizenwallet/engine/pkg/common"
+)
+
+func TestVerifyInitCode(t *testing.T) {
+	initCode := make([]byte, 24) // factory address and calldata
+	key := big.NewInt(42)
+
+	tests := []struct {
+		name     string
+		nonce    *big.Int
+		initCode []byte
+		deploys  bool
+		err      bool
+	}{
+		{"first op", big.NewInt(0), initCode, true, false},
+		{"next op", big.NewInt(1), nil, false, false},
+		{"next op with init code", big.NewInt(1), initCode, false, true},
+		{"first op of a key", (&comm.Nonce{Key: key, Seq: 0}).BigInt(), initCode, true, false},
+		{"next op of a key", (&comm.Nonce{Key: key, Seq: 2}).BigInt(), nil, false, false},
+		{"next op of a key with init code", (&comm.Nonce{Key: key, Seq: 2}).BigInt(), initCode, false, true},
+		{"missing nonce", nil, nil, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deploys, err := verifyInitCode(tt.nonce, tt.initCode)
+			if (err != nil) != tt.err {
+				t.Fatalf("expected error %v, got %v", tt.err, err)
+			}
+
+			if deploys != tt.deploys {
+				t.Errorf("expected deploys %v, got %v", tt.deploys, deploys)
+			}
+		})
+	}
+}
+
+func TestNewOONonces(t *testing.T) {
+	nonces, err := newOONonces(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(nonces) != 10 {
+		t.Fatalf("expected 10 nonces, got %d", len(nonces))
+	}
+
+	keys := map[string]bool{}
+	for _, nonce := range nonces {
+		n := comm.ParseNonce(nonce)
+		if n.Seq != 0 {
+			t.Errorf("expected sequence 0, got %d", n.Seq)
+		}
+
+		if n.Key.Sign() == 0 {
+			t.Error("expected a non zero key")
+		}
+
+		keys[n.Key.String()] = true
+	}
+
+	if len(keys) != len(nonces) {
+		t.Errorf("expected %d distinct keys, got %d", len(nonces), len(keys))
+	}
+}
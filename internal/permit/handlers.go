@@ -0,0 +1,125 @@
+package permit
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"time"
+
+	"github.com/citizenwallet/engine/internal/db"
+	"github.com/citizenwallet/engine/internal/queue"
+	comm "github.com/citizenwallet/engine/pkg/common"
+	"github.com/citizenwallet/engine/pkg/engine"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/go-chi/chi/v5"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// permits that expire sooner than this could expire while they wait in the queue
+const deadlineMargin = 1 * time.Minute
+
+type Service struct {
+	evm     engine.EVMRequester
+	db      *db.DB
+	useropq *queue.Service
+	chainId *big.Int
+}
+
+// NewService
+func NewService(evm engine.EVMRequester, db *db.DB, useropq *queue.Service, chid *big.Int) *Service {
+	return &Service{
+		evm,
+		db,
+		useropq,
+		chid,
+	}
+}
+
+type permitRequest struct {
+	Token     string        `json:"token"`
+	Owner     string        `json:"owner"`
+	Spender   string        `json:"spender"`
+	Value     *hexutil.Big  `json:"value"`
+	Deadline  *hexutil.Big  `json:"deadline"`
+	Signature hexutil.Bytes `json:"signature"` // [r][s][v] over the EIP-712 permit of the token
+}
+
+// Relay sends a signed EIP-2612 permit with a sponsor of the paymaster so that the owner doesn't need gas to approve,
+// it returns the hash of the transaction
+func (s *Service) Relay(r *http.Request) (any, error) {
+	pm, err := comm.ParseAddress(chi.URLParam(r, "pm_address"))
+	if err != nil {
+		return nil, errors.New("invalid paymaster address")
+	}
+
+	var params []permitRequest
+	err = json.NewDecoder(r.Body).Decode(&params)
+	if err != nil || len(params) == 0 {
+		return nil, errors.New("error parsing permit")
+	}
+
+	p := params[0]
+
+	token, err := comm.ParseAddress(p.Token)
+	if err != nil {
+		return nil, errors.New("invalid token address")
+	}
+
+	owner, err := comm.ParseAddress(p.Owner)
+	if err != nil {
+		return nil, errors.New("invalid owner address")
+	}
+
+	spender, err := comm.ParseAddress(p.Spender)
+	if err != nil {
+		return nil, errors.New("invalid spender address")
+	}
+
+	if p.Value == nil || p.Deadline == nil {
+		return nil, errors.New("error permit value and deadline are required")
+	}
+
+	deadline := p.Deadline.ToInt()
+	if deadline.Cmp(big.NewInt(time.Now().Add(deadlineMargin).Unix())) < 0 {
+		return nil, errors.New("error permit deadline has passed or is too close")
+	}
+
+	// sponsors only pay for the tokens of the communities we index
+	exists, err := s.db.EventDB.EventExists(r.Context(), token.Hex())
+	if err != nil || !exists {
+		return nil, errors.New("error token is not indexed")
+	}
+
+	err = Supports(s.evm, token)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := CallData(owner, spender, p.Value.ToInt(), deadline, p.Signature)
+	if err != nil {
+		return nil, err
+	}
+
+	// a permit with a bad signature or a used nonce reverts, it should not cost the sponsor any gas
+	_, err = s.evm.CallContract(ethereum.CallMsg{
+		To:   &token,
+		Data: data,
+	}, nil)
+	if err != nil {
+		return nil, errors.New("error permit is invalid: " + err.Error())
+	}
+
+	message := engine.NewCallMessage(pm, token, s.chainId, data)
+
+	// link the processing in the queue to this request
+	message.TraceContext = trace.SpanContextFromContext(r.Context())
+
+	err = s.useropq.Enqueue(*message)
+	if err != nil {
+		return nil, err
+	}
+
+	return message.WaitForResponse()
+}
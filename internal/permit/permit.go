@@ -0,0 +1,74 @@
+package permit
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/citizenwallet/engine/pkg/engine"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+var (
+	ErrInvalidSignature = errors.New("permit signature must be 65 bytes")
+	ErrNotSupported     = errors.New("token does not support EIP-2612 permits")
+
+	permitSig          = crypto.Keccak256([]byte("permit(address,address,uint256,uint256,uint8,bytes32,bytes32)"))[:4]
+	domainSeparatorSig = crypto.Keccak256([]byte("DOMAIN_SEPARATOR()"))[:4]
+)
+
+var permitArgs = func() abi.Arguments {
+	addressTy, _ := abi.NewType("address", "address", nil)
+	uint256Ty, _ := abi.NewType("uint256", "uint256", nil)
+	uint8Ty, _ := abi.NewType("uint8", "uint8", nil)
+	bytes32Ty, _ := abi.NewType("bytes32", "bytes32", nil)
+
+	return abi.Arguments{
+		{Name: "owner", Type: addressTy},
+		{Name: "spender", Type: addressTy},
+		{Name: "value", Type: uint256Ty},
+		{Name: "deadline", Type: uint256Ty},
+		{Name: "v", Type: uint8Ty},
+		{Name: "r", Type: bytes32Ty},
+		{Name: "s", Type: bytes32Ty},
+	}
+}()
+
+// CallData assembles the calldata of an EIP-2612 permit call from a 65 byte [r][s][v] signature
+func CallData(owner, spender common.Address, value, deadline *big.Int, sig []byte) ([]byte, error) {
+	if len(sig) != crypto.SignatureLength {
+		return nil, ErrInvalidSignature
+	}
+
+	var r, s [32]byte
+	copy(r[:], sig[:32])
+	copy(s[:], sig[32:64])
+
+	// tokens recover with ecrecover which expects v to be 27 or 28
+	v := sig[crypto.RecoveryIDOffset]
+	if v == 0 || v == 1 {
+		v += 27
+	}
+
+	args, err := permitArgs.Pack(owner, spender, value, deadline, v, r, s)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(append([]byte{}, permitSig...), args...), nil
+}
+
+// Supports checks that the token exposes the DOMAIN_SEPARATOR that EIP-2612 permits are signed against
+func Supports(evm engine.EVMRequester, token common.Address) error {
+	result, err := evm.CallContract(ethereum.CallMsg{
+		To:   &token,
+		Data: domainSeparatorSig,
+	}, nil)
+	if err != nil || len(result) != 32 {
+		return ErrNotSupported
+	}
+
+	return nil
+}
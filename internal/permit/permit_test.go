@@ -0,0 +1,87 @@
+package permit
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/citizenwallet/engine/pkg/engine"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestCallData(t *testing.T) {
+	owner, spender := common.HexToAddress("0x1"), common.HexToAddress("0x2")
+	value, deadline := big.NewInt(1000), big.NewInt(1700000000)
+
+	sig := append(bytes.Repeat([]byte{0x11}, 32), bytes.Repeat([]byte{0x22}, 32)...)
+	sig = append(sig, 1)
+
+	data, err := CallData(owner, spender, value, deadline, sig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(data[:4], permitSig) {
+		t.Fatalf("expected the permit selector, got %x", data[:4])
+	}
+
+	args, err := permitArgs.Unpack(data[4:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if args[0].(common.Address) != owner || args[1].(common.Address) != spender {
+		t.Errorf("unexpected owner and spender %v %v", args[0], args[1])
+	}
+
+	if args[2].(*big.Int).Cmp(value) != 0 || args[3].(*big.Int).Cmp(deadline) != 0 {
+		t.Errorf("unexpected value and deadline %v %v", args[2], args[3])
+	}
+
+	if args[4].(uint8) != 28 {
+		t.Errorf("expected v to be normalized to 28, got %d", args[4])
+	}
+
+	r, s := args[5].([32]byte), args[6].([32]byte)
+	if !bytes.Equal(r[:], sig[:32]) || !bytes.Equal(s[:], sig[32:64]) {
+		t.Errorf("unexpected r and s %x %x", r, s)
+	}
+
+	if _, err := CallData(owner, spender, value, deadline, sig[:64]); err != ErrInvalidSignature {
+		t.Errorf("expected %v, got %v", ErrInvalidSignature, err)
+	}
+}
+
+type callEVM struct {
+	engine.EVMRequester
+	result []byte
+	err    error
+}
+
+func (c *callEVM) CallContract(call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	return c.result, c.err
+}
+
+func TestSupports(t *testing.T) {
+	token := common.HexToAddress("0x3")
+
+	tests := []struct {
+		name string
+		evm  *callEVM
+		err  error
+	}{
+		{"domain separator", &callEVM{result: make([]byte, 32)}, nil},
+		{"reverts", &callEVM{err: errors.New("execution reverted")}, ErrNotSupported},
+		{"no code", &callEVM{result: []byte{}}, ErrNotSupported},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Supports(tt.evm, token); err != tt.err {
+				t.Errorf("expected %v, got %v", tt.err, err)
+			}
+		})
+	}
+}
@@ -35,19 +35,27 @@ func (s *Service) PinProfile(w http.ResponseWriter, r *http.Request) {
 	// ensure that the address in the url matches the one in the headers
 	addr, ok := com.GetContextAddress(r.Context())
 	if !ok {
-		w.WriteHeader(http.StatusBadRequest)
+		com.WriteError(w, http.StatusBadRequest, "missing signed account")
 		return
 	}
 
-	haccaddr := common.HexToAddress(addr)
+	haccaddr, err := com.ParseAddress(addr)
+	if err != nil {
+		com.WriteError(w, http.StatusBadRequest, "invalid signed account")
+		return
+	}
 
 	// parse address from url params
 	accaddr := chi.URLParam(r, "acc_addr")
 
-	acc := common.HexToAddress(accaddr)
+	acc, err := com.ParseAddress(accaddr)
+	if err != nil {
+		com.WriteError(w, http.StatusBadRequest, "invalid account address")
+		return
+	}
 
 	if haccaddr != acc {
-		w.WriteHeader(http.StatusUnauthorized)
+		com.WriteError(w, http.StatusUnauthorized, "account does not match the signed account")
 		return
 	}
 
@@ -59,27 +67,27 @@ func (s *Service) PinProfile(w http.ResponseWriter, r *http.Request) {
 	// Get the contract's bytecode
 	bytecode, err := s.evm.CodeAt(context.Background(), prf, nil)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		com.WriteError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
 	// Check if the profile contract is deployed
 	if len(bytecode) == 0 {
-		http.Error(w, "profile contract is missing", http.StatusBadRequest)
+		com.WriteError(w, http.StatusBadRequest, "profile contract is missing")
 		return
 	}
 
 	// instantiate profile contract
 	prfcontract, err := profile.NewProfile(prf, s.evm.Backend())
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		com.WriteError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
 	var profile engine.Profile
 	err = json.NewDecoder(r.Body).Decode(&profile)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+		com.WriteError(w, http.StatusBadRequest, "invalid profile")
 		return
 	}
 	defer r.Body.Close()
@@ -87,20 +95,20 @@ func (s *Service) PinProfile(w http.ResponseWriter, r *http.Request) {
 	praddr := common.HexToAddress(profile.Account)
 
 	if acc != praddr {
-		w.WriteHeader(http.StatusUnauthorized)
+		com.WriteError(w, http.StatusUnauthorized, "profile is not for this account")
 		return
 	}
 
 	// pin profile to ipfs
 	b, err := json.Marshal(profile)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		com.WriteError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
 	uri, err := s.b.PinJSONToIPFS(r.Context(), b)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		com.WriteError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
@@ -119,7 +127,7 @@ func (s *Service) PinProfile(w http.ResponseWriter, r *http.Request) {
 
 	err = com.Body(w, &pinResponse{IpfsURL: uri}, nil)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		com.WriteError(w, http.StatusInternalServerError, "error writing response")
 	}
 }
 
@@ -128,26 +136,34 @@ func (s *Service) PinMultiPartProfile(w http.ResponseWriter, r *http.Request) {
 	// Parse the form data to get the uploaded file
 	err := r.ParseMultipartForm(10 << 20) // 10 MB limit (adjust as needed)
 	if err != nil {
-		http.Error(w, "Unable to parse form", http.StatusBadRequest)
+		com.WriteError(w, http.StatusBadRequest, "Unable to parse form")
 		return
 	}
 
 	// ensure that the address in the url matches the one in the headers
 	addr, ok := com.GetContextAddress(r.Context())
 	if !ok {
-		w.WriteHeader(http.StatusBadRequest)
+		com.WriteError(w, http.StatusBadRequest, "missing signed account")
 		return
 	}
 
-	haccaddr := common.HexToAddress(addr)
+	haccaddr, err := com.ParseAddress(addr)
+	if err != nil {
+		com.WriteError(w, http.StatusBadRequest, "invalid signed account")
+		return
+	}
 
 	// parse address from url params
 	accaddr := chi.URLParam(r, "acc_addr")
 
-	acc := common.HexToAddress(accaddr)
+	acc, err := com.ParseAddress(accaddr)
+	if err != nil {
+		com.WriteError(w, http.StatusBadRequest, "invalid account address")
+		return
+	}
 
 	if haccaddr != acc {
-		w.WriteHeader(http.StatusUnauthorized)
+		com.WriteError(w, http.StatusUnauthorized, "account does not match the signed account")
 		return
 	}
 
@@ -159,26 +175,26 @@ func (s *Service) PinMultiPartProfile(w http.ResponseWriter, r *http.Request) {
 	// Get the contract's bytecode
 	bytecode, err := s.evm.CodeAt(context.Background(), prf, nil)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		com.WriteError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
 	// Check if the profile contract is deployed
 	if len(bytecode) == 0 {
-		http.Error(w, "profile contract is missing", http.StatusBadRequest)
+		com.WriteError(w, http.StatusBadRequest, "profile contract is missing")
 		return
 	}
 
 	// instantiate profile contract
 	prfcontract, err := profile.NewProfile(prf, s.evm.Backend())
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		com.WriteError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
 	file, _, err := r.FormFile("file")
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		com.WriteError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 	defer file.Close()
@@ -186,33 +202,33 @@ func (s *Service) PinMultiPartProfile(w http.ResponseWriter, r *http.Request) {
 	// parse image
 	si, err := com.ParseImage(file)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		com.WriteError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
 	strbody := r.MultipartForm.Value["body"][0]
 	if strbody == "" {
-		w.WriteHeader(http.StatusBadRequest)
+		com.WriteError(w, http.StatusBadRequest, "missing profile body")
 		return
 	}
 
 	var profile engine.Profile
 	if err := json.Unmarshal([]byte(strbody), &profile); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+		com.WriteError(w, http.StatusBadRequest, "invalid profile")
 		return
 	}
 
 	praddr := common.HexToAddress(profile.Account)
 
 	if acc != praddr {
-		w.WriteHeader(http.StatusUnauthorized)
+		com.WriteError(w, http.StatusUnauthorized, "profile is not for this account")
 		return
 	}
 
 	// pin image to ipfs
 	uri, err := s.b.PinFileToIPFS(r.Context(), si.Big, "big.jpg")
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		com.WriteError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
@@ -221,7 +237,7 @@ func (s *Service) PinMultiPartProfile(w http.ResponseWriter, r *http.Request) {
 	// pin medium image to ipfs
 	uri, err = s.b.PinFileToIPFS(r.Context(), si.Medium, "medium.jpg")
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		com.WriteError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
@@ -230,7 +246,7 @@ func (s *Service) PinMultiPartProfile(w http.ResponseWriter, r *http.Request) {
 	// pin small image to ipfs
 	uri, err = s.b.PinFileToIPFS(r.Context(), si.Small, "small.jpg")
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		com.WriteError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
@@ -239,13 +255,13 @@ func (s *Service) PinMultiPartProfile(w http.ResponseWriter, r *http.Request) {
 	// pin profile to ipfs
 	b, err := json.Marshal(profile)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		com.WriteError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
 	uri, err = s.b.PinJSONToIPFS(r.Context(), b)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		com.WriteError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
@@ -264,7 +280,7 @@ func (s *Service) PinMultiPartProfile(w http.ResponseWriter, r *http.Request) {
 
 	err = com.Body(w, &pinResponse{IpfsURL: uri}, nil)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		com.WriteError(w, http.StatusInternalServerError, "error writing response")
 	}
 }
 
@@ -273,19 +289,27 @@ func (s *Service) Unpin(w http.ResponseWriter, r *http.Request) {
 	// ensure that the address in the url matches the one in the headers
 	addr, ok := com.GetContextAddress(r.Context())
 	if !ok {
-		w.WriteHeader(http.StatusBadRequest)
+		com.WriteError(w, http.StatusBadRequest, "missing signed account")
 		return
 	}
 
-	haccaddr := common.HexToAddress(addr)
+	haccaddr, err := com.ParseAddress(addr)
+	if err != nil {
+		com.WriteError(w, http.StatusBadRequest, "invalid signed account")
+		return
+	}
 
 	// parse address from url params
 	accaddr := chi.URLParam(r, "acc_addr")
 
-	acc := common.HexToAddress(accaddr)
+	acc, err := com.ParseAddress(accaddr)
+	if err != nil {
+		com.WriteError(w, http.StatusBadRequest, "invalid account address")
+		return
+	}
 
 	if haccaddr != acc {
-		w.WriteHeader(http.StatusUnauthorized)
+		com.WriteError(w, http.StatusUnauthorized, "account does not match the signed account")
 		return
 	}
 
@@ -297,33 +321,33 @@ func (s *Service) Unpin(w http.ResponseWriter, r *http.Request) {
 	// Get the contract's bytecode
 	bytecode, err := s.evm.CodeAt(context.Background(), prf, nil)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		com.WriteError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
 	// Check if the profile contract is deployed
 	if len(bytecode) == 0 {
-		http.Error(w, "profile contract is missing", http.StatusBadRequest)
+		com.WriteError(w, http.StatusBadRequest, "profile contract is missing")
 		return
 	}
 
 	// instantiate profile contract
 	prfcontract, err := profile.NewProfile(prf, s.evm.Backend())
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		com.WriteError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
 	// get the hash from the profile contract, makes sure that users can only delete their own profile
 	hash, err := prfcontract.Get(nil, acc)
 	if err != nil {
-		w.WriteHeader(http.StatusNotFound)
+		com.WriteError(w, http.StatusNotFound, "profile not found")
 		return
 	}
 
 	err = s.b.Unpin(r.Context(), hash)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		com.WriteError(w, http.StatusInternalServerError, "error unpinning profile")
 		return
 	}
 }
@@ -7,7 +7,6 @@ import (
 	"github.com/citizenwallet/engine/internal/db"
 	com "github.com/citizenwallet/engine/pkg/common"
 	"github.com/citizenwallet/engine/pkg/engine"
-	"github.com/ethereum/go-ethereum/common"
 	"github.com/go-chi/chi/v5"
 )
 
@@ -25,19 +24,27 @@ func (s *Service) AddToken(w http.ResponseWriter, r *http.Request) {
 	// ensure that the address in the url matches the one in the headers
 	addr, ok := com.GetContextAddress(r.Context())
 	if !ok {
-		w.WriteHeader(http.StatusBadRequest)
+		com.WriteError(w, http.StatusBadRequest, "missing signed account")
 		return
 	}
 
-	haccaddr := common.HexToAddress(addr)
+	haccaddr, err := com.ParseAddress(addr)
+	if err != nil {
+		com.WriteError(w, http.StatusBadRequest, "invalid signed account")
+		return
+	}
 
 	// parse address from url params
 	accaddr := chi.URLParam(r, "acc_addr")
 
-	acc := common.HexToAddress(accaddr)
+	acc, err := com.ParseAddress(accaddr)
+	if err != nil {
+		com.WriteError(w, http.StatusBadRequest, "invalid account address")
+		return
+	}
 
 	if haccaddr != acc {
-		w.WriteHeader(http.StatusUnauthorized)
+		com.WriteError(w, http.StatusUnauthorized, "account does not match the signed account")
 		return
 	}
 
@@ -45,43 +52,47 @@ func (s *Service) AddToken(w http.ResponseWriter, r *http.Request) {
 	contractAddr := chi.URLParam(r, "contract_address")
 
 	var pt engine.PushToken
-	err := json.NewDecoder(r.Body).Decode(&pt)
+	err = json.NewDecoder(r.Body).Decode(&pt)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+		com.WriteError(w, http.StatusBadRequest, "invalid push token")
 		return
 	}
 	defer r.Body.Close()
 
 	// make sure the addresses are EIP55 checksummed
-	pt.Account = com.ChecksumAddress(pt.Account)
+	pt.Account, err = com.NormalizeAddress(pt.Account)
+	if err != nil {
+		com.WriteError(w, http.StatusBadRequest, "invalid push token account")
+		return
+	}
 
 	// check that the push token is from the sender of the transaction
-	if !com.IsSameHexAddress(pt.Account, acc.Hex()) {
-		w.WriteHeader(http.StatusUnauthorized)
+	if pt.Account != acc.Hex() {
+		com.WriteError(w, http.StatusUnauthorized, "push token is not for this account")
 		return
 	}
 
 	tname, err := s.db.TableNameSuffix(contractAddr)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+		com.WriteError(w, http.StatusBadRequest, "invalid contract address")
 		return
 	}
 
 	pdb, ok := s.db.PushTokenDB[tname]
 	if !ok {
-		w.WriteHeader(http.StatusNotFound)
+		com.WriteError(w, http.StatusNotFound, "contract is not indexed")
 		return
 	}
 
 	err = pdb.AddToken(&pt)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		com.WriteError(w, http.StatusInternalServerError, "error saving push token")
 		return
 	}
 
 	err = com.Body(w, pt, nil)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		com.WriteError(w, http.StatusInternalServerError, "error writing response")
 	}
 }
 
@@ -89,19 +100,27 @@ func (s *Service) RemoveAccountToken(w http.ResponseWriter, r *http.Request) {
 	// ensure that the address in the url matches the one in the headers
 	addr, ok := com.GetContextAddress(r.Context())
 	if !ok {
-		w.WriteHeader(http.StatusBadRequest)
+		com.WriteError(w, http.StatusBadRequest, "missing signed account")
 		return
 	}
 
-	haccaddr := common.HexToAddress(addr)
+	haccaddr, err := com.ParseAddress(addr)
+	if err != nil {
+		com.WriteError(w, http.StatusBadRequest, "invalid signed account")
+		return
+	}
 
 	// parse address from url params
 	accaddr := chi.URLParam(r, "acc_addr")
 
-	acc := common.HexToAddress(accaddr)
+	acc, err := com.ParseAddress(accaddr)
+	if err != nil {
+		com.WriteError(w, http.StatusBadRequest, "invalid account address")
+		return
+	}
 
 	if haccaddr != acc {
-		w.WriteHeader(http.StatusUnauthorized)
+		com.WriteError(w, http.StatusUnauthorized, "account does not match the signed account")
 		return
 	}
 
@@ -112,30 +131,30 @@ func (s *Service) RemoveAccountToken(w http.ResponseWriter, r *http.Request) {
 	token := chi.URLParam(r, "token")
 
 	if token == "" {
-		w.WriteHeader(http.StatusBadRequest)
+		com.WriteError(w, http.StatusBadRequest, "missing token")
 		return
 	}
 
 	tname, err := s.db.TableNameSuffix(contractAddr)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+		com.WriteError(w, http.StatusBadRequest, "invalid contract address")
 		return
 	}
 
 	pdb, ok := s.db.PushTokenDB[tname]
 	if !ok {
-		w.WriteHeader(http.StatusNotFound)
+		com.WriteError(w, http.StatusNotFound, "contract is not indexed")
 		return
 	}
 
-	err = pdb.RemoveAccountPushToken(token, accaddr)
+	err = pdb.RemoveAccountPushToken(token, acc.Hex())
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		com.WriteError(w, http.StatusInternalServerError, "error removing push token")
 		return
 	}
 
 	err = com.Body(w, []byte("{}"), nil)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		com.WriteError(w, http.StatusInternalServerError, "error writing response")
 	}
 }
@@ -0,0 +1,103 @@
+package queue
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/citizenwallet/engine/pkg/engine"
+	"github.com/ethereum/go-ethereum/core/types"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// processCall sends a call to its contract with a sponsor of the paymaster and responds with the tx hash
+func (s *UserOpService) processCall(msg engine.Message, call engine.CallMessage) (err error) {
+	ctx := trace.ContextWithSpanContext(context.Background(), msg.TraceContext)
+	ctx, span := tracer.Start(ctx, "call.submit", trace.WithAttributes(
+		attribute.String("call.to", call.To.Hex()),
+		attribute.String("call.paymaster", call.Paymaster.Hex()),
+	))
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+
+	selected, err := s.selectSponsor(call.Paymaster)
+	if err != nil {
+		return err
+	}
+
+	sponsor := selected.address
+
+	span.SetAttributes(attribute.String("call.sponsor", sponsor.Hex()))
+
+	// calls share the nonces of the sponsor with its batches
+	unlock := s.lockSponsor(sponsor)
+	defer unlock()
+
+	nonce, err := s.evm.NonceAt(context.Background(), sponsor, nil)
+	if err != nil {
+		return err
+	}
+
+	nonce = s.nextNonce(sponsor, nonce)
+
+	tx, err := s.evm.NewTx(nonce, sponsor, call.To, call.Data, false)
+	if err != nil {
+		return err
+	}
+
+	signedTx, err := types.SignTx(tx, types.NewLondonSigner(call.ChainId), selected.key)
+	if err != nil {
+		return err
+	}
+
+	signedTxHash := signedTx.Hash().Hex()
+
+	s.addInProgress(sponsor, inProgressTx{
+		hash:   signedTxHash,
+		nonce:  nonce,
+		to:     call.To,
+		sentAt: time.Now(),
+	})
+
+	err = s.evm.SendTransaction(signedTx)
+	if err != nil {
+		s.removeInProgress(sponsor, signedTxHash)
+
+		if strings.Contains(err.Error(), "insufficient funds") {
+			// let the other sponsors of the paymaster take over while this one is refilled
+			s.sponsors.markDrained(sponsor)
+		}
+
+		return err
+	}
+
+	msg.Respond(signedTxHash, nil)
+
+	go func() {
+		// the tx is never left in progress, even if waiting for it panics
+		defer s.removeInProgress(sponsor, signedTxHash)
+		defer func() {
+			if pv := recover(); pv != nil {
+				log.Default().Printf("panic while waiting for tx %s: %v\n", signedTxHash, pv)
+			}
+		}()
+
+		_, wspan := tracer.Start(ctx, "eth.waitForTx", trace.WithAttributes(attribute.String("tx.hash", signedTxHash)))
+		defer wspan.End()
+
+		if err := s.evm.WaitForTx(signedTx, 16); err != nil {
+			wspan.RecordError(err)
+			wspan.SetStatus(codes.Error, err.Error())
+		}
+	}()
+
+	return nil
+}
@@ -0,0 +1,75 @@
+package queue
+
+import (
+	"math/big"
+
+	"github.com/citizenwallet/engine/pkg/engine"
+)
+
+// DefaultMaxBatchGas keeps a handleOps transaction well below the block gas limit of the chains we run on
+const DefaultMaxBatchGas = 10_000_000
+
+// opGas is the most gas a user operation can use, as the entrypoint computes its prefund
+//
+// the verification gas is used up to 3 times when there is a paymaster: validation, postOp and its revert
+func opGas(op engine.UserOp) *big.Int {
+	mul := int64(1)
+	if len(op.PaymasterAndData) > 0 {
+		mul = 3
+	}
+
+	gas := new(big.Int).Mul(gasOf(op.VerificationGasLimit), big.NewInt(mul))
+	gas.Add(gas, gasOf(op.CallGasLimit))
+	gas.Add(gas, gasOf(op.PreVerificationGas))
+
+	return gas
+}
+
+func gasOf(v *big.Int) *big.Int {
+	if v == nil {
+		return new(big.Int)
+	}
+
+	return v
+}
+
+// gasChunk is a part of a batch that is sent in its own handleOps transaction
+type gasChunk struct {
+	txms []engine.UserOpMessage
+	msgs []engine.Message
+}
+
+// splitByGas splits a batch in order into chunks whose user operations don't use more than maxGas together
+//
+// an operation that exceeds maxGas on its own is sent alone, a max of 0 keeps the batch whole
+func splitByGas(txms []engine.UserOpMessage, msgs []engine.Message, maxGas uint64) []gasChunk {
+	if maxGas == 0 {
+		return []gasChunk{{txms: txms, msgs: msgs}}
+	}
+
+	max := new(big.Int).SetUint64(maxGas)
+
+	chunks := []gasChunk{}
+	current := gasChunk{}
+	total := new(big.Int)
+
+	for i, txm := range txms {
+		gas := opGas(txm.UserOp)
+
+		if len(current.txms) > 0 && new(big.Int).Add(total, gas).Cmp(max) > 0 {
+			chunks = append(chunks, current)
+			current = gasChunk{}
+			total = new(big.Int)
+		}
+
+		current.txms = append(current.txms, txm)
+		current.msgs = append(current.msgs, msgs[i])
+		total.Add(total, gas)
+	}
+
+	if len(current.txms) > 0 {
+		chunks = append(chunks, current)
+	}
+
+	return chunks
+}
@@ -0,0 +1,85 @@
+package queue
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/citizenwallet/engine/pkg/engine"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func gasOp(nonce int64, call, verification, preVerification int64, paymaster bool) engine.UserOpMessage {
+	op := engine.UserOp{
+		Sender:               common.HexToAddress("0x1"),
+		Nonce:                big.NewInt(nonce),
+		CallGasLimit:         big.NewInt(call),
+		VerificationGasLimit: big.NewInt(verification),
+		PreVerificationGas:   big.NewInt(preVerification),
+	}
+
+	if paymaster {
+		op.PaymasterAndData = []byte{1}
+	}
+
+	return engine.UserOpMessage{UserOp: op}
+}
+
+func TestOpGas(t *testing.T) {
+	if gas := opGas(gasOp(0, 100, 10, 1, false).UserOp); gas.Int64() != 111 {
+		t.Errorf("expected 111 without a paymaster, got %s", gas)
+	}
+
+	if gas := opGas(gasOp(0, 100, 10, 1, true).UserOp); gas.Int64() != 131 {
+		t.Errorf("expected 131 with a paymaster, got %s", gas)
+	}
+}
+
+func TestSplitByGas(t *testing.T) {
+	txms := []engine.UserOpMessage{
+		gasOp(0, 40, 0, 0, false),
+		gasOp(1, 40, 0, 0, false),
+		gasOp(2, 40, 0, 0, false),
+		gasOp(3, 150, 0, 0, false), // too big on its own
+		gasOp(4, 10, 0, 0, false),
+	}
+
+	msgs := make([]engine.Message, len(txms))
+	for i := range msgs {
+		msgs[i] = engine.Message{ID: string(rune('a' + i))}
+	}
+
+	tests := []struct {
+		name   string
+		maxGas uint64
+		chunks [][]int64 // nonces per chunk
+	}{
+		{"disabled", 0, [][]int64{{0, 1, 2, 3, 4}}},
+		{"split", 100, [][]int64{{0, 1}, {2}, {3}, {4}}},
+		{"fits", 1000, [][]int64{{0, 1, 2, 3, 4}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chunks := splitByGas(txms, msgs, tt.maxGas)
+			if len(chunks) != len(tt.chunks) {
+				t.Fatalf("expected %d chunks, got %d", len(tt.chunks), len(chunks))
+			}
+
+			for i, chunk := range chunks {
+				if len(chunk.txms) != len(tt.chunks[i]) || len(chunk.msgs) != len(chunk.txms) {
+					t.Fatalf("chunk %d: expected %d ops, got %d ops and %d messages", i, len(tt.chunks[i]), len(chunk.txms), len(chunk.msgs))
+				}
+
+				for j, nonce := range tt.chunks[i] {
+					if chunk.txms[j].UserOp.Nonce.Int64() != nonce {
+						t.Errorf("chunk %d op %d: expected nonce %d, got %d", i, j, nonce, chunk.txms[j].UserOp.Nonce.Int64())
+					}
+
+					if chunk.msgs[j].ID != msgs[nonce].ID {
+						t.Errorf("chunk %d op %d: message doesn't follow its user operation", i, j)
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,193 @@
+package queue
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const (
+	// DefaultInProgressTTL is how long a sent transaction is waited for before it is dropped from the in progress list
+	DefaultInProgressTTL = 5 * time.Minute
+
+	janitorInterval = 30 * time.Second
+)
+
+// ErrSenderBusy is returned by AcquireSender when a sender already has as many user operations in flight as allowed
+var ErrSenderBusy error = &senderBusyError{}
+
+type senderBusyError struct{}
+
+func (e *senderBusyError) Error() string {
+	return "too many user operations of this sender in flight, try again later"
+}
+
+// ErrorCode is the JSON RPC code of a request that exceeds a limit (EIP-1474)
+func (e *senderBusyError) ErrorCode() int {
+	return -32005
+}
+
+// inProgressTx is a transaction that was sent by a sponsor and isn't known to be mined yet
+type inProgressTx struct {
+	hash   string
+	nonce  uint64
+	to     common.Address // the entrypoint, or the contract of a call
+	sentAt time.Time
+}
+
+func (s *UserOpService) addInProgress(sponsor common.Address, tx inProgressTx) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.inProgress[sponsor] = append(s.inProgress[sponsor], tx)
+}
+
+func (s *UserOpService) removeInProgress(sponsor common.Address, hash string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	kept := []inProgressTx{}
+	for _, tx := range s.inProgress[sponsor] {
+		if tx.hash != hash {
+			kept = append(kept, tx)
+		}
+	}
+
+	s.setInProgress(sponsor, kept)
+}
+
+// AcquireSender counts a user operation of a sender as in flight until the returned function is called
+//
+// a sender that is at the limit gets ErrSenderBusy so that it can't take up the queue that it shares with other senders
+func (s *UserOpService) AcquireSender(sender common.Address) (func(), error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.maxInFlight > 0 && s.inFlight[sender] >= s.maxInFlight {
+		return nil, ErrSenderBusy
+	}
+
+	s.inFlight[sender]++
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { s.releaseSender(sender) })
+	}, nil
+}
+
+func (s *UserOpService) releaseSender(sender common.Address) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.inFlight[sender]--
+	if s.inFlight[sender] <= 0 {
+		delete(s.inFlight, sender)
+	}
+}
+
+// setInProgress replaces the in progress transactions of a sponsor, s.mu must be held
+func (s *UserOpService) setInProgress(sponsor common.Address, txs []inProgressTx) {
+	if len(txs) == 0 {
+		delete(s.inProgress, sponsor)
+		return
+	}
+
+	s.inProgress[sponsor] = txs
+}
+
+// nextNonce returns the nonce of the next transaction of a sponsor given its on-chain nonce
+//
+// only the in progress transactions that the chain hasn't caught up with yet are counted
+func (s *UserOpService) nextNonce(sponsor common.Address, nonce uint64) uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	next := nonce
+	for _, tx := range s.inProgress[sponsor] {
+		if tx.nonce >= nonce {
+			next++
+		}
+	}
+
+	return next
+}
+
+// InProgress returns the number of sent transactions waiting to be mined per entrypoint, calls are counted by contract
+func (s *UserOpService) InProgress() map[string]int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	counts := map[string]int{}
+	for _, txs := range s.inProgress {
+		for _, tx := range txs {
+			counts[tx.to.Hex()]++
+		}
+	}
+
+	return counts
+}
+
+// Janitor periodically drops the in progress transactions that are mined or have expired, until the context is done
+//
+// this keeps the nonce offset of a sponsor correct when a wait for a transaction never returns
+func (s *UserOpService) Janitor(ctx context.Context, ttl time.Duration) error {
+	ticker := time.NewTicker(janitorInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			s.cleanInProgress(ctx, ttl, time.Now())
+		}
+	}
+}
+
+func (s *UserOpService) cleanInProgress(ctx context.Context, ttl time.Duration, now time.Time) {
+	s.mu.Lock()
+	sponsors := make([]common.Address, 0, len(s.inProgress))
+	for sponsor := range s.inProgress {
+		sponsors = append(sponsors, sponsor)
+	}
+	s.mu.Unlock()
+
+	for _, sponsor := range sponsors {
+		s.reconcile(ctx, sponsor, ttl, now)
+	}
+}
+
+// reconcile drops the transactions of a sponsor that are below its on-chain nonce or older than the ttl
+func (s *UserOpService) reconcile(ctx context.Context, sponsor common.Address, ttl time.Duration, now time.Time) {
+	// a batch of the sponsor could be computing its nonce
+	unlock := s.lockSponsor(sponsor)
+	defer unlock()
+
+	nonce, err := s.evm.NonceAt(ctx, sponsor, nil)
+	if err != nil {
+		// expired transactions are still dropped
+		log.Default().Println("error fetching sponsor nonce: ", err.Error())
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	kept := []inProgressTx{}
+	for _, tx := range s.inProgress[sponsor] {
+		if err == nil && tx.nonce < nonce {
+			continue
+		}
+
+		if now.Sub(tx.sentAt) > ttl {
+			log.Default().Printf("dropping in progress tx %s of sponsor %s, sent %s ago\n", tx.hash, sponsor.Hex(), now.Sub(tx.sentAt).Round(time.Second))
+			continue
+		}
+
+		kept = append(kept, tx)
+	}
+
+	s.setInProgress(sponsor, kept)
+}
@@ -8,10 +8,39 @@ import (
 	"time"
 
 	"github.com/citizenwallet/engine/pkg/engine"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const batchSize = 10 // Size of each batch
 
+const errBufferSize = 16 // errors that can wait for the consumer of the error channel, more are dropped
+
+var tracer = otel.Tracer("github.com/citizenwallet/engine/internal/queue")
+
+var meter = otel.Meter("github.com/citizenwallet/engine/internal/queue")
+
+const (
+	DefaultBatchMinWait = 10 * time.Millisecond  // how long a batch waits for more messages when the queue is empty
+	DefaultBatchMaxWait = 250 * time.Millisecond // how long a batch waits for more messages when the queue is filling up
+)
+
+// ErrQueueFull is returned by Enqueue when there is no room left in the queue, the request can be tried again later
+var ErrQueueFull error = &queueFullError{}
+
+type queueFullError struct{}
+
+func (e *queueFullError) Error() string {
+	return "queue is full, try again later"
+}
+
+// ErrorCode is the JSON RPC code of a request that exceeds a limit (EIP-1474)
+func (e *queueFullError) ErrorCode() int {
+	return -32005
+}
+
 // Service struct represents a queue service with a queue channel, quit channel, maximum retries, context and a webhook messager.
 type Service struct {
 	name       string              // Name of the queue service
@@ -19,6 +48,8 @@ type Service struct {
 	quit       chan bool           // Channel to signal service to stop
 	maxRetries int                 // Maximum number of retries for processing a message
 	bufferSize int                 // Buffer size of the queue channel
+	minWait    time.Duration       // Shortest time a batch waits to be filled
+	maxWait    time.Duration       // Longest time a batch waits to be filled
 
 	ctx context.Context // Context to carry deadlines, cancellation signals, and other request-scoped values across API boundaries and between processes
 	err chan error      // to notify errors
@@ -31,33 +62,113 @@ type Processor interface {
 
 // NewService function initializes a new Service with provided maximum retries, context and webhook messager.
 func NewService(name string, maxRetries, bufferSize int, ctx context.Context) (*Service, chan error) {
-	err := make(chan error)
+	err := make(chan error, errBufferSize)
 
-	return &Service{
+	s := &Service{
 		name:       name,                                  // Set the name
 		queue:      make(chan engine.Message, bufferSize), // Initialize the buffered queue channel
 		quit:       make(chan bool),                       // Initialize the quit channel
 		maxRetries: maxRetries,                            // Set the maximum retries
 		bufferSize: bufferSize,                            // Set the buffer size
+		minWait:    DefaultBatchMinWait,                   // Set the minimum batch wait
+		maxWait:    DefaultBatchMaxWait,                   // Set the maximum batch wait
 		ctx:        ctx,                                   // Set the context
 		err:        err,                                   // Initialize the error channel
-	}, err
+	}
+
+	s.observeDepth()
+
+	return s, err
+}
+
+// observeDepth reports the depth and capacity of the queue as gauges, they are exported when a meter provider is set
+func (s *Service) observeDepth() {
+	depth, err := meter.Int64ObservableGauge("queue.depth", metric.WithDescription("messages waiting in the queue"))
+	if err != nil {
+		log.Default().Printf("%s queue depth is not observed: %v\n", s.name, err)
+		return
+	}
+
+	capacity, err := meter.Int64ObservableGauge("queue.capacity", metric.WithDescription("messages the queue can hold"))
+	if err != nil {
+		log.Default().Printf("%s queue capacity is not observed: %v\n", s.name, err)
+		return
+	}
+
+	attrs := metric.WithAttributes(attribute.String("queue.name", s.name))
+
+	_, err = meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		o.ObserveInt64(depth, int64(s.Depth()), attrs)
+		o.ObserveInt64(capacity, int64(s.Capacity()), attrs)
+		return nil
+	}, depth, capacity)
+	if err != nil {
+		log.Default().Printf("%s queue depth is not observed: %v\n", s.name, err)
+	}
+}
+
+// Depth returns the number of messages waiting in the queue
+func (s *Service) Depth() int {
+	return len(s.queue)
+}
+
+// Capacity returns the number of messages the queue can hold
+func (s *Service) Capacity() int {
+	return cap(s.queue)
+}
+
+// Saturated returns true when the queue is filled up to the given ratio of its capacity (ex: 0.95)
+func (s *Service) Saturated(ratio float64) bool {
+	return float64(s.Depth()) >= float64(s.Capacity())*ratio
+}
+
+// SetBatchWindow sets the bounds of how long a batch waits to be filled before it is processed
+func (s *Service) SetBatchWindow(minWait, maxWait time.Duration) {
+	if maxWait < minWait {
+		maxWait = minWait
+	}
+
+	s.minWait = minWait
+	s.maxWait = maxWait
 }
 
-// Enqueue method enqueues a message to the queue channel.
-func (s *Service) Enqueue(message engine.Message) {
+// batchWindow returns how long to wait for a batch to fill up, the more messages are waiting the longer it waits
+// so that a lone message goes out fast and a busy queue sends fuller batches
+func (s *Service) batchWindow(queued int) time.Duration {
+	if queued >= batchSize {
+		return s.maxWait
+	}
+
+	return s.minWait + (s.maxWait-s.minWait)*time.Duration(queued)/time.Duration(batchSize)
+}
+
+// Enqueue method enqueues a message to the queue channel, it returns ErrQueueFull instead of waiting for room.
+func (s *Service) Enqueue(message engine.Message) error {
 	// if the queue channel is almost full, notify the webhook messager with a warning notification
 	bufferWarning := s.bufferSize - (s.bufferSize / 5)
 	if len(s.queue) > bufferWarning {
-		s.err <- errors.New(fmt.Sprintf("%s queue is almost full", s.name))
+		s.notify(errors.New(fmt.Sprintf("%s queue is almost full", s.name)))
 	}
 
-	// if the queue channel is full, notify the webhook messager with an error notification
-	if len(s.queue) == s.bufferSize {
-		s.err <- errors.New(fmt.Sprintf("%s queue is full", s.name))
+	select {
+	case s.queue <- message:
+		return nil
+	default:
+		// if the queue channel is full, notify the webhook messager with an error notification
+		s.notify(errors.New(fmt.Sprintf("%s queue is full", s.name)))
+
+		return ErrQueueFull
 	}
+}
 
-	s.queue <- message
+// notify sends an error to the error channel without waiting for its consumer, the error is dropped if the channel
+// is full so that notifying never slows down the queue
+func (s *Service) notify(err error) {
+	select {
+	case s.err <- err:
+	default:
+		log.Default().Printf("%s queue dropped an error notification: %v\n", s.name, err)
+	}
 }
 
 // Close method sends a signal to the quit channel to stop the service.
@@ -67,7 +178,7 @@ func (s *Service) Close() {
 
 // Start method starts the service and processes messages from the queue channel.
 // If processing a message fails, it requeues the message until the maximum retries is reached.
-// If the queue was empty, it waits for a duration before continuing to avoid a busy loop.
+// Each batch waits for more messages for a window that grows with the number of queued messages.
 // It also notifies errors using the webhook messager.
 // The service can be stopped by sending a signal to the quit channel.
 func (s *Service) Start(p Processor) error {
@@ -80,21 +191,38 @@ func (s *Service) Start(p Processor) error {
 
 			batch = append(batch, message)
 
-			time.Sleep(250 * time.Millisecond)
-
-			// Fill the batch
+			// Fill the batch until it is full or the window closes
+			window := time.NewTimer(s.batchWindow(len(s.queue)))
 		batchLoop:
 			for len(batch) < batchSize {
 				select {
 				case item, ok := <-s.queue:
 					if !ok {
+						window.Stop()
 						return fmt.Errorf("channel is closed") // Channel is closed
 					}
 					batch = append(batch, item)
-				default:
-					break batchLoop // Channel is empty
+				case <-window.C:
+					break batchLoop // Window closed
 				}
 			}
+			window.Stop()
+
+			// show how long each message waited in the trace of its request
+			now := time.Now()
+			for _, msg := range batch {
+				if !msg.TraceContext.IsValid() {
+					continue
+				}
+
+				ctx := trace.ContextWithSpanContext(context.Background(), msg.TraceContext)
+				_, span := tracer.Start(ctx, "queue.wait", trace.WithTimestamp(msg.CreatedAt), trace.WithAttributes(
+					attribute.String("queue.name", s.name),
+					attribute.Int("queue.batch_size", len(batch)),
+					attribute.Int("queue.retry_count", msg.RetryCount),
+				))
+				span.End(trace.WithTimestamp(now))
+			}
 
 			msgs, errs := p.Process(batch)
 			for i, msg := range msgs {
@@ -109,8 +237,12 @@ func (s *Service) Start(p Processor) error {
 							time.Sleep(extraWait)
 						}
 
-						s.Enqueue(msg)
-						continue
+						err = s.Enqueue(msg)
+						if err == nil {
+							continue
+						}
+
+						// there is no room to retry, the message fails with the queue error
 					}
 
 					// Message has exceeded the maximum retries
@@ -119,7 +251,7 @@ func (s *Service) Start(p Processor) error {
 					msg.Respond(nil, err)
 
 					// Notify the webhook messager with an error notification
-					s.err <- err
+					s.notify(err)
 				}
 			}
 		case <-s.quit:
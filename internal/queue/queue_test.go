@@ -3,6 +3,7 @@ package queue
 import (
 	"errors"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -37,6 +38,103 @@ func (p *TestTxProcessor) Process(messages []engine.Message) ([]engine.Message,
 	return invalidMessages, messageErrors
 }
 
+// batchRecorder records the size of each batch and when it was processed
+type batchRecorder struct {
+	mu      sync.Mutex
+	sizes   []int
+	times   []time.Time
+	batches chan struct{}
+}
+
+func (p *batchRecorder) Process(messages []engine.Message) ([]engine.Message, []error) {
+	p.mu.Lock()
+	p.sizes = append(p.sizes, len(messages))
+	p.times = append(p.times, time.Now())
+	p.mu.Unlock()
+
+	p.batches <- struct{}{}
+
+	return nil, nil
+}
+
+func TestBatchWindow(t *testing.T) {
+	q, _ := NewService("tx", 3, 100, nil)
+	q.SetBatchWindow(10*time.Millisecond, 110*time.Millisecond)
+
+	tests := []struct {
+		queued   int
+		expected time.Duration
+	}{
+		{0, 10 * time.Millisecond},
+		{5, 60 * time.Millisecond},
+		{batchSize, 110 * time.Millisecond},
+		{10 * batchSize, 110 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		if w := q.batchWindow(tt.queued); w != tt.expected {
+			t.Errorf("%d queued: expected %s, got %s", tt.queued, tt.expected, w)
+		}
+	}
+}
+
+func TestAdaptiveBatching(t *testing.T) {
+	t.Run("a lone message is flushed quickly", func(t *testing.T) {
+		q, _ := NewService("tx", 3, 100, nil)
+		q.SetBatchWindow(10*time.Millisecond, 2*time.Second)
+
+		p := &batchRecorder{batches: make(chan struct{}, 10)}
+		go q.Start(p)
+		defer q.Close()
+
+		start := time.Now()
+		q.Enqueue(*engine.NewTxMessage(common.Address{}, common.Address{}, common.Big0, engine.UserOp{}, nil, nil))
+
+		select {
+		case <-p.batches:
+		case <-time.After(time.Second):
+			t.Fatal("expected the message to be processed before the max wait")
+		}
+
+		if elapsed := p.times[0].Sub(start); elapsed > 500*time.Millisecond {
+			t.Errorf("expected a low latency flush, took %s", elapsed)
+		}
+	})
+
+	t.Run("a busy queue waits for a fuller batch", func(t *testing.T) {
+		q, _ := NewService("tx", 3, 100, nil)
+		q.SetBatchWindow(10*time.Millisecond, 2*time.Second)
+
+		// the queue is filling up when the batch starts
+		for i := 0; i < batchSize/2; i++ {
+			q.Enqueue(*engine.NewTxMessage(common.Address{}, common.Address{}, common.Big0, engine.UserOp{}, nil, nil))
+		}
+
+		p := &batchRecorder{batches: make(chan struct{}, 10)}
+		go q.Start(p)
+		defer q.Close()
+
+		// messages that arrive while the window is open end up in the same batch
+		time.Sleep(100 * time.Millisecond)
+		for i := 0; i < batchSize/2; i++ {
+			q.Enqueue(*engine.NewTxMessage(common.Address{}, common.Address{}, common.Big0, engine.UserOp{}, nil, nil))
+		}
+
+		select {
+		case <-p.batches:
+		case <-time.After(3 * time.Second):
+			t.Fatal("expected a batch to be processed")
+		}
+
+		p.mu.Lock()
+		defer p.mu.Unlock()
+
+		if p.sizes[0] != batchSize {
+			t.Errorf("expected a full batch of %d, got %d", batchSize, p.sizes[0])
+		}
+	})
+}
+
 func TestProcessMessages(t *testing.T) {
 	expectedTxError := errors.New("invalid tx message")
 
@@ -61,7 +159,7 @@ func TestProcessMessages(t *testing.T) {
 				}
 
 				if err != expectedTxError {
-					t.Fatalf("expected %s, got %s", expectedTxError, err)
+					t.Errorf("expected %s, got %s", expectedTxError, err)
 				}
 			}
 		}()
@@ -117,7 +215,7 @@ func TestProcessMessages(t *testing.T) {
 				}
 
 				if err != expectedTxError {
-					t.Fatalf("expected %s, got %s", expectedTxError, err)
+					t.Errorf("expected %s, got %s", expectedTxError, err)
 				}
 			}
 		}()
@@ -151,3 +249,58 @@ func TestProcessMessages(t *testing.T) {
 		// TODO: implement
 	})
 }
+
+func TestEnqueueDoesNotBlock(t *testing.T) {
+	q, _ := NewService("tx", 3, 10, nil)
+
+	// nothing reads the queue or the errors, neither the warnings of a filling queue nor a full queue block the enqueuers
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 10; i++ {
+			q.Enqueue(*engine.NewTxMessage(common.Address{}, common.Address{}, common.Big0, engine.UserOp{}, nil, nil))
+		}
+
+		if err := q.Enqueue(*engine.NewTxMessage(common.Address{}, common.Address{}, common.Big0, engine.UserOp{}, nil, nil)); err != ErrQueueFull {
+			t.Errorf("expected ErrQueueFull, got %v", err)
+		}
+
+		// more warnings than the error channel can hold
+		for i := 0; i < 2*errBufferSize; i++ {
+			q.notify(errors.New("warning"))
+		}
+
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected enqueue not to block on a full queue or error channel")
+	}
+}
+
+func TestDepth(t *testing.T) {
+	q, _ := NewService("tx", 3, 20, nil)
+
+	if q.Capacity() != 20 {
+		t.Fatalf("expected capacity 20, got %d", q.Capacity())
+	}
+
+	for i := 0; i < 18; i++ {
+		q.Enqueue(*engine.NewTxMessage(common.Address{}, common.Address{}, common.Big0, engine.UserOp{}, nil, nil))
+	}
+
+	if q.Depth() != 18 {
+		t.Fatalf("expected depth 18, got %d", q.Depth())
+	}
+
+	if q.Saturated(0.95) {
+		t.Error("expected 18 of 20 not to be saturated at 95%")
+	}
+
+	q.Enqueue(*engine.NewTxMessage(common.Address{}, common.Address{}, common.Big0, engine.UserOp{}, nil, nil))
+
+	if !q.Saturated(0.95) {
+		t.Error("expected 19 of 20 to be saturated at 95%")
+	}
+}
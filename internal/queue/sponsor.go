@@ -0,0 +1,143 @@
+package queue
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"math/big"
+	"sync"
+	"time"
+
+	comm "github.com/citizenwallet/engine/pkg/common"
+	"github.com/citizenwallet/engine/pkg/engine"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// sponsors that ran out of funds are skipped for a while so that they don't stall the other sponsors
+const drainedCooldown = 1 * time.Minute
+
+var ErrNoSponsor = errors.New("no sponsor available")
+
+// sponsorAccount is a sponsor key that can submit transactions
+type sponsorAccount struct {
+	key     *ecdsa.PrivateKey
+	address common.Address
+}
+
+// parseSponsors converts the keys of the sponsors of a paymaster to accounts
+func parseSponsors(sponsors []*engine.Sponsor) ([]sponsorAccount, error) {
+	accounts := make([]sponsorAccount, 0, len(sponsors))
+	for _, sponsor := range sponsors {
+		key, err := comm.HexToPrivateKey(sponsor.PrivateKey)
+		if err != nil {
+			return nil, err
+		}
+
+		accounts = append(accounts, sponsorAccount{
+			key:     key,
+			address: crypto.PubkeyToAddress(key.PublicKey),
+		})
+	}
+
+	return accounts, nil
+}
+
+// sponsorSelector picks which sponsor of a paymaster submits the next batch
+type sponsorSelector struct {
+	strategy engine.SponsorStrategy
+	evm      engine.EVMRequester
+
+	mu      sync.Mutex
+	next    map[common.Address]int       // round robin position per paymaster
+	drained map[common.Address]time.Time // sponsors that ran out of funds and until when to skip them
+}
+
+func newSponsorSelector(strategy engine.SponsorStrategy, evm engine.EVMRequester) *sponsorSelector {
+	return &sponsorSelector{
+		strategy: strategy,
+		evm:      evm,
+		next:     map[common.Address]int{},
+		drained:  map[common.Address]time.Time{},
+	}
+}
+
+// pick returns the sponsor that should submit the next batch of a paymaster
+func (s *sponsorSelector) pick(paymaster common.Address, sponsors []sponsorAccount) (sponsorAccount, error) {
+	if len(sponsors) == 0 {
+		return sponsorAccount{}, ErrNoSponsor
+	}
+
+	candidates := s.available(sponsors)
+
+	if s.strategy == engine.SponsorStrategyHighestBalance {
+		return s.highestBalance(candidates)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.next[paymaster] % len(candidates)
+	s.next[paymaster] = i + 1
+
+	return candidates[i], nil
+}
+
+// available filters out the drained sponsors, when all of them are drained they are all tried again
+func (s *sponsorSelector) available(sponsors []sponsorAccount) []sponsorAccount {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+
+	candidates := []sponsorAccount{}
+	for _, sponsor := range sponsors {
+		until, ok := s.drained[sponsor.address]
+		if ok && now.Before(until) {
+			continue
+		}
+
+		delete(s.drained, sponsor.address)
+		candidates = append(candidates, sponsor)
+	}
+
+	if len(candidates) == 0 {
+		return sponsors
+	}
+
+	return candidates
+}
+
+// highestBalance returns the sponsor with the highest balance, sponsors whose balance can't be fetched are skipped
+func (s *sponsorSelector) highestBalance(sponsors []sponsorAccount) (sponsorAccount, error) {
+	var best sponsorAccount
+	var bestBalance *big.Int
+
+	var lastErr error
+	for _, sponsor := range sponsors {
+		balance, err := s.evm.BalanceAt(context.Background(), sponsor.address, nil)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		if bestBalance == nil || balance.Cmp(bestBalance) > 0 {
+			best = sponsor
+			bestBalance = balance
+		}
+	}
+
+	if bestBalance == nil {
+		return sponsorAccount{}, lastErr
+	}
+
+	return best, nil
+}
+
+// markDrained skips a sponsor until the cooldown has passed
+func (s *sponsorSelector) markDrained(sponsor common.Address) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.drained[sponsor] = time.Now().Add(drainedCooldown)
+}
@@ -0,0 +1,120 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/citizenwallet/engine/pkg/engine"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+type balanceEVM struct {
+	engine.EVMRequester
+	balances map[common.Address]*big.Int
+}
+
+func (b *balanceEVM) BalanceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (*big.Int, error) {
+	balance, ok := b.balances[account]
+	if !ok {
+		return nil, errors.New("unknown account")
+	}
+
+	return balance, nil
+}
+
+func testSponsors() []sponsorAccount {
+	return []sponsorAccount{
+		{address: common.HexToAddress("0x1")},
+		{address: common.HexToAddress("0x2")},
+		{address: common.HexToAddress("0x3")},
+	}
+}
+
+func TestSponsorSelector_RoundRobin(t *testing.T) {
+	sponsors := testSponsors()
+	paymaster := common.HexToAddress("0xa")
+
+	s := newSponsorSelector(engine.SponsorStrategyRoundRobin, nil)
+
+	for i := 0; i < 2*len(sponsors); i++ {
+		picked, err := s.pick(paymaster, sponsors)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if picked.address != sponsors[i%len(sponsors)].address {
+			t.Errorf("pick %d: expected %s, got %s", i, sponsors[i%len(sponsors)].address.Hex(), picked.address.Hex())
+		}
+	}
+}
+
+func TestSponsorSelector_SkipsDrained(t *testing.T) {
+	sponsors := testSponsors()
+	paymaster := common.HexToAddress("0xa")
+
+	s := newSponsorSelector(engine.SponsorStrategyRoundRobin, nil)
+	s.markDrained(sponsors[0].address)
+	s.markDrained(sponsors[1].address)
+
+	for i := 0; i < 3; i++ {
+		picked, err := s.pick(paymaster, sponsors)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if picked.address != sponsors[2].address {
+			t.Errorf("expected the only funded sponsor, got %s", picked.address.Hex())
+		}
+	}
+
+	// when every sponsor is drained they are all tried again
+	s.markDrained(sponsors[2].address)
+
+	picked, err := s.pick(paymaster, sponsors)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if picked.address == (common.Address{}) {
+		t.Error("expected a sponsor to be picked")
+	}
+}
+
+func TestSponsorSelector_HighestBalance(t *testing.T) {
+	sponsors := testSponsors()
+	paymaster := common.HexToAddress("0xa")
+
+	evm := &balanceEVM{balances: map[common.Address]*big.Int{
+		sponsors[0].address: big.NewInt(10),
+		sponsors[1].address: big.NewInt(30),
+	}}
+
+	s := newSponsorSelector(engine.SponsorStrategyHighestBalance, evm)
+
+	picked, err := s.pick(paymaster, sponsors)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if picked.address != sponsors[1].address {
+		t.Errorf("expected %s, got %s", sponsors[1].address.Hex(), picked.address.Hex())
+	}
+
+	evm.balances = map[common.Address]*big.Int{}
+
+	_, err = s.pick(paymaster, sponsors)
+	if err == nil {
+		t.Error("expected an error when no balance can be fetched")
+	}
+}
+
+func TestSponsorSelector_NoSponsors(t *testing.T) {
+	s := newSponsorSelector(engine.SponsorStrategyRoundRobin, nil)
+
+	_, err := s.pick(common.HexToAddress("0xa"), nil)
+	if err != ErrNoSponsor {
+		t.Errorf("expected %v, got %v", ErrNoSponsor, err)
+	}
+}
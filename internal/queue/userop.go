@@ -1,45 +1,130 @@
 package queue
 
 import (
+	"bytes"
 	"context"
-	"crypto/ecdsa"
 	"encoding/json"
 	"fmt"
+	"log"
+	"math/big"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/citizenwallet/engine/internal/db"
+	"github.com/citizenwallet/engine/internal/tracing"
 	"github.com/citizenwallet/engine/internal/ws"
 	comm "github.com/citizenwallet/engine/pkg/common"
 	"github.com/citizenwallet/engine/pkg/engine"
 	"github.com/citizenwallet/smartcontracts/pkg/contracts/tokenEntryPoint"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
-	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/rpc"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// batchWorkers is how many batches are processed at the same time
+const batchWorkers = 4
+
 type UserOpService struct {
-	inProgress map[common.Address][]string
-	mu         sync.Mutex
-	db         *db.DB
-	evm        engine.EVMRequester
-	pushq      *Service
-	pools      *ws.ConnectionPools
+	inProgress  map[common.Address][]inProgressTx // in progress transactions per sponsor
+	inFlight    map[common.Address]int            // user operations that are queued or being submitted per sender
+	maxInFlight int                               // per sender, 0 is unlimited
+	mu          sync.Mutex
+	sponsorMu   map[common.Address]*sync.Mutex // serializes the submissions of each sponsor
+	db          *db.DB
+	evm         engine.EVMRequester
+	pushq       *Service
+	pools       *ws.ConnectionPools
+	entryPoints engine.EntryPoints
+	sponsors    *sponsorSelector
+	maxBatchGas uint64
 }
 
 func NewUserOpService(db *db.DB,
 	evm engine.EVMRequester,
 	pushq *Service,
-	pools *ws.ConnectionPools) *UserOpService {
+	pools *ws.ConnectionPools,
+	entryPoints engine.EntryPoints,
+	sponsorStrategy engine.SponsorStrategy) *UserOpService {
 	return &UserOpService{
-		inProgress: map[common.Address][]string{},
-		db:         db,
-		evm:        evm,
-		pushq:      pushq,
-		pools:      pools,
+		inProgress:  map[common.Address][]inProgressTx{},
+		inFlight:    map[common.Address]int{},
+		sponsorMu:   map[common.Address]*sync.Mutex{},
+		db:          db,
+		evm:         evm,
+		pushq:       pushq,
+		pools:       pools,
+		entryPoints: entryPoints,
+		sponsors:    newSponsorSelector(sponsorStrategy, evm),
+		maxBatchGas: DefaultMaxBatchGas,
+	}
+}
+
+// SetMaxBatchGas sets the gas ceiling of a handleOps transaction, 0 disables splitting
+func (s *UserOpService) SetMaxBatchGas(gas uint64) {
+	s.maxBatchGas = gas
+}
+
+// SetMaxInFlightPerSender sets how many user operations of a sender can be queued or submitted at the same time, 0 is unlimited
+func (s *UserOpService) SetMaxInFlightPerSender(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.maxInFlight = n
+}
+
+// lockSponsor locks the submissions of a sponsor and returns the function to unlock them
+func (s *UserOpService) lockSponsor(sponsor common.Address) func() {
+	s.mu.Lock()
+	l, ok := s.sponsorMu[sponsor]
+	if !ok {
+		l = &sync.Mutex{}
+		s.sponsorMu[sponsor] = l
+	}
+	s.mu.Unlock()
+
+	l.Lock()
+
+	return l.Unlock
+}
+
+// batchKey groups the user operations that can be submitted in the same transaction
+type batchKey struct {
+	entrypoint common.Address
+	paymaster  common.Address
+}
+
+// userOpsByNonce sorts the user operations of a batch and their messages by sender and nonce
+type userOpsByNonce struct {
+	txms []engine.UserOpMessage
+	msgs []engine.Message
+}
+
+func (b userOpsByNonce) Len() int { return len(b.txms) }
+
+func (b userOpsByNonce) Less(i, j int) bool {
+	if c := bytes.Compare(b.txms[i].UserOp.Sender.Bytes(), b.txms[j].UserOp.Sender.Bytes()); c != 0 {
+		return c < 0
 	}
+
+	return nonceOf(b.txms[i].UserOp).Cmp(nonceOf(b.txms[j].UserOp)) < 0
+}
+
+func (b userOpsByNonce) Swap(i, j int) {
+	b.txms[i], b.txms[j] = b.txms[j], b.txms[i]
+	b.msgs[i], b.msgs[j] = b.msgs[j], b.msgs[i]
+}
+
+func nonceOf(op engine.UserOp) *big.Int {
+	if op.Nonce == nil {
+		return common.Big0
+	}
+
+	return op.Nonce
 }
 
 // Process method processes messages of type []engine.Message and returns processed messages and an errors if any.
@@ -47,11 +132,18 @@ func (s *UserOpService) Process(messages []engine.Message) (invalid []engine.Mes
 	invalid = []engine.Message{}
 	errors = []error{}
 
-	messagesByEntryPoint := map[common.Address][]engine.Message{}
-	txmByEntryPoint := map[common.Address][]engine.UserOpMessage{}
+	messagesByBatch := map[batchKey][]engine.Message{}
+	txmByBatch := map[batchKey][]engine.UserOpMessage{}
+	calls := []engine.Message{}
 
-	// first organize messages by txm.EntryPoint
+	// first organize messages by txm.EntryPoint and txm.Paymaster
 	for _, message := range messages {
+		// calls are sent on their own
+		if _, ok := message.Message.(engine.CallMessage); ok {
+			calls = append(calls, message)
+			continue
+		}
+
 		// Type assertion to check if the msgs... is of type engine.UserOpMessage
 		txm, ok := message.Message.(engine.UserOpMessage)
 		if !ok {
@@ -61,315 +153,414 @@ func (s *UserOpService) Process(messages []engine.Message) (invalid []engine.Mes
 			continue
 		}
 
-		messagesByEntryPoint[txm.EntryPoint] = append(messagesByEntryPoint[txm.EntryPoint], message)
-		txmByEntryPoint[txm.EntryPoint] = append(txmByEntryPoint[txm.EntryPoint], txm)
+		// never submit to an entrypoint we don't know about
+		if !s.entryPoints.Supports(txm.EntryPoint) {
+			invalid = append(invalid, message)
+			errors = append(errors, fmt.Errorf("%w: %s", engine.ErrUnsupportedEntryPoint, txm.EntryPoint.Hex()))
+			continue
+		}
+
+		key := batchKey{entrypoint: txm.EntryPoint, paymaster: txm.Paymaster}
+		messagesByBatch[key] = append(messagesByBatch[key], message)
+		txmByBatch[key] = append(txmByBatch[key], txm)
 	}
 
-	// go through each entry point and process the messages
-	for entrypoint, txms := range txmByEntryPoint {
-		sampleTxm := txms[0] // use the first txm to get information we need to process the messages
-		msgs := messagesByEntryPoint[entrypoint]
+	// process the batches in parallel, batches that share a sponsor wait for each other to keep its nonces in order
+	var wg sync.WaitGroup
+	var resultsMu sync.Mutex
+	workers := make(chan struct{}, batchWorkers)
 
-		// Fetch the sponsor's corresponding private key from the database
-		sponsorKey, err := s.db.SponsorDB.GetSponsor(sampleTxm.Paymaster.Hex())
-		if err != nil {
-			invalid = append(invalid, msgs...)
-			for range msgs {
-				for range msgs {
-					errors = append(errors, err)
-				}
-			}
-			continue
-		}
+	for batch, txms := range txmByBatch {
+		msgs := messagesByBatch[batch]
 
-		// Generate ecdsa.PrivateKey from bytes
-		privateKey, err := comm.HexToPrivateKey(sponsorKey.PrivateKey)
-		if err != nil {
-			invalid = append(invalid, msgs...)
-			for range msgs {
-				errors = append(errors, err)
-			}
-			continue
-		}
+		wg.Add(1)
+		workers <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-workers }()
 
-		// Get the public key from the private key
-		publicKey := privateKey.Public().(*ecdsa.PublicKey)
+			binvalid, berrors := s.processBatch(batch, txms, msgs)
 
-		// Convert the public key to an Ethereum address
-		sponsor := crypto.PubkeyToAddress(*publicKey)
+			resultsMu.Lock()
+			invalid = append(invalid, binvalid...)
+			errors = append(errors, berrors...)
+			resultsMu.Unlock()
+		}()
+	}
 
-		// Get the nonce for the sponsor's address
-		nonce, err := s.evm.NonceAt(context.Background(), sponsor, nil)
-		if err != nil {
-			invalid = append(invalid, msgs...)
-			for range msgs {
-				errors = append(errors, err)
+	for _, msg := range calls {
+		wg.Add(1)
+		workers <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-workers }()
+
+			err := s.processCall(msg, msg.Message.(engine.CallMessage))
+			if err == nil {
+				return
 			}
-			continue
-		}
 
-		// Get the in progress transactions for the entrypoint and increment the nonce
-		inProgress := s.inProgress[entrypoint]
-		nonce += uint64(len(inProgress))
+			resultsMu.Lock()
+			invalid = append(invalid, msg)
+			errors = append(errors, err)
+			resultsMu.Unlock()
+		}()
+	}
 
-		// Parse the contract ABI
-		parsedABI, err := tokenEntryPoint.TokenEntryPointMetaData.GetAbi()
-		if err != nil {
-			invalid = append(invalid, msgs...)
-			for range msgs {
-				errors = append(errors, err)
-			}
-			continue
-		}
+	wg.Wait()
 
-		ops := []tokenEntryPoint.UserOperation{}
+	return invalid, errors
+}
 
-		for _, txm := range txms {
-			ops = append(ops, tokenEntryPoint.UserOperation(txm.UserOp))
-		}
+// selectSponsor picks the sponsor of the paymaster that sends the next transaction
+func (s *UserOpService) selectSponsor(paymaster common.Address) (sponsorAccount, error) {
+	// Fetch the paymaster's sponsor keys from the database
+	sponsorKeys, err := s.db.SponsorDB.GetSponsors(paymaster.Hex())
+	if err != nil {
+		return sponsorAccount{}, err
+	}
 
-		// Pack the function name and arguments into calldata
-		data, err := parsedABI.Pack("handleOps", ops, sampleTxm.EntryPoint)
-		if err != nil {
-			invalid = append(invalid, msgs...)
-			for range msgs {
-				errors = append(errors, err)
-			}
-			continue
-		}
+	accounts, err := parseSponsors(sponsorKeys)
+	if err != nil {
+		return sponsorAccount{}, err
+	}
 
-		// Create a new transaction
-		tx, err := s.evm.NewTx(nonce, sponsor, sampleTxm.EntryPoint, data, false)
-		if err != nil {
-			invalid = append(invalid, msgs...)
-			for range msgs {
-				errors = append(errors, err)
-			}
-			continue
+	return s.sponsors.pick(paymaster, accounts)
+}
+
+// processBatch submits the user operations of a single entrypoint and paymaster with one of the paymaster's sponsors
+func (s *UserOpService) processBatch(batch batchKey, txms []engine.UserOpMessage, msgs []engine.Message) (invalid []engine.Message, errors []error) {
+	// handleOps executes the operations in the order they are packed, the operations of a sender have to follow their nonces
+	sort.Stable(userOpsByNonce{txms: txms, msgs: msgs})
+
+	// the batch continues the trace of its first message and links to the others
+	scs := make([]trace.SpanContext, len(msgs))
+	for i, msg := range msgs {
+		scs[i] = msg.TraceContext
+	}
+
+	ctx := trace.ContextWithSpanContext(context.Background(), scs[0])
+	ctx, span := tracer.Start(ctx, "userop.submit", trace.WithLinks(tracing.Links(scs[1:]...)...), trace.WithAttributes(
+		attribute.String("userop.entrypoint", batch.entrypoint.Hex()),
+		attribute.String("userop.paymaster", batch.paymaster.Hex()),
+		attribute.Int("userop.count", len(msgs)),
+	))
+	defer func() {
+		if len(errors) > 0 {
+			span.RecordError(errors[0])
+			span.SetStatus(codes.Error, errors[0].Error())
+		}
+		span.End()
+	}()
+
+	// Select the sponsor that submits this batch
+	selected, err := s.selectSponsor(batch.paymaster)
+	if err != nil {
+		invalid = append(invalid, msgs...)
+		for range msgs {
+			errors = append(errors, err)
 		}
+		return
+	}
 
-		// Sign the transaction
-		signedTx, err := types.SignTx(tx, types.NewLondonSigner(sampleTxm.ChainId), privateKey)
-		if err != nil {
-			invalid = append(invalid, msgs...)
-			for range msgs {
-				errors = append(errors, err)
-			}
-			continue
+	sponsor := selected.address
+
+	span.SetAttributes(attribute.String("userop.sponsor", sponsor.Hex()))
+
+	// batches of the same sponsor are submitted one after the other so that their nonces don't collide
+	unlock := s.lockSponsor(sponsor)
+	defer unlock()
+
+	// a batch that doesn't fit in the gas ceiling is sent as several handleOps transactions of the same sponsor,
+	// they are submitted in order so that the operations of a sender keep following their nonces
+	for _, chunk := range splitByGas(txms, msgs, s.maxBatchGas) {
+		cinvalid, cerrors := s.submit(ctx, selected, chunk.txms, chunk.msgs)
+		invalid = append(invalid, cinvalid...)
+		errors = append(errors, cerrors...)
+	}
+
+	return invalid, errors
+}
+
+// submit sends the user operations in one handleOps transaction signed by the sponsor, the sponsor must be locked
+func (s *UserOpService) submit(ctx context.Context, selected sponsorAccount, txms []engine.UserOpMessage, msgs []engine.Message) (invalid []engine.Message, errors []error) {
+	sampleTxm := txms[0] // use the first txm to get information we need to process the messages
+
+	privateKey := selected.key
+	sponsor := selected.address
+
+	// Get the nonce for the sponsor's address
+	nonce, err := s.evm.NonceAt(context.Background(), sponsor, nil)
+	if err != nil {
+		invalid = append(invalid, msgs...)
+		for range msgs {
+			errors = append(errors, err)
 		}
+		return
+	}
 
-		signedTxHash := signedTx.Hash().Hex()
+	// Get the in progress transactions for the sponsor and increment the nonce
+	nonce = s.nextNonce(sponsor, nonce)
 
-		// update inProgress
-		s.mu.Lock()
-		s.inProgress[entrypoint] = append(s.inProgress[entrypoint], signedTxHash)
-		s.mu.Unlock()
+	// Parse the contract ABI
+	parsedABI, err := tokenEntryPoint.TokenEntryPointMetaData.GetAbi()
+	if err != nil {
+		invalid = append(invalid, msgs...)
+		for range msgs {
+			errors = append(errors, err)
+		}
+		return
+	}
 
-		insertedLogs := map[common.Address][]*engine.Log{}
+	ops := []tokenEntryPoint.UserOperation{}
 
-		ldb := s.db.LogDB
-		edb := s.db.EventDB
+	for _, txm := range txms {
+		ops = append(ops, tokenEntryPoint.UserOperation(txm.UserOp))
+	}
 
-		events, err := edb.GetEvents()
-		if err != nil {
-			invalid = append(invalid, msgs...)
-			for range msgs {
-				errors = append(errors, err)
-			}
-			continue
+	// Pack the function name and arguments into calldata
+	data, err := parsedABI.Pack("handleOps", ops, sampleTxm.EntryPoint)
+	if err != nil {
+		invalid = append(invalid, msgs...)
+		for range msgs {
+			errors = append(errors, err)
 		}
+		return
+	}
 
-		for _, txm := range txms {
-			// Detect if this user operation is a transfer using the call data
+	// Create a new transaction
+	tx, err := s.evm.NewTx(nonce, sponsor, sampleTxm.EntryPoint, data, false)
+	if err != nil {
+		invalid = append(invalid, msgs...)
+		for range msgs {
+			errors = append(errors, err)
+		}
+		return
+	}
 
-			userop := txm.UserOp
-			data, ok := txm.Data.(*json.RawMessage)
-			if !ok {
-				data = nil
-			}
+	// Sign the transaction
+	signedTx, err := types.SignTx(tx, types.NewLondonSigner(sampleTxm.ChainId), privateKey)
+	if err != nil {
+		invalid = append(invalid, msgs...)
+		for range msgs {
+			errors = append(errors, err)
+		}
+		return
+	}
 
-			if data == nil {
-				// if there is no data, it is impossible for us to generate a stable unique hash
-				// so we skip it
-				continue
-			}
+	signedTxHash := signedTx.Hash().Hex()
 
-			var dataMap map[string]any
-			if err := json.Unmarshal(*data, &dataMap); err != nil {
-				continue
-			}
+	// update inProgress
+	s.addInProgress(sponsor, inProgressTx{
+		hash:   signedTxHash,
+		nonce:  nonce,
+		to:     sampleTxm.EntryPoint,
+		sentAt: time.Now(),
+	})
 
-			// there is data, let's check if it is valid according to any of the event signatures that we are indexing
-			valid := false
-			for _, event := range events {
-				if event.IsValidData(dataMap) {
-					// we have a match
-					valid = true
-					break
-				}
-			}
+	insertedLogs := map[common.Address][]*engine.Log{}
 
-			if !valid {
-				continue
-			}
+	ldb := s.db.LogDB
+	edb := s.db.EventDB
 
-			txdata, ok := txm.ExtraData.(*json.RawMessage)
-			if !ok {
-				// if it's invalid, set it to nil to avoid errors and corrupted json
-				txdata = nil
-			}
+	events, err := edb.GetEvents(ctx)
+	if err != nil {
+		s.removeInProgress(sponsor, signedTxHash)
 
-			// get destination address from calldata
-			dest, err := comm.ParseDestinationFromCallData(userop.CallData)
-			if err != nil {
-				continue
-			}
+		invalid = append(invalid, msgs...)
+		for range msgs {
+			errors = append(errors, err)
+		}
+		return
+	}
 
-			log := &engine.Log{
-				TxHash:    signedTxHash,
-				CreatedAt: time.Now().UTC(),
-				UpdatedAt: time.Now().UTC(),
-				Nonce:     userop.Nonce.Int64(),
-				Sender:    userop.Sender.Hex(),
-				To:        dest.Hex(),
-				Value:     common.Big0,
-				Data:      data,
-				ExtraData: txdata,
-				Status:    engine.LogStatusSending,
-			}
+	for _, txm := range txms {
+		// Detect if this user operation is a transfer using the call data
 
-			log.Hash = log.GenerateUniqueHash()
+		userop := txm.UserOp
+		data, ok := txm.Data.(*json.RawMessage)
+		if !ok {
+			data = nil
+		}
 
-			err = ldb.AddLog(log)
-			if err != nil {
-				println("error adding log", err.Error())
-			}
+		if data == nil {
+			// if there is no data, it is impossible for us to generate a stable unique hash
+			// so we skip it
+			continue
+		}
 
-			// broadcast updates to connected clients
-			s.pools.BroadcastMessage(engine.WSMessageTypeNew, log)
+		var dataMap map[string]any
+		if err := json.Unmarshal(*data, &dataMap); err != nil {
+			continue
+		}
+
+		// there is data, let's check if it is valid according to any of the event signatures that we are indexing
+		_, err := engine.MatchEventData(events, dataMap)
+		if err != nil {
+			println("user operation data is not tracked:", err.Error())
+			continue
+		}
 
-			insertedLogs[txm.Paymaster] = append(insertedLogs[txm.Paymaster], log)
+		txdata, ok := txm.ExtraData.(*json.RawMessage)
+		if !ok {
+			// if it's invalid, set it to nil to avoid errors and corrupted json
+			txdata = nil
 		}
 
-		// Send the signed transaction
-		err = s.evm.SendTransaction(signedTx)
+		// get destination address from calldata
+		dest, err := comm.ParseDestinationFromCallData(userop.CallData)
 		if err != nil {
-			// If there's an error, check if it's an RPC error
-			e, ok := err.(rpc.Error)
-			if ok && e.ErrorCode() != -32000 {
-				// If it's an RPC error and the error code is not -32000, remove the sending transfer and return the error
-				for _, logs := range insertedLogs {
-					for _, log := range logs {
-						ldb.RemoveLog(log.Hash)
-
-						// broadcast updates to connected clients
-						s.pools.BroadcastMessage(engine.WSMessageTypeRemove, log)
-					}
-				}
+			continue
+		}
 
-				invalid = append(invalid, msgs...)
-				for range msgs {
-					errors = append(errors, err)
-				}
+		log := &engine.Log{
+			TxHash:    signedTxHash,
+			CreatedAt: time.Now().UTC(),
+			UpdatedAt: time.Now().UTC(),
+			Nonce:     userop.Nonce.Int64(),
+			Sender:    userop.Sender.Hex(),
+			To:        dest.Hex(),
+			Value:     common.Big0,
+			Data:      data,
+			ExtraData: txdata,
+			Status:    engine.LogStatusSending,
+		}
 
-				// remove from inProgress
-				s.mu.Lock()
-				s.inProgress[entrypoint] = comm.Filter(s.inProgress[entrypoint], func(s string) bool {
-					return s != signedTxHash
-				})
-				s.mu.Unlock()
-				continue
-			}
+		log.Hash = log.GenerateUniqueHash()
 
-			if !strings.Contains(e.Error(), "insufficient funds") {
-				// If the error is not about insufficient funds, remove the sending transfer and return the error
-				for _, logs := range insertedLogs {
-					for _, log := range logs {
-						ldb.RemoveLog(log.Hash)
+		err = ldb.AddLog(ctx, log)
+		if err != nil {
+			println("error adding log", err.Error())
+		}
 
-						// broadcast updates to connected clients
-						s.pools.BroadcastMessage(engine.WSMessageTypeRemove, log)
-					}
-				}
+		// broadcast updates to connected clients
+		s.pools.BroadcastMessage(engine.WSMessageTypeNew, log)
 
-				invalid = append(invalid, msgs...)
-				for range msgs {
-					errors = append(errors, err)
+		insertedLogs[txm.Paymaster] = append(insertedLogs[txm.Paymaster], log)
+	}
+
+	// Send the signed transaction
+	_, sspan := tracer.Start(ctx, "eth.sendTransaction", trace.WithAttributes(attribute.String("tx.hash", signedTxHash)))
+	err = s.evm.SendTransaction(signedTx)
+	if err != nil {
+		sspan.RecordError(err)
+		sspan.SetStatus(codes.Error, err.Error())
+	}
+	sspan.End()
+	if err != nil {
+		// If there's an error, check if it's an RPC error
+		e, ok := err.(rpc.Error)
+		if ok && e.ErrorCode() != -32000 {
+			// If it's an RPC error and the error code is not -32000, remove the sending transfer and return the error
+			for _, logs := range insertedLogs {
+				for _, log := range logs {
+					ldb.RemoveLog(ctx, log.Hash)
+
+					// broadcast updates to connected clients
+					s.pools.BroadcastMessage(engine.WSMessageTypeRemove, log)
 				}
+			}
 
-				// remove from inProgress
-				s.mu.Lock()
-				s.inProgress[entrypoint] = comm.Filter(s.inProgress[entrypoint], func(s string) bool {
-					return s != signedTxHash
-				})
-				s.mu.Unlock()
-				continue
+			invalid = append(invalid, msgs...)
+			for range msgs {
+				errors = append(errors, err)
 			}
 
+			// remove from inProgress
+			s.removeInProgress(sponsor, signedTxHash)
+			return
+		}
+
+		if !strings.Contains(err.Error(), "insufficient funds") {
+			// If the error is not about insufficient funds, remove the sending transfer and return the error
 			for _, logs := range insertedLogs {
 				for _, log := range logs {
-					ldb.SetStatus(log.Hash, string(engine.LogStatusFail))
+					ldb.RemoveLog(ctx, log.Hash)
 
 					// broadcast updates to connected clients
-					log.Status = engine.LogStatusFail
-					s.pools.BroadcastMessage(engine.WSMessageTypeUpdate, log)
+					s.pools.BroadcastMessage(engine.WSMessageTypeRemove, log)
 				}
 			}
 
-			// Return the error about insufficient funds
 			invalid = append(invalid, msgs...)
 			for range msgs {
 				errors = append(errors, err)
 			}
 
 			// remove from inProgress
-			s.mu.Lock()
-			s.inProgress[entrypoint] = comm.Filter(s.inProgress[entrypoint], func(s string) bool {
-				return s != signedTxHash
-			})
-			s.mu.Unlock()
-			continue
-		}
-
-		// Respond to the messages with the tx hash
-		for _, msg := range msgs {
-			msg.Respond(signedTxHash, nil)
+			s.removeInProgress(sponsor, signedTxHash)
+			return
 		}
 
 		for _, logs := range insertedLogs {
 			for _, log := range logs {
-				err := ldb.SetStatus(log.Hash, string(engine.LogStatusPending))
-				if err != nil {
-					ldb.RemoveLog(log.Hash)
+				ldb.SetStatus(ctx, string(engine.LogStatusFail), log.Hash)
 
-					// broadcast updates to connected clients
-					s.pools.BroadcastMessage(engine.WSMessageTypeRemove, log)
-				}
+				// broadcast updates to connected clients
+				log.Status = engine.LogStatusFail
+				s.pools.BroadcastMessage(engine.WSMessageTypeUpdate, log)
 			}
 		}
 
-		go func() {
-			// async wait for the transaction to be mined
-			err = s.evm.WaitForTx(signedTx, 16)
+		// let the other sponsors of the paymaster take over while this one is refilled
+		s.sponsors.markDrained(sponsor)
+
+		// Return the error about insufficient funds
+		invalid = append(invalid, msgs...)
+		for range msgs {
+			errors = append(errors, err)
+		}
+
+		// remove from inProgress
+		s.removeInProgress(sponsor, signedTxHash)
+		return
+	}
+
+	// Respond to the messages with the tx hash
+	for _, msg := range msgs {
+		msg.Respond(signedTxHash, nil)
+	}
+
+	for _, logs := range insertedLogs {
+		for _, log := range logs {
+			err := ldb.SetStatus(ctx, string(engine.LogStatusPending), log.Hash)
 			if err != nil {
-				for _, logs := range insertedLogs {
-					for _, log := range logs {
-						ldb.RemoveLog(log.Hash)
+				ldb.RemoveLog(ctx, log.Hash)
 
-						// broadcast updates to connected clients
-						s.pools.BroadcastMessage(engine.WSMessageTypeRemove, log)
-					}
-				}
+				// broadcast updates to connected clients
+				s.pools.BroadcastMessage(engine.WSMessageTypeRemove, log)
 			}
+		}
+	}
 
-			// remove from inProgress
-			s.mu.Lock()
-			s.inProgress[entrypoint] = comm.Filter(s.inProgress[entrypoint], func(s string) bool {
-				return s != signedTxHash
-			})
-			s.mu.Unlock()
+	go func() {
+		// the tx is never left in progress, even if waiting for it panics
+		defer s.removeInProgress(sponsor, signedTxHash)
+		defer func() {
+			if pv := recover(); pv != nil {
+				log.Default().Printf("panic while waiting for tx %s: %v\n", signedTxHash, pv)
+			}
 		}()
-	}
 
+		_, wspan := tracer.Start(ctx, "eth.waitForTx", trace.WithAttributes(attribute.String("tx.hash", signedTxHash)))
+		defer wspan.End()
+
+		// async wait for the transaction to be mined
+		err = s.evm.WaitForTx(signedTx, 16)
+		if err != nil {
+			wspan.RecordError(err)
+			wspan.SetStatus(codes.Error, err.Error())
+		}
+		if err != nil {
+			for _, logs := range insertedLogs {
+				for _, log := range logs {
+					ldb.RemoveLog(ctx, log.Hash)
+
+					// broadcast updates to connected clients
+					s.pools.BroadcastMessage(engine.WSMessageTypeRemove, log)
+				}
+			}
+		}
+	}()
 	return invalid, errors
 }
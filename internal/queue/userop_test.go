@@ -0,0 +1,214 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/citizenwallet/engine/pkg/engine"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestUserOpProcess_UnsupportedEntryPoint(t *testing.T) {
+	supported := common.HexToAddress("0x1")
+	s := NewUserOpService(nil, nil, nil, nil, engine.EntryPoints{supported}, engine.SponsorStrategyRoundRobin)
+
+	messages := []engine.Message{
+		*engine.NewTxMessage(common.Address{}, common.HexToAddress("0x2"), common.Big0, engine.UserOp{}, nil, nil),
+		{ID: "invalid", CreatedAt: time.Now(), Message: "invalid"},
+		*engine.NewTxMessage(common.Address{}, common.HexToAddress("0x3"), common.Big0, engine.UserOp{}, nil, nil),
+	}
+
+	invalid, errs := s.Process(messages)
+	if len(invalid) != len(messages) || len(errs) != len(messages) {
+		t.Fatalf("expected %d invalid messages and errors, got %d and %d", len(messages), len(invalid), len(errs))
+	}
+
+	for i, msg := range invalid {
+		_, isUserOp := msg.Message.(engine.UserOpMessage)
+		if isUserOp != errors.Is(errs[i], engine.ErrUnsupportedEntryPoint) {
+			t.Errorf("message %s got the wrong error: %v", msg.ID, errs[i])
+		}
+	}
+}
+
+func TestLockSponsor(t *testing.T) {
+	s := NewUserOpService(nil, nil, nil, nil, nil, engine.SponsorStrategyRoundRobin)
+	sponsor := common.HexToAddress("0x1")
+
+	var active, overlaps atomic.Int64
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			unlock := s.lockSponsor(sponsor)
+			defer unlock()
+
+			if active.Add(1) > 1 {
+				overlaps.Add(1)
+			}
+			time.Sleep(time.Millisecond)
+			active.Add(-1)
+		}()
+	}
+
+	wg.Wait()
+
+	if overlaps.Load() != 0 {
+		t.Errorf("expected the submissions of a sponsor to never overlap, got %d overlaps", overlaps.Load())
+	}
+
+	// other sponsors are not blocked
+	unlock := s.lockSponsor(sponsor)
+	defer unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.lockSponsor(common.HexToAddress("0x2"))()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Error("expected another sponsor to not be blocked")
+	}
+}
+
+type nonceEVM struct {
+	engine.EVMRequester
+	nonce uint64
+}
+
+func (n *nonceEVM) NonceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (uint64, error) {
+	return n.nonce, nil
+}
+
+func TestInProgress_Reconcile(t *testing.T) {
+	evm := &nonceEVM{nonce: 5}
+	s := NewUserOpService(nil, evm, nil, nil, nil, engine.SponsorStrategyRoundRobin)
+
+	sponsor := common.HexToAddress("0x1")
+	ep1, ep2 := common.HexToAddress("0xa"), common.HexToAddress("0xb")
+	now := time.Now()
+
+	s.addInProgress(sponsor, inProgressTx{hash: "mined", nonce: 4, to: ep1, sentAt: now})
+	s.addInProgress(sponsor, inProgressTx{hash: "expired", nonce: 5, to: ep1, sentAt: now.Add(-time.Hour)})
+	s.addInProgress(sponsor, inProgressTx{hash: "pending", nonce: 6, to: ep2, sentAt: now})
+
+	// transactions the chain has caught up with don't push the nonce
+	if nonce := s.nextNonce(sponsor, 5); nonce != 7 {
+		t.Errorf("expected next nonce 7, got %d", nonce)
+	}
+
+	counts := s.InProgress()
+	if counts[ep1.Hex()] != 2 || counts[ep2.Hex()] != 1 {
+		t.Errorf("unexpected in progress counts %v", counts)
+	}
+
+	s.cleanInProgress(context.Background(), time.Minute, now)
+
+	txs := s.inProgress[sponsor]
+	if len(txs) != 1 || txs[0].hash != "pending" {
+		t.Fatalf("expected only the pending tx to be kept, got %+v", txs)
+	}
+
+	s.removeInProgress(sponsor, "pending")
+
+	if _, ok := s.inProgress[sponsor]; ok {
+		t.Error("expected the sponsor to be removed once it has nothing in progress")
+	}
+}
+
+func TestUserOpsByNonce(t *testing.T) {
+	alice, bob := common.HexToAddress("0x1"), common.HexToAddress("0x2")
+
+	ops := []struct {
+		sender common.Address
+		nonce  int64
+	}{
+		{bob, 1},
+		{alice, 2},
+		{alice, 0},
+		{bob, 0},
+		{alice, 1},
+	}
+
+	txms := []engine.UserOpMessage{}
+	msgs := []engine.Message{}
+	for _, op := range ops {
+		msg := engine.NewTxMessage(common.Address{}, common.Address{}, common.Big0, engine.UserOp{Sender: op.sender, Nonce: big.NewInt(op.nonce)}, nil, nil)
+		msgs = append(msgs, *msg)
+		txms = append(txms, msg.Message.(engine.UserOpMessage))
+	}
+
+	sort.Stable(userOpsByNonce{txms: txms, msgs: msgs})
+
+	expected := []struct {
+		sender common.Address
+		nonce  int64
+	}{
+		{alice, 0},
+		{alice, 1},
+		{alice, 2},
+		{bob, 0},
+		{bob, 1},
+	}
+
+	for i, e := range expected {
+		op := txms[i].UserOp
+		if op.Sender != e.sender || op.Nonce.Int64() != e.nonce {
+			t.Errorf("op %d: expected %s/%d, got %s/%d", i, e.sender.Hex(), e.nonce, op.Sender.Hex(), op.Nonce.Int64())
+		}
+
+		// the messages follow their user operations
+		if msgs[i].Message.(engine.UserOpMessage).UserOp.Nonce != op.Nonce {
+			t.Errorf("message %d is not the message of its user operation", i)
+		}
+	}
+}
+
+func TestAcquireSender(t *testing.T) {
+	s := NewUserOpService(nil, nil, nil, nil, nil, engine.SponsorStrategyRoundRobin)
+	s.SetMaxInFlightPerSender(2)
+
+	flooder := common.HexToAddress("0x1")
+	other := common.HexToAddress("0x2")
+
+	release1, err := s.AcquireSender(flooder)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if _, err := s.AcquireSender(flooder); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if _, err := s.AcquireSender(flooder); err != ErrSenderBusy {
+		t.Fatalf("expected ErrSenderBusy, got %v", err)
+	}
+
+	// other senders proceed
+	if _, err := s.AcquireSender(other); err != nil {
+		t.Fatalf("expected another sender to proceed, got %v", err)
+	}
+
+	// releasing twice frees a single slot
+	release1()
+	release1()
+
+	if _, err := s.AcquireSender(flooder); err != nil {
+		t.Fatalf("expected a released slot to be available, got %v", err)
+	}
+
+	if _, err := s.AcquireSender(flooder); err != ErrSenderBusy {
+		t.Fatalf("expected ErrSenderBusy, got %v", err)
+	}
+}
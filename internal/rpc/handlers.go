@@ -1,21 +1,184 @@
 package rpc
 
 import (
+	"context"
+	"encoding/json"
 	"net/http"
+	"strings"
+	"sync"
 
 	"github.com/citizenwallet/engine/internal/ws"
+	comm "github.com/citizenwallet/engine/pkg/common"
+	"github.com/citizenwallet/engine/pkg/engine"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/go-chi/chi/v5"
+)
+
+const poolTopic = "rpc"
+
+const (
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
 )
 
 type Handlers struct {
-	Manager *ws.ConnectionPool
+	evm     engine.EVMRequester
+	pools   *ws.ConnectionPools
+	methods map[string]engine.RPCHandlerFunc
+
+	heads     event.Feed
+	headsOnce sync.Once
 }
 
-func NewHandlers() *Handlers {
+func NewHandlers(evm engine.EVMRequester, pools *ws.ConnectionPools, methods map[string]engine.RPCHandlerFunc) *Handlers {
 	return &Handlers{
-		Manager: ws.NewConnectionPool("rpc"),
+		evm:     evm,
+		pools:   pools,
+		methods: methods,
 	}
 }
 
+// HandleConnection upgrades the request to a websocket and answers the JSON RPC requests sent over it
 func (h *Handlers) HandleConnection(w http.ResponseWriter, r *http.Request) {
-	h.Manager.Connect(w, r)
+	h.pools.ConnectWithHandler(w, r, poolTopic, h.dispatcher(r))
+}
+
+// subscribeHeads subscribes to new block headers, a single upstream subscription is shared by all connections
+func (h *Handlers) subscribeHeads(ch chan<- *types.Header) event.Subscription {
+	h.headsOnce.Do(func() {
+		go func() {
+			ctx := h.evm.Context()
+
+			heads := make(chan *types.Header)
+			go h.evm.ListenForHeads(ctx, heads)
+
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case head := <-heads:
+					h.heads.Send(head)
+				}
+			}
+		}()
+	})
+
+	return h.heads.Subscribe(ch)
+}
+
+// dispatcher returns a message handler that calls the rpc methods with the url params of the websocket request
+func (h *Handlers) dispatcher(r *http.Request) ws.MessageHandler {
+	// chi recycles the route context once the upgrade returns, the url params need to be copied
+	rctx := chi.NewRouteContext()
+	if params := chi.RouteContext(r.Context()); params != nil {
+		for i, key := range params.URLParams.Keys {
+			rctx.URLParams.Add(key, params.URLParams.Values[i])
+		}
+	}
+
+	// the request context is cancelled once the upgrade returns as well
+	ctx := context.WithValue(context.Background(), chi.RouteCtxKey, rctx)
+	url := r.URL.String()
+
+	newRequest := func(params json.RawMessage) (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, strings.NewReader(string(params)))
+		if err != nil {
+			return nil, err
+		}
+		req.ContentLength = int64(len(params))
+
+		return req, nil
+	}
+
+	// subscriptions only exist on a websocket, they belong to the connection
+	s := newSession(h)
+
+	methods := make(map[string]engine.RPCHandlerFunc, len(h.methods)+2)
+	for method, handler := range h.methods {
+		methods[method] = handler
+	}
+	methods["eth_subscribe"] = s.subscribe
+	methods["eth_unsubscribe"] = s.unsubscribe
+
+	return func(client *ws.Client, message []byte) []byte {
+		return s.handle(client, message, methods, newRequest)
+	}
+}
+
+// dispatch calls the methods of a single or batch JSON RPC request and returns the encoded response,
+// newRequest creates the request that is passed to a method with its params as body
+func dispatch(methods map[string]engine.RPCHandlerFunc, message []byte, newRequest func(params json.RawMessage) (*http.Request, error)) []byte {
+	message = []byte(strings.TrimSpace(string(message)))
+
+	// batch request
+	if len(message) > 0 && message[0] == '[' {
+		var reqs []engine.JsonRPCRequest
+		if err := json.Unmarshal(message, &reqs); err != nil {
+			return encode(errorResponse(nil, codeParseError, "parse error"))
+		}
+
+		if len(reqs) == 0 {
+			return encode(errorResponse(nil, codeInvalidRequest, "invalid request"))
+		}
+
+		responses := make([]engine.JsonRPCResponse, len(reqs))
+		for i, req := range reqs {
+			responses[i] = call(methods, req, newRequest)
+		}
+
+		return encode(responses)
+	}
+
+	var req engine.JsonRPCRequest
+	if err := json.Unmarshal(message, &req); err != nil {
+		return encode(errorResponse(nil, codeParseError, "parse error"))
+	}
+
+	return encode(call(methods, req, newRequest))
+}
+
+// call calls the method of a single JSON RPC request
+func call(methods map[string]engine.RPCHandlerFunc, req engine.JsonRPCRequest, newRequest func(params json.RawMessage) (*http.Request, error)) engine.JsonRPCResponse {
+	if req.Method == "" {
+		return errorResponse(req.ID, codeInvalidRequest, "invalid request")
+	}
+
+	h, ok := methods[req.Method]
+	if !ok {
+		return errorResponse(req.ID, codeMethodNotFound, "method not found")
+	}
+
+	r, err := newRequest(req.Params)
+	if err != nil {
+		return comm.NewJSONRPCResponse(req.ID, nil, err)
+	}
+
+	body, err := h(r)
+	if err != nil {
+		println(err.Error())
+	}
+
+	return comm.NewJSONRPCResponse(req.ID, body, err)
+}
+
+func errorResponse(id any, code int, message string) engine.JsonRPCResponse {
+	return engine.JsonRPCResponse{
+		Version: "2.0",
+		ID:      id,
+		Error: &engine.JSONRPCError{
+			Code:    code,
+			Message: message,
+		},
+	}
+}
+
+func encode(v any) []byte {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+
+	return b
 }
@@ -0,0 +1,130 @@
+package rpc
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/citizenwallet/engine/internal/ws"
+	"github.com/citizenwallet/engine/pkg/engine"
+	"github.com/go-chi/chi/v5"
+	"github.com/gorilla/websocket"
+)
+
+func testMethods() map[string]engine.RPCHandlerFunc {
+	return map[string]engine.RPCHandlerFunc{
+		"echo": func(r *http.Request) (any, error) {
+			b, err := io.ReadAll(r.Body)
+			if err != nil {
+				return nil, err
+			}
+
+			return json.RawMessage(b), nil
+		},
+		"address": func(r *http.Request) (any, error) {
+			return chi.URLParam(r, "pm_address"), nil
+		},
+		"fail": func(r *http.Request) (any, error) {
+			return nil, errors.New("failed")
+		},
+	}
+}
+
+func newTestRequest(params json.RawMessage) (*http.Request, error) {
+	return http.NewRequest(http.MethodPost, "/", strings.NewReader(string(params)))
+}
+
+func TestDispatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		message  string
+		expected string
+	}{
+		{
+			name:     "single",
+			message:  `{"jsonrpc":"2.0","id":1,"method":"echo","params":["0x1"]}`,
+			expected: `{"jsonrpc":"2.0","id":1,"result":["0x1"]}`,
+		},
+		{
+			name:     "batch",
+			message:  `[{"jsonrpc":"2.0","id":1,"method":"echo","params":[1]},{"jsonrpc":"2.0","id":"b","method":"echo","params":[2]}]`,
+			expected: `[{"jsonrpc":"2.0","id":1,"result":[1]},{"jsonrpc":"2.0","id":"b","result":[2]}]`,
+		},
+		{
+			name:     "method error",
+			message:  `{"jsonrpc":"2.0","id":2,"method":"fail","params":[]}`,
+			expected: `{"jsonrpc":"2.0","id":2,"result":null,"error":{"code":-32000,"message":"failed","data":null}}`,
+		},
+		{
+			name:     "method not found",
+			message:  `{"jsonrpc":"2.0","id":3,"method":"eth_unknown","params":[]}`,
+			expected: `{"jsonrpc":"2.0","id":3,"result":null,"error":{"code":-32601,"message":"method not found","data":null}}`,
+		},
+		{
+			name:     "batch with method not found",
+			message:  `[{"jsonrpc":"2.0","id":1,"method":"eth_unknown"},{"jsonrpc":"2.0","id":2,"method":"echo","params":[2]}]`,
+			expected: `[{"jsonrpc":"2.0","id":1,"result":null,"error":{"code":-32601,"message":"method not found","data":null}},{"jsonrpc":"2.0","id":2,"result":[2]}]`,
+		},
+		{
+			name:     "parse error",
+			message:  `{"jsonrpc":`,
+			expected: `{"jsonrpc":"2.0","id":null,"result":null,"error":{"code":-32700,"message":"parse error","data":null}}`,
+		},
+		{
+			name:     "empty batch",
+			message:  `[]`,
+			expected: `{"jsonrpc":"2.0","id":null,"result":null,"error":{"code":-32600,"message":"invalid request","data":null}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := dispatch(testMethods(), []byte(tt.message), newTestRequest)
+			if string(got) != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestHandleConnection(t *testing.T) {
+	h := NewHandlers(nil, ws.NewConnectionPools(), testMethods())
+
+	cr := chi.NewRouter()
+	cr.Get("/rpc/{pm_address}", h.HandleConnection)
+
+	srv := httptest.NewServer(cr)
+	defer srv.Close()
+
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/rpc/0xpaymaster"
+
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// the url params of the websocket request should still be available after the upgrade
+	for i := 0; i < 2; i++ {
+		err = conn.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","id":7,"method":"address","params":[]}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		_, msg, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := `{"jsonrpc":"2.0","id":7,"result":"0xpaymaster"}`
+		if string(msg) != expected {
+			t.Errorf("expected %s, got %s", expected, msg)
+		}
+	}
+}
@@ -0,0 +1,267 @@
+package rpc
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/citizenwallet/engine/internal/ws"
+	"github.com/citizenwallet/engine/pkg/engine"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+const (
+	subscriptionNewHeads = "newHeads"
+	subscriptionLogs     = "logs"
+)
+
+var (
+	ErrInvalidSubscription  = errors.New("invalid subscription params")
+	ErrUnknownSubscription  = errors.New("unsupported subscription type")
+	ErrMissingLogsAddress   = errors.New("logs subscriptions require an address")
+	ErrUnsubscribeInvalidID = errors.New("invalid subscription id")
+)
+
+type subscriptionNotification struct {
+	Version string             `json:"jsonrpc"`
+	Method  string             `json:"method"`
+	Params  subscriptionResult `json:"params"`
+}
+
+type subscriptionResult struct {
+	Subscription string `json:"subscription"`
+	Result       any    `json:"result"`
+}
+
+// logsFilter matches broadcast logs against the address and first topic of an eth_subscribe logs filter
+type logsFilter struct {
+	addresses []string
+	topics    []string
+}
+
+func (f *logsFilter) UnmarshalJSON(b []byte) error {
+	var raw struct {
+		Address json.RawMessage   `json:"address"`
+		Topics  []json.RawMessage `json:"topics"`
+	}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+
+	addresses, err := stringOrList(raw.Address)
+	if err != nil {
+		return err
+	}
+	f.addresses = addresses
+
+	// logs are pooled by their event topic, only the first topic can be matched
+	if len(raw.Topics) > 0 {
+		topics, err := stringOrList(raw.Topics[0])
+		if err != nil {
+			return err
+		}
+		f.topics = topics
+	}
+
+	return nil
+}
+
+func (f *logsFilter) matches(m *engine.WSMessageLog) bool {
+	contract, topic, ok := strings.Cut(m.PoolID, "/")
+	if !ok {
+		return false
+	}
+
+	return matchesAny(f.addresses, contract) && (len(f.topics) == 0 || matchesAny(f.topics, topic))
+}
+
+// stringOrList parses a filter value that can be null, a string or a list of strings
+func stringOrList(b json.RawMessage) ([]string, error) {
+	if len(b) == 0 || string(b) == "null" {
+		return nil, nil
+	}
+
+	var v string
+	if err := json.Unmarshal(b, &v); err == nil {
+		return []string{v}, nil
+	}
+
+	var vs []string
+	if err := json.Unmarshal(b, &vs); err != nil {
+		return nil, ErrInvalidSubscription
+	}
+
+	return vs, nil
+}
+
+func matchesAny(values []string, v string) bool {
+	for _, value := range values {
+		if strings.EqualFold(value, v) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// session holds the subscriptions of a single websocket connection
+type session struct {
+	h      *Handlers
+	client *ws.Client
+	start  sync.Once
+
+	mu   sync.Mutex
+	subs map[string]func()
+}
+
+func newSession(h *Handlers) *session {
+	return &session{
+		h:    h,
+		subs: make(map[string]func()),
+	}
+}
+
+// handle dispatches a message from the client of the session
+func (s *session) handle(client *ws.Client, message []byte, methods map[string]engine.RPCHandlerFunc, newRequest func(params json.RawMessage) (*http.Request, error)) []byte {
+	s.start.Do(func() {
+		s.client = client
+
+		// clean up the subscriptions once the client disconnects
+		go func() {
+			<-client.Done()
+			s.unsubscribeAll()
+		}()
+	})
+
+	return dispatch(methods, message, newRequest)
+}
+
+// subscribe handles eth_subscribe
+func (s *session) subscribe(r *http.Request) (any, error) {
+	var params []json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil || len(params) == 0 {
+		return nil, ErrInvalidSubscription
+	}
+
+	var kind string
+	if err := json.Unmarshal(params[0], &kind); err != nil {
+		return nil, ErrInvalidSubscription
+	}
+
+	id, err := newSubscriptionID()
+	if err != nil {
+		return nil, err
+	}
+
+	var stop func()
+	switch kind {
+	case subscriptionNewHeads:
+		stop = s.subscribeHeads(id)
+	case subscriptionLogs:
+		var filter logsFilter
+		if len(params) > 1 {
+			if err := json.Unmarshal(params[1], &filter); err != nil {
+				return nil, ErrInvalidSubscription
+			}
+		}
+
+		if len(filter.addresses) == 0 {
+			return nil, ErrMissingLogsAddress
+		}
+
+		stop = s.h.pools.Listen(func(m *engine.WSMessageLog) {
+			if filter.matches(m) {
+				s.notify(id, m)
+			}
+		})
+	default:
+		return nil, ErrUnknownSubscription
+	}
+
+	s.mu.Lock()
+	s.subs[id] = stop
+	s.mu.Unlock()
+
+	return id, nil
+}
+
+// subscribeHeads forwards new block headers from the upstream node until the returned function is called
+func (s *session) subscribeHeads(id string) func() {
+	ch := make(chan *types.Header, 16)
+	sub := s.h.subscribeHeads(ch)
+
+	go func() {
+		for {
+			select {
+			case head := <-ch:
+				s.notify(id, head)
+			case <-sub.Err():
+				return
+			}
+		}
+	}()
+
+	return sub.Unsubscribe
+}
+
+// unsubscribe handles eth_unsubscribe
+func (s *session) unsubscribe(r *http.Request) (any, error) {
+	var params []string
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil || len(params) == 0 {
+		return nil, ErrUnsubscribeInvalidID
+	}
+
+	s.mu.Lock()
+	stop, ok := s.subs[params[0]]
+	delete(s.subs, params[0])
+	s.mu.Unlock()
+
+	if !ok {
+		return false, nil
+	}
+
+	stop()
+
+	return true, nil
+}
+
+func (s *session) unsubscribeAll() {
+	s.mu.Lock()
+	subs := s.subs
+	s.subs = make(map[string]func())
+	s.mu.Unlock()
+
+	for _, stop := range subs {
+		stop()
+	}
+}
+
+// notify sends a subscription notification to the client, it is dropped if the client is not keeping up
+func (s *session) notify(id string, result any) {
+	b, err := json.Marshal(&subscriptionNotification{
+		Version: "2.0",
+		Method:  "eth_subscription",
+		Params: subscriptionResult{
+			Subscription: id,
+			Result:       result,
+		},
+	})
+	if err != nil {
+		return
+	}
+
+	s.client.Send(b)
+}
+
+func newSubscriptionID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hexutil.Encode(b), nil
+}
@@ -0,0 +1,183 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/citizenwallet/engine/internal/ws"
+	"github.com/citizenwallet/engine/pkg/engine"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/go-chi/chi/v5"
+	"github.com/gorilla/websocket"
+)
+
+// headsEVM only implements what is needed to subscribe to new heads
+type headsEVM struct {
+	engine.EVMRequester
+
+	ctx   context.Context
+	heads chan *types.Header
+}
+
+func (e *headsEVM) Context() context.Context {
+	return e.ctx
+}
+
+func (e *headsEVM) ListenForHeads(ctx context.Context, ch chan<- *types.Header) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case head := <-e.heads:
+			ch <- head
+		}
+	}
+}
+
+func connectRPC(t *testing.T, h *Handlers) *websocket.Conn {
+	t.Helper()
+
+	cr := chi.NewRouter()
+	cr.Get("/rpc/{pm_address}", h.HandleConnection)
+
+	srv := httptest.NewServer(cr)
+	t.Cleanup(srv.Close)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/rpc/0xpaymaster", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return conn
+}
+
+// request sends a request over the websocket and decodes the response into result
+func request(t *testing.T, conn *websocket.Conn, msg string, result any) {
+	t.Helper()
+
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(msg)); err != nil {
+		t.Fatal(err)
+	}
+
+	var resp struct {
+		Result json.RawMessage      `json:"result"`
+		Error  *engine.JSONRPCError `json:"error"`
+	}
+	readJSON(t, conn, &resp)
+
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %s", resp.Error.Message)
+	}
+
+	if err := json.Unmarshal(resp.Result, result); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readJSON(t *testing.T, conn *websocket.Conn, v any) {
+	t.Helper()
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if err := conn.ReadJSON(v); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSubscribeLogs(t *testing.T) {
+	pools := ws.NewConnectionPools()
+	conn := connectRPC(t, NewHandlers(nil, pools, testMethods()))
+
+	var id string
+	request(t, conn, `{"jsonrpc":"2.0","id":1,"method":"eth_subscribe","params":["logs",{"address":"0xToken","topics":["0xTopic"]}]}`, &id)
+
+	other := json.RawMessage(`{"topic":"0xOther"}`)
+	pools.BroadcastMessage(engine.WSMessageTypeNew, &engine.Log{Hash: "0x1", To: "0xtoken", Value: big.NewInt(1), Data: &other})
+
+	data := json.RawMessage(`{"topic":"0xtopic"}`)
+	pools.BroadcastMessage(engine.WSMessageTypeNew, &engine.Log{Hash: "0x2", To: "0xtoken", Value: big.NewInt(1), Data: &data})
+
+	// only the log with a matching topic should be received
+	var n struct {
+		Method string `json:"method"`
+		Params struct {
+			Subscription string              `json:"subscription"`
+			Result       engine.WSMessageLog `json:"result"`
+		} `json:"params"`
+	}
+	readJSON(t, conn, &n)
+
+	if n.Method != "eth_subscription" || n.Params.Subscription != id {
+		t.Fatalf("unexpected notification %+v", n)
+	}
+
+	if n.Params.Result.ID != "0x2" {
+		t.Errorf("expected log %s, got %s", "0x2", n.Params.Result.ID)
+	}
+
+	var ok bool
+	request(t, conn, `{"jsonrpc":"2.0","id":2,"method":"eth_unsubscribe","params":["`+id+`"]}`, &ok)
+	if !ok {
+		t.Error("expected subscription to be removed")
+	}
+
+	request(t, conn, `{"jsonrpc":"2.0","id":3,"method":"eth_unsubscribe","params":["`+id+`"]}`, &ok)
+	if ok {
+		t.Error("expected subscription to be removed only once")
+	}
+}
+
+func TestSubscribeLogsRequiresAddress(t *testing.T) {
+	conn := connectRPC(t, NewHandlers(nil, ws.NewConnectionPools(), testMethods()))
+
+	err := conn.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","id":1,"method":"eth_subscribe","params":["logs",{}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var resp engine.JsonRPCResponse
+	readJSON(t, conn, &resp)
+
+	if resp.Error == nil || resp.Error.Message != ErrMissingLogsAddress.Error() {
+		t.Errorf("expected error %s, got %+v", ErrMissingLogsAddress, resp.Error)
+	}
+}
+
+func TestSubscribeNewHeads(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	evm := &headsEVM{ctx: ctx, heads: make(chan *types.Header)}
+	conn := connectRPC(t, NewHandlers(evm, ws.NewConnectionPools(), testMethods()))
+
+	var id string
+	request(t, conn, `{"jsonrpc":"2.0","id":1,"method":"eth_subscribe","params":["newHeads"]}`, &id)
+
+	go func() {
+		select {
+		case evm.heads <- &types.Header{Number: big.NewInt(42), Difficulty: big.NewInt(0)}:
+		case <-ctx.Done():
+		}
+	}()
+
+	var n struct {
+		Params struct {
+			Subscription string        `json:"subscription"`
+			Result       *types.Header `json:"result"`
+		} `json:"params"`
+	}
+	readJSON(t, conn, &n)
+
+	if n.Params.Subscription != id {
+		t.Errorf("expected subscription %s, got %s", id, n.Params.Subscription)
+	}
+
+	if n.Params.Result == nil || n.Params.Result.Number.Int64() != 42 {
+		t.Errorf("expected head %d, got %+v", 42, n.Params.Result)
+	}
+}
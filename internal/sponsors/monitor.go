@@ -0,0 +1,147 @@
+package sponsors
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/citizenwallet/engine/internal/db"
+	com "github.com/citizenwallet/engine/pkg/common"
+	"github.com/citizenwallet/engine/pkg/engine"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Monitor periodically checks the balances of the sponsor accounts and warns when they run low
+type Monitor struct {
+	ctx       context.Context
+	db        *db.DB
+	evm       engine.EVMRequester
+	webhook   engine.WebhookMessager
+	threshold *big.Int
+	interval  time.Duration
+
+	mu       sync.Mutex
+	balances map[string]*engine.SponsorBalance // by sponsor address
+}
+
+func NewMonitor(ctx context.Context, db *db.DB, evm engine.EVMRequester, webhook engine.WebhookMessager, threshold *big.Int, interval time.Duration) *Monitor {
+	return &Monitor{
+		ctx:       ctx,
+		db:        db,
+		evm:       evm,
+		webhook:   webhook,
+		threshold: threshold,
+		interval:  interval,
+		balances:  map[string]*engine.SponsorBalance{},
+	}
+}
+
+// Start checks the balances immediately and then on every interval until the context is done
+func (m *Monitor) Start() error {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		sponsors, err := m.db.SponsorDB.GetAllSponsors()
+		if err != nil {
+			log.Default().Println("error fetching sponsors: ", err.Error())
+		} else {
+			m.check(sponsors)
+		}
+
+		select {
+		case <-m.ctx.Done():
+			return m.ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Balances returns the last known balance of every sponsor account
+func (m *Monitor) Balances() []*engine.SponsorBalance {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	balances := make([]*engine.SponsorBalance, 0, len(m.balances))
+	for _, b := range m.balances {
+		balance := *b
+		balances = append(balances, &balance)
+	}
+
+	sort.Slice(balances, func(i, j int) bool {
+		if balances[i].Contract != balances[j].Contract {
+			return balances[i].Contract < balances[j].Contract
+		}
+
+		return balances[i].Address < balances[j].Address
+	})
+
+	return balances
+}
+
+// check fetches the balance of each sponsor and alerts once when one drops below the threshold
+func (m *Monitor) check(sponsors []*engine.Sponsor) {
+	current := map[string]*engine.SponsorBalance{}
+
+	for _, sponsor := range sponsors {
+		key, err := com.HexToPrivateKey(sponsor.PrivateKey)
+		if err != nil {
+			log.Default().Println("error parsing sponsor key for: ", sponsor.Contract)
+			continue
+		}
+
+		addr := crypto.PubkeyToAddress(key.PublicKey)
+
+		balance, err := m.evm.BalanceAt(m.ctx, addr, nil)
+		if err != nil {
+			log.Default().Println("error fetching sponsor balance: ", err.Error())
+
+			// keep the last known balance rather than dropping the sponsor from the list
+			m.mu.Lock()
+			if prev, ok := m.balances[addr.Hex()]; ok {
+				current[addr.Hex()] = prev
+			}
+			m.mu.Unlock()
+			continue
+		}
+
+		low := balance.Cmp(m.threshold) < 0
+
+		m.mu.Lock()
+		prev, ok := m.balances[addr.Hex()]
+		wasLow := ok && prev.Low
+		m.mu.Unlock()
+
+		if low && !wasLow {
+			m.alert(fmt.Errorf("sponsor %s of %s is running low: %s wei left, threshold is %s wei", addr.Hex(), sponsor.Contract, balance.String(), m.threshold.String()))
+		}
+
+		current[addr.Hex()] = &engine.SponsorBalance{
+			Contract:  sponsor.Contract,
+			Address:   addr.Hex(),
+			Balance:   balance.String(),
+			Low:       low,
+			CheckedAt: time.Now().UTC(),
+		}
+	}
+
+	m.mu.Lock()
+	m.balances = current
+	m.mu.Unlock()
+}
+
+func (m *Monitor) alert(err error) {
+	log.Default().Println(err.Error())
+
+	if m.webhook == nil {
+		return
+	}
+
+	if werr := m.webhook.NotifyWarning(m.ctx, err); werr != nil {
+		log.Default().Println("error sending sponsor alert: ", werr.Error())
+	}
+}
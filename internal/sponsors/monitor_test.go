@@ -0,0 +1,85 @@
+package sponsors
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/citizenwallet/engine/pkg/engine"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+type balanceEVM struct {
+	engine.EVMRequester
+	balance *big.Int
+}
+
+func (b *balanceEVM) BalanceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (*big.Int, error) {
+	return b.balance, nil
+}
+
+type recordingMessager struct {
+	warnings []error
+}
+
+func (m *recordingMessager) Notify(ctx context.Context, message string) error {
+	return nil
+}
+
+func (m *recordingMessager) NotifyWarning(ctx context.Context, errorMessage error) error {
+	m.warnings = append(m.warnings, errorMessage)
+	return nil
+}
+
+func (m *recordingMessager) NotifyError(ctx context.Context, errorMessage error) error {
+	return nil
+}
+
+func TestMonitor_AlertsOnceWhenLow(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sponsors := []*engine.Sponsor{
+		{Contract: "0x1", PrivateKey: hexutil.Encode(crypto.FromECDSA(key))[2:]},
+	}
+
+	evm := &balanceEVM{balance: big.NewInt(100)}
+	msgr := &recordingMessager{}
+
+	m := NewMonitor(context.Background(), nil, evm, msgr, big.NewInt(50), time.Minute)
+
+	m.check(sponsors)
+	if len(msgr.warnings) != 0 {
+		t.Fatalf("expected no alert above the threshold, got %d", len(msgr.warnings))
+	}
+
+	evm.balance = big.NewInt(10)
+	m.check(sponsors)
+	m.check(sponsors)
+	if len(msgr.warnings) != 1 {
+		t.Fatalf("expected a single alert while the balance stays low, got %d", len(msgr.warnings))
+	}
+
+	balances := m.Balances()
+	if len(balances) != 1 {
+		t.Fatalf("expected 1 balance, got %d", len(balances))
+	}
+
+	if balances[0].Address != crypto.PubkeyToAddress(key.PublicKey).Hex() || balances[0].Balance != "10" || !balances[0].Low {
+		t.Errorf("unexpected balance %+v", balances[0])
+	}
+
+	// refilling and draining again alerts again
+	evm.balance = big.NewInt(100)
+	m.check(sponsors)
+	evm.balance = big.NewInt(10)
+	m.check(sponsors)
+	if len(msgr.warnings) != 2 {
+		t.Errorf("expected a new alert after a refill, got %d", len(msgr.warnings))
+	}
+}
@@ -0,0 +1,155 @@
+package stats
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/citizenwallet/engine/internal/db"
+	com "github.com/citizenwallet/engine/pkg/common"
+	"github.com/citizenwallet/engine/pkg/engine"
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	// the aggregate queries scan all the transfers of a period, results are cached for a short while
+	cacheTTL        = 1 * time.Minute
+	maxCacheEntries = 1000
+)
+
+// defaultRanges is how far back the stats go when no from date is given
+var defaultRanges = map[engine.StatsPeriod]time.Duration{
+	engine.StatsPeriodDay:   30 * 24 * time.Hour,
+	engine.StatsPeriodWeek:  26 * 7 * 24 * time.Hour,
+	engine.StatsPeriodMonth: 365 * 24 * time.Hour,
+}
+
+type cacheEntry struct {
+	stats   []*engine.TransferStats
+	expires time.Time
+}
+
+type Service struct {
+	db *db.DB
+
+	mu    sync.Mutex
+	cache map[string]cacheEntry
+}
+
+func NewService(db *db.DB) *Service {
+	return &Service{
+		db:    db,
+		cache: map[string]cacheEntry{},
+	}
+}
+
+// Get returns a time series of the transfer count, active accounts and volume of a contract
+//
+// period is one of day, week or month and defaults to day, from and to are optional RFC3339 dates
+func (s *Service) Get(w http.ResponseWriter, r *http.Request) {
+	// parse contract address from url params
+	contract, err := com.NormalizeAddress(chi.URLParam(r, "contract_address"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	// parse period from url query
+	period := engine.StatsPeriod(r.URL.Query().Get("period"))
+	if period == "" {
+		period = engine.StatsPeriodDay
+	}
+
+	if !period.IsValid() {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	// parse the date range from url query
+	fromq := r.URL.Query().Get("from")
+	toq := r.URL.Query().Get("to")
+
+	to := time.Now().UTC()
+	if toq != "" {
+		t, err := time.Parse(time.RFC3339, toq)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		to = t.UTC()
+	}
+
+	from := to.Add(-defaultRanges[period])
+	if fromq != "" {
+		t, err := time.Parse(time.RFC3339, fromq)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		from = t.UTC()
+	}
+
+	if !from.Before(to) {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	// the default range moves with every request, cache on the query instead of the dates
+	key := contract + ":" + string(period) + ":" + fromq + ":" + toq
+
+	stats, ok := s.cached(key)
+	if !ok {
+		stats, err = s.db.LogDB.GetTransferStats(r.Context(), contract, period, from, to)
+		if err != nil {
+			if db.IsQueryTimeout(err) {
+				http.Error(w, "query timed out", http.StatusServiceUnavailable)
+				return
+			}
+
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		s.store(key, stats)
+	}
+
+	err = com.BodyMultiple(w, stats, nil)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+func (s *Service) cached(key string) ([]*engine.TransferStats, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.cache[key]
+	if !ok || time.Now().After(entry.expires) {
+		return nil, false
+	}
+
+	return entry.stats, true
+}
+
+func (s *Service) store(key string, stats []*engine.TransferStats) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if len(s.cache) >= maxCacheEntries {
+		for k, entry := range s.cache {
+			if now.After(entry.expires) {
+				delete(s.cache, k)
+			}
+		}
+	}
+
+	if len(s.cache) >= maxCacheEntries {
+		// the cache is full of fresh entries, drop them all rather than growing
+		s.cache = map[string]cacheEntry{}
+	}
+
+	s.cache[key] = cacheEntry{stats: stats, expires: now.Add(cacheTTL)}
+}
@@ -0,0 +1,68 @@
+package stats
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/citizenwallet/engine/pkg/engine"
+	"github.com/go-chi/chi/v5"
+)
+
+func TestGetInvalidParams(t *testing.T) {
+	tests := []struct {
+		name     string
+		contract string
+		query    string
+	}{
+		{"invalid contract", "not_an_address", ""},
+		{"invalid period", "0x480fbe37526226b6c6e2a7afa449cdf661939d2f", "period=year"},
+		{"invalid from", "0x480fbe37526226b6c6e2a7afa449cdf661939d2f", "from=yesterday"},
+		{"from after to", "0x480fbe37526226b6c6e2a7afa449cdf661939d2f", "from=2024-02-01T00:00:00Z&to=2024-01-01T00:00:00Z"},
+	}
+
+	s := NewService(nil)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("contract_address", tt.contract)
+
+			r := httptest.NewRequest(http.MethodGet, "/v1/stats/"+tt.contract+"?"+tt.query, nil)
+			r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+
+			w := httptest.NewRecorder()
+			s.Get(w, r)
+
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("expected %d, got %d", http.StatusBadRequest, w.Code)
+			}
+		})
+	}
+}
+
+func TestCache(t *testing.T) {
+	s := NewService(nil)
+
+	if _, ok := s.cached("key"); ok {
+		t.Fatal("expected an empty cache")
+	}
+
+	stats := []*engine.TransferStats{{Transfers: 1, Volume: "10"}}
+	s.store("key", stats)
+
+	cached, ok := s.cached("key")
+	if !ok || len(cached) != 1 || cached[0].Volume != "10" {
+		t.Fatalf("expected the stored stats, got %v", cached)
+	}
+
+	for i := 0; i < maxCacheEntries+1; i++ {
+		s.store(strconv.Itoa(i), stats)
+	}
+
+	if len(s.cache) > maxCacheEntries {
+		t.Errorf("expected at most %d entries, got %d", maxCacheEntries, len(s.cache))
+	}
+}
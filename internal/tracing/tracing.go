@@ -0,0 +1,62 @@
+package tracing
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Setup exports spans over OTLP/HTTP when enabled, the exporter is configured by the standard OTEL_EXPORTER_OTLP_* env vars
+//
+// the returned function flushes the remaining spans, when tracing is disabled spans are dropped
+func Setup(ctx context.Context, enabled bool, serviceName string) (func(context.Context) error, error) {
+	// propagate the trace context of incoming requests either way so that it shows up in the logs of clients
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	if !enabled {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.New(ctx,
+		resource.WithAttributes(attribute.String("service.name", serviceName)),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+
+	otel.SetTracerProvider(tp)
+
+	return tp.Shutdown, nil
+}
+
+// Links returns links to the spans of the given span contexts, invalid ones are skipped
+func Links(scs ...trace.SpanContext) []trace.Link {
+	links := []trace.Link{}
+	for _, sc := range scs {
+		if !sc.IsValid() {
+			continue
+		}
+
+		links = append(links, trace.Link{SpanContext: sc})
+	}
+
+	return links
+}
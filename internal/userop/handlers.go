@@ -5,40 +5,111 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"log"
 	"math/big"
 	"net/http"
 	"time"
 
 	"github.com/citizenwallet/engine/internal/db"
+	"github.com/citizenwallet/engine/internal/paymaster"
 	"github.com/citizenwallet/engine/internal/queue"
 	comm "github.com/citizenwallet/engine/pkg/common"
 	"github.com/citizenwallet/engine/pkg/engine"
 	pay "github.com/citizenwallet/smartcontracts/pkg/contracts/paymaster"
 	"github.com/ethereum/go-ethereum/accounts"
-	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/go-chi/chi/v5"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var tracer = otel.Tracer("github.com/citizenwallet/engine/internal/userop")
+
+// submissions that stay pending for longer than this can be retried with the same idempotency key
+const idempotencyStale = 1 * time.Minute
+
+const (
+	saturationRatio = 0.95            // user operations are rejected when the queue is filled up to this ratio
+	busyRetryAfter  = 5 * time.Second // how long clients should wait before sending again when the queue is saturated
+)
+
+var (
+	ErrIdempotencyKeyReused  = errors.New("idempotency key was already used for another user operation")
+	ErrIdempotencyKeyPending = errors.New("user operation with this idempotency key is still being processed")
+)
+
+// BusyError is the JSON RPC error of a user operation that is rejected because the queue is saturated
+type BusyError struct {
+	RetryAfter time.Duration
+}
+
+func (e *BusyError) Error() string {
+	return "server busy, try again later"
+}
+
+// ErrorCode is the JSON RPC code of a request that exceeds a limit (EIP-1474)
+func (e *BusyError) ErrorCode() int {
+	return -32005
+}
+
+// ErrorData tells clients how many seconds to wait before sending again
+func (e *BusyError) ErrorData() any {
+	return map[string]int64{"retryAfter": int64(e.RetryAfter / time.Second)}
+}
+
 type Service struct {
-	evm     engine.EVMRequester
-	db      *db.DB
-	useropq *queue.Service
-	chainId *big.Int
+	evm         engine.EVMRequester
+	db          *db.DB
+	useropq     *queue.Service
+	userOps     *queue.UserOpService
+	chainId     *big.Int
+	entryPoints engine.EntryPoints
 }
 
 // NewService
-func NewService(evm engine.EVMRequester, db *db.DB, useropq *queue.Service, chid *big.Int) *Service {
+func NewService(evm engine.EVMRequester, db *db.DB, useropq *queue.Service, userOps *queue.UserOpService, chid *big.Int, entryPoints engine.EntryPoints) *Service {
 	return &Service{
 		evm,
 		db,
 		useropq,
+		userOps,
 		chid,
+		entryPoints,
+	}
+}
+
+// SupportedEntryPoints returns the entrypoints user operations can be sent to
+func (s *Service) SupportedEntryPoints(r *http.Request) (any, error) {
+	eps := make([]string, len(s.entryPoints))
+	for i, ep := range s.entryPoints {
+		eps[i] = ep.Hex()
 	}
+
+	return eps, nil
 }
 
 func (s *Service) Send(r *http.Request) (any, error) {
+	ctx, span := tracer.Start(r.Context(), "userop.send")
+	defer span.End()
+
+	resp, err := s.send(r.WithContext(ctx))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	return resp, err
+}
+
+func (s *Service) send(r *http.Request) (any, error) {
+	// a saturated queue sheds load before any work is done so that clients back off instead of waiting
+	if s.useropq.Saturated(saturationRatio) {
+		return nil, &BusyError{RetryAfter: busyRetryAfter}
+	}
+
 	// parse contract address from url params
 	contractAddr := chi.URLParam(r, "pm_address")
 
@@ -128,34 +199,23 @@ func (s *Service) Send(r *http.Request) (any, error) {
 		return nil, errors.New("error missing entry point address")
 	}
 
-	// check the paymaster signature, make sure it matches the paymaster address
+	entryPoint, err := comm.ParseAddress(epAddr)
+	if err != nil {
+		return nil, errors.New("invalid entry point address")
+	}
 
-	// unpack the validity and check if it is valid
-	// Define the arguments
-	uint48Ty, _ := abi.NewType("uint48", "uint48", nil)
-	args := abi.Arguments{
-		abi.Argument{
-			Type: uint48Ty,
-		},
-		abi.Argument{
-			Type: uint48Ty,
-		},
+	if !s.entryPoints.Supports(entryPoint) {
+		return nil, fmt.Errorf("%w: %s", engine.ErrUnsupportedEntryPoint, entryPoint.Hex())
 	}
 
-	// Encode the values
-	validity, err := args.Unpack(userop.PaymasterAndData[20:84])
+	// check the paymaster signature, make sure it matches the paymaster address
+	pmAddr, validUntil, validAfter, sig, err := paymaster.ParsePaymasterAndData(userop.PaymasterAndData)
 	if err != nil {
 		return nil, err
 	}
 
-	validUntil, ok := validity[0].(*big.Int)
-	if !ok {
-		return nil, errors.New("error unmarshalling validity")
-	}
-
-	validAfter, ok := validity[1].(*big.Int)
-	if !ok {
-		return nil, errors.New("error unmarshalling validity")
+	if pmAddr != addr {
+		return nil, errors.New("paymaster and data is for another paymaster")
 	}
 
 	// check if the signature is theoretically still valid
@@ -177,9 +237,6 @@ func (s *Service) Send(r *http.Request) (any, error) {
 	// Convert the hash to an Ethereum signed message hash
 	hhash := accounts.TextHash(hash[:])
 
-	sig := make([]byte, len(userop.PaymasterAndData[84:]))
-	copy(sig, userop.PaymasterAndData[84:])
-
 	// update the signature v to undo the 27/28 addition
 	sig[crypto.RecoveryIDOffset] -= 27
 
@@ -209,17 +266,59 @@ func (s *Service) Send(r *http.Request) (any, error) {
 		return nil, errors.New("paymaster signature does not match")
 	}
 
-	entryPoint := common.HexToAddress(epAddr)
-
 	// Create a new message
 	message := engine.NewTxMessage(addr, entryPoint, s.chainId, userop, data, xdata)
 
-	// Enqueue the message
-	s.useropq.Enqueue(*message)
+	// a retried submission with the same idempotency key returns the original result instead of enqueueing again
+	sender := userop.Sender.Hex()
+	key := r.Header.Get(engine.IdempotencyKeyHeader)
+	if key != "" {
+		reserved, sub, err := s.db.UserOpDB.ReserveIdempotencyKey(r.Context(), sender, key, message.ID, idempotencyStale)
+		if err != nil {
+			return nil, err
+		}
+
+		if !reserved {
+			return submissionResult(sub, message.ID)
+		}
+	}
+
+	// the result of a submission is stored even if the client is gone, otherwise its key stays pending until it is stale
+	resultCtx := context.WithoutCancel(r.Context())
+
+	// link the processing in the queue to this request
+	message.TraceContext = trace.SpanContextFromContext(r.Context())
+
+	// a sender can only have so many user operations in flight so that it doesn't starve the others
+	release, err := s.userOps.AcquireSender(userop.Sender)
+	if err != nil {
+		if key != "" {
+			s.db.UserOpDB.SetSubmissionResult(resultCtx, sender, key, engine.UserOpStatusFail, "", err.Error())
+		}
+
+		return nil, err
+	}
+	defer release()
+
+	// Enqueue the message, a full queue fails fast so that the client can try again later
+	err = s.useropq.Enqueue(*message)
+	if err != nil {
+		if key != "" {
+			s.db.UserOpDB.SetSubmissionResult(resultCtx, sender, key, engine.UserOpStatusFail, "", err.Error())
+		}
+
+		return nil, err
+	}
 
 	resp, err := message.WaitForResponse()
 	if err != nil {
 		println("error waiting for response", err.Error())
+
+		// on a timeout the user operation could still be submitted, the key stays pending until it is stale
+		if key != "" && !errors.Is(err, engine.ErrRequestTimeout) {
+			s.db.UserOpDB.SetSubmissionResult(resultCtx, sender, key, engine.UserOpStatusFail, "", err.Error())
+		}
+
 		return nil, err
 	}
 
@@ -228,6 +327,33 @@ func (s *Service) Send(r *http.Request) (any, error) {
 		return nil, errors.New("error unmarshalling tx hash")
 	}
 
+	if key != "" {
+		err = s.db.UserOpDB.SetSubmissionResult(resultCtx, sender, key, engine.UserOpStatusSuccess, txHash, "")
+		if err != nil {
+			log.Default().Println("error storing submission result: ", err.Error())
+		}
+	}
+
 	// Return the message ID
 	return txHash, nil
 }
+
+// submissionResult returns the result of a previous submission with the same idempotency key
+func submissionResult(sub *db.UserOpSubmission, id string) (any, error) {
+	if sub == nil {
+		return nil, ErrIdempotencyKeyPending
+	}
+
+	if sub.ID != id {
+		return nil, ErrIdempotencyKeyReused
+	}
+
+	switch sub.Status {
+	case engine.UserOpStatusSuccess:
+		return sub.TxHash, nil
+	case engine.UserOpStatusFail:
+		return nil, errors.New(sub.Error)
+	}
+
+	return nil, ErrIdempotencyKeyPending
+}
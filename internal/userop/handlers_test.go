@@ -0,0 +1,80 @@
+package userop
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/citizenwallet/engine/internal/db"
+	"github.com/citizenwallet/engine/internal/queue"
+	"github.com/citizenwallet/engine/pkg/engine"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestSubmissionResult(t *testing.T) {
+	tests := []struct {
+		name   string
+		sub    *db.UserOpSubmission
+		result any
+		err    error
+	}{
+		{"success returns the original tx hash", &db.UserOpSubmission{ID: "op", Status: engine.UserOpStatusSuccess, TxHash: "0xabc"}, "0xabc", nil},
+		{"pending", &db.UserOpSubmission{ID: "op", Status: engine.UserOpStatusPending}, nil, ErrIdempotencyKeyPending},
+		{"another user operation", &db.UserOpSubmission{ID: "other", Status: engine.UserOpStatusSuccess, TxHash: "0xabc"}, nil, ErrIdempotencyKeyReused},
+		{"missing", nil, nil, ErrIdempotencyKeyPending},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := submissionResult(tt.sub, "op")
+			if err != tt.err {
+				t.Fatalf("expected error %v, got %v", tt.err, err)
+			}
+
+			if result != tt.result {
+				t.Errorf("expected %v, got %v", tt.result, result)
+			}
+		})
+	}
+}
+
+func TestSupportedEntryPoints(t *testing.T) {
+	ep := common.HexToAddress("0x7079253c0358eF9Fd87E16488299Ef6e06F403B6")
+	s := NewService(nil, nil, nil, nil, nil, engine.EntryPoints{ep})
+
+	result, err := s.SupportedEntryPoints(httptest.NewRequest(http.MethodPost, "/", nil))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	eps, ok := result.([]string)
+	if !ok || len(eps) != 1 || eps[0] != ep.Hex() {
+		t.Errorf("expected [%s], got %v", ep.Hex(), result)
+	}
+}
+
+func TestSendRejectsWhenSaturated(t *testing.T) {
+	q, _ := queue.NewService("userop", 3, 20, nil)
+	for i := 0; i < 19; i++ {
+		q.Enqueue(*engine.NewTxMessage(common.Address{}, common.Address{}, common.Big0, engine.UserOp{}, nil, nil))
+	}
+
+	s := NewService(nil, nil, q, nil, nil, nil)
+
+	_, err := s.send(httptest.NewRequest(http.MethodPost, "/", nil))
+
+	var busy *BusyError
+	if !errors.As(err, &busy) {
+		t.Fatalf("expected a busy error, got %v", err)
+	}
+
+	if busy.ErrorCode() != -32005 {
+		t.Errorf("expected code -32005, got %d", busy.ErrorCode())
+	}
+
+	data, ok := busy.ErrorData().(map[string]int64)
+	if !ok || data["retryAfter"] != 5 {
+		t.Errorf("expected a retry hint of 5 seconds, got %v", busy.ErrorData())
+	}
+}
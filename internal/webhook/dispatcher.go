@@ -0,0 +1,207 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/citizenwallet/engine/pkg/engine"
+)
+
+const (
+	// DefaultMaxFailures is the number of failed deliveries in a row after which a webhook is disabled
+	DefaultMaxFailures = 10
+
+	deliveryAttempts = 5
+	deliveryBackoff  = 1 * time.Second
+	deliveryTimeout  = 10 * time.Second
+
+	dispatchWorkers = 4
+	dispatchBuffer  = 1024
+)
+
+// headers of a delivery, the signature is the hex HMAC-SHA256 of "<timestamp>.<body>" with the secret of the webhook
+const (
+	HeaderWebhookID = "X-Webhook-Id"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// LogWebhookStore finds the webhooks of a log and records the outcome of their deliveries
+type LogWebhookStore interface {
+	GetActiveLogWebhooks(contract, topic string) ([]*engine.LogWebhook, error)
+	SetDeliveryResult(id string, deliveryErr error, maxFailures int) (engine.LogWebhookStatus, error)
+}
+
+// Dispatcher posts indexed logs to the webhooks that match them, failed deliveries are retried with a backoff
+type Dispatcher struct {
+	ctx         context.Context
+	store       LogWebhookStore
+	client      *http.Client
+	maxFailures int
+	backoff     time.Duration
+
+	logs chan *engine.Log
+}
+
+func NewDispatcher(ctx context.Context, store LogWebhookStore, maxFailures int) *Dispatcher {
+	return &Dispatcher{
+		ctx:         ctx,
+		store:       store,
+		client:      &http.Client{Timeout: deliveryTimeout},
+		maxFailures: maxFailures,
+		backoff:     deliveryBackoff,
+		logs:        make(chan *engine.Log, dispatchBuffer),
+	}
+}
+
+// Dispatch queues a log for its webhooks without blocking, the log is dropped if the queue is full
+func (d *Dispatcher) Dispatch(l *engine.Log) {
+	select {
+	case d.logs <- l:
+	default:
+		log.Default().Printf("webhook queue is full, dropping log %s\n", l.Hash)
+	}
+}
+
+// Start delivers the queued logs until the context is done
+func (d *Dispatcher) Start() error {
+	for i := 0; i < dispatchWorkers; i++ {
+		go d.work()
+	}
+
+	<-d.ctx.Done()
+
+	return d.ctx.Err()
+}
+
+func (d *Dispatcher) work() {
+	for {
+		select {
+		case <-d.ctx.Done():
+			return
+		case l := <-d.logs:
+			d.dispatch(l)
+		}
+	}
+}
+
+func (d *Dispatcher) dispatch(l *engine.Log) {
+	topic := l.GetTopic()
+	if topic == nil {
+		return
+	}
+
+	hooks, err := d.store.GetActiveLogWebhooks(l.To, strings.ToLower(*topic))
+	if err != nil {
+		log.Default().Println("error fetching log webhooks: ", err.Error())
+		return
+	}
+
+	if len(hooks) == 0 {
+		return
+	}
+
+	// the same payload as a websocket broadcast
+	msg := l.ToWSMessage(engine.WSMessageTypeUpdate)
+	if msg == nil {
+		return
+	}
+
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return
+	}
+
+	for _, h := range hooks {
+		if !h.Matches(l) {
+			continue
+		}
+
+		derr := d.deliver(h, body)
+		if derr != nil {
+			log.Default().Printf("error delivering log %s to webhook %s: %s\n", l.Hash, h.ID, derr.Error())
+		}
+
+		status, err := d.store.SetDeliveryResult(h.ID, derr, d.maxFailures)
+		if err != nil {
+			log.Default().Println("error recording webhook delivery: ", err.Error())
+			continue
+		}
+
+		if derr != nil && status == engine.LogWebhookStatusDisabled {
+			log.Default().Printf("webhook %s is disabled after %d failed deliveries\n", h.ID, d.maxFailures)
+		}
+	}
+}
+
+// deliver posts the body to a webhook, retrying with an exponential backoff
+func (d *Dispatcher) deliver(h *engine.LogWebhook, body []byte) error {
+	var err error
+
+	backoff := d.backoff
+	for attempt := 0; attempt < deliveryAttempts; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-d.ctx.Done():
+				return err
+			case <-time.After(backoff):
+			}
+
+			backoff *= 2
+		}
+
+		err = d.post(h, body)
+		if err == nil {
+			return nil
+		}
+	}
+
+	return err
+}
+
+func (d *Dispatcher) post(h *engine.LogWebhook, body []byte) error {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req, err := http.NewRequestWithContext(d.ctx, http.MethodPost, h.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderWebhookID, h.ID)
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, "sha256="+Sign(h.Secret, timestamp, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with %s", resp.Status)
+	}
+
+	return nil
+}
+
+// Sign returns the signature of a delivery, receivers compute it with their secret to verify a delivery
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
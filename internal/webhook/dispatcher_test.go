@@ -0,0 +1,157 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/citizenwallet/engine/pkg/engine"
+)
+
+type fakeStore struct {
+	mu       sync.Mutex
+	hooks    []*engine.LogWebhook
+	failures map[string]int
+	status   map[string]engine.LogWebhookStatus
+}
+
+func newFakeStore(hooks ...*engine.LogWebhook) *fakeStore {
+	return &fakeStore{
+		hooks:    hooks,
+		failures: map[string]int{},
+		status:   map[string]engine.LogWebhookStatus{},
+	}
+}
+
+func (s *fakeStore) GetActiveLogWebhooks(contract, topic string) ([]*engine.LogWebhook, error) {
+	return s.hooks, nil
+}
+
+func (s *fakeStore) SetDeliveryResult(id string, deliveryErr error, maxFailures int) (engine.LogWebhookStatus, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if deliveryErr == nil {
+		s.failures[id] = 0
+		s.status[id] = engine.LogWebhookStatusActive
+		return s.status[id], nil
+	}
+
+	s.failures[id]++
+	s.status[id] = engine.LogWebhookStatusActive
+	if s.failures[id] >= maxFailures {
+		s.status[id] = engine.LogWebhookStatusDisabled
+	}
+
+	return s.status[id], nil
+}
+
+func testLog(contract string) *engine.Log {
+	d := json.RawMessage(`{"topic":"0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef","from":"0x1111111111111111111111111111111111111111"}`)
+
+	return &engine.Log{
+		Hash:      "0xabc",
+		TxHash:    "0xdef",
+		To:        contract,
+		Data:      &d,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+}
+
+func TestDispatcherRetriesAndSigns(t *testing.T) {
+	var mu sync.Mutex
+	calls := 0
+
+	hook := &engine.LogWebhook{
+		ID:       "hook",
+		Secret:   "secret",
+		Contract: "0x742d35Cc6634C0532925a3b844Bc454e4438f44e",
+		Topic:    "0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef",
+		Status:   engine.LogWebhookStatusActive,
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		calls++
+		if calls <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		body, _ := io.ReadAll(r.Body)
+
+		want := "sha256=" + Sign(hook.Secret, r.Header.Get(HeaderTimestamp), body)
+		if r.Header.Get(HeaderSignature) != want {
+			t.Errorf("signature = %s, want %s", r.Header.Get(HeaderSignature), want)
+		}
+
+		if r.Header.Get(HeaderWebhookID) != hook.ID {
+			t.Errorf("webhook id = %s, want %s", r.Header.Get(HeaderWebhookID), hook.ID)
+		}
+
+		var msg engine.WSMessageLog
+		if err := json.Unmarshal(body, &msg); err != nil {
+			t.Errorf("error parsing body: %v", err)
+		}
+
+		if msg.Data.Hash != "0xabc" {
+			t.Errorf("hash = %s, want 0xabc", msg.Data.Hash)
+		}
+	}))
+	defer srv.Close()
+
+	hook.URL = srv.URL
+
+	store := newFakeStore(hook)
+
+	d := NewDispatcher(context.Background(), store, DefaultMaxFailures)
+	d.backoff = time.Millisecond
+
+	d.dispatch(testLog(hook.Contract))
+
+	if calls != 3 {
+		t.Fatalf("calls = %d, want 3", calls)
+	}
+
+	if store.failures[hook.ID] != 0 || store.status[hook.ID] != engine.LogWebhookStatusActive {
+		t.Fatalf("webhook = %d failures %s, want 0 failures active", store.failures[hook.ID], store.status[hook.ID])
+	}
+}
+
+func TestDispatcherDisables(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	hook := &engine.LogWebhook{
+		ID:       "hook",
+		URL:      srv.URL,
+		Contract: "0x742d35Cc6634C0532925a3b844Bc454e4438f44e",
+		Topic:    "0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef",
+		Status:   engine.LogWebhookStatusActive,
+	}
+
+	store := newFakeStore(hook)
+
+	d := NewDispatcher(context.Background(), store, 2)
+	d.backoff = time.Millisecond
+
+	d.dispatch(testLog(hook.Contract))
+	if store.status[hook.ID] != engine.LogWebhookStatusActive {
+		t.Fatalf("status = %s after 1 failure, want active", store.status[hook.ID])
+	}
+
+	d.dispatch(testLog(hook.Contract))
+	if store.status[hook.ID] != engine.LogWebhookStatusDisabled {
+		t.Fatalf("status = %s after 2 failures, want disabled", store.status[hook.ID])
+	}
+}
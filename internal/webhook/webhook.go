@@ -0,0 +1,65 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+type message struct {
+	Content string `json:"content"`
+}
+
+// Messager posts notifications to a chat webhook (discord/slack compatible)
+type Messager struct {
+	BaseURL   string
+	ChainName string
+}
+
+func NewMessager(baseURL, chainName string) *Messager {
+	return &Messager{
+		BaseURL:   baseURL,
+		ChainName: chainName,
+	}
+}
+
+func (m *Messager) post(ctx context.Context, content string) error {
+	data, err := json.Marshal(message{Content: content})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.BaseURL, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Add("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("error sending webhook message: %s", resp.Status)
+	}
+
+	return nil
+}
+
+func (m *Messager) Notify(ctx context.Context, msg string) error {
+	return m.post(ctx, fmt.Sprintf("[%s] %s", m.ChainName, msg))
+}
+
+func (m *Messager) NotifyWarning(ctx context.Context, errorMessage error) error {
+	return m.post(ctx, fmt.Sprintf("[%s] ⚠️ %s", m.ChainName, errorMessage.Error()))
+}
+
+func (m *Messager) NotifyError(ctx context.Context, errorMessage error) error {
+	return m.post(ctx, fmt.Sprintf("[%s] 🚨 %s", m.ChainName, errorMessage.Error()))
+}
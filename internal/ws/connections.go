@@ -3,16 +3,137 @@ package ws
 import (
 	"log"
 	"net/http"
+	"net/url"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/citizenwallet/engine/pkg/engine"
 	"github.com/gorilla/websocket"
 )
 
+const (
+	// SubprotocolV1 is the original message format, it is used as well when a client doesn't ask for one
+	SubprotocolV1 = "citizenwallet.v1"
+	// SubprotocolV2 is the message format with a version in the envelope
+	SubprotocolV2 = "citizenwallet.v2"
+)
+
+// subprotocols are the message formats that clients can ask for, in order of preference
+var subprotocols = []string{SubprotocolV2, SubprotocolV1}
+
+// subprotocolVersions are the schema versions of the messages of each subprotocol
+var subprotocolVersions = map[string]int{
+	SubprotocolV1: engine.WSSchemaV1,
+	SubprotocolV2: engine.WSSchemaV2,
+}
+
+// versionParam is the query param that clients without subprotocol support can ask for a schema version with
+const versionParam = "version"
+
+// sinceParam is the query param with the last sequence number that a reconnecting client has seen
+const sinceParam = "since"
+
+// clientSince returns the sequence number after which the messages are replayed to a client, whether it asked
+// for a replay, and its query without the since param
+func clientSince(query string) (uint64, bool, string) {
+	values, err := url.ParseQuery(query)
+	if err != nil || !values.Has(sinceParam) {
+		return 0, false, query
+	}
+
+	since, err := strconv.ParseUint(values.Get(sinceParam), 10, 64)
+
+	values.Del(sinceParam)
+
+	return since, err == nil, values.Encode()
+}
+
+// clientVersion returns the schema version of a client and its query without the version param,
+// the subprotocol takes precedence over the query param and unknown versions fall back to the first one
+func clientVersion(subprotocol, query string) (int, string) {
+	version := engine.WSSchemaV1
+
+	values, err := url.ParseQuery(query)
+	if err == nil && values.Has(versionParam) {
+		v, err := strconv.Atoi(values.Get(versionParam))
+		if err == nil && v >= engine.WSSchemaV1 && v <= engine.WSSchemaLatest {
+			version = v
+		}
+
+		values.Del(versionParam)
+		query = values.Encode()
+	}
+
+	if v, ok := subprotocolVersions[subprotocol]; ok {
+		version = v
+	}
+
+	return version, query
+}
+
+// MessageHandler handles a message received from a client, a non-nil reply is sent back to the client
+type MessageHandler func(client *Client, message []byte) []byte
+
 type Client struct {
-	query string
-	conn  *websocket.Conn
-	send  chan []byte
+	query       string
+	subprotocol string // negotiated with the client, empty when it didn't ask for one
+	version     int    // schema version of the messages sent to the client
+	since       uint64 // the last sequence number of the pool that the client has seen
+	replay      bool   // whether the client asked for the messages since then
+	conn        *websocket.Conn
+	send        chan []byte
+	done        chan struct{}
+	closeOnce   sync.Once
+	handle      MessageHandler
+}
+
+func newClient(conn *websocket.Conn, query string, handle MessageHandler) *Client {
+	version, query := clientVersion(conn.Subprotocol(), query)
+	since, replay, query := clientSince(query)
+
+	return &Client{conn: conn, send: make(chan []byte, 256), done: make(chan struct{}), query: query, subprotocol: conn.Subprotocol(), version: version, since: since, replay: replay, handle: handle}
+}
+
+// Subprotocol returns the message format that was negotiated with the client, empty when it didn't ask for one
+func (c *Client) Subprotocol() string {
+	return c.subprotocol
+}
+
+// Send queues a message for the client, returns false if the client's send buffer is full
+// the send channel is never closed, a client that is closing simply drops the message
+func (c *Client) Send(message []byte) bool {
+	select {
+	case <-c.done:
+		return true
+	default:
+	}
+
+	select {
+	case c.send <- message:
+		return true
+	default:
+		return false
+	}
+}
+
+// Version returns the schema version of the messages that are sent to the client
+func (c *Client) Version() int {
+	return c.version
+}
+
+// Done is closed once the client disconnects
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+// close stops the client's write pump and closes its connection, it is safe to call more than once
+func (c *Client) close() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		c.conn.Close()
+	})
 }
 
 type ConnectionPool struct {
@@ -21,53 +142,108 @@ type ConnectionPool struct {
 	register   chan *Client
 	unregister chan *Client
 	broadcast  chan []byte
+	quit       chan struct{}
+	closeOnce  sync.Once
 	mutex      sync.Mutex
-	open       bool
+	open       atomic.Bool
+	broadcasts atomic.Uint64
+
+	// pending counts clients that were handed to Run but are not in the clients map yet
+	pending atomic.Int64
+
+	// onEmpty is called when the last client of the pool unregisters
+	onEmpty func()
 
 	timeout      time.Duration
 	pingInterval time.Duration
 }
 
 func NewConnectionPool(topic string) *ConnectionPool {
-	return &ConnectionPool{
+	cm := &ConnectionPool{
 		topic:        topic,
 		clients:      make(map[string]map[*Client]bool),
 		register:     make(chan *Client),
 		unregister:   make(chan *Client),
 		broadcast:    make(chan []byte),
-		open:         true,
+		quit:         make(chan struct{}),
 		timeout:      60 * time.Second,
 		pingInterval: 54 * time.Second,
 	}
+
+	cm.open.Store(true)
+
+	return cm
 }
 
-func (cm *ConnectionPool) Connect(w http.ResponseWriter, r *http.Request) {
+// upgrade upgrades the request to a websocket connection and creates a client for it
+//
+// with compress, permessage-deflate is used with the clients that support it, it costs cpu for every message
+func upgrade(w http.ResponseWriter, r *http.Request, handle MessageHandler, compress bool) (*Client, error) {
 	upgrader := websocket.Upgrader{
-		ReadBufferSize:  1024,
-		WriteBufferSize: 1024,
+		ReadBufferSize:    1024,
+		WriteBufferSize:   1024,
+		Subprotocols:      subprotocols,
+		EnableCompression: compress,
 		CheckOrigin: func(r *http.Request) bool {
 			return true // Allow all origins for this example
 		},
 	}
 
 	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	query := r.URL.RawQuery
+
+	return newClient(conn, query, handle), nil
+}
+
+func (cm *ConnectionPool) Connect(w http.ResponseWriter, r *http.Request) {
+	client, err := upgrade(w, r, nil, false)
 	if err != nil {
 		log.Println("Error upgrading to WebSocket:", err)
 		return
 	}
 
-	query := r.URL.RawQuery
+	if !cm.add(client) {
+		client.conn.Close()
+		return
+	}
+
+	cm.start(client)
+}
 
-	client := &Client{conn: conn, send: make(chan []byte, 256), query: query}
-	cm.register <- client
+// add registers a client with the pool, returns false if the pool has been closed
+func (cm *ConnectionPool) add(client *Client) bool {
+	cm.pending.Add(1)
+
+	select {
+	case cm.register <- client:
+		return true
+	case <-cm.quit:
+		cm.pending.Add(-1)
+		return false
+	}
+}
 
+// start starts reading from and writing to the client's connection
+func (cm *ConnectionPool) start(client *Client) {
 	go cm.readPump(client)
 	go cm.writePump(client)
 }
 
+// remove unregisters a client from the pool, does nothing if the pool has been closed
+func (cm *ConnectionPool) remove(client *Client) {
+	select {
+	case cm.unregister <- client:
+	case <-cm.quit:
+	}
+}
+
 func (cm *ConnectionPool) readPump(client *Client) {
 	defer func() {
-		cm.unregister <- client
+		cm.remove(client)
 		client.conn.Close()
 	}()
 
@@ -81,7 +257,19 @@ func (cm *ConnectionPool) readPump(client *Client) {
 		}
 
 		// handle incoming messages
-		println("received message", string(message))
+		if client.handle == nil {
+			continue
+		}
+
+		reply := client.handle(client, message)
+		if reply == nil {
+			continue
+		}
+
+		if !client.Send(reply) {
+			// the client is not reading its replies
+			break
+		}
 	}
 }
 
@@ -98,12 +286,10 @@ func (cm *ConnectionPool) writePump(client *Client) {
 
 	for {
 		select {
-		case message, ok := <-client.send:
-			if !ok {
-				client.conn.WriteMessage(websocket.CloseMessage, []byte{})
-				return
-			}
-
+		case <-client.done:
+			client.conn.WriteMessage(websocket.CloseMessage, []byte{})
+			return
+		case message := <-client.send:
 			w, err := client.conn.NextWriter(websocket.TextMessage)
 			if err != nil {
 				return
@@ -124,9 +310,8 @@ func (cm *ConnectionPool) writePump(client *Client) {
 // Run this method in a separate goroutine
 // Run manages the main loop for the ConnectionPool, handling client registration,
 // unregistration, and message broadcasting. This method should be run in a separate goroutine.
+// The pool stays alive until Close is called, even if all of its clients disconnect.
 func (cm *ConnectionPool) Run() error {
-	defer cm.Close()
-
 	for {
 		select {
 		case client := <-cm.register:
@@ -136,50 +321,67 @@ func (cm *ConnectionPool) Run() error {
 				cm.clients[client.query] = make(map[*Client]bool)
 			}
 			cm.clients[client.query][client] = true
+			cm.pending.Add(-1)
 			cm.mutex.Unlock()
 		case client := <-cm.unregister:
-			// Unregister a client and close its send channel
+			// Unregister a client and stop its write pump
 			cm.mutex.Lock()
-			if _, ok := cm.clients[client.query]; ok {
-				cm.clients[client.query][client] = false
+			if clients, ok := cm.clients[client.query]; ok {
+				// a client can be unregistered more than once (read error and full buffer),
+				// only the first one should close it
+				if _, ok := clients[client]; ok {
+					delete(clients, client)
+
+					client.close()
+				}
+
 				// if there are no more clients for this query, remove the query
-				if cm.OpenClients(client.query) == 0 {
+				if len(clients) == 0 {
 					delete(cm.clients, client.query)
 				}
 			}
 
-			client.conn.Close()
-			close(client.send)
+			empty := len(cm.clients) == 0
+			cm.mutex.Unlock()
+
+			// let the owner of the pool decide what to do with it, it could be receiving a new client already
+			if empty && cm.onEmpty != nil {
+				go cm.onEmpty()
+			}
+		case <-cm.quit:
+			cm.mutex.Lock()
+			for query, clients := range cm.clients {
+				for client := range clients {
+					client.close()
+				}
 
-			// Check if this was the last client
-			if len(cm.clients) == 0 {
-				cm.mutex.Unlock()
-				return nil // This will trigger the deferred Close()
+				delete(cm.clients, query)
 			}
 			cm.mutex.Unlock()
-			// case message := <-cm.broadcast:
-			// 	// Broadcast a message to all connected clients
-			// 	cm.BroadcastMessage(message)
+
+			return nil
 		}
 	}
 }
 
+// Close stops the pool and disconnects all of its clients
 func (cm *ConnectionPool) Close() {
-	cm.open = false
-
-	for _, clients := range cm.clients {
-		for client := range clients {
-			cm.unregister <- client
-		}
-	}
-
-	close(cm.register)
-	close(cm.unregister)
-	close(cm.broadcast)
+	cm.closeOnce.Do(func() {
+		cm.open.Store(false)
+		close(cm.quit)
+	})
 }
 
 func (cm *ConnectionPool) IsOpen() bool {
-	return cm.open
+	return cm.open.Load()
+}
+
+// Empty returns true if the pool has no clients and none are being registered
+func (cm *ConnectionPool) Empty() bool {
+	cm.mutex.Lock()
+	defer cm.mutex.Unlock()
+
+	return len(cm.clients) == 0 && cm.pending.Load() == 0
 }
 
 // returns all clients in a query
@@ -204,9 +406,46 @@ func (cm *ConnectionPool) Queries() []string {
 	return queries
 }
 
+// Stats returns the amount of clients per query and the send buffer occupancy of each client
+func (cm *ConnectionPool) Stats() PoolStats {
+	cm.mutex.Lock()
+	defer cm.mutex.Unlock()
+
+	stats := PoolStats{
+		Topic:      cm.topic,
+		Queries:    make(map[string]int, len(cm.clients)),
+		Buffers:    []ClientStats{},
+		Broadcasts: cm.broadcasts.Load(),
+	}
+
+	for query, clients := range cm.clients {
+		for client, open := range clients {
+			if !open {
+				continue
+			}
+
+			stats.Clients++
+			stats.Queries[query]++
+			stats.Buffers = append(stats.Buffers, ClientStats{
+				Query:    query,
+				Buffered: len(client.send),
+				Capacity: cap(client.send),
+			})
+		}
+	}
+
+	return stats
+}
+
 // broadcastMessage sends a message to all connected clients.
 // If a client's send channel is full, it is unregistered.
 func (cm *ConnectionPool) BroadcastMessage(query string, message []byte) {
+	cm.broadcastVersions(query, map[int][]byte{engine.WSSchemaV1: message})
+}
+
+// broadcastVersions sends every client the message in the schema version it asked for,
+// clients of a version without a message get the first version
+func (cm *ConnectionPool) broadcastVersions(query string, messages map[int][]byte) {
 	// Create a copy of the clients map to avoid holding the lock while sending
 	cm.mutex.Lock()
 	clients := make([]*Client, 0, len(cm.clients[query]))
@@ -217,16 +456,18 @@ func (cm *ConnectionPool) BroadcastMessage(query string, message []byte) {
 	}
 	cm.mutex.Unlock()
 
+	cm.broadcasts.Add(1)
+
 	// Send the message to each client
 	for _, client := range clients {
-		select {
-		case client.send <- message:
-			// Message sent successfully
-		default:
+		message, ok := messages[client.version]
+		if !ok {
+			message = messages[engine.WSSchemaV1]
+		}
+
+		if !client.Send(message) {
 			// Client's send channel is full, unregister it
-			go func(c *Client) {
-				cm.unregister <- c
-			}(client)
+			go cm.remove(client)
 		}
 	}
 }
@@ -0,0 +1,259 @@
+package ws
+
+import (
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/citizenwallet/engine/pkg/engine"
+	"github.com/gorilla/websocket"
+)
+
+// wsURL returns the websocket url of the test server with the given query
+func wsURL(srv *httptest.Server, query string) string {
+	return "ws" + strings.TrimPrefix(srv.URL, "http") + "?" + query
+}
+
+// dial connects a websocket client to the test server with the given query
+func dial(t *testing.T, srv *httptest.Server, query string) *websocket.Conn {
+	t.Helper()
+
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL(srv, query), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return conn
+}
+
+// waitForClients waits until the pool has the expected amount of clients
+func waitForClients(t *testing.T, pool *ConnectionPool, expected int) {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if pool.Stats().Clients == expected {
+			return
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Fatalf("expected %d clients, got %d", expected, pool.Stats().Clients)
+}
+
+func TestUnregisterKeepsOtherClients(t *testing.T) {
+	pool := NewConnectionPool("test")
+	go pool.Run()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pool.Connect(w, r)
+	}))
+	defer srv.Close()
+
+	query := "data.to=0x1"
+
+	c1 := dial(t, srv, query)
+	c2 := dial(t, srv, query)
+	defer c2.Close()
+
+	waitForClients(t, pool, 2)
+
+	// disconnect the first client
+	c1.Close()
+
+	waitForClients(t, pool, 1)
+
+	pool.BroadcastMessage(query, []byte("hello"))
+
+	c2.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, msg, err := c2.ReadMessage()
+	if err != nil {
+		t.Fatalf("expected remaining client to receive the broadcast: %v", err)
+	}
+
+	if string(msg) != "hello" {
+		t.Errorf("expected %s, got %s", "hello", msg)
+	}
+
+	stats := pool.Stats()
+	if stats.Queries[query] != 1 {
+		t.Errorf("expected 1 client for query, got %d", stats.Queries[query])
+	}
+}
+
+func TestBroadcastWhileUnregistering(t *testing.T) {
+	pool := NewConnectionPool("test")
+	go pool.Run()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pool.Connect(w, r)
+	}))
+	defer srv.Close()
+
+	query := "data.to=0x1"
+
+	conns := make([]*websocket.Conn, 0, 10)
+	for i := 0; i < 10; i++ {
+		conns = append(conns, dial(t, srv, query))
+	}
+
+	waitForClients(t, pool, len(conns))
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				pool.BroadcastMessage(query, []byte("hello"))
+			}
+		}
+	}()
+
+	// disconnect clients while messages are being broadcast to them
+	for _, conn := range conns {
+		conn.Close()
+	}
+
+	waitForClients(t, pool, 0)
+
+	// closing the pool while broadcasting should not panic either
+	pool.Close()
+
+	close(stop)
+	<-done
+}
+
+func TestClientVersion(t *testing.T) {
+	tests := []struct {
+		name        string
+		subprotocol string
+		query       string
+		version     int
+		outQuery    string
+	}{
+		{"default", "", "data.to=0xb", engine.WSSchemaV1, "data.to=0xb"},
+		{"subprotocol", SubprotocolV2, "data.to=0xb", engine.WSSchemaV2, "data.to=0xb"},
+		{"query param", "", "data.to=0xb&version=2", engine.WSSchemaV2, "data.to=0xb"},
+		{"only the query param", "", "version=2", engine.WSSchemaV2, ""},
+		{"unknown version", "", "version=99", engine.WSSchemaV1, ""},
+		{"subprotocol takes precedence", SubprotocolV1, "version=2", engine.WSSchemaV1, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			version, query := clientVersion(tt.subprotocol, tt.query)
+			if version != tt.version {
+				t.Errorf("expected version %d, got %d", tt.version, version)
+			}
+
+			if query != tt.outQuery {
+				t.Errorf("expected query %q, got %q", tt.outQuery, query)
+			}
+		})
+	}
+}
+
+func TestBroadcastVersions(t *testing.T) {
+	pools := NewConnectionPools()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pools.Connect(w, r, "0xtoken/0xtopic")
+	}))
+	defer srv.Close()
+
+	v1 := dial(t, srv, "")
+	defer v1.Close()
+
+	dialer := websocket.Dialer{Subprotocols: []string{SubprotocolV2}}
+	v2, _, err := dialer.Dial(wsURL(srv, ""), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer v2.Close()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		pools.mu.Lock()
+		pool, ok := pools.pools["0xtoken/0xtopic"]
+		pools.mu.Unlock()
+
+		if ok && pool.Stats().Clients == 2 {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatal("expected 2 clients")
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	broadcastLog(pools, "0x1", "0xb")
+
+	for version, conn := range map[int]*websocket.Conn{0: v1, engine.WSSchemaV2: v2} {
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+
+		var msg map[string]any
+		if err := conn.ReadJSON(&msg); err != nil {
+			t.Fatal(err)
+		}
+
+		v, ok := msg["version"].(float64)
+		if version == 0 && ok {
+			t.Errorf("expected no version in the first schema, got %v", msg["version"])
+		}
+
+		if version != 0 && int(v) != version {
+			t.Errorf("expected version %d, got %v", version, msg["version"])
+		}
+
+		if msg["id"] != "0x1" {
+			t.Errorf("expected log 0x1, got %v", msg["id"])
+		}
+	}
+}
+
+func TestSequenceAndReplay(t *testing.T) {
+	pools := NewConnectionPools()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pools.Connect(w, r, "0xtoken/0xtopic")
+	}))
+	defer srv.Close()
+
+	// every pool counts its own broadcasts
+	broadcastLog(pools, "0x1", "0xa")
+	broadcastLog(pools, "0x2", "0xb")
+	otherData := json.RawMessage(`{"topic":"0xtopic"}`)
+	pools.BroadcastMessage(engine.WSMessageTypeNew, &engine.Log{Hash: "0x9", To: "0xother", Value: big.NewInt(1), Data: &otherData})
+	broadcastLog(pools, "0x3", "0xb")
+
+	// a client that saw seq 1 gets the ones it missed that match its query, the seq is only in the second schema
+	conn := dial(t, srv, "data.to=0xb&since=1&version=2")
+	defer conn.Close()
+
+	for _, expected := range []struct {
+		id  string
+		seq uint64
+	}{{"0x2", 2}, {"0x3", 3}} {
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+
+		var msg engine.WSMessageLog
+		if err := conn.ReadJSON(&msg); err != nil {
+			t.Fatal(err)
+		}
+
+		if msg.ID != expected.id || msg.Seq != expected.seq {
+			t.Errorf("expected %s with seq %d, got %s with seq %d", expected.id, expected.seq, msg.ID, msg.Seq)
+		}
+	}
+}
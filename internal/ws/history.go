@@ -0,0 +1,103 @@
+package ws
+
+import (
+	"sync"
+
+	"github.com/citizenwallet/engine/pkg/engine"
+)
+
+// DefaultHistorySize is the number of recent messages that are kept per pool for clients that reconnect
+const DefaultHistorySize = 100
+
+type historyEntry struct {
+	id   uint64
+	msg  *engine.WSMessageLog
+	data []byte
+}
+
+// history keeps the recent broadcast messages of every pool, ids increase across pools
+type history struct {
+	mu      sync.Mutex
+	size    int
+	lastID  uint64
+	entries map[string][]historyEntry // by pool
+
+	// the messages up to these ids are not in memory anymore, the ones before a restart and the dropped ones by pool
+	floor   uint64
+	trimmed map[string]uint64
+}
+
+func newHistory(size int) *history {
+	return &history{
+		size:    size,
+		entries: map[string][]historyEntry{},
+		trimmed: map[string]uint64{},
+	}
+}
+
+// add keeps a message and returns its id, the oldest message of the pool is dropped when it is full
+func (h *history) add(msg *engine.WSMessageLog, data []byte) uint64 {
+	return h.put(0, msg, data)
+}
+
+// put keeps a message with the id it was given by the outbox, 0 or an id that doesn't increase takes the next one
+func (h *history) put(id uint64, msg *engine.WSMessageLog, data []byte) uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if id <= h.lastID {
+		id = h.lastID + 1
+	}
+
+	h.lastID = id
+
+	entries := append(h.entries[msg.PoolID], historyEntry{id: id, msg: msg, data: data})
+	if len(entries) > h.size {
+		h.trimmed[msg.PoolID] = entries[len(entries)-h.size-1].id
+		entries = entries[len(entries)-h.size:]
+	}
+
+	h.entries[msg.PoolID] = entries
+
+	return id
+}
+
+// last returns the id of the last message that was added
+func (h *history) last() uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.lastID
+}
+
+// since returns the messages of a pool that were added after the given id, and whether those are all of them
+func (h *history) since(pool string, after uint64) ([]historyEntry, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	complete := after >= h.floor && after >= h.trimmed[pool]
+
+	entries := h.entries[pool]
+	for i, e := range entries {
+		if e.id > after {
+			return append([]historyEntry{}, entries[i:]...), complete
+		}
+	}
+
+	return nil, complete
+}
+
+// sinceSeq returns the messages of a pool that are after the given sequence number of the pool
+func (h *history) sinceSeq(pool string, seq uint64) []historyEntry {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	entries := h.entries[pool]
+	for i, e := range entries {
+		if e.msg.Seq > seq {
+			return append([]historyEntry{}, entries[i:]...)
+		}
+	}
+
+	return nil
+}
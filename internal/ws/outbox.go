@@ -0,0 +1,197 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/citizenwallet/engine/pkg/engine"
+)
+
+const (
+	// DefaultOutboxRetention is how long broadcast messages are kept in the outbox for clients to catch up with
+	DefaultOutboxRetention = 24 * time.Hour
+
+	outboxPruneInterval = 10 * time.Minute
+	outboxRestoreLimit  = 10000 // messages that are loaded back into the history on start
+	outboxReplayLimit   = 1000  // messages that are read from the outbox at once for a client that is behind
+)
+
+// Outbox records the broadcast messages before they are sent so that clients can catch up with them after a restart,
+// the sequence numbers are the ids of the messages, every message also keeps the sequence number of its pool
+type Outbox interface {
+	AppendOutbox(pool string, poolSeq uint64, data []byte) (uint64, error)
+	GetRecentOutbox(limit int) ([]*engine.OutboxMessage, error)
+	GetOutboxSince(pool string, after uint64, limit int) ([]*engine.OutboxMessage, error)
+	GetOutboxSincePoolSeq(pool string, poolSeq uint64, limit int) ([]*engine.OutboxMessage, error)
+	GetOutboxPoolSeqs() (map[string]uint64, error)
+	PruneOutbox(retention time.Duration) (int64, error)
+}
+
+// SetOutbox records the broadcasts in an outbox and restores the history from it, it should be set before any broadcast
+func (p *ConnectionPools) SetOutbox(o Outbox) error {
+	msgs, err := o.GetRecentOutbox(outboxRestoreLimit)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range msgs {
+		e, err := outboxEntry(m)
+		if err != nil {
+			continue
+		}
+
+		p.history.put(e.id, e.msg, e.data)
+
+		// the sequence numbers of the pools carry on from the restored messages
+		p.omu.Lock()
+		p.poolSeqs[e.msg.PoolID] = max(p.poolSeqs[e.msg.PoolID], e.msg.Seq)
+		p.omu.Unlock()
+	}
+
+	if len(msgs) > 0 {
+		// older messages are only in the outbox
+		p.history.floor = msgs[0].Seq - 1
+	}
+
+	// the pools whose messages are all older than the restored ones carry on as well
+	seqs, err := o.GetOutboxPoolSeqs()
+	if err != nil {
+		return err
+	}
+
+	p.omu.Lock()
+	for pool, seq := range seqs {
+		p.poolSeqs[pool] = max(p.poolSeqs[pool], seq)
+	}
+	p.omu.Unlock()
+
+	p.outbox = o
+
+	return nil
+}
+
+func outboxEntry(m *engine.OutboxMessage) (historyEntry, error) {
+	var msg engine.WSMessageLog
+	err := json.Unmarshal(m.Data, &msg)
+	if err != nil {
+		return historyEntry{}, err
+	}
+
+	return historyEntry{id: m.Seq, msg: &msg, data: m.Data}, nil
+}
+
+// since returns the messages of a pool after an id, the ones that aren't in the history anymore are read from the outbox
+//
+// a client that is far behind gets a page of the outbox at a time, it calls again with the id of the last one
+func (p *ConnectionPools) since(pool string, after uint64) []historyEntry {
+	entries, complete := p.history.since(pool, after)
+	if complete || p.outbox == nil {
+		return entries
+	}
+
+	msgs, err := p.outbox.GetOutboxSince(pool, after, outboxReplayLimit)
+	if err != nil {
+		log.Default().Println("error reading the ws outbox: ", err.Error())
+		return entries
+	}
+
+	replayed := []historyEntry{}
+	for _, m := range msgs {
+		e, err := outboxEntry(m)
+		if err != nil {
+			continue
+		}
+
+		replayed = append(replayed, e)
+	}
+
+	if len(msgs) == outboxReplayLimit || len(replayed) == 0 {
+		return replayed
+	}
+
+	last := replayed[len(replayed)-1].id
+	for _, e := range entries {
+		if e.id > last {
+			replayed = append(replayed, e)
+		}
+	}
+
+	return replayed
+}
+
+// sinceSeq returns the messages of a pool after a sequence number of the pool, the ones that aren't in the history
+// anymore are read from the outbox
+//
+// a client that is far behind gets a page of the outbox at a time, it reconnects with the last sequence number
+func (p *ConnectionPools) sinceSeq(pool string, seq uint64) []historyEntry {
+	entries := p.history.sinceSeq(pool, seq)
+	if p.outbox == nil || (len(entries) > 0 && entries[0].msg.Seq == seq+1) {
+		return entries
+	}
+
+	p.omu.Lock()
+	last := p.poolSeqs[pool]
+	p.omu.Unlock()
+
+	if len(entries) == 0 && last <= seq {
+		return entries
+	}
+
+	msgs, err := p.outbox.GetOutboxSincePoolSeq(pool, seq, outboxReplayLimit)
+	if err != nil {
+		log.Default().Println("error reading the ws outbox: ", err.Error())
+		return entries
+	}
+
+	replayed := []historyEntry{}
+	for _, m := range msgs {
+		e, err := outboxEntry(m)
+		if err != nil {
+			continue
+		}
+
+		replayed = append(replayed, e)
+	}
+
+	if len(msgs) == outboxReplayLimit || len(replayed) == 0 {
+		return replayed
+	}
+
+	lastSeq := replayed[len(replayed)-1].msg.Seq
+	for _, e := range entries {
+		if e.msg.Seq > lastSeq {
+			replayed = append(replayed, e)
+		}
+	}
+
+	return replayed
+}
+
+// PruneOutbox periodically removes the messages that are older than the retention from the outbox, until the context is done
+func (p *ConnectionPools) PruneOutbox(ctx context.Context, retention time.Duration) error {
+	if p.outbox == nil {
+		return nil
+	}
+
+	ticker := time.NewTicker(outboxPruneInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			n, err := p.outbox.PruneOutbox(retention)
+			if err != nil {
+				log.Default().Println("error pruning the ws outbox: ", err.Error())
+				continue
+			}
+
+			if n > 0 {
+				log.Default().Printf("pruned %d messages from the ws outbox\n", n)
+			}
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package ws
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/citizenwallet/engine/pkg/engine"
+)
+
+// memoryOutbox is an outbox that survives a restart of the pools
+type memoryOutbox struct {
+	mu   sync.Mutex
+	seq  uint64
+	msgs []*engine.OutboxMessage
+}
+
+func (o *memoryOutbox) AppendOutbox(pool string, poolSeq uint64, data []byte) (uint64, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.seq++
+	o.msgs = append(o.msgs, &engine.OutboxMessage{Seq: o.seq, Pool: pool, PoolSeq: poolSeq, Data: data, CreatedAt: time.Now()})
+
+	return o.seq, nil
+}
+
+func (o *memoryOutbox) GetRecentOutbox(limit int) ([]*engine.OutboxMessage, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if len(o.msgs) > limit {
+		return append([]*engine.OutboxMessage{}, o.msgs[len(o.msgs)-limit:]...), nil
+	}
+
+	return append([]*engine.OutboxMessage{}, o.msgs...), nil
+}
+
+func (o *memoryOutbox) GetOutboxSince(pool string, after uint64, limit int) ([]*engine.OutboxMessage, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	msgs := []*engine.OutboxMessage{}
+	for _, m := range o.msgs {
+		if m.Pool == pool && m.Seq > after && len(msgs) < limit {
+			msgs = append(msgs, m)
+		}
+	}
+
+	return msgs, nil
+}
+
+func (o *memoryOutbox) GetOutboxSincePoolSeq(pool string, poolSeq uint64, limit int) ([]*engine.OutboxMessage, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	msgs := []*engine.OutboxMessage{}
+	for _, m := range o.msgs {
+		if m.Pool == pool && m.PoolSeq > poolSeq && len(msgs) < limit {
+			msgs = append(msgs, m)
+		}
+	}
+
+	return msgs, nil
+}
+
+func (o *memoryOutbox) GetOutboxPoolSeqs() (map[string]uint64, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	seqs := map[string]uint64{}
+	for _, m := range o.msgs {
+		seqs[m.Pool] = max(seqs[m.Pool], m.PoolSeq)
+	}
+
+	return seqs, nil
+}
+
+func (o *memoryOutbox) PruneOutbox(retention time.Duration) (int64, error) {
+	return 0, nil
+}
+
+func TestOutbox(t *testing.T) {
+	outbox := &memoryOutbox{seq: 41} // the sequence doesn't start with the history
+
+	topic := "0xtoken/0xtopic"
+
+	pools := NewConnectionPools()
+	if err := pools.SetOutbox(outbox); err != nil {
+		t.Fatal(err)
+	}
+
+	broadcastLog(pools, "0x1", "0xa")
+	broadcastLog(pools, "0x2", "0xb")
+
+	if pools.Cursor() != 43 {
+		t.Fatalf("expected the cursor to be the sequence number 43, got %d", pools.Cursor())
+	}
+
+	// a restart restores the history from the outbox, the ids carry on
+	pools = NewConnectionPools()
+	pools.history = newHistory(1)
+	if err := pools.SetOutbox(outbox); err != nil {
+		t.Fatal(err)
+	}
+
+	if pools.Cursor() != 43 {
+		t.Fatalf("expected the cursor 43 after a restart, got %d", pools.Cursor())
+	}
+
+	broadcastLog(pools, "0x3", "0xb")
+
+	// the messages that were dropped from the history are read from the outbox
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	msgs, cursor := pools.Poll(ctx, topic, "", 41)
+	if len(msgs) != 3 || msgs[0].ID != "0x1" || msgs[1].ID != "0x2" || msgs[2].ID != "0x3" || cursor != 44 {
+		t.Fatalf("expected logs 0x1, 0x2 and 0x3 and cursor 44, got %d messages and cursor %d", len(msgs), cursor)
+	}
+
+	msgs, cursor = pools.Poll(ctx, topic, "data.to=0xb", 42)
+	if len(msgs) != 2 || msgs[0].ID != "0x2" || msgs[1].ID != "0x3" || cursor != 44 {
+		t.Fatalf("expected logs 0x2 and 0x3 and cursor 44, got %d messages and cursor %d", len(msgs), cursor)
+	}
+}
+
+// staleOutbox is an outbox whose messages are all older than the ones that are restored into the history
+type staleOutbox struct {
+	*memoryOutbox
+}
+
+func (o staleOutbox) GetRecentOutbox(limit int) ([]*engine.OutboxMessage, error) {
+	return nil, nil
+}
+
+func TestReplayAfterRestart(t *testing.T) {
+	outbox := &memoryOutbox{}
+
+	pools := NewConnectionPools()
+	if err := pools.SetOutbox(outbox); err != nil {
+		t.Fatal(err)
+	}
+
+	broadcastLog(pools, "0x1", "0xb")
+	broadcastLog(pools, "0x2", "0xb")
+	broadcastLog(pools, "0x3", "0xb")
+
+	// after a restart nothing is in the history, the sequence of the pool carries on from the outbox
+	pools = NewConnectionPools()
+	if err := pools.SetOutbox(staleOutbox{outbox}); err != nil {
+		t.Fatal(err)
+	}
+
+	broadcastLog(pools, "0x4", "0xb")
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pools.Connect(w, r, "0xtoken/0xtopic")
+	}))
+	defer srv.Close()
+
+	// the messages that the client missed before the restart are read from the outbox
+	conn := dial(t, srv, "since=1&version=2")
+	defer conn.Close()
+
+	for _, expected := range []struct {
+		id  string
+		seq uint64
+	}{{"0x2", 2}, {"0x3", 3}, {"0x4", 4}} {
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+
+		var msg engine.WSMessageLog
+		if err := conn.ReadJSON(&msg); err != nil {
+			t.Fatal(err)
+		}
+
+		if msg.ID != expected.id || msg.Seq != expected.seq {
+			t.Errorf("expected %s with seq %d, got %s with seq %d", expected.id, expected.seq, msg.ID, msg.Seq)
+		}
+	}
+}
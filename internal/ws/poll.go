@@ -0,0 +1,59 @@
+package ws
+
+import (
+	"context"
+
+	"github.com/citizenwallet/engine/pkg/engine"
+)
+
+// Cursor returns the cursor of the last message that was broadcast, a poll with it only gets new messages
+func (p *ConnectionPools) Cursor() uint64 {
+	return p.history.last()
+}
+
+// Poll returns the messages of a topic that match the query and were broadcast after the cursor together with
+// the cursor for the next poll, if there are none yet it waits for one until the context is done
+//
+// without an outbox a cursor from before a restart is ahead of the history, it only gets new messages
+func (p *ConnectionPools) Poll(ctx context.Context, topic, query string, cursor uint64) ([]*engine.WSMessageLog, uint64) {
+	if last := p.history.last(); cursor > last {
+		cursor = last
+	}
+
+	// a one-shot waiter, a pending wake up is enough since the history is read again after it
+	wake := make(chan struct{}, 1)
+	stop := p.Listen(func(m *engine.WSMessageLog) {
+		if m.PoolID != topic || !m.Data.MatchesQuery(query) {
+			return
+		}
+
+		select {
+		case wake <- struct{}{}:
+		default:
+		}
+	})
+	defer stop()
+
+	for {
+		msgs := []*engine.WSMessageLog{}
+		for batch := p.since(topic, cursor); len(batch) > 0 && len(msgs) == 0; batch = p.since(topic, cursor) {
+			for _, e := range batch {
+				cursor = e.id
+
+				if e.msg.Data.MatchesQuery(query) {
+					msgs = append(msgs, e.msg)
+				}
+			}
+		}
+
+		if len(msgs) > 0 {
+			return msgs, cursor
+		}
+
+		select {
+		case <-ctx.Done():
+			return msgs, cursor
+		case <-wake:
+		}
+	}
+}
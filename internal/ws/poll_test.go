@@ -0,0 +1,45 @@
+package ws
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestPoll(t *testing.T) {
+	pools := NewConnectionPools()
+
+	topic := "0xtoken/0xtopic"
+
+	// messages past the cursor are returned immediately
+	broadcastLog(pools, "0x1", "0xb")
+
+	msgs, cursor := pools.Poll(context.Background(), topic, "", 0)
+	if len(msgs) != 1 || msgs[0].ID != "0x1" || cursor != 1 {
+		t.Fatalf("expected log 0x1 and cursor 1, got %d messages and cursor %d", len(msgs), cursor)
+	}
+
+	// without new messages the poll waits for a matching one
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		broadcastLog(pools, "0x2", "0xa")
+		broadcastLog(pools, "0x3", "0xb")
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	msgs, cursor = pools.Poll(ctx, topic, "data.to=0xb", cursor)
+	if len(msgs) != 1 || msgs[0].ID != "0x3" || cursor != 3 {
+		t.Fatalf("expected log 0x3 and cursor 3, got %d messages and cursor %d", len(msgs), cursor)
+	}
+
+	// the poll gives up when the context is done
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	msgs, next := pools.Poll(ctx, topic, "", cursor)
+	if len(msgs) != 0 || next != cursor {
+		t.Fatalf("expected no messages and the same cursor, got %d messages and cursor %d", len(msgs), next)
+	}
+}
@@ -2,37 +2,173 @@ package ws
 
 import (
 	"encoding/json"
+	"log"
 	"net/http"
 	"sync"
 
 	"github.com/citizenwallet/engine/pkg/engine"
 )
 
+// Listener is called with every message that is broadcast, whether the pool it belongs to has clients or not
+// listeners are called while broadcasting and should not block
+type Listener func(m *engine.WSMessageLog)
+
 type ConnectionPools struct {
 	pools map[string]*ConnectionPool
 	mu    sync.Mutex
+
+	listeners  map[uint64]Listener
+	listenerID uint64
+	lmu        sync.RWMutex
+
+	history *history
+	outbox  Outbox
+	omu     sync.Mutex // messages are kept in the order of their sequence numbers
+
+	poolSeqs map[string]uint64 // the last sequence number of every pool, guarded by omu
+
+	compression bool
 }
 
 func NewConnectionPools() *ConnectionPools {
 	return &ConnectionPools{
-		pools: make(map[string]*ConnectionPool),
+		pools:     make(map[string]*ConnectionPool),
+		listeners: make(map[uint64]Listener),
+		history:   newHistory(DefaultHistorySize),
+		poolSeqs:  make(map[string]uint64),
+	}
+}
+
+// SetCompression compresses the messages of the clients that connect from now on if they support it
+func (p *ConnectionPools) SetCompression(enabled bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.compression = enabled
+}
+
+// Listen adds a listener for broadcast messages, the returned function removes it again
+func (p *ConnectionPools) Listen(l Listener) func() {
+	p.lmu.Lock()
+	defer p.lmu.Unlock()
+
+	p.listenerID++
+	id := p.listenerID
+
+	p.listeners[id] = l
+
+	return func() {
+		p.lmu.Lock()
+		defer p.lmu.Unlock()
+
+		delete(p.listeners, id)
 	}
 }
 
+type ClientStats struct {
+	Query    string `json:"query"`
+	Buffered int    `json:"buffered"`
+	Capacity int    `json:"capacity"`
+}
+
+type PoolStats struct {
+	Topic      string         `json:"topic"`
+	Clients    int            `json:"clients"`
+	Queries    map[string]int `json:"queries"`
+	Buffers    []ClientStats  `json:"buffers"`
+	Broadcasts uint64         `json:"broadcasts"`
+}
+
+type Stats struct {
+	Connections int         `json:"connections"`
+	Pools       []PoolStats `json:"pools"`
+}
+
 // Connect connects a client to a topic or creates a new topic
 func (p *ConnectionPools) Connect(w http.ResponseWriter, r *http.Request, topic string) {
+	p.ConnectWithHandler(w, r, topic, nil)
+}
+
+// ConnectWithHandler connects a client to a topic and handles the messages it sends with h
+func (p *ConnectionPools) ConnectWithHandler(w http.ResponseWriter, r *http.Request, topic string, h MessageHandler) {
 	p.mu.Lock()
-	defer p.mu.Unlock()
+	compress := p.compression
+	p.mu.Unlock()
+
+	client, err := upgrade(w, r, h, compress)
+	if err != nil {
+		log.Println("Error upgrading to WebSocket:", err)
+		return
+	}
+
+	p.mu.Lock()
+	pool, ok := p.pools[topic]
+	if !ok {
+		pool = NewConnectionPool(topic)
+		pool.onEmpty = func() {
+			p.release(topic, pool)
+		}
+
+		p.pools[topic] = pool
+
+		go pool.Run()
+	}
+
+	// registering while holding the lock guarantees that the pool cannot be released in between
+	pool.add(client)
+	p.mu.Unlock()
+
+	if client.replay {
+		p.replay(client, topic)
+	}
+
+	pool.start(client)
+}
+
+// replay sends a client that reconnects the messages of its pool that it missed since its last sequence number,
+// the ones that aren't in the history anymore are read from the outbox
+//
+// the client is registered first so that nothing is missed in between, a message can reach it twice and
+// clients drop the sequence numbers that they have already seen
+func (p *ConnectionPools) replay(client *Client, topic string) {
+	for _, e := range p.sinceSeq(topic, client.since) {
+		if !e.msg.Data.MatchesQuery(client.query) {
+			continue
+		}
+
+		data := encodeVersion(e.msg, client.version)
+		if data == nil {
+			continue
+		}
+
+		if !client.Send(data) {
+			return
+		}
+	}
+}
+
+// encodeVersion returns a message in a schema version, nil if it can't be encoded
+func encodeVersion(msg *engine.WSMessageLog, version int) []byte {
+	b, err := json.Marshal(msg.WithVersion(version))
+	if err != nil {
+		return nil
+	}
 
-	println("connect", topic)
+	return b
+}
 
-	if _, ok := p.pools[topic]; !ok || !p.pools[topic].IsOpen() {
-		p.pools[topic] = NewConnectionPool(topic)
+// release closes and removes a pool if it is still empty
+func (p *ConnectionPools) release(topic string, pool *ConnectionPool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
 
-		go p.pools[topic].Run()
+	if p.pools[topic] != pool || !pool.Empty() {
+		return
 	}
 
-	p.pools[topic].Connect(w, r)
+	delete(p.pools, topic)
+
+	pool.Close()
 }
 
 // BroadcastMessage broadcasts a message to all clients in a topic
@@ -42,11 +178,44 @@ func (p *ConnectionPools) BroadcastMessage(t engine.WSMessageType, m engine.WSMe
 		return
 	}
 
+	// the message is recorded before it is sent, if the outbox fails it is still sent without a sequence number
+	p.omu.Lock()
+
+	p.poolSeqs[wsm.PoolID]++
+	wsm.Seq = p.poolSeqs[wsm.PoolID]
+
 	b, err := json.Marshal(wsm)
 	if err != nil {
+		p.omu.Unlock()
 		return
 	}
 
+	var seq uint64
+	if p.outbox != nil {
+		seq, err = p.outbox.AppendOutbox(wsm.PoolID, wsm.Seq, b)
+		if err != nil {
+			log.Default().Println("error appending to the ws outbox: ", err.Error())
+		}
+	}
+
+	// the message is in the history before the listeners hear about it
+	p.history.put(seq, wsm, b)
+	p.omu.Unlock()
+
+	p.lmu.RLock()
+	for _, l := range p.listeners {
+		l(wsm)
+	}
+	p.lmu.RUnlock()
+
+	// the history and outbox keep the message with its seq, live clients get the version they asked for
+	versions := map[int][]byte{}
+	for _, v := range []int{engine.WSSchemaV1, engine.WSSchemaV2} {
+		if vb := encodeVersion(wsm, v); vb != nil {
+			versions[v] = vb
+		}
+	}
+
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
@@ -57,7 +226,30 @@ func (p *ConnectionPools) BroadcastMessage(t engine.WSMessageType, m engine.WSMe
 				continue
 			}
 
-			pool.BroadcastMessage(query, b)
+			pool.broadcastVersions(query, versions)
+		}
+	}
+}
+
+// Stats returns the connection counts of every open topic
+func (p *ConnectionPools) Stats() Stats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	stats := Stats{
+		Pools: []PoolStats{},
+	}
+
+	for _, pool := range p.pools {
+		if !pool.IsOpen() {
+			continue
 		}
+
+		ps := pool.Stats()
+
+		stats.Connections += ps.Clients
+		stats.Pools = append(stats.Pools, ps)
 	}
+
+	return stats
 }
@@ -0,0 +1,162 @@
+package ws
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestPoolsConnectDisconnect(t *testing.T) {
+	pools := NewConnectionPools()
+
+	topic := "0x1/0x2"
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pools.Connect(w, r, topic)
+	}))
+	defer srv.Close()
+
+	// rapidly connect and disconnect clients so that pools empty and get recreated
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < 10; j++ {
+				conn, _, err := websocket.DefaultDialer.Dial(wsURL(srv, ""), nil)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				conn.Close()
+			}
+		}()
+	}
+	wg.Wait()
+
+	// a client connecting after the churn should end up in an open pool
+	conn := dial(t, srv, "")
+	defer conn.Close()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		pools.mu.Lock()
+		pool, ok := pools.pools[topic]
+		pools.mu.Unlock()
+
+		if ok && pool.IsOpen() && pool.Stats().Clients == 1 {
+			pool.BroadcastMessage("", []byte("hello"))
+
+			conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+			_, msg, err := conn.ReadMessage()
+			if err != nil {
+				t.Fatalf("expected client to receive the broadcast: %v", err)
+			}
+
+			if string(msg) != "hello" {
+				t.Errorf("expected %s, got %s", "hello", msg)
+			}
+
+			return
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Fatal("expected an open pool with 1 client")
+}
+
+func TestPoolsReleaseEmptyPool(t *testing.T) {
+	pools := NewConnectionPools()
+
+	topic := "0x1/0x2"
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pools.Connect(w, r, topic)
+	}))
+	defer srv.Close()
+
+	conn := dial(t, srv, "")
+	conn.Close()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		pools.mu.Lock()
+		n := len(pools.pools)
+		pools.mu.Unlock()
+
+		if n == 0 {
+			return
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Fatal("expected the empty pool to be released")
+}
+
+func TestPoolsCompressionAndSubprotocol(t *testing.T) {
+	pools := NewConnectionPools()
+	pools.SetCompression(true)
+
+	topic := "0x1/0x2"
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pools.Connect(w, r, topic)
+	}))
+	defer srv.Close()
+
+	dialer := websocket.Dialer{
+		EnableCompression: true,
+		Subprotocols:      []string{"unknown", SubprotocolV1},
+	}
+
+	conn, resp, err := dialer.Dial(wsURL(srv, ""), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if conn.Subprotocol() != SubprotocolV1 {
+		t.Errorf("expected subprotocol %s, got %q", SubprotocolV1, conn.Subprotocol())
+	}
+
+	if ext := resp.Header.Get("Sec-WebSocket-Extensions"); !strings.Contains(ext, "permessage-deflate") {
+		t.Errorf("expected permessage-deflate to be negotiated, got %q", ext)
+	}
+
+	// compressed messages are still read by the client
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		pools.mu.Lock()
+		pool, ok := pools.pools[topic]
+		pools.mu.Unlock()
+
+		if ok && pool.Stats().Clients == 1 {
+			msg := strings.Repeat("hello ", 100)
+			pool.BroadcastMessage("", []byte(msg))
+
+			conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+			_, b, err := conn.ReadMessage()
+			if err != nil {
+				t.Fatalf("expected client to receive the broadcast: %v", err)
+			}
+
+			if string(b) != msg {
+				t.Errorf("expected %s, got %s", msg, b)
+			}
+
+			return
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Fatal("expected an open pool with 1 client")
+}
@@ -0,0 +1,89 @@
+package ws
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/citizenwallet/engine/pkg/engine"
+)
+
+const streamPingInterval = 30 * time.Second
+
+// Stream sends the messages of a topic to a client as server-sent events until it disconnects, the client filters
+// with the same query as a websocket client
+//
+// a client that reconnects with the Last-Event-ID header first receives the messages it missed that are still in the history
+// or the outbox
+func (p *ConnectionPools) Stream(w http.ResponseWriter, r *http.Request, topic string) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	query := r.URL.RawQuery
+
+	lastID := p.history.last()
+	if id, err := strconv.ParseUint(r.Header.Get("Last-Event-ID"), 10, 64); err == nil && id < lastID {
+		// without an outbox ids from before a restart are ahead of the history, those clients only get new messages
+		lastID = id
+	}
+
+	// the history is read on every broadcast to the topic, a pending wake up is enough to not miss any
+	wake := make(chan struct{}, 1)
+	stop := p.Listen(func(m *engine.WSMessageLog) {
+		if m.PoolID != topic {
+			return
+		}
+
+		select {
+		case wake <- struct{}{}:
+		default:
+		}
+	})
+	defer stop()
+
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("Connection", "keep-alive")
+	h.Set("X-Accel-Buffering", "no") // proxies should not buffer the stream
+
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	ping := time.NewTicker(streamPingInterval)
+	defer ping.Stop()
+
+	for {
+		for batch := p.since(topic, lastID); len(batch) > 0; batch = p.since(topic, lastID) {
+			for _, e := range batch {
+				lastID = e.id
+
+				if !e.msg.Data.MatchesQuery(query) {
+					continue
+				}
+
+				_, err := fmt.Fprintf(w, "id: %d\ndata: %s\n\n", e.id, e.data)
+				if err != nil {
+					return
+				}
+			}
+		}
+
+		flusher.Flush()
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-wake:
+		case <-ping.C:
+			_, err := fmt.Fprint(w, ": ping\n\n")
+			if err != nil {
+				return
+			}
+		}
+	}
+}
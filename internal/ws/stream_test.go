@@ -0,0 +1,125 @@
+package ws
+
+import (
+	"bufio"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/citizenwallet/engine/pkg/engine"
+)
+
+func broadcastLog(pools *ConnectionPools, hash, to string) {
+	data := json.RawMessage(`{"topic":"0xtopic","to":"` + to + `"}`)
+	pools.BroadcastMessage(engine.WSMessageTypeNew, &engine.Log{Hash: hash, To: "0xtoken", Value: big.NewInt(1), Data: &data})
+}
+
+type streamEvent struct {
+	id  string
+	msg engine.WSMessageLog
+}
+
+// readEvent reads the next event of the stream, comments are skipped
+func readEvent(t *testing.T, r *bufio.Reader) streamEvent {
+	t.Helper()
+
+	var ev streamEvent
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "" && ev.id != "":
+			return ev
+		case strings.HasPrefix(line, "id: "):
+			ev.id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "data: "):
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &ev.msg); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+}
+
+func TestStream(t *testing.T) {
+	pools := NewConnectionPools()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pools.Stream(w, r, "0xtoken/0xtopic")
+	}))
+	defer srv.Close()
+
+	// broadcast before the client connected, it is replayed from the client's last event
+	broadcastLog(pools, "0x1", "0xb")
+
+	req, err := http.NewRequest(http.MethodGet, srv.URL+"?data.to=0xb", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Last-Event-ID", "0")
+
+	client := &http.Client{Timeout: 2 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("expected an event stream, got %s", ct)
+	}
+
+	r := bufio.NewReader(resp.Body)
+
+	ev := readEvent(t, r)
+	if ev.id != "1" || ev.msg.ID != "0x1" {
+		t.Fatalf("expected the missed log 0x1 with id 1, got %s with id %s", ev.msg.ID, ev.id)
+	}
+
+	// only logs that match the query are sent
+	broadcastLog(pools, "0x2", "0xa")
+	broadcastLog(pools, "0x3", "0xb")
+
+	ev = readEvent(t, r)
+	if ev.id != "3" || ev.msg.ID != "0x3" || ev.msg.Type != engine.WSMessageTypeNew {
+		t.Fatalf("expected log 0x3 with id 3, got %s with id %s", ev.msg.ID, ev.id)
+	}
+}
+
+func TestHistory(t *testing.T) {
+	h := newHistory(2)
+
+	for _, hash := range []string{"0x1", "0x2", "0x3"} {
+		h.add(&engine.WSMessageLog{WSMessage: engine.WSMessage{PoolID: "a", ID: hash}}, nil)
+	}
+	h.add(&engine.WSMessageLog{WSMessage: engine.WSMessage{PoolID: "b", ID: "0x4"}}, nil)
+
+	if h.last() != 4 {
+		t.Fatalf("expected last id 4, got %d", h.last())
+	}
+
+	// the oldest message of a full pool is dropped
+	entries, complete := h.since("a", 0)
+	if len(entries) != 2 || entries[0].id != 2 || entries[1].id != 3 {
+		t.Fatalf("unexpected entries %+v", entries)
+	}
+
+	if complete {
+		t.Fatal("expected the entries after 0 to be missing the dropped one")
+	}
+
+	if _, complete := h.since("a", 1); !complete {
+		t.Fatal("expected the entries after 1 to be complete")
+	}
+
+	if entries, _ := h.since("a", 3); len(entries) != 0 {
+		t.Fatalf("expected no entries after 3, got %d", len(entries))
+	}
+}
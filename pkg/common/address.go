@@ -1,13 +1,62 @@
 package common
 
 import (
+	"encoding/hex"
+	"errors"
+	"math/big"
 	"strings"
 
 	"github.com/ethereum/go-ethereum/common"
 )
 
+var (
+	ErrInvalidAddress = errors.New("invalid address")
+	ErrInvalidCAIP10  = errors.New("invalid CAIP-10 account id")
+)
+
+const caip10Namespace = "eip155"
+
+// IsSameHexAddress returns true if both are valid addresses and they are the same, invalid addresses are never the same
 func IsSameHexAddress(a, b string) bool {
-	return strings.ToLower(a) == strings.ToLower(b)
+	na, err := NormalizeAddress(a)
+	if err != nil {
+		return false
+	}
+
+	nb, err := NormalizeAddress(b)
+	if err != nil {
+		return false
+	}
+
+	return na == nb
+}
+
+// ParseAddress strictly parses a 20 byte hex address, with or without 0x prefix
+func ParseAddress(addr string) (common.Address, error) {
+	h := addr
+	if strings.HasPrefix(h, "0x") || strings.HasPrefix(h, "0X") {
+		h = h[2:]
+	}
+	if len(h) != 2*common.AddressLength {
+		return common.Address{}, ErrInvalidAddress
+	}
+
+	b, err := hex.DecodeString(h)
+	if err != nil {
+		return common.Address{}, ErrInvalidAddress
+	}
+
+	return common.BytesToAddress(b), nil
+}
+
+// NormalizeAddress strictly parses a 20 byte hex address and returns it EIP55 checksummed
+func NormalizeAddress(addr string) (string, error) {
+	a, err := ParseAddress(addr)
+	if err != nil {
+		return "", err
+	}
+
+	return a.Hex(), nil
 }
 
 func ChecksumAddress(addr string) string {
@@ -15,3 +64,27 @@ func ChecksumAddress(addr string) string {
 
 	return address.Hex()
 }
+
+// ParseCAIP10 parses a CAIP-10 account id (eip155:<chain id>:<address>) into its chain id and address
+func ParseCAIP10(id string) (*big.Int, common.Address, error) {
+	parts := strings.Split(id, ":")
+	if len(parts) != 3 || parts[0] != caip10Namespace {
+		return nil, common.Address{}, ErrInvalidCAIP10
+	}
+
+	chainID, ok := new(big.Int).SetString(parts[1], 10)
+	if !ok || chainID.Sign() <= 0 {
+		return nil, common.Address{}, ErrInvalidCAIP10
+	}
+
+	if !common.IsHexAddress(parts[2]) {
+		return nil, common.Address{}, ErrInvalidCAIP10
+	}
+
+	return chainID, common.HexToAddress(parts[2]), nil
+}
+
+// IsCAIP10 returns true if the id looks like a CAIP-10 account id rather than a bare address
+func IsCAIP10(id string) bool {
+	return strings.Contains(id, ":")
+}
@@ -36,3 +36,115 @@ func TestChecksumAddress(t *testing.T) {
 		})
 	}
 }
+
+func TestParseCAIP10(t *testing.T) {
+	tests := []struct {
+		name    string
+		id      string
+		chainID int64
+		addr    string
+		err     bool
+	}{
+		{"valid", "eip155:137:0x480fbe37526226b6c6e2a7afa449cdf661939d2f", 137, "0x480Fbe37526226b6c6E2a7AfA449cDf661939D2f", false},
+		{"bare address", "0x480fbe37526226b6c6e2a7afa449cdf661939d2f", 0, "", true},
+		{"wrong namespace", "cosmos:137:0x480fbe37526226b6c6e2a7afa449cdf661939d2f", 0, "", true},
+		{"invalid chain id", "eip155:abc:0x480fbe37526226b6c6e2a7afa449cdf661939d2f", 0, "", true},
+		{"invalid address", "eip155:137:not_an_address", 0, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chainID, addr, err := ParseCAIP10(tt.id)
+			if (err != nil) != tt.err {
+				t.Fatalf("expected error %v, got %v", tt.err, err)
+			}
+
+			if tt.err {
+				return
+			}
+
+			if chainID.Int64() != tt.chainID {
+				t.Errorf("expected chain id %d, got %d", tt.chainID, chainID)
+			}
+
+			if addr.Hex() != tt.addr {
+				t.Errorf("expected address %s, got %s", tt.addr, addr.Hex())
+			}
+		})
+	}
+}
+
+func TestNormalizeAddress(t *testing.T) {
+	tests := []struct {
+		name     string
+		addr     string
+		expected string
+		err      bool
+	}{
+		{"lowercase", "0x480fbe37526226b6c6e2a7afa449cdf661939d2f", "0x480Fbe37526226b6c6E2a7AfA449cDf661939D2f", false},
+		{"no prefix", "480fbe37526226b6c6e2a7afa449cdf661939d2f", "0x480Fbe37526226b6c6E2a7AfA449cDf661939D2f", false},
+		{"uppercase prefix", "0X480FBE37526226B6C6E2A7AFA449CDF661939D2F", "0x480Fbe37526226b6c6E2a7AfA449cDf661939D2f", false},
+		{"empty", "", "", true},
+		{"prefix only", "0x", "", true},
+		{"too short", "0x480fbe37526226b6c6e2a7afa449cdf661939d", "", true},
+		{"too long", "0x480fbe37526226b6c6e2a7afa449cdf661939d2f00", "", true},
+		{"not hex", "0x480fbe37526226b6c6e2a7afa449cdf661939dzz", "", true},
+		{"double prefix", "0x0x480fbe37526226b6c6e2a7afa449cdf661939d", "", true},
+		{"whitespace", " 0x480fbe37526226b6c6e2a7afa449cdf661939d2f", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual, err := NormalizeAddress(tt.addr)
+			if (err != nil) != tt.err {
+				t.Fatalf("expected error %v, got %v", tt.err, err)
+			}
+
+			if actual != tt.expected {
+				t.Errorf("NormalizeAddress(%s): expected %s, but got %s", tt.addr, tt.expected, actual)
+			}
+		})
+	}
+}
+
+func TestIsSameHexAddress(t *testing.T) {
+	if !IsSameHexAddress("0x480fbe37526226b6c6e2a7afa449cdf661939d2f", "480FBE37526226B6C6E2A7AFA449CDF661939D2F") {
+		t.Error("expected the same address in different cases to match")
+	}
+
+	if IsSameHexAddress("not_an_address", "not_an_address") {
+		t.Error("expected invalid addresses not to match")
+	}
+
+	if IsSameHexAddress("", "0x0000000000000000000000000000000000000000") {
+		t.Error("expected an empty address not to match the zero address")
+	}
+}
+
+func FuzzNormalizeAddress(f *testing.F) {
+	f.Add("0x480fbe37526226b6c6e2a7afa449cdf661939d2f")
+	f.Add("480FBE37526226B6C6E2A7AFA449CDF661939D2F")
+	f.Add("0x")
+	f.Add("")
+	f.Add("not_an_address")
+
+	f.Fuzz(func(t *testing.T, addr string) {
+		n, err := NormalizeAddress(addr)
+		if err != nil {
+			if IsSameHexAddress(addr, addr) {
+				t.Errorf("invalid address %q matches itself", addr)
+			}
+			return
+		}
+
+		// a normalized address is stable and still the same address
+		again, err := NormalizeAddress(n)
+		if err != nil || again != n {
+			t.Errorf("NormalizeAddress(%q) = %q is not stable: %q, %v", addr, n, again, err)
+		}
+
+		if !IsSameHexAddress(addr, n) {
+			t.Errorf("NormalizeAddress(%q) = %q is not the same address", addr, n)
+		}
+	})
+}
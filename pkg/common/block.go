@@ -0,0 +1,28 @@
+package common
+
+import (
+	"encoding/json"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// ParseBlockTag parses a block tag ("latest", "pending", "finalized", "safe", "earliest" or a hex number)
+// into a block number as expected by the go-ethereum client, a missing tag defaults to latest (nil)
+func ParseBlockTag(raw json.RawMessage) (*big.Int, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+
+	var bn rpc.BlockNumber
+	if err := json.Unmarshal(raw, &bn); err != nil {
+		return nil, err
+	}
+
+	if bn == rpc.LatestBlockNumber {
+		return nil, nil
+	}
+
+	// negative numbers are understood by the client as pending, finalized and safe
+	return big.NewInt(bn.Int64()), nil
+}
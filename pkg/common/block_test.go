@@ -0,0 +1,38 @@
+package common
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+)
+
+func TestParseBlockTag(t *testing.T) {
+	tests := []struct {
+		name     string
+		tag      string
+		expected *big.Int
+		err      bool
+	}{
+		{"missing", "", nil, false},
+		{"null", "null", nil, false},
+		{"latest", `"latest"`, nil, false},
+		{"pending", `"pending"`, big.NewInt(-1), false},
+		{"finalized", `"finalized"`, big.NewInt(-3), false},
+		{"earliest", `"earliest"`, big.NewInt(0), false},
+		{"number", `"0x2a"`, big.NewInt(42), false},
+		{"invalid", `"tomorrow"`, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseBlockTag(json.RawMessage(tt.tag))
+			if (err != nil) != tt.err {
+				t.Fatalf("expected error %v, got %v", tt.err, err)
+			}
+
+			if (got == nil) != (tt.expected == nil) || (got != nil && got.Cmp(tt.expected) != 0) {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
@@ -111,7 +111,7 @@ func (m *MockEVMRequester) BlockTime(number *big.Int) (uint64, error) {
 	panic("unimplemented")
 }
 
-func (m *MockEVMRequester) Call(method string, result any, params json.RawMessage) error {
+func (m *MockEVMRequester) Call(ctx context.Context, method string, result any, params json.RawMessage) error {
 	panic("unimplemented")
 }
 
@@ -155,6 +155,11 @@ func (m *MockEVMRequester) EstimateGasLimit(msg ethereum.CallMsg) (uint64, error
 	panic("unimplemented")
 }
 
+// GetFeeEstimates implements indexer.EVMRequester.
+func (m *MockEVMRequester) GetFeeEstimates(speed engine.FeeSpeed) (*engine.FeeEstimate, error) {
+	panic("unimplemented")
+}
+
 // EstimateGasPrice implements indexer.EVMRequester.
 func (m *MockEVMRequester) EstimateGasPrice() (*big.Int, error) {
 	panic("unimplemented")
@@ -175,6 +180,11 @@ func (m *MockEVMRequester) ListenForLogs(ctx context.Context, q ethereum.FilterQ
 	panic("unimplemented")
 }
 
+// ListenForHeads implements indexer.EVMRequester.
+func (m *MockEVMRequester) ListenForHeads(ctx context.Context, ch chan<- *types.Header) error {
+	panic("unimplemented")
+}
+
 // NewTx implements indexer.EVMRequester.
 func (m *MockEVMRequester) NewTx(nonce uint64, from common.Address, to common.Address, data []byte, extraGas bool) (*types.Transaction, error) {
 	panic("unimplemented")
@@ -185,13 +195,18 @@ func (m *MockEVMRequester) NonceAt(ctx context.Context, account common.Address,
 	panic("unimplemented")
 }
 
+// BalanceAt implements indexer.EVMRequester.
+func (m *MockEVMRequester) BalanceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (*big.Int, error) {
+	panic("unimplemented")
+}
+
 // SendTransaction implements indexer.EVMRequester.
 func (m *MockEVMRequester) SendTransaction(tx *types.Transaction) error {
 	panic("unimplemented")
 }
 
 // StorageAt implements indexer.EVMRequester.
-func (m *MockEVMRequester) StorageAt(addr common.Address, slot common.Hash) ([]byte, error) {
+func (m *MockEVMRequester) StorageAt(addr common.Address, slot common.Hash, blockNumber *big.Int) ([]byte, error) {
 	panic("unimplemented")
 }
 
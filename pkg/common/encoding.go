@@ -0,0 +1,294 @@
+package common
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"math"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Encoder serializes response bodies for a content type
+type Encoder interface {
+	ContentType() string
+	Encode(v any) ([]byte, error)
+}
+
+type jsonEncoder struct{}
+
+func (jsonEncoder) ContentType() string {
+	return "application/json"
+}
+
+func (jsonEncoder) Encode(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+// msgpackEncoder serializes the json representation of a value as MessagePack
+//
+// the schema is the same as the json one: objects are maps with the same keys (sorted), json numbers are integers when
+// they fit in 64 bits and floats when they have a fraction or exponent, larger integers (ex: token values) are
+// decimal strings
+type msgpackEncoder struct{}
+
+func (msgpackEncoder) ContentType() string {
+	return "application/msgpack"
+}
+
+func (msgpackEncoder) Encode(v any) ([]byte, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+
+	var value any
+	if err := dec.Decode(&value); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := writeMsgpack(&buf, value); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+var (
+	JSONEncoder    Encoder = jsonEncoder{}
+	MsgpackEncoder Encoder = msgpackEncoder{}
+)
+
+// encoders by the media types that clients can ask for
+var encoders = map[string]Encoder{
+	"application/json":        JSONEncoder,
+	"application/msgpack":     MsgpackEncoder,
+	"application/x-msgpack":   MsgpackEncoder,
+	"application/vnd.msgpack": MsgpackEncoder,
+}
+
+// RegisterEncoder makes an encoder available to content negotiation for a media type
+func RegisterEncoder(mediaType string, e Encoder) {
+	encoders[mediaType] = e
+}
+
+// NegotiateEncoder returns the encoder that the Accept header prefers, json is the default
+func NegotiateEncoder(accept string) Encoder {
+	var (
+		best  Encoder = JSONEncoder
+		bestQ float64
+	)
+
+	for _, part := range strings.Split(accept, ",") {
+		mt, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		e, ok := encoders[mt]
+		if !ok {
+			continue
+		}
+
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			q, err = strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+		}
+
+		// the first of equally preferred types wins
+		if q > bestQ {
+			best, bestQ = e, q
+		}
+	}
+
+	return best
+}
+
+// encodingWriter carries the negotiated encoder of a request to Body and BodyMultiple
+type encodingWriter struct {
+	http.ResponseWriter
+	encoder Encoder
+}
+
+func (ew *encodingWriter) Unwrap() http.ResponseWriter {
+	return ew.ResponseWriter
+}
+
+// WithEncoder makes Body and BodyMultiple encode their responses to w with e
+func WithEncoder(w http.ResponseWriter, e Encoder) http.ResponseWriter {
+	return &encodingWriter{ResponseWriter: w, encoder: e}
+}
+
+// encoderOf returns the encoder of a response writer, json unless one was negotiated
+//
+// writers that wrap the one with the encoder are unwrapped
+func encoderOf(w http.ResponseWriter) Encoder {
+	for w != nil {
+		if ew, ok := w.(*encodingWriter); ok {
+			return ew.encoder
+		}
+
+		u, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			break
+		}
+
+		w = u.Unwrap()
+	}
+
+	return JSONEncoder
+}
+
+var errMsgpackType = errors.New("unsupported msgpack type")
+
+// writeMsgpack writes a value decoded from json
+func writeMsgpack(buf *bytes.Buffer, v any) error {
+	switch v := v.(type) {
+	case nil:
+		buf.WriteByte(0xc0)
+	case bool:
+		if v {
+			buf.WriteByte(0xc3)
+		} else {
+			buf.WriteByte(0xc2)
+		}
+	case string:
+		writeMsgpackString(buf, v)
+	case json.Number:
+		return writeMsgpackNumber(buf, v)
+	case []any:
+		writeMsgpackHeader(buf, len(v), 0x90, 0xdc, 0xdd)
+		for _, item := range v {
+			if err := writeMsgpack(buf, item); err != nil {
+				return err
+			}
+		}
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		writeMsgpackHeader(buf, len(keys), 0x80, 0xde, 0xdf)
+		for _, k := range keys {
+			writeMsgpackString(buf, k)
+			if err := writeMsgpack(buf, v[k]); err != nil {
+				return err
+			}
+		}
+	default:
+		return errMsgpackType
+	}
+
+	return nil
+}
+
+// writeMsgpackHeader writes the length of a map or array, fix is the type of lengths below 16
+func writeMsgpackHeader(buf *bytes.Buffer, n int, fix, b16, b32 byte) {
+	switch {
+	case n < 16:
+		buf.WriteByte(fix | byte(n))
+	case n <= math.MaxUint16:
+		buf.WriteByte(b16)
+		buf.Write(binary.BigEndian.AppendUint16(nil, uint16(n)))
+	default:
+		buf.WriteByte(b32)
+		buf.Write(binary.BigEndian.AppendUint32(nil, uint32(n)))
+	}
+}
+
+func writeMsgpackString(buf *bytes.Buffer, s string) {
+	n := len(s)
+	switch {
+	case n < 32:
+		buf.WriteByte(0xa0 | byte(n))
+	case n <= math.MaxUint8:
+		buf.WriteByte(0xd9)
+		buf.WriteByte(byte(n))
+	case n <= math.MaxUint16:
+		buf.WriteByte(0xda)
+		buf.Write(binary.BigEndian.AppendUint16(nil, uint16(n)))
+	default:
+		buf.WriteByte(0xdb)
+		buf.Write(binary.BigEndian.AppendUint32(nil, uint32(n)))
+	}
+
+	buf.WriteString(s)
+}
+
+func writeMsgpackNumber(buf *bytes.Buffer, n json.Number) error {
+	s := n.String()
+
+	if strings.ContainsAny(s, ".eE") {
+		f, err := n.Float64()
+		if err != nil {
+			return err
+		}
+
+		buf.WriteByte(0xcb)
+		buf.Write(binary.BigEndian.AppendUint64(nil, math.Float64bits(f)))
+		return nil
+	}
+
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		writeMsgpackInt(buf, i)
+		return nil
+	}
+
+	if u, err := strconv.ParseUint(s, 10, 64); err == nil {
+		buf.WriteByte(0xcf)
+		buf.Write(binary.BigEndian.AppendUint64(nil, u))
+		return nil
+	}
+
+	// integers that don't fit in 64 bits keep their precision as strings
+	writeMsgpackString(buf, s)
+
+	return nil
+}
+
+// writeMsgpackInt writes an integer in its smallest representation
+func writeMsgpackInt(buf *bytes.Buffer, i int64) {
+	switch {
+	case i >= 0 && i <= math.MaxInt8:
+		buf.WriteByte(byte(i))
+	case i < 0 && i >= -32:
+		buf.WriteByte(byte(int8(i)))
+	case i >= 0 && i <= math.MaxUint8:
+		buf.WriteByte(0xcc)
+		buf.WriteByte(byte(i))
+	case i >= 0 && i <= math.MaxUint16:
+		buf.WriteByte(0xcd)
+		buf.Write(binary.BigEndian.AppendUint16(nil, uint16(i)))
+	case i >= 0 && i <= math.MaxUint32:
+		buf.WriteByte(0xce)
+		buf.Write(binary.BigEndian.AppendUint32(nil, uint32(i)))
+	case i >= 0:
+		buf.WriteByte(0xcf)
+		buf.Write(binary.BigEndian.AppendUint64(nil, uint64(i)))
+	case i >= math.MinInt8:
+		buf.WriteByte(0xd0)
+		buf.WriteByte(byte(int8(i)))
+	case i >= math.MinInt16:
+		buf.WriteByte(0xd1)
+		buf.Write(binary.BigEndian.AppendUint16(nil, uint16(int16(i))))
+	case i >= math.MinInt32:
+		buf.WriteByte(0xd2)
+		buf.Write(binary.BigEndian.AppendUint32(nil, uint32(int32(i))))
+	default:
+		buf.WriteByte(0xd3)
+		buf.Write(binary.BigEndian.AppendUint64(nil, uint64(i)))
+	}
+}
@@ -0,0 +1,117 @@
+package common
+
+import (
+	"bytes"
+	"math/big"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNegotiateEncoder(t *testing.T) {
+	tests := []struct {
+		name     string
+		accept   string
+		expected Encoder
+	}{
+		{"empty", "", JSONEncoder},
+		{"any", "*/*", JSONEncoder},
+		{"json", "application/json", JSONEncoder},
+		{"msgpack", "application/msgpack", MsgpackEncoder},
+		{"x-msgpack", "application/x-msgpack", MsgpackEncoder},
+		{"preferred msgpack", "application/json;q=0.5, application/msgpack", MsgpackEncoder},
+		{"preferred json", "application/msgpack;q=0.5, application/json", JSONEncoder},
+		{"refused msgpack", "application/msgpack;q=0", JSONEncoder},
+		{"unsupported", "application/xml", JSONEncoder},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NegotiateEncoder(tt.accept); got != tt.expected {
+				t.Errorf("NegotiateEncoder(%q) = %s, want %s", tt.accept, got.ContentType(), tt.expected.ContentType())
+			}
+		})
+	}
+}
+
+func TestMsgpackEncoder(t *testing.T) {
+	value, _ := new(big.Int).SetString("100000000000000000000", 10)
+
+	tests := []struct {
+		name     string
+		value    any
+		expected []byte
+	}{
+		{
+			name: "object with sorted keys",
+			value: struct {
+				C string `json:"c"`
+				A int    `json:"a"`
+				B []any  `json:"b"`
+			}{C: "x", A: 1, B: []any{true, nil}},
+			expected: []byte{0x83, 0xa1, 'a', 0x01, 0xa1, 'b', 0x92, 0xc3, 0xc0, 0xa1, 'c', 0xa1, 'x'},
+		},
+		{
+			name:     "integers",
+			value:    []int64{-1, -33, 200, 70000, -70000, 1 << 40},
+			expected: []byte{0x96, 0xff, 0xd0, 0xdf, 0xcc, 0xc8, 0xce, 0x00, 0x01, 0x11, 0x70, 0xd2, 0xff, 0xfe, 0xee, 0x90, 0xcf, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00},
+		},
+		{
+			name:     "float",
+			value:    1.5,
+			expected: []byte{0xcb, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0},
+		},
+		{
+			name:     "integer larger than 64 bits",
+			value:    value,
+			expected: append([]byte{0xb5}, "100000000000000000000"...),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := MsgpackEncoder.Encode(tt.value)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(b, tt.expected) {
+				t.Errorf("Encode() = %x, want %x", b, tt.expected)
+			}
+		})
+	}
+}
+
+func TestBodyMultipleWithEncoder(t *testing.T) {
+	rec := httptest.NewRecorder()
+
+	err := BodyMultiple(WithEncoder(rec, MsgpackEncoder), []int{1}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ct := rec.Header().Get("Content-Type"); ct != "application/msgpack" {
+		t.Fatalf("content type = %s, want application/msgpack", ct)
+	}
+
+	// {"array": [1], "response_type": "array"}
+	expected := append([]byte{0x82, 0xa5}, "array"...)
+	expected = append(expected, 0x91, 0x01, 0xad)
+	expected = append(expected, "response_type"...)
+	expected = append(expected, 0xa5)
+	expected = append(expected, "array"...)
+
+	if !bytes.Equal(rec.Body.Bytes(), expected) {
+		t.Fatalf("body = %x, want %x", rec.Body.Bytes(), expected)
+	}
+
+	rec = httptest.NewRecorder()
+
+	err = BodyMultiple(rec, []int{1}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Fatalf("content type = %s, want application/json", ct)
+	}
+}
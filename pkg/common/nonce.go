@@ -56,7 +56,7 @@ func NewNonce() (*Nonce, error) {
 }
 
 // ParseNonce parses a nonce from a big.Int containing a uint256
-// with the first 64 bits being the key and the last 192 bits being the seq.
+// with the first 192 bits being the key and the last 64 bits being the seq.
 func ParseNonce(nonce *big.Int) *Nonce {
 	// Create a big.Int with 1 followed by 64 zeros
 	mask := new(big.Int).Lsh(big.NewInt(1), 64)
@@ -67,14 +67,14 @@ func ParseNonce(nonce *big.Int) *Nonce {
 	// Bitwise AND the nonce with the mask to get the seq
 	seq := new(big.Int).And(nonce, mask)
 
-	// Shift the nonce 192 bits to the right to get the key
-	keyInt := new(big.Int).Rsh(nonce, 192)
+	// Shift the nonce 64 bits to the right to get the key
+	keyInt := new(big.Int).Rsh(nonce, 64)
 
 	return &Nonce{Seq: seq.Uint64(), Key: keyInt}
 }
 
 func (n *Nonce) BigInt() *big.Int {
-	seq := big.NewInt(int64(n.Seq))
+	seq := new(big.Int).SetUint64(n.Seq)
 
 	// Convert the random uint192 to a big.Int by calling SetBytes on a new big.Int,
 	// which interprets the byte slice as a big-endian integer.
@@ -0,0 +1,56 @@
+package common
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestParseNonce(t *testing.T) {
+	key, _ := new(big.Int).SetString("123456789abcdef0123456789abcdef0123456789abcdef", 16)
+
+	tests := []struct {
+		name  string
+		nonce *big.Int
+		key   *big.Int
+		seq   uint64
+	}{
+		{"zero", big.NewInt(0), big.NewInt(0), 0},
+		{"sequential", big.NewInt(7), big.NewInt(0), 7},
+		{"key only", new(big.Int).Lsh(key, 64), key, 0},
+		{"key and seq", new(big.Int).Or(new(big.Int).Lsh(key, 64), big.NewInt(3)), key, 3},
+		{"max seq", new(big.Int).SetUint64(^uint64(0)), big.NewInt(0), ^uint64(0)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := ParseNonce(tt.nonce)
+			if n.Key.Cmp(tt.key) != 0 || n.Seq != tt.seq {
+				t.Errorf("expected key %s seq %d, got key %s seq %d", tt.key.Text(16), tt.seq, n.Key.Text(16), n.Seq)
+			}
+
+			if n.BigInt().Cmp(tt.nonce) != 0 {
+				t.Errorf("expected the nonce to round trip, got %s", n.BigInt().Text(16))
+			}
+		})
+	}
+}
+
+func TestNewNonce(t *testing.T) {
+	a, err := NewNonce()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := NewNonce()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if a.Seq != 0 || a.Key.Cmp(b.Key) == 0 {
+		t.Errorf("expected new nonces to start a fresh key, got %s and %s", a, b)
+	}
+
+	if a.Key.BitLen() > 192 {
+		t.Errorf("expected the key to fit in 192 bits, got %d", a.Key.BitLen())
+	}
+}
@@ -39,9 +39,46 @@ type Response struct {
 	Meta         any          `json:"meta,omitempty"`
 }
 
+// Error is the reason why a request failed
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// ErrorResponse is the body of the responses of failed requests
+type ErrorResponse struct {
+	Error Error `json:"error"`
+}
+
+// WriteError writes the status code of a failed request with a json body that says why it failed, the reason defaults
+// to the text of the status code
+func WriteError(w http.ResponseWriter, code int, reason string) {
+	if reason == "" {
+		reason = http.StatusText(code)
+	}
+
+	b, err := json.Marshal(&ErrorResponse{
+		Error: Error{
+			Code:    code,
+			Message: reason,
+		},
+	})
+	if err != nil {
+		w.WriteHeader(code)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(code)
+	w.Write(b)
+}
+
 func Body(w http.ResponseWriter, body any, meta any) error {
 
-	b, err := json.Marshal(&Response{
+	enc := encoderOf(w)
+
+	b, err := enc.Encode(&Response{
 		ResponseType: ResponseTypeObject,
 		Object:       body,
 		Meta:         meta,
@@ -50,7 +87,7 @@ func Body(w http.ResponseWriter, body any, meta any) error {
 		return err
 	}
 
-	w.Header().Add("Content-Type", "application/json")
+	w.Header().Add("Content-Type", enc.ContentType())
 	w.Write(b)
 
 	return nil
@@ -58,7 +95,9 @@ func Body(w http.ResponseWriter, body any, meta any) error {
 
 func BodyMultiple(w http.ResponseWriter, body any, meta any) error {
 
-	b, err := json.Marshal(&Response{
+	enc := encoderOf(w)
+
+	b, err := enc.Encode(&Response{
 		ResponseType: ResponseTypeArray,
 		Array:        body,
 		Meta:         meta,
@@ -67,7 +106,7 @@ func BodyMultiple(w http.ResponseWriter, body any, meta any) error {
 		return err
 	}
 
-	w.Header().Add("Content-Type", "application/json")
+	w.Header().Add("Content-Type", enc.ContentType())
 	w.Write(b)
 
 	return nil
@@ -90,18 +129,31 @@ func StreamedBody(w http.ResponseWriter, body string) error {
 	return nil
 }
 
-func JSONRPCBody(w http.ResponseWriter, id any, body any, meta any, err error) error {
-	b, err := json.Marshal(&engine.JsonRPCResponse{
+// NewJSONRPCResponse creates a JSON RPC response for a request id, the error is converted to a JSON RPC error
+func NewJSONRPCResponse(id any, body any, err error) engine.JsonRPCResponse {
+	return engine.JsonRPCResponse{
 		Version: "2.0",
 		ID:      id,
 		Result:  body,
 		Error:   parseRPCError(err),
-	})
+	}
+}
+
+func JSONRPCBody(w http.ResponseWriter, id any, body any, meta any, err error) error {
+	resp := NewJSONRPCResponse(id, body, err)
+
+	// the rpc node was not called, clients can retry later instead of treating it as an error of their request
+	unavailable := errors.Is(err, engine.ErrRPCUnavailable)
+
+	b, err := json.Marshal(&resp)
 	if err != nil {
 		return err
 	}
 
 	w.Header().Add("Content-Type", "application/json")
+	if unavailable {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
 	w.Write(b)
 
 	return nil
@@ -120,12 +172,7 @@ func JSONRPCMultiBody(w http.ResponseWriter, ids []any, bodies []any, meta any,
 	responses := make([]engine.JsonRPCResponse, len(ids))
 
 	for i, id := range ids {
-		responses[i] = engine.JsonRPCResponse{
-			Version: "2.0",
-			ID:      id,
-			Result:  bodies[i],
-			Error:   parseRPCError(errs[i]),
-		}
+		responses[i] = NewJSONRPCResponse(id, bodies[i], errs[i])
 	}
 
 	b, err := json.Marshal(&responses)
@@ -145,10 +192,16 @@ func parseRPCError(err error) *engine.JSONRPCError {
 	}
 
 	if rpcErr, ok := err.(rpc.Error); ok {
-		return &engine.JSONRPCError{
+		e := &engine.JSONRPCError{
 			Code:    rpcErr.ErrorCode(),
 			Message: rpcErr.Error(),
 		}
+
+		if dataErr, ok := err.(rpc.DataError); ok {
+			e.Data = dataErr.ErrorData()
+		}
+
+		return e
 	}
 
 	return &engine.JSONRPCError{
@@ -0,0 +1,80 @@
+package common
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/citizenwallet/engine/pkg/engine"
+)
+
+func TestWriteError(t *testing.T) {
+	tests := []struct {
+		name    string
+		code    int
+		reason  string
+		message string
+	}{
+		{"reason", http.StatusBadRequest, "missing signature", "missing signature"},
+		{"status text", http.StatusInternalServerError, "", "Internal Server Error"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			WriteError(w, tt.code, tt.reason)
+
+			if w.Code != tt.code {
+				t.Errorf("expected %d, got %d", tt.code, w.Code)
+			}
+
+			if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+				t.Errorf("expected a json body, got %s", ct)
+			}
+
+			var resp ErrorResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+
+			if resp.Error.Code != tt.code || resp.Error.Message != tt.message {
+				t.Errorf("expected %d %q, got %d %q", tt.code, tt.message, resp.Error.Code, resp.Error.Message)
+			}
+		})
+	}
+}
+
+func TestJSONRPCBody_Unavailable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		code int
+	}{
+		{"result", nil, http.StatusOK},
+		{"error", errors.New("execution reverted"), http.StatusOK},
+		{"unavailable", fmt.Errorf("eth_call: %w", engine.ErrRPCUnavailable), http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			JSONRPCBody(w, 1, nil, nil, tt.err)
+
+			if w.Code != tt.code {
+				t.Errorf("expected %d, got %d", tt.code, w.Code)
+			}
+
+			var resp engine.JsonRPCResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+
+			if (resp.Error != nil) != (tt.err != nil) {
+				t.Errorf("expected an error %t, got %+v", tt.err != nil, resp.Error)
+			}
+		})
+	}
+}
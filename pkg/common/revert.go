@@ -0,0 +1,91 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// DecodeRevertReason returns the reason of a revert from its abi encoded data, the message of Error(string)
+// (0x08c379a0) or the description of the code of Panic(uint256) (0x4e487b71), custom errors have no reason
+func DecodeRevertReason(data []byte) string {
+	reason, err := abi.UnpackRevert(data)
+	if err != nil {
+		return ""
+	}
+
+	return reason
+}
+
+// RevertReason decodes the reason of the revert from the data of an rpc error, nodes return it as a hex string
+func RevertReason(err error) string {
+	var dataErr rpc.DataError
+	if !errors.As(err, &dataErr) {
+		return ""
+	}
+
+	data, ok := dataErr.ErrorData().(string)
+	if !ok {
+		return ""
+	}
+
+	b, decodeErr := hexutil.Decode(data)
+	if decodeErr != nil {
+		return ""
+	}
+
+	return DecodeRevertReason(b)
+}
+
+// RevertError is the error of a call that reverted, its message has the decoded reason so that clients don't have to
+// decode the data themselves, the code and the data of the rpc error are kept
+type RevertError struct {
+	Reason string
+	Err    error
+}
+
+func (e *RevertError) Error() string {
+	msg := e.Err.Error()
+	if strings.Contains(msg, e.Reason) {
+		return msg
+	}
+
+	return fmt.Sprintf("%s: %s", msg, e.Reason)
+}
+
+func (e *RevertError) Unwrap() error {
+	return e.Err
+}
+
+func (e *RevertError) ErrorCode() int {
+	var rpcErr rpc.Error
+	if errors.As(e.Err, &rpcErr) {
+		return rpcErr.ErrorCode()
+	}
+
+	return -32000
+}
+
+func (e *RevertError) ErrorData() any {
+	var dataErr rpc.DataError
+	if errors.As(e.Err, &dataErr) {
+		return dataErr.ErrorData()
+	}
+
+	return nil
+}
+
+// WithRevertReason returns a RevertError if the data of an rpc error is a revert with a reason, other errors are
+// returned as is
+func WithRevertReason(err error) error {
+	reason := RevertReason(err)
+	if reason == "" {
+		return err
+	}
+
+	return &RevertError{Reason: reason, Err: err}
+}
@@ -0,0 +1,84 @@
+package common
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+func packRevert(t *testing.T, selector []byte, typ string, v any) []byte {
+	t.Helper()
+
+	abiType, err := abi.NewType(typ, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	packed, err := abi.Arguments{{Type: abiType}}.Pack(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return append(append([]byte{}, selector...), packed...)
+}
+
+var (
+	errorSelector = []byte{0x08, 0xc3, 0x79, 0xa0}
+	panicSelector = []byte{0x4e, 0x48, 0x7b, 0x71}
+)
+
+func TestDecodeRevertReason(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   []byte
+		reason string
+	}{
+		{"error", packRevert(t, errorSelector, "string", "ERC20: transfer amount exceeds balance"), "ERC20: transfer amount exceeds balance"},
+		{"panic", packRevert(t, panicSelector, "uint256", big.NewInt(0x11)), "arithmetic underflow or overflow"},
+		{"custom error", packRevert(t, []byte{0xe4, 0x50, 0xd3, 0x8c}, "uint256", big.NewInt(1)), ""},
+		{"short data", []byte{0x08, 0xc3}, ""},
+		{"empty", nil, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if reason := DecodeRevertReason(tt.data); reason != tt.reason {
+				t.Errorf("expected %q, got %q", tt.reason, reason)
+			}
+		})
+	}
+}
+
+// callError is the error of a node for a call that reverts
+type callError struct {
+	data any
+}
+
+func (e callError) Error() string  { return "execution reverted" }
+func (e callError) ErrorCode() int { return 3 }
+func (e callError) ErrorData() any { return e.data }
+
+func TestWithRevertReason(t *testing.T) {
+	data := hexutil.Encode(packRevert(t, errorSelector, "string", "not the owner"))
+
+	err := WithRevertReason(callError{data: data})
+
+	var revertErr *RevertError
+	if !errors.As(err, &revertErr) || revertErr.Reason != "not the owner" {
+		t.Fatalf("expected a revert error with the reason, got %v", err)
+	}
+
+	rpcErr := parseRPCError(err)
+	if rpcErr.Code != 3 || rpcErr.Message != "execution reverted: not the owner" || rpcErr.Data != data {
+		t.Errorf("expected the code, reason and data to be kept, got %+v", rpcErr)
+	}
+
+	for _, other := range []error{errors.New("connection refused"), callError{}, callError{data: "0x1234"}} {
+		if got := WithRevertReason(other); got != other {
+			t.Errorf("expected %v to be returned as is, got %v", other, got)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package engine
+
+import (
+	"math/big"
+	"strings"
+)
+
+// ContractMetadata is how a contract is presented in notifications, the name, symbol and decimals are the ones of its
+// events, the community and icon brand them
+type ContractMetadata struct {
+	Contract  string `json:"contract"`
+	Name      string `json:"name"`
+	Symbol    string `json:"symbol"`
+	Decimals  int    `json:"decimals"`
+	Community string `json:"community"`
+	Icon      string `json:"icon"`
+}
+
+// DisplayName returns the community of the contract, its name if it has none or its address if it has neither
+func (m *ContractMetadata) DisplayName() string {
+	if m.Community != "" {
+		return m.Community
+	}
+
+	if m.Name != "" {
+		return m.Name
+	}
+
+	return m.Contract
+}
+
+// FormatAmount formats an amount of the smallest unit of the contract with its decimals, trailing zeros are dropped
+// Example: 5000000000000000000 with 18 decimals
+// Returns: 5
+func (m *ContractMetadata) FormatAmount(value *big.Int) string {
+	if value == nil {
+		return "0"
+	}
+
+	if m.Decimals <= 0 {
+		return value.String()
+	}
+
+	digits := new(big.Int).Abs(value).String()
+	if len(digits) <= m.Decimals {
+		digits = strings.Repeat("0", m.Decimals-len(digits)+1) + digits
+	}
+
+	whole := digits[:len(digits)-m.Decimals]
+	frac := strings.TrimRight(digits[len(digits)-m.Decimals:], "0")
+
+	amount := whole
+	if frac != "" {
+		amount += "." + frac
+	}
+
+	if value.Sign() < 0 {
+		amount = "-" + amount
+	}
+
+	return amount
+}
@@ -0,0 +1,56 @@
+package engine
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestContractMetadata_DisplayName(t *testing.T) {
+	meta := &ContractMetadata{Contract: "0x5815E61eF72c9E6107b5c5A05FD121F334f7a7f1"}
+	if got := meta.DisplayName(); got != meta.Contract {
+		t.Errorf("expected the address, got %s", got)
+	}
+
+	meta.Name = "Breadchain Community Token"
+	if got := meta.DisplayName(); got != meta.Name {
+		t.Errorf("expected the name, got %s", got)
+	}
+
+	meta.Community = "Bread"
+	if got := meta.DisplayName(); got != meta.Community {
+		t.Errorf("expected the community, got %s", got)
+	}
+}
+
+func TestContractMetadata_FormatAmount(t *testing.T) {
+	tests := []struct {
+		decimals int
+		value    string
+		want     string
+	}{
+		{18, "5000000000000000000", "5"},
+		{18, "1500000000000000000", "1.5"},
+		{6, "1", "0.000001"},
+		{6, "0", "0"},
+		{2, "-150", "-1.5"},
+		{0, "42", "42"},
+	}
+
+	for _, tt := range tests {
+		value, _ := new(big.Int).SetString(tt.value, 10)
+
+		meta := &ContractMetadata{Decimals: tt.decimals}
+		if got := meta.FormatAmount(value); got != tt.want {
+			t.Errorf("%s with %d decimals: expected %s, got %s", tt.value, tt.decimals, tt.want, got)
+		}
+	}
+}
+
+func TestNewContractPushMessage(t *testing.T) {
+	meta := &ContractMetadata{Symbol: "BREAD", Decimals: 18, Community: "Bread"}
+
+	msg := NewContractPushMessage(nil, meta, big.NewInt(5e18), &Log{Status: LogStatusSuccess})
+	if msg.Title != "Bread" || msg.Body != "5 BREAD received" {
+		t.Errorf("expected the branded message, got %q %q", msg.Title, msg.Body)
+	}
+}
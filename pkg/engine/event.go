@@ -2,20 +2,45 @@ package engine
 
 import (
 	"fmt"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
 )
 
+type EventState string
+
+const (
+	EventStateActive EventState = "active"
+	EventStatePaused EventState = "paused"
+	EventStateQueued EventState = "queued"
+)
+
+// IsValid returns true if the state is one of the known event states
+func (s EventState) IsValid() bool {
+	switch s {
+	case EventStateActive, EventStatePaused, EventStateQueued:
+		return true
+	}
+
+	return false
+}
+
 type Event struct {
-	Contract       string    `json:"contract"`
-	EventSignature string    `json:"event_signature"`
-	Name           string    `json:"name"`
-	CreatedAt      time.Time `json:"created_at"`
-	UpdatedAt      time.Time `json:"updated_at"`
+	Contract       string     `json:"contract"`
+	EventSignature string     `json:"event_signature"`
+	Name           string     `json:"name"`
+	Standard       string     `json:"standard"`
+	Symbol         string     `json:"symbol"`
+	Decimals       int        `json:"decimals"`
+	State          EventState `json:"state"`
+	LastBlock      int64      `json:"last_block"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
 }
 
 type ArgType struct {
@@ -38,23 +63,33 @@ type ArgType struct {
 //
 // Example: Transfer (index_topic_1 address from, index_topic_2 address to, uint256 value)
 // Returns: ("Transfer", ["from", "to", "value"], [{Name: "address", Indexed: true}, {Name: "address", Indexed: true}, {Name: "uint256", Indexed: false}])
+//
+// Empty arguments (ex: a trailing comma) and spaces around the signature are ignored, a signature without arguments
+// returns the name only
 func (e *Event) ParseEventSignature() (string, []string, []ArgType) {
-	if e.EventSignature == "" {
+	sig := strings.TrimSpace(e.EventSignature)
+	if sig == "" {
 		return "", []string{}, []ArgType{}
 	}
 
-	parts := strings.SplitN(e.EventSignature, "(", 2)
+	parts := strings.SplitN(sig, "(", 2)
 	eventName := strings.TrimSpace(parts[0])
 
 	argNames := []string{}
 	argTypes := []ArgType{}
 
-	rawArgs := strings.TrimSuffix(parts[1], ")")
+	if len(parts) < 2 {
+		return eventName, argNames, argTypes
+	}
+
+	rawArgs := strings.TrimSuffix(strings.TrimSpace(parts[1]), ")")
 	argParts := strings.Split(rawArgs, ",")
 
-	for i, arg := range argParts {
-		arg = strings.TrimSpace(arg)
+	for _, arg := range argParts {
 		parts := strings.Fields(arg)
+		if len(parts) == 0 {
+			continue
+		}
 
 		isIndexed := false
 		var argName, argType string
@@ -65,14 +100,10 @@ func (e *Event) ParseEventSignature() (string, []string, []ArgType) {
 			parts = parts[1:] // Remove "index_topic_N" from parts
 		}
 
-		if len(parts) >= 2 && (parts[0] == "indexed" || parts[1] == "indexed") {
-			// Indexed argument
+		// "indexed" usually follows the type, it is dropped wherever it is
+		if len(parts) >= 2 && slices.Contains(parts, "indexed") {
 			isIndexed = true
-			if parts[0] == "indexed" {
-				parts = parts[1:] // Remove "indexed" from the beginning
-			} else {
-				parts = append(parts[:1], parts[2:]...) // Remove "indexed" from the middle
-			}
+			parts = slices.DeleteFunc(parts, func(p string) bool { return p == "indexed" })
 		}
 
 		if len(parts) == 2 {
@@ -80,8 +111,8 @@ func (e *Event) ParseEventSignature() (string, []string, []ArgType) {
 			argName = parts[1]
 			argType = parts[0]
 		} else if len(parts) == 1 {
-			// Unnamed argument
-			argName = strconv.Itoa(i)
+			// Unnamed argument, named after its position
+			argName = strconv.Itoa(len(argNames))
 			argType = parts[0]
 		}
 
@@ -92,9 +123,75 @@ func (e *Event) ParseEventSignature() (string, []string, []ArgType) {
 	return eventName, argNames, argTypes
 }
 
+// ValidateEventSignature checks that the event signature has a name and a valid solidity type for every argument,
+// it is safe to parse after
+func (e *Event) ValidateEventSignature() error {
+	sig := strings.TrimSpace(e.EventSignature)
+
+	open := strings.Index(sig, "(")
+	if open <= 0 || !strings.HasSuffix(sig, ")") {
+		return fmt.Errorf("invalid event signature %q, expected Name(type name, ...)", e.EventSignature)
+	}
+
+	// an event without arguments (ex: Paused()) is valid
+	if args := sig[open+1 : len(sig)-1]; strings.TrimSpace(args) != "" {
+		for i, arg := range strings.Split(args, ",") {
+			if strings.TrimSpace(arg) == "" {
+				return fmt.Errorf("invalid event signature %q, argument %d is empty", e.EventSignature, i)
+			}
+		}
+	}
+
+	name, _, argTypes := e.ParseEventSignature()
+	if name == "" {
+		return fmt.Errorf("invalid event signature %q, the event name is empty", e.EventSignature)
+	}
+
+	for i, argType := range argTypes {
+		if argType.Name == "" || argType.Name == "indexed" {
+			return fmt.Errorf("invalid event signature %q, argument %d has no type", e.EventSignature, i)
+		}
+	}
+
+	rawABI, err := e.ConstructABIFromEventSignature()
+	if err != nil {
+		return fmt.Errorf("invalid event signature %q: %w", e.EventSignature, err)
+	}
+
+	// the abi rejects unknown types, ex: a typo like uin256
+	parsed, err := abi.JSON(strings.NewReader(rawABI))
+	if err != nil {
+		return fmt.Errorf("invalid event signature %q: %w", e.EventSignature, err)
+	}
+
+	for _, ev := range parsed.Events {
+		for i, input := range ev.Inputs {
+			if !validTypeSize(input.Type) {
+				return fmt.Errorf("invalid event signature %q, argument %d has an invalid size %s", e.EventSignature, i, input.Type.String())
+			}
+		}
+	}
+
+	return nil
+}
+
+// validTypeSize returns false for the sizes that the abi accepts but solidity doesn't, ex: uint265 or bytes0
+func validTypeSize(t abi.Type) bool {
+	switch t.T {
+	case abi.IntTy, abi.UintTy:
+		return t.Size > 0 && t.Size <= 256 && t.Size%8 == 0
+	case abi.FixedBytesTy:
+		return t.Size > 0 && t.Size <= 32
+	case abi.SliceTy, abi.ArrayTy:
+		return validTypeSize(*t.Elem)
+	}
+
+	return true
+}
+
 func (e *Event) GetTopic0FromEventSignature() common.Hash {
 	name, _, argTypes := e.ParseEventSignature()
-	if name == "" || len(argTypes) == 0 {
+	if name == "" {
 		return common.Hash{}
 	}
 
@@ -116,7 +213,7 @@ func (e *Event) GetTopic0FromEventSignature() common.Hash {
 // Returns: {"name":"Transfer","type":"event","inputs":[{"name":"from","type":"address", "indexed": true},{"name":"to","type":"address", "indexed": true},{"name":"value","type":"uint256", "indexed": false}]}
 func (e *Event) ConstructABIFromEventSignature() (string, error) {
 	name, args, argTypes := e.ParseEventSignature()
-	if name == "" || len(args) == 0 || len(argTypes) == 0 {
+	if name == "" || len(args) != len(argTypes) {
 		return "", fmt.Errorf("event name is required")
 	}
 
@@ -134,27 +231,10 @@ func (e *Event) ConstructABIFromEventSignature() (string, error) {
 	return abi, nil
 }
 
-// IsValidData checks if the provided data contains exactly all the argument names
-// returned by ParseEventSignature, plus the "topic" field, no more and no less.
+// IsValidData checks that the data of a user operation can be tracked as a log of the event: it has a topic that is the
+// topic of the event and one key per argument name of the signature, no more and no less, with values of their types
+//
+// ValidateData returns the reason when it is not valid
 func (e *Event) IsValidData(data map[string]any) bool {
-	_, argNames, _ := e.ParseEventSignature()
-
-	// Check if the number of keys in data matches the number of argument names plus one (for "topic")
-	if len(data) != len(argNames)+1 {
-		return false
-	}
-
-	// Check if "topic" is present in the data
-	if _, exists := data["topic"]; !exists {
-		return false
-	}
-
-	// Check if all argument names are present in the data
-	for _, argName := range argNames {
-		if _, exists := data[argName]; !exists {
-			return false
-		}
-	}
-
-	return true
+	return e.ValidateData(data) == nil
 }
@@ -51,6 +51,34 @@ func TestEvent_ParseEventSignature(t *testing.T) {
 			wantArgNames:  []string{"0", "1", "2"},
 			wantArgTypes:  []ArgType{{Name: "address", Indexed: false}, {Name: "address", Indexed: false}, {Name: "uint256", Indexed: false}},
 		},
+		{
+			name:          "Trailing spaces",
+			signature:     "  Transfer(address from, address to, uint256 value)  ",
+			wantEventName: "Transfer",
+			wantArgNames:  []string{"from", "to", "value"},
+			wantArgTypes:  []ArgType{{Name: "address", Indexed: false}, {Name: "address", Indexed: false}, {Name: "uint256", Indexed: false}},
+		},
+		{
+			name:          "Extra commas",
+			signature:     "Transfer(address,, address,uint256,)",
+			wantEventName: "Transfer",
+			wantArgNames:  []string{"0", "1", "2"},
+			wantArgTypes:  []ArgType{{Name: "address", Indexed: false}, {Name: "address", Indexed: false}, {Name: "uint256", Indexed: false}},
+		},
+		{
+			name:          "No arguments",
+			signature:     "Paused()",
+			wantEventName: "Paused",
+			wantArgNames:  []string{},
+			wantArgTypes:  []ArgType{},
+		},
+		{
+			name:          "No parenthesis",
+			signature:     "Paused",
+			wantEventName: "Paused",
+			wantArgNames:  []string{},
+			wantArgTypes:  []ArgType{},
+		},
 	}
 
 	for _, tt := range tests {
@@ -116,6 +144,27 @@ func TestEvent_ParseIndexedEventSignature(t *testing.T) {
 			wantArgNames:  []string{"0", "1", "2"},
 			wantArgTypes:  []ArgType{{Name: "uint256", Indexed: false}, {Name: "address", Indexed: true}, {Name: "address", Indexed: true}},
 		},
+		{
+			name:          "Indexed non-address types",
+			signature:     "Claimed(bytes32 indexed id, uint8 indexed kind, int256 delta)",
+			wantEventName: "Claimed",
+			wantArgNames:  []string{"id", "kind", "delta"},
+			wantArgTypes:  []ArgType{{Name: "bytes32", Indexed: true}, {Name: "uint8", Indexed: true}, {Name: "int256", Indexed: false}},
+		},
+		{
+			name:          "Mixed named and unnamed arguments",
+			signature:     "Claimed(address indexed, bytes32 indexed id, uint256)",
+			wantEventName: "Claimed",
+			wantArgNames:  []string{"0", "id", "2"},
+			wantArgTypes:  []ArgType{{Name: "address", Indexed: true}, {Name: "bytes32", Indexed: true}, {Name: "uint256", Indexed: false}},
+		},
+		{
+			name:          "Indexed before the type",
+			signature:     "Claimed(indexed bytes32 id, uint256 value)",
+			wantEventName: "Claimed",
+			wantArgNames:  []string{"id", "value"},
+			wantArgTypes:  []ArgType{{Name: "bytes32", Indexed: true}, {Name: "uint256", Indexed: false}},
+		},
 	}
 
 	for _, tt := range tests {
@@ -169,6 +218,16 @@ func TestGetTopic0FromEventSignature(t *testing.T) {
 			eventSignature: "Transfer (index_topic_1 address from, index_topic_2 address to, uint256 value)",
 			expectedTopic0: "0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef",
 		},
+		{
+			name:           "No arguments",
+			eventSignature: "Paused()",
+			expectedTopic0: "0x9e87fac88ff661f02d44f95383c817fece4bce600a3dab7a54406878b965e752",
+		},
+		{
+			name:           "Other event without arguments",
+			eventSignature: "Unpaused()",
+			expectedTopic0: "0xa45f47fdea8a1efdd9029a5691c7f759c32b7c698632b563573e155625d16933",
+		},
 		{
 			name:           "Empty signature",
 			eventSignature: "",
@@ -217,6 +276,12 @@ func TestConstructABIFromEventSignature(t *testing.T) {
 			expectedABI:    `[{"name":"Transfer","type":"event","inputs":[{"name":"0","type":"address","indexed":false},{"name":"1","type":"address","indexed":false},{"name":"2","type":"uint256","indexed":false}]}]`,
 			expectError:    false,
 		},
+		{
+			name:           "Event without parameters",
+			eventSignature: "Paused()",
+			expectedABI:    `[{"name":"Paused","type":"event","inputs":[]}]`,
+			expectError:    false,
+		},
 		{
 			name:           "Empty event signature",
 			eventSignature: "",
@@ -251,9 +316,9 @@ func TestEvent_IsValidData(t *testing.T) {
 			name:           "Valid data with named arguments",
 			eventSignature: "Transfer(address from, address to, uint256 value)",
 			data: map[string]interface{}{
-				"topic": "0x...",
-				"from":  "0x1234...",
-				"to":    "0x5678...",
+				"topic": TransferTopic0.Hex(),
+				"from":  "0x7079253c0358eF9Fd87E16488299Ef6e06F403B6",
+				"to":    "0x5815E61eF72c9E6107b5c5A05FD121F334f7a7f1",
 				"value": "1000000000000000000",
 			},
 			want: true,
@@ -262,19 +327,41 @@ func TestEvent_IsValidData(t *testing.T) {
 			name:           "Valid data with unnamed arguments",
 			eventSignature: "Transfer(address,address,uint256)",
 			data: map[string]interface{}{
-				"topic": "0x...",
-				"0":     "0x1234...",
-				"1":     "0x5678...",
+				"topic": TransferTopic0.Hex(),
+				"0":     "0x7079253c0358eF9Fd87E16488299Ef6e06F403B6",
+				"1":     "0x5815E61eF72c9E6107b5c5A05FD121F334f7a7f1",
 				"2":     "1000000000000000000",
 			},
 			want: true,
 		},
+		{
+			name:           "Valid ERC-20 transfer with indexed arguments",
+			eventSignature: "Transfer(address indexed from, address indexed to, uint256 value)",
+			data: map[string]interface{}{
+				"topic": TransferTopic0.Hex(),
+				"from":  "0x7079253c0358eF9Fd87E16488299Ef6e06F403B6",
+				"to":    "0x5815E61eF72c9E6107b5c5A05FD121F334f7a7f1",
+				"value": "1000000000000000000",
+			},
+			want: true,
+		},
+		{
+			name:           "Invalid data - mismatched topic",
+			eventSignature: "Transfer(address from, address to, uint256 value)",
+			data: map[string]interface{}{
+				"topic": "0x8c5be1e5ebec7d5bd14f71427d1e84f3dd0314c0f7b2291e5b200ac8c7c3b925", // Approval
+				"from":  "0x7079253c0358eF9Fd87E16488299Ef6e06F403B6",
+				"to":    "0x5815E61eF72c9E6107b5c5A05FD121F334f7a7f1",
+				"value": "1000000000000000000",
+			},
+			want: false,
+		},
 		{
 			name:           "Invalid data - missing topic",
 			eventSignature: "Transfer(address from, address to, uint256 value)",
 			data: map[string]interface{}{
-				"from":  "0x1234...",
-				"to":    "0x5678...",
+				"from":  "0x7079253c0358eF9Fd87E16488299Ef6e06F403B6",
+				"to":    "0x5815E61eF72c9E6107b5c5A05FD121F334f7a7f1",
 				"value": "1000000000000000000",
 			},
 			want: false,
@@ -283,9 +370,9 @@ func TestEvent_IsValidData(t *testing.T) {
 			name:           "Invalid data - extra field",
 			eventSignature: "Transfer(address from, address to, uint256 value)",
 			data: map[string]interface{}{
-				"topic": "0x...",
-				"from":  "0x1234...",
-				"to":    "0x5678...",
+				"topic": TransferTopic0.Hex(),
+				"from":  "0x7079253c0358eF9Fd87E16488299Ef6e06F403B6",
+				"to":    "0x5815E61eF72c9E6107b5c5A05FD121F334f7a7f1",
 				"value": "1000000000000000000",
 				"extra": "extra field",
 			},
@@ -295,9 +382,9 @@ func TestEvent_IsValidData(t *testing.T) {
 			name:           "Invalid data - missing field",
 			eventSignature: "Transfer(address from, address to, uint256 value)",
 			data: map[string]interface{}{
-				"topic": "0x...",
-				"from":  "0x1234...",
-				"to":    "0x5678...",
+				"topic": TransferTopic0.Hex(),
+				"from":  "0x7079253c0358eF9Fd87E16488299Ef6e06F403B6",
+				"to":    "0x5815E61eF72c9E6107b5c5A05FD121F334f7a7f1",
 			},
 			want: false,
 		},
@@ -312,3 +399,45 @@ func TestEvent_IsValidData(t *testing.T) {
 		})
 	}
 }
+
+func TestEventState_IsValid(t *testing.T) {
+	assert.True(t, EventStateActive.IsValid())
+	assert.True(t, EventStatePaused.IsValid())
+	assert.True(t, EventStateQueued.IsValid())
+	assert.False(t, EventState("").IsValid())
+	assert.False(t, EventState("deleted").IsValid())
+}
+
+func TestEvent_ValidateEventSignature(t *testing.T) {
+	tests := []struct {
+		name      string
+		signature string
+		wantErr   bool
+	}{
+		{"named arguments", "Transfer(address indexed from, address indexed to, uint256 value)", false},
+		{"unnamed arguments", "Transfer(address,address,uint256)", false},
+		{"index topics", "Transfer (index_topic_1 address from, index_topic_2 address to, uint256 value)", false},
+		{"empty", "", true},
+		{"no parenthesis", "Transfer", true},
+		{"no arguments", "Paused()", false},
+		{"no name", "(address from)", true},
+		{"missing parenthesis", "Transfer(address from, address to", true},
+		{"empty argument", "Transfer(address from,,uint256 value)", true},
+		{"only indexed", "Transfer(indexed, address to)", true},
+		{"unknown type", "Transfer(address from, address to, uin256 value)", true},
+		{"invalid size", "Transfer(address from, address to, uint265 value)", true},
+		{"invalid array size", "Batch(address from, uint7[] values)", true},
+		{"array", "Batch(address indexed from, uint256[] values, bytes32 id)", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := &Event{EventSignature: tt.signature}
+
+			err := e.ValidateEventSignature()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Event.ValidateEventSignature() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,272 @@
+package engine
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// DataTopicField is the field of the data of a log that holds the topic of its event
+const DataTopicField = "topic"
+
+// DataField is a field that the data of a log of an event must have, Type is the solidity type of the argument
+type DataField struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+var (
+	ErrInvalidData      = errors.New("invalid log data")
+	ErrUnknownDataTopic = errors.New("no indexed event has the topic of the log data")
+)
+
+// DataSchema returns the fields that the data of a log of the event must have: the topic of the event (bytes32)
+// followed by one field per argument of the signature
+//
+// an indexed argument of a reference type is the hash of the argument (bytes32), its name has the HashedFieldSuffix
+func (e *Event) DataSchema() []DataField {
+	_, argNames, argTypes := e.ParseEventSignature()
+
+	fields := make([]DataField, 0, len(argNames)+1)
+	fields = append(fields, DataField{Name: DataTopicField, Type: "bytes32"})
+
+	for i, name := range argNames {
+		typ := argTypes[i].Name
+		if argTypes[i].Indexed && isReferenceType(typ) {
+			typ = "bytes32"
+		}
+
+		fields = append(fields, DataField{Name: dataFieldName(name, argTypes[i]), Type: typ})
+	}
+
+	return fields
+}
+
+// DataArgNames returns the names of the arguments in the data of a log of the event, without the topic
+func (e *Event) DataArgNames() []string {
+	schema := e.DataSchema()
+
+	names := make([]string, 0, len(schema)-1)
+	for _, field := range schema[1:] {
+		names = append(names, field.Name)
+	}
+
+	return names
+}
+
+// ValidateData checks that the data has the fields of DataSchema, no more and no less, with values of their types,
+// the error says which field is wrong
+//
+// values are the json representation of the arguments: addresses, bytes and the topic are hex strings, integers are
+// decimal or hex strings (numbers are accepted if they are whole), arrays are json arrays, tuples are not checked
+func (e *Event) ValidateData(data map[string]any) error {
+	schema := e.DataSchema()
+
+	topic, ok := data[DataTopicField].(string)
+	if !ok {
+		return fmt.Errorf("%w: missing %s", ErrInvalidData, DataTopicField)
+	}
+
+	if !strings.EqualFold(topic, e.GetTopic0FromEventSignature().Hex()) {
+		return fmt.Errorf("%w: %s %s is not the topic of %s", ErrInvalidData, DataTopicField, topic, e.EventSignature)
+	}
+
+	for _, field := range schema[1:] {
+		v, ok := data[field.Name]
+		if !ok {
+			return fmt.Errorf("%w: missing %s", ErrInvalidData, field.Name)
+		}
+
+		if err := checkDataValue(field.Type, v); err != nil {
+			return fmt.Errorf("%w: %s: %v", ErrInvalidData, field.Name, err)
+		}
+	}
+
+	if len(data) != len(schema) {
+		for key := range data {
+			if !hasField(schema, key) {
+				return fmt.Errorf("%w: unknown field %s", ErrInvalidData, key)
+			}
+		}
+	}
+
+	return nil
+}
+
+// MatchEventData returns the event that the data of a log is valid for
+//
+// when none is, the error is the reason why the data is invalid for an event with the same topic, or
+// ErrUnknownDataTopic when no event has that topic
+func MatchEventData(events []*Event, data map[string]any) (*Event, error) {
+	var reason error
+
+	for _, ev := range events {
+		err := ev.ValidateData(data)
+		if err == nil {
+			return ev, nil
+		}
+
+		topic, _ := data[DataTopicField].(string)
+		if reason == nil && strings.EqualFold(topic, ev.GetTopic0FromEventSignature().Hex()) {
+			reason = fmt.Errorf("%s %s: %w", ev.Contract, ev.EventSignature, err)
+		}
+	}
+
+	if reason == nil {
+		return nil, ErrUnknownDataTopic
+	}
+
+	return nil, reason
+}
+
+func hasField(schema []DataField, name string) bool {
+	for _, field := range schema {
+		if field.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// the length of a fixed size array is the last [N] of its type
+var arrayTypePattern = regexp.MustCompile(`^(.+)\[(\d*)\]$`)
+
+// checkDataValue checks that a value decoded from json can be an argument of a solidity type
+func checkDataValue(typ string, v any) error {
+	if m := arrayTypePattern.FindStringSubmatch(typ); m != nil {
+		items, ok := v.([]any)
+		if !ok {
+			return fmt.Errorf("expected an array of %s", m[1])
+		}
+
+		if m[2] != "" {
+			n, _ := strconv.Atoi(m[2])
+			if len(items) != n {
+				return fmt.Errorf("expected %d items, got %d", n, len(items))
+			}
+		}
+
+		for i, item := range items {
+			if err := checkDataValue(m[1], item); err != nil {
+				return fmt.Errorf("item %d: %w", i, err)
+			}
+		}
+
+		return nil
+	}
+
+	switch {
+	case typ == "address":
+		s, ok := v.(string)
+		if !ok || !common.IsHexAddress(s) {
+			return errors.New("expected an address")
+		}
+	case typ == "bool":
+		if _, ok := v.(bool); !ok {
+			return errors.New("expected a bool")
+		}
+	case typ == "string":
+		if _, ok := v.(string); !ok {
+			return errors.New("expected a string")
+		}
+	case typ == "bytes":
+		if _, ok := dataBytes(v); !ok {
+			return errors.New("expected hex bytes")
+		}
+	case strings.HasPrefix(typ, "bytes"):
+		size, err := strconv.Atoi(strings.TrimPrefix(typ, "bytes"))
+		if err != nil {
+			return nil
+		}
+
+		b, ok := dataBytes(v)
+		if !ok || len(b) != size {
+			return fmt.Errorf("expected %d hex bytes", size)
+		}
+	case strings.HasPrefix(typ, "uint"), strings.HasPrefix(typ, "int"):
+		signed := strings.HasPrefix(typ, "int")
+
+		size := 256
+		if bits := strings.TrimPrefix(strings.TrimPrefix(typ, "u"), "int"); bits != "" {
+			n, err := strconv.Atoi(bits)
+			if err != nil {
+				return nil
+			}
+			size = n
+		}
+
+		i, ok := dataInt(v)
+		if !ok {
+			return errors.New("expected an integer")
+		}
+
+		if !intFits(i, size, signed) {
+			return fmt.Errorf("%s is out of range of %s", i.String(), typ)
+		}
+	case v == nil:
+		return errors.New("expected a value")
+	}
+
+	return nil
+}
+
+// dataBytes decodes a 0x prefixed hex string
+func dataBytes(v any) ([]byte, bool) {
+	s, ok := v.(string)
+	if !ok || !strings.HasPrefix(s, "0x") {
+		return nil, false
+	}
+
+	b, err := hex.DecodeString(s[2:])
+	if err != nil {
+		return nil, false
+	}
+
+	return b, true
+}
+
+// dataInt parses an integer from a decimal or 0x prefixed hex string or a whole json number
+func dataInt(v any) (*big.Int, bool) {
+	switch v := v.(type) {
+	case string:
+		if strings.HasPrefix(v, "0x") {
+			return new(big.Int).SetString(v[2:], 16)
+		}
+
+		return new(big.Int).SetString(v, 10)
+	case json.Number:
+		return new(big.Int).SetString(v.String(), 10)
+	case float64:
+		if v != math.Trunc(v) || math.IsInf(v, 0) {
+			return nil, false
+		}
+
+		i, _ := big.NewFloat(v).Int(nil)
+		return i, true
+	}
+
+	return nil, false
+}
+
+// intFits checks that an integer can be represented by a solidity integer of the given size
+func intFits(i *big.Int, size int, signed bool) bool {
+	if !signed {
+		return i.Sign() >= 0 && i.BitLen() <= size
+	}
+
+	limit := new(big.Int).Lsh(big.NewInt(1), uint(size-1))
+	if i.Sign() < 0 {
+		return i.CmpAbs(limit) <= 0
+	}
+
+	return i.Cmp(limit) < 0
+}
@@ -0,0 +1,148 @@
+package engine
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const transferSignature = "Transfer(address indexed from, address indexed to, uint256 value)"
+
+func transferData(overrides map[string]any) map[string]any {
+	data := map[string]any{
+		"topic": TransferTopic0.Hex(),
+		"from":  "0x7079253c0358eF9Fd87E16488299Ef6e06F403B6",
+		"to":    "0x5815E61eF72c9E6107b5c5A05FD121F334f7a7f1",
+		"value": "1000000000000000000",
+	}
+
+	for k, v := range overrides {
+		if v == nil {
+			delete(data, k)
+			continue
+		}
+		data[k] = v
+	}
+
+	return data
+}
+
+func TestEvent_DataSchema(t *testing.T) {
+	e := &Event{EventSignature: transferSignature}
+
+	want := []DataField{
+		{Name: "topic", Type: "bytes32"},
+		{Name: "from", Type: "address"},
+		{Name: "to", Type: "address"},
+		{Name: "value", Type: "uint256"},
+	}
+
+	if got := e.DataSchema(); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func TestEvent_ValidateData(t *testing.T) {
+	e := &Event{EventSignature: transferSignature}
+
+	valid := map[string]map[string]any{
+		"decimal value":   transferData(nil),
+		"hex value":       transferData(map[string]any{"value": "0xde0b6b3a7640000"}),
+		"number value":    transferData(map[string]any{"value": float64(1000)}),
+		"json number":     transferData(map[string]any{"value": json.Number("1000")}),
+		"lowercase topic": transferData(map[string]any{"topic": strings.ToLower(TransferTopic0.Hex())}),
+		"lowercase from":  transferData(map[string]any{"from": "0x7079253c0358ef9fd87e16488299ef6e06f403b6"}),
+	}
+
+	for name, data := range valid {
+		t.Run(name, func(t *testing.T) {
+			if err := e.ValidateData(data); err != nil {
+				t.Errorf("expected valid data, got %v", err)
+			}
+		})
+	}
+
+	invalid := []struct {
+		name   string
+		data   map[string]any
+		reason string
+	}{
+		{"missing topic", transferData(map[string]any{"topic": nil}), "missing topic"},
+		{"other topic", transferData(map[string]any{"topic": "0x8c5be1e5ebec7d5bd14f71427d1e84f3dd0314c0f7b2291e5b200ac8c7c3b925"}), "is not the topic"},
+		{"missing value", transferData(map[string]any{"value": nil}), "missing value"},
+		{"extra field", transferData(map[string]any{"memo": "hello"}), "unknown field memo"},
+		{"short address", transferData(map[string]any{"to": "0x5815"}), "to: expected an address"},
+		{"address number", transferData(map[string]any{"from": float64(1)}), "from: expected an address"},
+		{"negative value", transferData(map[string]any{"value": "-1"}), "value: -1 is out of range"},
+		{"fraction value", transferData(map[string]any{"value": 1.5}), "value: expected an integer"},
+		{"text value", transferData(map[string]any{"value": "one"}), "value: expected an integer"},
+		{"overflow value", transferData(map[string]any{"value": "0x1" + strings.Repeat("0", 64)}), "out of range of uint256"},
+	}
+
+	for _, tt := range invalid {
+		t.Run(tt.name, func(t *testing.T) {
+			err := e.ValidateData(tt.data)
+			if !errors.Is(err, ErrInvalidData) {
+				t.Fatalf("expected ErrInvalidData, got %v", err)
+			}
+
+			if !strings.Contains(err.Error(), tt.reason) {
+				t.Errorf("expected the error to contain %q, got %v", tt.reason, err)
+			}
+		})
+	}
+}
+
+func TestCheckDataValue(t *testing.T) {
+	tests := []struct {
+		typ   string
+		value any
+		valid bool
+	}{
+		{"bool", true, true},
+		{"bool", "true", false},
+		{"string", "hello", true},
+		{"bytes", "0x0102", true},
+		{"bytes", "0102", false},
+		{"bytes4", "0x01020304", true},
+		{"bytes4", "0x0102", false},
+		{"int8", "-128", true},
+		{"int8", "128", false},
+		{"uint8", "255", true},
+		{"uint8", "256", false},
+		{"address[]", []any{"0x7079253c0358eF9Fd87E16488299Ef6e06F403B6"}, true},
+		{"address[]", []any{"0x1"}, false},
+		{"uint256[2]", []any{"1"}, false},
+		{"(uint256,address)", []any{"1", "0x1"}, true},
+	}
+
+	for _, tt := range tests {
+		err := checkDataValue(tt.typ, tt.value)
+		if (err == nil) != tt.valid {
+			t.Errorf("%s %v: expected valid %t, got %v", tt.typ, tt.value, tt.valid, err)
+		}
+	}
+}
+
+func TestMatchEventData(t *testing.T) {
+	transfer := &Event{Contract: "0x5815E61eF72c9E6107b5c5A05FD121F334f7a7f1", EventSignature: transferSignature}
+	approval := &Event{Contract: "0x5815E61eF72c9E6107b5c5A05FD121F334f7a7f1", EventSignature: "Approval(address indexed owner, address indexed spender, uint256 value)"}
+	events := []*Event{approval, transfer}
+
+	ev, err := MatchEventData(events, transferData(nil))
+	if err != nil || ev != transfer {
+		t.Fatalf("expected the transfer event, got %v, %v", ev, err)
+	}
+
+	_, err = MatchEventData(events, transferData(map[string]any{"value": "-1"}))
+	if !errors.Is(err, ErrInvalidData) || !strings.Contains(err.Error(), transfer.EventSignature) {
+		t.Errorf("expected the reason for the transfer event, got %v", err)
+	}
+
+	_, err = MatchEventData(events, transferData(map[string]any{"topic": "0x01"}))
+	if !errors.Is(err, ErrUnknownDataTopic) {
+		t.Errorf("expected ErrUnknownDataTopic, got %v", err)
+	}
+}
@@ -3,7 +3,11 @@ package engine
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"math/big"
+	"strings"
+	"time"
 
 	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
@@ -19,26 +23,87 @@ const (
 	EVMTypeCelo     EVMType = "celo"
 )
 
+// RPCHealth is the state of the rpc node as last seen by the monitor
+type RPCHealth struct {
+	Healthy     bool      `json:"healthy"`
+	Stale       bool      `json:"stale"`    // the head has not advanced in the expected interval
+	Degraded    bool      `json:"degraded"` // calls are slower than the maximum latency
+	Latency     int64     `json:"latency_ms"`
+	BlockNumber uint64    `json:"block_number"`
+	LastBlockAt time.Time `json:"last_block_at"`
+	CheckedAt   time.Time `json:"checked_at"`
+	Error       string    `json:"error,omitempty"`
+
+	Breaker BreakerState `json:"breaker"`
+}
+
+// BreakerState is the state of the circuit breaker in front of the rpc node
+type BreakerState string
+
+const (
+	BreakerClosed   BreakerState = "closed"    // calls go through
+	BreakerOpen     BreakerState = "open"      // calls fail fast with ErrRPCUnavailable
+	BreakerHalfOpen BreakerState = "half_open" // the cooldown is over, the next call probes the node
+)
+
+// ErrRPCUnavailable is returned without calling the rpc node when it has been failing
+var ErrRPCUnavailable = errors.New("rpc node unavailable")
+
+// EstimateGasError is returned when the node rejects the estimation of the gas of a call, it keeps the code and data
+// of the rpc error so that they are passed on to the clients of the rpc endpoints
+type EstimateGasError struct {
+	Code   int
+	Reason string // the revert reason of the call, empty if the node didn't return one
+	Data   any
+	Err    error
+}
+
+func (e *EstimateGasError) Error() string {
+	if e.Reason != "" && !strings.Contains(e.Err.Error(), e.Reason) {
+		return fmt.Sprintf("estimate gas: %s: %s", e.Err, e.Reason)
+	}
+
+	return fmt.Sprintf("estimate gas: %s", e.Err)
+}
+
+func (e *EstimateGasError) Unwrap() error {
+	return e.Err
+}
+
+// ErrorCode is the code of the rpc error
+func (e *EstimateGasError) ErrorCode() int {
+	return e.Code
+}
+
+// ErrorData is the data of the rpc error, usually the encoded revert
+func (e *EstimateGasError) ErrorData() any {
+	return e.Data
+}
+
 type EVMRequester interface {
 	Context() context.Context
 	Backend() bind.ContractBackend
 
 	CodeAt(ctx context.Context, account common.Address, blockNumber *big.Int) ([]byte, error)
 	NonceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (uint64, error)
+	BalanceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (*big.Int, error)
 	BaseFee() (*big.Int, error)
 	EstimateGasPrice() (*big.Int, error)
+	GetFeeEstimates(speed FeeSpeed) (*FeeEstimate, error)
 	EstimateGasLimit(msg ethereum.CallMsg) (uint64, error)
 	NewTx(nonce uint64, from, to common.Address, data []byte, extraGas bool) (*types.Transaction, error)
 	SendTransaction(tx *types.Transaction) error
-	StorageAt(addr common.Address, slot common.Hash) ([]byte, error)
+	StorageAt(addr common.Address, slot common.Hash, blockNumber *big.Int) ([]byte, error)
 
 	ChainID() (*big.Int, error)
-	Call(method string, result any, params json.RawMessage) error
+	Call(ctx context.Context, method string, result any, params json.RawMessage) error
 	LatestBlock() (*big.Int, error)
 	FilterLogs(q ethereum.FilterQuery) ([]types.Log, error)
 	BlockTime(number *big.Int) (uint64, error)
 	CallContract(call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error)
+	// ListenForLogs sends the logs from q.FromBlock on and the new ones until the subscription fails
 	ListenForLogs(ctx context.Context, q ethereum.FilterQuery, ch chan<- types.Log) error
+	ListenForHeads(ctx context.Context, ch chan<- *types.Header) error
 
 	WaitForTx(tx *types.Transaction, timeout int) error
 
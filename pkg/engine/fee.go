@@ -0,0 +1,62 @@
+package engine
+
+import (
+	"errors"
+	"math/big"
+)
+
+type FeeSpeed string
+
+const (
+	FeeSpeedSlow     FeeSpeed = "slow"
+	FeeSpeedStandard FeeSpeed = "standard"
+	FeeSpeedFast     FeeSpeed = "fast"
+)
+
+var ErrUnknownFeeSpeed = errors.New("unknown fee speed")
+
+func FeeSpeedFromString(s string) (FeeSpeed, error) {
+	switch s {
+	case "slow":
+		return FeeSpeedSlow, nil
+	case "", "standard":
+		return FeeSpeedStandard, nil
+	case "fast":
+		return FeeSpeedFast, nil
+	}
+
+	return FeeSpeedStandard, ErrUnknownFeeSpeed
+}
+
+// FeeSettings describe how fees are estimated for a speed
+type FeeSettings struct {
+	// Percentile of the priority fees paid in recent blocks
+	Percentile float64
+	// PriorityFeeBuffer is the percentage added on top of the priority fee
+	PriorityFeeBuffer int64
+	// BaseFeeMultiplier is applied to the base fee to cover increases over the next blocks
+	BaseFeeMultiplier int64
+}
+
+// DefaultFeeSettings are used for the speeds that are not configured
+var DefaultFeeSettings = map[FeeSpeed]FeeSettings{
+	FeeSpeedSlow:     {Percentile: 25, PriorityFeeBuffer: 1, BaseFeeMultiplier: 2},
+	FeeSpeedStandard: {Percentile: 50, PriorityFeeBuffer: 1, BaseFeeMultiplier: 2},
+	FeeSpeedFast:     {Percentile: 75, PriorityFeeBuffer: 20, BaseFeeMultiplier: 2},
+}
+
+// FeeEstimate is the fee recommendation used for the transactions that the engine sends
+type FeeEstimate struct {
+	Speed FeeSpeed
+
+	BaseFee              *big.Int
+	MaxPriorityFeePerGas *big.Int
+	MaxFeePerGas         *big.Int
+
+	// Percentile of the priority fees paid in recent blocks
+	Percentile float64
+	// PriorityFeeBuffer is the percentage added on top of the priority fee
+	PriorityFeeBuffer int64
+	// BaseFeeMultiplier is applied to the base fee to cover increases over the next blocks
+	BaseFeeMultiplier int64
+}
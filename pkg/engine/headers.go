@@ -11,6 +11,8 @@ const (
 	AddressHeader = "X-Address"
 	// AppVersionHeader is the header that contains the app version of the sender
 	AppVersionHeader = "X-App-Version"
+	// IdempotencyKeyHeader is the header that makes retried submissions of a user operation safe
+	IdempotencyKeyHeader = "Idempotency-Key"
 )
 
 type ContextKey string
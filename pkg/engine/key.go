@@ -7,6 +7,12 @@ import (
 	"github.com/ethereum/go-ethereum/crypto"
 )
 
+// KeyCipher encrypts private keys before they are stored and decrypts them when they are read
+type KeyCipher interface {
+	Encrypt(plaintext string) (string, error)
+	Decrypt(ciphertext string) (string, error)
+}
+
 // generate a new private key
 func GeneratePrivateKey() (*ecdsa.PrivateKey, error) {
 	return crypto.GenerateKey()
@@ -25,6 +25,17 @@ const (
 	TEMP_HASH_PREFIX = "TEMP_HASH"
 )
 
+// TransferTopic0 is the topic of the ERC20 Transfer(address from, address to, uint256 value) event
+var TransferTopic0 = crypto.Keccak256Hash([]byte("Transfer(address,address,uint256)"))
+
+// AddressFilter filters logs on the from and to addresses of their data, an empty address matches any log
+//
+// logs of events without a from or a to (ex: approvals) never match a filter on it
+type AddressFilter struct {
+	Sender    string
+	Recipient string
+}
+
 func LogStatusFromString(s string) (LogStatus, error) {
 	switch s {
 	case "sending":
@@ -52,6 +63,12 @@ type Log struct {
 	Data      *json.RawMessage `json:"data"`
 	ExtraData *json.RawMessage `json:"extra_data"`
 	Status    LogStatus        `json:"status"`
+
+	// the position of the log on chain, empty for logs that aren't indexed yet
+	BlockNumber int64 `json:"block_number,omitempty"`
+	LogIndex    int64 `json:"log_index,omitempty"`
+
+	Confirmations int64 `json:"confirmations,omitempty"` // only set on the broadcasts of indexed logs
 }
 
 // generate hash for transfer using a provided index, from, to and the tx hash
@@ -87,6 +104,61 @@ func (t *Log) ToRounded(decimals int64) float64 {
 	return result
 }
 
+// LogTransferData is the data of a transfer log (ex: an ERC20 Transfer)
+type LogTransferData struct {
+	From  common.Address
+	To    common.Address
+	Value *big.Int
+}
+
+var ErrInvalidTransferData = errors.New("invalid transfer data")
+
+// ParseTransferData strictly parses the data of a transfer log, data that is missing the from, to or value of a
+// transfer, or where they are invalid, is an error instead of a default so that balances and volumes aren't corrupted
+func (t *Log) ParseTransferData() (*LogTransferData, error) {
+	if t.Data == nil {
+		return nil, fmt.Errorf("%w: no data", ErrInvalidTransferData)
+	}
+
+	var data struct {
+		From  *string      `json:"from"`
+		To    *string      `json:"to"`
+		Value *json.Number `json:"value"`
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(*t.Data))
+	dec.UseNumber()
+
+	err := dec.Decode(&data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidTransferData, err)
+	}
+
+	if data.From == nil || !common.IsHexAddress(*data.From) {
+		return nil, fmt.Errorf("%w: invalid from", ErrInvalidTransferData)
+	}
+
+	if data.To == nil || !common.IsHexAddress(*data.To) {
+		return nil, fmt.Errorf("%w: invalid to", ErrInvalidTransferData)
+	}
+
+	if data.Value == nil {
+		return nil, fmt.Errorf("%w: no value", ErrInvalidTransferData)
+	}
+
+	// values are decimal strings in the data, json numbers are accepted as long as they are integers
+	value, ok := new(big.Int).SetString(data.Value.String(), 10)
+	if !ok || value.Sign() < 0 {
+		return nil, fmt.Errorf("%w: invalid value %q", ErrInvalidTransferData, data.Value.String())
+	}
+
+	return &LogTransferData{
+		From:  common.HexToAddress(*data.From),
+		To:    common.HexToAddress(*data.To),
+		Value: value,
+	}, nil
+}
+
 // Update updates the transfer using the given transfer
 func (t *Log) Update(tx *Log) {
 	// update all fields
@@ -101,9 +173,12 @@ func (t *Log) Update(tx *Log) {
 	t.Data = tx.Data
 	t.ExtraData = tx.ExtraData
 	t.Status = tx.Status
+	t.BlockNumber = tx.BlockNumber
+	t.LogIndex = tx.LogIndex
 }
 
-func (t *Log) GetPoolTopic() *string {
+// GetTopic returns the event topic in the data of the log
+func (t *Log) GetTopic() *string {
 	if t.Data == nil {
 		return nil
 	}
@@ -117,7 +192,16 @@ func (t *Log) GetPoolTopic() *string {
 		return nil
 	}
 
-	topic := fmt.Sprintf("%s/%s", t.To, v)
+	return &v
+}
+
+func (t *Log) GetPoolTopic() *string {
+	v := t.GetTopic()
+	if v == nil {
+		return nil
+	}
+
+	topic := fmt.Sprintf("%s/%s", t.To, *v)
 
 	return &topic
 }
@@ -2,9 +2,11 @@ package engine
 
 import (
 	"encoding/json"
+	"errors"
 	"math/big"
 	"testing"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -40,3 +42,56 @@ func TestLog_GenerateUniqueHash(t *testing.T) {
 	hash3 := log.GenerateUniqueHash()
 	assert.NotEqual(t, hash, hash3)
 }
+
+func TestTransferTopic0(t *testing.T) {
+	assert.Equal(t, "0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef", TransferTopic0.Hex())
+}
+
+func TestLog_ParseTransferData(t *testing.T) {
+	from := "0x5566D6D4Df27a6fD7856b7564F81266863Ba3ee8"
+	to := "0x1234567890123456789012345678901234567890"
+
+	tests := []struct {
+		name    string
+		data    string
+		want    *big.Int
+		wantErr bool
+	}{
+		{"string value", `{"topic": "0xddf2", "from": "` + from + `", "to": "` + to + `", "value": "1000000000000000000000"}`, big.NewInt(0).Mul(big.NewInt(1000), big.NewInt(1e18)), false},
+		{"number value", `{"from": "` + from + `", "to": "` + to + `", "value": 42}`, big.NewInt(42), false},
+		{"missing value", `{"from": "` + from + `", "to": "` + to + `"}`, nil, true},
+		{"invalid value", `{"from": "` + from + `", "to": "` + to + `", "value": "abc"}`, nil, true},
+		{"fractional value", `{"from": "` + from + `", "to": "` + to + `", "value": 1.5}`, nil, true},
+		{"negative value", `{"from": "` + from + `", "to": "` + to + `", "value": "-1"}`, nil, true},
+		{"missing from", `{"to": "` + to + `", "value": "1"}`, nil, true},
+		{"invalid to", `{"from": "` + from + `", "to": "0x1234", "value": "1"}`, nil, true},
+		{"not an object", `[]`, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := json.RawMessage(tt.data)
+			l := &Log{Data: &data}
+
+			got, err := l.ParseTransferData()
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidTransferData) {
+					t.Fatalf("expected ErrInvalidTransferData, got %v", err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got.From != common.HexToAddress(from) || got.To != common.HexToAddress(to) || got.Value.Cmp(tt.want) != 0 {
+				t.Fatalf("unexpected transfer data: %+v", got)
+			}
+		})
+	}
+
+	if _, err := (&Log{}).ParseTransferData(); !errors.Is(err, ErrInvalidTransferData) {
+		t.Fatalf("expected ErrInvalidTransferData without data, got %v", err)
+	}
+}
@@ -3,6 +3,7 @@ package engine
 import (
 	"encoding/json"
 	"fmt"
+	"math/big"
 )
 
 type PushToken struct {
@@ -38,6 +39,10 @@ const PushMessageTitle = "%s - %s"
 const PushMessageBody = "%s %s received from %s"
 
 func parseDescriptionFromData(data *json.RawMessage) *string {
+	if data == nil {
+		return nil
+	}
+
 	var desc PushDescription
 	err := json.Unmarshal(*data, &desc)
 	if err != nil {
@@ -85,6 +90,12 @@ func NewAnonymousPushMessage(token []*PushToken, community, amount, symbol strin
 	}
 }
 
+// NewContractPushMessage builds the anonymous push message of a log of a contract with its branding, the amount is
+// formatted with the decimals of the contract
+func NewContractPushMessage(token []*PushToken, meta *ContractMetadata, value *big.Int, tx *Log) *PushMessage {
+	return NewAnonymousPushMessage(token, meta.DisplayName(), meta.FormatAmount(value), meta.Symbol, tx)
+}
+
 func NewSilentPushMessage(token []*PushToken, tx *Log) *PushMessage {
 	mtx, err := json.Marshal(tx)
 	if err != nil {
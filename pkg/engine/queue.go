@@ -2,13 +2,19 @@ package engine
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"math/big"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// ErrRequestTimeout is returned when the queue did not respond in time, the message could still be processed later
+var ErrRequestTimeout = errors.New("request timeout")
+
 type MessageResponse struct {
 	Data any
 	Err  error
@@ -20,6 +26,9 @@ type Message struct {
 	RetryCount int
 	Message    any
 	Response   *chan MessageResponse
+
+	// TraceContext links the processing of the message to the request that enqueued it
+	TraceContext trace.SpanContext
 }
 
 func (m *Message) Respond(data any, err error) {
@@ -48,7 +57,7 @@ func (m *Message) WaitForResponse() (any, error) {
 
 		return resp.Data, nil
 	case <-time.After(time.Second * 12): // timeout so that we don't block the request forever in case the queue is stuck
-		return nil, fmt.Errorf("request timeout")
+		return nil, ErrRequestTimeout
 	}
 }
 
@@ -69,6 +78,14 @@ type UserOpMessage struct {
 	ExtraData  any
 }
 
+// CallMessage is a call that a sponsor of the paymaster sends to a contract directly, ex: a permit
+type CallMessage struct {
+	Paymaster common.Address
+	To        common.Address
+	ChainId   *big.Int
+	Data      []byte
+}
+
 func newMessage(id string, message any, response *chan MessageResponse) *Message {
 	return &Message{
 		ID:         id,
@@ -92,3 +109,15 @@ func NewTxMessage(pm, entrypoint common.Address, chainId *big.Int, userop UserOp
 	respch := make(chan MessageResponse)
 	return newMessage(common.Bytes2Hex(userop.Signature), op, &respch)
 }
+
+func NewCallMessage(pm, to common.Address, chainId *big.Int, data []byte) *Message {
+	call := CallMessage{
+		Paymaster: pm,
+		To:        to,
+		ChainId:   chainId,
+		Data:      data,
+	}
+
+	respch := make(chan MessageResponse)
+	return newMessage(common.Bytes2Hex(crypto.Keccak256(to.Bytes(), data)), call, &respch)
+}
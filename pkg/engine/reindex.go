@@ -0,0 +1,26 @@
+package engine
+
+import "time"
+
+type ReindexStatus string
+
+const (
+	ReindexStatusRunning ReindexStatus = "running"
+	ReindexStatusDone    ReindexStatus = "done"
+	ReindexStatusFailed  ReindexStatus = "failed"
+)
+
+// ReindexJob is the progress of the re-indexing of the logs of a contract from a block up to the latest block when
+// it started, the live indexing of the contract continues in the meantime
+type ReindexJob struct {
+	Contract  string        `json:"contract"`
+	FromBlock int64         `json:"from_block"`
+	ToBlock   int64         `json:"to_block"`
+	Block     int64         `json:"block"` // the last block that was re-indexed, it is before FromBlock until the first batch is done
+	Removed   int64         `json:"removed"`
+	Indexed   int64         `json:"indexed"`
+	Status    ReindexStatus `json:"status"`
+	Error     string        `json:"error,omitempty"`
+	StartedAt time.Time     `json:"started_at"`
+	UpdatedAt time.Time     `json:"updated_at"`
+}
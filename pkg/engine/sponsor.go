@@ -8,3 +8,30 @@ type Sponsor struct {
 	CreatedAt  time.Time `json:"created_at"`
 	UpdatedAt  time.Time `json:"updated_at"`
 }
+
+// SponsorBalance is the last known balance of a sponsor account
+type SponsorBalance struct {
+	Contract  string    `json:"contract"`
+	Address   string    `json:"address"`
+	Balance   string    `json:"balance"`
+	Low       bool      `json:"low"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// SponsorStrategy decides which of the sponsors of a paymaster submits a batch of user operations
+type SponsorStrategy string
+
+const (
+	SponsorStrategyRoundRobin     SponsorStrategy = "round-robin"
+	SponsorStrategyHighestBalance SponsorStrategy = "highest-balance"
+)
+
+// IsValid returns true if the strategy is one of the known sponsor strategies
+func (s SponsorStrategy) IsValid() bool {
+	switch s {
+	case SponsorStrategyRoundRobin, SponsorStrategyHighestBalance:
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,29 @@
+package engine
+
+import "time"
+
+type StatsPeriod string
+
+const (
+	StatsPeriodDay   StatsPeriod = "day"
+	StatsPeriodWeek  StatsPeriod = "week"
+	StatsPeriodMonth StatsPeriod = "month"
+)
+
+// IsValid returns true if the period is one of the known stats periods
+func (p StatsPeriod) IsValid() bool {
+	switch p {
+	case StatsPeriodDay, StatsPeriodWeek, StatsPeriodMonth:
+		return true
+	}
+
+	return false
+}
+
+// TransferStats are the aggregated transfers of a contract for a single period
+type TransferStats struct {
+	Period         time.Time `json:"period"`
+	Transfers      int       `json:"transfers"`
+	ActiveAccounts int       `json:"active_accounts"`
+	Volume         string    `json:"volume"`
+}
@@ -3,9 +3,13 @@ package engine
 import (
 	"database/sql/driver"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"math/big"
 	"net/url"
+	"regexp"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -17,6 +21,28 @@ type Topic struct {
 	Name  string `json:"name"`
 	Type  string `json:"type"`
 	Value any    `json:"value"`
+
+	// Hashed is true for an indexed argument of a reference type, its value is the keccak256 hash of the argument
+	// as the topic doesn't contain the argument itself
+	Hashed bool `json:"hashed,omitempty"`
+}
+
+// HashedFieldSuffix is appended to the name of an indexed argument of a reference type (string, bytes, arrays and
+// tuples) in the data of a log, the value is the hash of the argument and not the argument itself
+//
+// the argument can't be recovered from its hash, an event that needs it readable has to emit it again as a
+// non-indexed argument, which is decoded under its own name
+// Example: Transfer(string indexed id, address to, uint256 value)
+// Data: {"topic": "0x...", "id_hash": "0x...", "to": "0x...", "value": "1"}
+const HashedFieldSuffix = "_hash"
+
+// dataFieldName returns the name of an argument in the data of a log
+func dataFieldName(name string, argType ArgType) string {
+	if argType.Indexed && isReferenceType(argType.Name) {
+		return name + HashedFieldSuffix
+	}
+
+	return name
 }
 
 type Topics []Topic
@@ -31,7 +57,7 @@ func ParseTopicsFromHashes(event *Event, topicHashes []common.Hash, data []byte)
 	}
 
 	name, args, argTypes := event.ParseEventSignature()
-	if name == "" || len(args) == 0 || len(argTypes) == 0 {
+	if name == "" || len(args) != len(argTypes) {
 		return nil, fmt.Errorf("event name is required")
 	}
 
@@ -70,6 +96,18 @@ func ParseTopicsFromHashes(event *Event, topicHashes []common.Hash, data []byte)
 		return nil, err
 	}
 
+	indexed := 0
+	for _, argType := range argTypes {
+		if argType.Indexed {
+			indexed++
+		}
+	}
+
+	// a log without a topic for each indexed argument is not of this event
+	if len(topicHashes) < indexed+1 {
+		return nil, fmt.Errorf("expected %d topics for %s, got %d", indexed+1, event.EventSignature, len(topicHashes))
+	}
+
 	indexedTopicIndex := 1
 	// Parse remaining topics
 	for i, argType := range argTypes {
@@ -84,6 +122,8 @@ func ParseTopicsFromHashes(event *Event, topicHashes []common.Hash, data []byte)
 				return nil, err
 			}
 
+			t.Name = dataFieldName(t.Name, argType)
+
 			topics = append(topics, t)
 
 			indexedTopicIndex++
@@ -140,54 +180,58 @@ func (t *Topic) valueToJsonParseable() any {
 	}
 }
 
+// isReferenceType returns true for the types that are hashed when they are indexed: string, bytes, arrays and tuples
+func isReferenceType(typ string) bool {
+	return typ == "string" || typ == "bytes" || strings.HasSuffix(typ, "]") || strings.HasPrefix(typ, "(") || strings.HasPrefix(typ, "tuple")
+}
+
+// convertHashToValue decodes an indexed argument from its topic
+//
+// value types are padded to 32 bytes in the topic, the value of a reference type can't be recovered from its hash,
+// the hash is kept instead and the topic is flagged as hashed
 func (t *Topic) convertHashToValue(hash common.Hash) error {
 	bytes := hash.Bytes()
 
-	switch t.Type {
-	case "bool":
+	switch {
+	case t.Type == "bool":
 		t.Value = bytes[31] != 0
 		return nil
-	case "address":
+	case t.Type == "address":
 		t.Value = common.HexToAddress(hash.Hex())
 		return nil
-	case "string", "bytes":
-		// For dynamic types, the hash is actually a pointer to the data
-		// In this case, we can't retrieve the actual value from just the hash
+	case isReferenceType(t.Type):
 		t.Value = hash.Hex()
+		t.Hashed = true
 		return nil
-	default:
-		// Handle integer types
-		if strings.HasPrefix(t.Type, "uint") || strings.HasPrefix(t.Type, "int") {
-			bitSize, err := strconv.Atoi(strings.TrimPrefix(strings.TrimPrefix(t.Type, "uint"), "int"))
-			if err != nil {
-				bitSize = 256 // Default to 256 if no size specified
+	case strings.HasPrefix(t.Type, "uint"), strings.HasPrefix(t.Type, "int"):
+		signed := strings.HasPrefix(t.Type, "int")
+
+		// uint and int are aliases of uint256 and int256
+		if size := strings.TrimPrefix(strings.TrimPrefix(t.Type, "u"), "int"); size != "" {
+			n, err := strconv.Atoi(size)
+			if err != nil || n <= 0 || n > 256 || n%8 != 0 {
+				return fmt.Errorf("invalid integer type: %s", t.Type)
 			}
+		}
 
-			value := new(big.Int).SetBytes(bytes)
-
-			if strings.HasPrefix(t.Type, "int") && bitSize < 256 {
-				// Handle sign extension for smaller signed integers
-				if value.Bit(bitSize-1) == 1 {
-					mask := new(big.Int).Lsh(big.NewInt(1), uint(bitSize))
-					mask.Sub(mask, big.NewInt(1))
-					value.And(value, mask)
-					value.Neg(value)
-				}
-			}
+		value := new(big.Int).SetBytes(bytes)
 
-			t.Value = value
-			return nil
+		// signed integers of any size are sign extended to 32 bytes, the topic is the two's complement of the value
+		if signed && value.Bit(255) == 1 {
+			value.Sub(value, new(big.Int).Lsh(big.NewInt(1), 256))
 		}
 
-		// Handle fixed-size byte arrays
-		if strings.HasPrefix(t.Type, "bytes") {
-			size, err := strconv.Atoi(strings.TrimPrefix(t.Type, "bytes"))
-			if err != nil {
-				return fmt.Errorf("invalid bytes type: %s", t.Type)
-			}
-			t.Value = bytes[:size]
-			return nil
+		t.Value = value
+		return nil
+	case strings.HasPrefix(t.Type, "bytes"):
+		size, err := strconv.Atoi(strings.TrimPrefix(t.Type, "bytes"))
+		if err != nil || size <= 0 || size > 32 {
+			return fmt.Errorf("invalid bytes type: %s", t.Type)
 		}
+
+		// fixed size byte arrays are left aligned
+		t.Value = bytes[:size]
+		return nil
 	}
 
 	return fmt.Errorf("unsupported type: %s", t.Type)
@@ -230,19 +274,38 @@ func ParseJSONBFilters(query url.Values, prefix string) map[string]any {
 	return jsonFilter
 }
 
-func GenerateJSONBQuery(prefix string, start int, data map[string]any) (string, []any) {
+var ErrUnknownFilterKey = errors.New("unknown data filter key")
+
+// filter keys are formatted into the queries, they are restricted to what an argument name can be
+var filterKeyPattern = regexp.MustCompile(`^[a-zA-Z0-9_]+$`)
+
+// GenerateJSONBQuery returns the conditions that match the data of a log with every filter, the keys are sorted so that
+// the same filters give the same query
+//
+// keys must be one of the argument names of the event, unknown keys are rejected with ErrUnknownFilterKey
+func GenerateJSONBQuery(prefix string, start int, data map[string]any, argNames []string) (string, []any, error) {
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		if !filterKeyPattern.MatchString(key) || !slices.Contains(argNames, key) {
+			return "", nil, fmt.Errorf("%w: %q", ErrUnknownFilterKey, key)
+		}
+
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
 	var query strings.Builder
 	args := make([]any, 0, len(data))
 
 	i := start
-	for key, value := range data {
+	for _, key := range keys {
 		if i > start {
 			query.WriteString(" AND ")
 		}
 		query.WriteString(fmt.Sprintf("%sdata->>'%s' = $%d", prefix, key, i))
-		args = append(args, value)
+		args = append(args, data[key])
 		i++
 	}
 
-	return query.String(), args
+	return query.String(), args, nil
 }
@@ -2,12 +2,16 @@ package engine
 
 import (
 	"encoding/json"
+	"errors"
 	"math/big"
 	"net/url"
 	"reflect"
+	"strings"
 	"testing"
 
+	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -113,6 +117,118 @@ func TestTopic_convertHashToGoType(t *testing.T) {
 			},
 			expected: "0x0000000000000000000000000000000000000000000000000000000000000020",
 		},
+		{
+			name: "uint8",
+			hash: common.HexToHash("0x00000000000000000000000000000000000000000000000000000000000000ff"),
+			topic: Topic{
+				Type: "uint8",
+			},
+			expected: big.NewInt(255),
+		},
+		{
+			name: "uint alias",
+			hash: common.HexToHash("0x000000000000000000000000000000000000000000000000000000000000000a"),
+			topic: Topic{
+				Type: "uint",
+			},
+			expected: big.NewInt(10),
+		},
+		{
+			name: "int8 negative",
+			hash: common.HexToHash("0xffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff80"),
+			topic: Topic{
+				Type: "int8",
+			},
+			expected: big.NewInt(-128),
+		},
+		{
+			name: "int16 positive",
+			hash: common.HexToHash("0x0000000000000000000000000000000000000000000000000000000000007fff"),
+			topic: Topic{
+				Type: "int16",
+			},
+			expected: big.NewInt(32767),
+		},
+		{
+			name: "int256 negative",
+			hash: common.HexToHash("0xffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff"),
+			topic: Topic{
+				Type: "int256",
+			},
+			expected: big.NewInt(-1),
+		},
+		{
+			name: "int256 positive",
+			hash: common.HexToHash("0x0000000000000000000000000000000000000000000000000000000000000064"),
+			topic: Topic{
+				Type: "int256",
+			},
+			expected: big.NewInt(100),
+		},
+		{
+			name: "bytes32",
+			hash: common.HexToHash("0x1234567890abcdef1234567890abcdef1234567890abcdef1234567890abcdef"),
+			topic: Topic{
+				Type: "bytes32",
+			},
+			expected: common.Hex2Bytes("1234567890abcdef1234567890abcdef1234567890abcdef1234567890abcdef"),
+		},
+		{
+			name: "bytes1",
+			hash: common.HexToHash("0xab00000000000000000000000000000000000000000000000000000000000000"),
+			topic: Topic{
+				Type: "bytes1",
+			},
+			expected: []byte{0xab},
+		},
+		{
+			name: "bytes",
+			hash: common.HexToHash("0x1234567890abcdef1234567890abcdef1234567890abcdef1234567890abcdef"),
+			topic: Topic{
+				Type: "bytes",
+			},
+			expected: "0x1234567890abcdef1234567890abcdef1234567890abcdef1234567890abcdef",
+		},
+		{
+			name: "array",
+			hash: common.HexToHash("0x1234567890abcdef1234567890abcdef1234567890abcdef1234567890abcdef"),
+			topic: Topic{
+				Type: "uint256[]",
+			},
+			expected: "0x1234567890abcdef1234567890abcdef1234567890abcdef1234567890abcdef",
+		},
+		{
+			name: "tuple",
+			hash: common.HexToHash("0x1234567890abcdef1234567890abcdef1234567890abcdef1234567890abcdef"),
+			topic: Topic{
+				Type: "(address,uint256)",
+			},
+			expected: "0x1234567890abcdef1234567890abcdef1234567890abcdef1234567890abcdef",
+		},
+		{
+			name: "fixed size array",
+			hash: common.HexToHash("0x1234567890abcdef1234567890abcdef1234567890abcdef1234567890abcdef"),
+			topic: Topic{
+				Type: "bytes32[2]",
+			},
+			expected: "0x1234567890abcdef1234567890abcdef1234567890abcdef1234567890abcdef",
+		},
+		{
+			name: "invalid bytes size",
+			hash: common.HexToHash("0x0000000000000000000000000000000000000000000000000000000000000000"),
+			topic: Topic{
+				Type: "bytes33",
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid integer size",
+			hash: common.HexToHash("0x0000000000000000000000000000000000000000000000000000000000000000"),
+			topic: Topic{
+				Type: "uint7",
+			},
+			wantErr: true,
+		},
 		{
 			name: "unsupported type",
 			hash: common.HexToHash("0x0000000000000000000000000000000000000000000000000000000000000000"),
@@ -132,6 +248,7 @@ func TestTopic_convertHashToGoType(t *testing.T) {
 			} else {
 				assert.NoError(t, err)
 				assert.Equal(t, tt.expected, tt.topic.Value)
+				assert.Equal(t, isReferenceType(tt.topic.Type), tt.topic.Hashed)
 			}
 		})
 	}
@@ -196,6 +313,103 @@ func TestParseTopicsFromHashes(t *testing.T) {
 	}
 }
 
+func TestParseTopicsFromHashesMixedArguments(t *testing.T) {
+	// an indexed bytes32 between an unnamed indexed address and non-indexed values
+	event := &Event{
+		EventSignature: " Claimed(address indexed, bytes32 indexed id, uint8 kind, int256 delta,) ",
+	}
+
+	topicHashes := []common.Hash{
+		event.GetTopic0FromEventSignature(),
+		common.HexToHash("0x000000000000000000000000a1e4380a3b1f749673e270229993ee55f35663b4"),
+		common.HexToHash("0x1234567890abcdef1234567890abcdef1234567890abcdef1234567890abcdef"),
+	}
+
+	data := common.Hex2Bytes("0000000000000000000000000000000000000000000000000000000000000007" +
+		"fffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffe")
+
+	topics, err := ParseTopicsFromHashes(event, topicHashes, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, Topics{
+		{Name: "topic", Type: "bytes32", Value: topicHashes[0]},
+		{Name: "0", Type: "address", Value: common.HexToAddress("0xa1e4380a3b1f749673e270229993ee55f35663b4")},
+		{Name: "id", Type: "bytes32", Value: topicHashes[2].Bytes()},
+		{Name: "kind", Type: "uint8", Value: uint8(7)},
+		{Name: "delta", Type: "int256", Value: big.NewInt(-2)},
+	}, topics)
+
+	// a log with fewer topics than indexed arguments is not of this event
+	_, err = ParseTopicsFromHashes(event, topicHashes[:2], data)
+	assert.Error(t, err)
+}
+
+func TestParseTopicsFromHashesNoArguments(t *testing.T) {
+	event := &Event{EventSignature: "Paused()"}
+
+	topicHashes := []common.Hash{event.GetTopic0FromEventSignature()}
+
+	topics, err := ParseTopicsFromHashes(event, topicHashes, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, Topics{{Name: "topic", Type: "bytes32", Value: topicHashes[0]}}, topics)
+}
+
+func TestParseTopicsFromHashesHashedArguments(t *testing.T) {
+	// the id is only in the topics as its hash, the memo is decoded from the data
+	event := &Event{
+		EventSignature: "Transfer(string indexed id, address to, string memo)",
+	}
+
+	id := crypto.Keccak256Hash([]byte("order-1"))
+	topicHashes := []common.Hash{event.GetTopic0FromEventSignature(), id}
+
+	memo, err := abi.Arguments{{Type: mustNewType(t, "address")}, {Type: mustNewType(t, "string")}}.Pack(common.HexToAddress("0xbcd4042de499d14e55001ccbb24a551f3b954096"), "order-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	topics, err := ParseTopicsFromHashes(event, topicHashes, memo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, Topics{
+		{Name: "topic", Type: "bytes32", Value: topicHashes[0]},
+		{Name: "id_hash", Type: "string", Value: id.Hex(), Hashed: true},
+		{Name: "to", Type: "address", Value: common.HexToAddress("0xbcd4042de499d14e55001ccbb24a551f3b954096")},
+		{Name: "memo", Type: "string", Value: "order-1"},
+	}, topics)
+
+	assert.Equal(t, []string{"id_hash", "to", "memo"}, event.DataArgNames())
+
+	b, err := json.Marshal(topics)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var data map[string]any
+	if err := json.Unmarshal(b, &data); err != nil {
+		t.Fatal(err)
+	}
+
+	// the data of the log is valid for the event
+	assert.NoError(t, event.ValidateData(data))
+}
+
+func mustNewType(t *testing.T, typ string) abi.Type {
+	abiType, err := abi.NewType(typ, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return abiType
+}
+
 func TestParseJSONBFilters(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -288,14 +502,17 @@ func TestGenerateJSONBQuery(t *testing.T) {
 			name:      "Multiple key-value pairs",
 			start:     2,
 			data:      map[string]any{"name": "John", "age": 30, "city": "New York"},
-			wantQuery: "l.data->>'name' = $2 AND l.data->>'age' = $3 AND l.data->>'city' = $4",
-			wantArgs:  []any{"John", 30, "New York"},
+			wantQuery: "l.data->>'age' = $2 AND l.data->>'city' = $3 AND l.data->>'name' = $4",
+			wantArgs:  []any{30, "New York", "John"},
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			gotQuery, gotArgs := GenerateJSONBQuery("l.", tt.start, tt.data)
+			gotQuery, gotArgs, err := GenerateJSONBQuery("l.", tt.start, tt.data, []string{"name", "age", "city"})
+			if err != nil {
+				t.Fatalf("GenerateJSONBQuery() error = %v", err)
+			}
 			if gotQuery != tt.wantQuery {
 				t.Errorf("GenerateJSONBQuery() gotQuery = %v, want %v", gotQuery, tt.wantQuery)
 			}
@@ -305,3 +522,50 @@ func TestGenerateJSONBQuery(t *testing.T) {
 		})
 	}
 }
+
+func TestGenerateJSONBQuery_UnknownKeys(t *testing.T) {
+	argNames := []string{"from", "to", "value"}
+
+	keys := []string{
+		"data",
+		"from' = '' OR 1=1 --",
+		"to'::text; DROP TABLE t_logs_1; --",
+		"value ",
+		"",
+		"topic",
+	}
+
+	for _, key := range keys {
+		_, _, err := GenerateJSONBQuery("l.", 1, map[string]any{"from": "0x1", key: "0x2"}, argNames)
+		if !errors.Is(err, ErrUnknownFilterKey) {
+			t.Errorf("expected key %q to be rejected, got %v", key, err)
+		}
+	}
+}
+
+func FuzzGenerateJSONBQuery(f *testing.F) {
+	f.Add("from", "0x1")
+	f.Add("from' OR '1'='1", "x")
+	f.Add("value') UNION SELECT 1 --", "x")
+
+	f.Fuzz(func(t *testing.T, key, value string) {
+		argNames := []string{"from", "to", "value", key}
+
+		query, args, err := GenerateJSONBQuery("l.", 1, map[string]any{key: value}, argNames)
+		if err != nil {
+			if !errors.Is(err, ErrUnknownFilterKey) {
+				t.Fatalf("unexpected error %v", err)
+			}
+			return
+		}
+
+		// a key that is accepted can only be a plain condition on the data with its value as a parameter
+		want := "l.data->>'" + key + "' = $1"
+		if query != want || strings.ContainsAny(key, "'\"\\;-() ") {
+			t.Fatalf("key %q altered the query: %s", key, query)
+		}
+		if len(args) != 1 || args[0] != value {
+			t.Fatalf("expected the value as the only argument, got %v", args)
+		}
+	})
+}
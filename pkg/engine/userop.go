@@ -2,6 +2,7 @@ package engine
 
 import (
 	"encoding/json"
+	"errors"
 	"math/big"
 
 	"github.com/ethereum/go-ethereum/common"
@@ -16,6 +17,30 @@ var (
 	FuncSigSafeExecFromModule = crypto.Keccak256([]byte("execTransactionFromModule(address,uint256,bytes,uint8)"))[:4]
 )
 
+var ErrUnsupportedEntryPoint = errors.New("unsupported entry point")
+
+// EntryPoints are the entrypoint contracts the engine submits user operations to
+type EntryPoints []common.Address
+
+// Supports returns true if the entrypoint is one of the supported entrypoints
+func (e EntryPoints) Supports(entrypoint common.Address) bool {
+	for _, ep := range e {
+		if ep == entrypoint {
+			return true
+		}
+	}
+
+	return false
+}
+
+type UserOpStatus string
+
+const (
+	UserOpStatusPending UserOpStatus = "pending"
+	UserOpStatusSuccess UserOpStatus = "success"
+	UserOpStatusFail    UserOpStatus = "fail"
+)
+
 type UserOp struct {
 	Sender               common.Address `json:"sender"               mapstructure:"sender"               validate:"required"`
 	Nonce                *big.Int       `json:"nonce"                mapstructure:"nonce"                validate:"required"`
@@ -0,0 +1,24 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestEntryPoints_Supports(t *testing.T) {
+	ep := common.HexToAddress("0x7079253c0358eF9Fd87E16488299Ef6e06F403B6")
+	eps := EntryPoints{ep}
+
+	if !eps.Supports(ep) {
+		t.Errorf("expected %s to be supported", ep.Hex())
+	}
+
+	if eps.Supports(common.HexToAddress("0x5FF137D4b0FDCD49DcA30c7CF57E578a026d2789")) {
+		t.Error("expected an unknown entrypoint not to be supported")
+	}
+
+	if (EntryPoints{}).Supports(ep) {
+		t.Error("expected no entrypoint to be supported by an empty list")
+	}
+}
@@ -1,9 +1,66 @@
 package engine
 
-import "context"
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+)
 
 type WebhookMessager interface {
 	Notify(ctx context.Context, message string) error
 	NotifyWarning(ctx context.Context, errorMessage error) error
 	NotifyError(ctx context.Context, errorMessage error) error
 }
+
+type LogWebhookStatus string
+
+const (
+	LogWebhookStatusActive   LogWebhookStatus = "active"
+	LogWebhookStatusDisabled LogWebhookStatus = "disabled" // after too many failed deliveries
+)
+
+// LogWebhook is a callback url that the indexed logs of a contract and topic are posted to
+type LogWebhook struct {
+	ID       string           `json:"id"`
+	URL      string           `json:"url"`
+	Secret   string           `json:"secret,omitempty"` // signs the deliveries, only returned when the webhook is added
+	Contract string           `json:"contract"`
+	Topic    string           `json:"topic"`
+	Address  string           `json:"address,omitempty"` // optional, only logs from or to the address are delivered
+	Status   LogWebhookStatus `json:"status"`
+
+	// the consecutive failed deliveries and the outcome of the last one
+	Failures       int        `json:"failures"`
+	LastDeliveryAt *time.Time `json:"last_delivery_at,omitempty"`
+	LastError      string     `json:"last_error,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Matches returns true if the log is one the webhook should receive
+func (h *LogWebhook) Matches(l *Log) bool {
+	if !strings.EqualFold(h.Contract, l.To) || l.Data == nil {
+		return false
+	}
+
+	var data map[string]any
+	if err := json.Unmarshal(*l.Data, &data); err != nil {
+		return false
+	}
+
+	topic, _ := data["topic"].(string)
+	if !strings.EqualFold(h.Topic, topic) {
+		return false
+	}
+
+	if h.Address == "" {
+		return true
+	}
+
+	from, _ := data["from"].(string)
+	to, _ := data["to"].(string)
+
+	return strings.EqualFold(h.Address, from) || strings.EqualFold(h.Address, to)
+}
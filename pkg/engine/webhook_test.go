@@ -0,0 +1,60 @@
+package engine
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestLogWebhookMatches(t *testing.T) {
+	d := json.RawMessage(`{"topic":"0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef","from":"0x1111111111111111111111111111111111111111","to":"0x2222222222222222222222222222222222222222"}`)
+
+	l := &Log{
+		To:   "0x742d35Cc6634C0532925a3b844Bc454e4438f44e",
+		Data: &d,
+	}
+
+	tests := []struct {
+		name string
+		hook LogWebhook
+		want bool
+	}{
+		{
+			name: "contract and topic",
+			hook: LogWebhook{Contract: "0x742d35cc6634c0532925a3b844bc454e4438f44e", Topic: "0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"},
+			want: true,
+		},
+		{
+			name: "other contract",
+			hook: LogWebhook{Contract: "0x0000000000000000000000000000000000000001", Topic: "0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"},
+			want: false,
+		},
+		{
+			name: "other topic",
+			hook: LogWebhook{Contract: "0x742d35Cc6634C0532925a3b844Bc454e4438f44e", Topic: "0x8c5be1e5ebec7d5bd14f71427d1e84f3dd0314c0f7b2291e5b200ac8c7c3b925"},
+			want: false,
+		},
+		{
+			name: "sender",
+			hook: LogWebhook{Contract: "0x742d35Cc6634C0532925a3b844Bc454e4438f44e", Topic: "0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef", Address: "0x1111111111111111111111111111111111111111"},
+			want: true,
+		},
+		{
+			name: "recipient",
+			hook: LogWebhook{Contract: "0x742d35Cc6634C0532925a3b844Bc454e4438f44e", Topic: "0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef", Address: "0x2222222222222222222222222222222222222222"},
+			want: true,
+		},
+		{
+			name: "other address",
+			hook: LogWebhook{Contract: "0x742d35Cc6634C0532925a3b844Bc454e4438f44e", Topic: "0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef", Address: "0x3333333333333333333333333333333333333333"},
+			want: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.hook.Matches(l); got != tt.want {
+				t.Errorf("Matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"strings"
+	"time"
 )
 
 type WSMessageType string
@@ -20,10 +21,19 @@ const (
 	WSMessageDataTypeLog WSMessageDataType = "log"
 )
 
+// the versions of the websocket message schema, clients that don't ask for one get WSSchemaV1
+const (
+	WSSchemaV1     = 1 // the original envelope, without a version or seq field
+	WSSchemaV2     = 2 // the envelope declares its version and the seq of the pool
+	WSSchemaLatest = WSSchemaV2
+)
+
 type WSMessage struct {
-	PoolID string        `json:"pool_id"`
-	Type   WSMessageType `json:"type"`
-	ID     string        `json:"id"`
+	Version int           `json:"version,omitempty"` // omitted in WSSchemaV1
+	PoolID  string        `json:"pool_id"`
+	Seq     uint64        `json:"seq,omitempty"` // increases by one with every broadcast of the pool, a client that skips one missed it, omitted in WSSchemaV1
+	Type    WSMessageType `json:"type"`
+	ID      string        `json:"id"`
 }
 
 type WSMessageLog struct {
@@ -59,6 +69,19 @@ func (l *Log) ToWSMessage(t WSMessageType) *WSMessageLog {
 	}
 }
 
+// WithVersion returns a copy of the message in the given schema version
+func (m *WSMessageLog) WithVersion(version int) *WSMessageLog {
+	v := *m
+
+	v.Version = version
+	if version <= WSSchemaV1 {
+		v.Version = 0
+		v.Seq = 0
+	}
+
+	return &v
+}
+
 func (l *Log) MatchesQuery(query string) bool {
 	// Empty query matches everything
 	if query == "" {
@@ -93,3 +116,12 @@ func (l *Log) MatchesQuery(query string) bool {
 
 	return false
 }
+
+// OutboxMessage is a broadcast message as it was recorded before it was sent, in the order of its sequence number
+type OutboxMessage struct {
+	Seq       uint64    `json:"seq"`
+	Pool      string    `json:"pool"`
+	PoolSeq   uint64    `json:"pool_seq"` // the sequence number of the message in its pool, 0 if it was recorded without one
+	Data      []byte    `json:"data"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -74,3 +74,41 @@ func TestLogMatchesQuery(t *testing.T) {
 		})
 	}
 }
+
+func TestWSMessageLog_WithVersion(t *testing.T) {
+	m := &WSMessageLog{WSMessage: WSMessage{PoolID: "0xtoken/0xtopic", Seq: 3, Type: WSMessageTypeNew, ID: "0x1"}, DataType: WSMessageDataTypeLog}
+
+	v1, err := json.Marshal(m.WithVersion(WSSchemaV1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var fields map[string]any
+	json.Unmarshal(v1, &fields)
+	if _, ok := fields["version"]; ok {
+		t.Errorf("expected no version in the first schema, got %s", v1)
+	}
+
+	if _, ok := fields["seq"]; ok {
+		t.Errorf("expected no seq in the first schema, got %s", v1)
+	}
+
+	v2, err := json.Marshal(m.WithVersion(WSSchemaV2))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fields = nil
+	json.Unmarshal(v2, &fields)
+	if fields["version"] != float64(WSSchemaV2) {
+		t.Errorf("expected version %d, got %s", WSSchemaV2, v2)
+	}
+
+	if fields["seq"] != float64(3) {
+		t.Errorf("expected seq 3, got %s", v2)
+	}
+
+	if m.Version != 0 || m.Seq != 3 {
+		t.Errorf("expected the original message to be left as is, got version %d and seq %d", m.Version, m.Seq)
+	}
+}